@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"bytes"
+	"dwarfbot/pkg/dwarfbot"
+	"dwarfbot/pkg/mqtt"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configMu serializes replacing the global viper config, which reloads
+// and restarts both do. configData is the config file contents the
+// global viper was last loaded from.
+var (
+	configMu   sync.Mutex
+	configData []byte
+)
+
+// setConfig makes data the global config and returns the contents it
+// replaced.
+func setConfig(data []byte) (previous []byte, err error) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	previous, configData = configData, data
+	return previous, nil
+}
+
+// reloadMetrics records the outcome of each hot reload.
+type reloadMetrics interface {
+	RecordConfigReload(result string)
+}
+
+// reloader applies config changes to running components without
+// dropping connections. Any component may be nil when not running.
+type reloader struct {
+	mu         sync.Mutex
+	twitch     *dwarfbot.DwarfBot
+	discord    *dwarfbot.DiscordBot
+	bridge     *mqtt.Bridge
+	acl        *dwarfbot.ACL
+	triggers   *dwarfbot.Triggers
+	ignore     *dwarfbot.IgnoreList
+	moderation *dwarfbot.Moderation
+	metrics    reloadMetrics
+
+	// cmd's flags override the file, as they do at startup.
+	cmd *cobra.Command
+}
+
+// reload re-reads the config file, validates it, and diffs it against
+// the running state. Nothing is applied, and the global config is left
+// alone, if validation fails.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.apply()
+	result := "success"
+	if err != nil {
+		result = "failure"
+		log.Printf("Config reload failed, keeping running config: %v", err)
+	}
+	if r.metrics != nil {
+		r.metrics.RecordConfigReload(result)
+	}
+	return err
+}
+
+func (r *reloader) apply() error {
+	v, data, err := readConfig(r.cmd)
+	if err != nil {
+		return err
+	}
+
+	// Validate everything before touching running components.
+	var aclRules []dwarfbot.ACLRule
+	if err := v.UnmarshalKey("acl", &aclRules); err != nil {
+		return fmt.Errorf("ACL configuration: %w", err)
+	}
+	if err := dwarfbot.ValidateACLRules(aclRules); err != nil {
+		return fmt.Errorf("ACL configuration: %w", err)
+	}
+	var triggerConfigs []dwarfbot.TriggerConfig
+	if err := v.UnmarshalKey("triggers", &triggerConfigs); err != nil {
+		return fmt.Errorf("trigger configuration: %w", err)
+	}
+	if err := dwarfbot.ValidateTriggerConfigs(triggerConfigs); err != nil {
+		return fmt.Errorf("trigger configuration: %w", err)
+	}
+	var moderationConfig dwarfbot.ModerationConfig
+	if err := v.UnmarshalKey("moderation", &moderationConfig); err != nil {
+		return fmt.Errorf("moderation configuration: %w", err)
+	}
+	if err := dwarfbot.ValidateModerationConfig(moderationConfig); err != nil {
+		return fmt.Errorf("moderation configuration: %w", err)
+	}
+	catalog, err := loadCatalog(v)
+	if err != nil {
+		return err
+	}
+	channelConfigs, err := loadChannelConfigs(v, catalog)
+	if err != nil {
+		return err
+	}
+	twitchChannels := stringSlice(v, "twitch_channels")
+	if r.twitch != nil && len(twitchChannels) == 0 {
+		return fmt.Errorf("twitch_channels may not be emptied while Twitch is running")
+	}
+	discordChannels := stringSlice(v, "discord_channels")
+	if r.discord != nil && len(discordChannels) == 0 {
+		return fmt.Errorf("discord_channels may not be emptied while Discord is running")
+	}
+
+	// Valid: from here on the new file is the config, so a later restart
+	// starts from it too.
+	if data != nil {
+		if _, err := setConfig(data); err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+	}
+
+	// The setters below validate again. They can't fail after the checks
+	// above, but they run first so that if one ever does, the reload is
+	// reported as a failure before anything else has changed.
+	var changes []string
+	if r.acl != nil {
+		if err := r.acl.SetRules(aclRules); err != nil {
+			return fmt.Errorf("ACL configuration: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("acl: %d rule(s)", len(aclRules)))
+	}
+	if r.triggers != nil {
+		if err := r.triggers.SetTriggers(triggerConfigs); err != nil {
+			return fmt.Errorf("trigger configuration: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("triggers: %d", len(triggerConfigs)))
+	}
+	if r.moderation != nil {
+		if err := r.moderation.SetConfig(moderationConfig); err != nil {
+			return fmt.Errorf("moderation configuration: %w", err)
+		}
+		changes = append(changes, fmt.Sprintf("moderation: %d banned word(s)", len(moderationConfig.BannedWords)))
+	}
+	dwarfbot.SetAliases(stringSlice(v, "aliases"))
+	dwarfbot.SetCatalog(catalog)
+	changes = append(changes, fmt.Sprintf("personas: %d defined", len(catalog.Personas())))
+	dwarfbot.SetChannelConfigs(channelConfigs)
+	changes = append(changes, fmt.Sprintf("channel settings: %d", len(channelConfigs)))
+	if r.ignore != nil {
+		users := stringSlice(v, "ignore_users")
+		r.ignore.SetConfigured(users)
+		changes = append(changes, fmt.Sprintf("ignore_users: %d", len(users)))
+	}
+	if r.twitch != nil {
+		joined, parted := r.twitch.SetChannels(twitchChannels)
+		changes = append(changes, "twitch channels"+formatDiff(joined, parted))
+	}
+	if r.discord != nil {
+		added, removed := r.discord.SetChannelIDs(discordChannels)
+		changes = append(changes, "discord channels"+formatDiff(added, removed))
+		if role := v.GetString("discord_admin_role"); r.discord.SetAdminRole(role) {
+			changes = append(changes, fmt.Sprintf("discord admin role: %q", role))
+		}
+	}
+	if r.bridge != nil {
+		added, removed, err := r.bridge.SetTopics(stringSlice(v, "mqtt_topics"))
+		changes = append(changes, "mqtt topics"+formatDiff(added, removed))
+		if err != nil {
+			// Already applied; a failed subscribe is retried on reconnect.
+			log.Printf("Config reload: MQTT resubscribe error: %v", err)
+		}
+	}
+
+	log.Printf("Config reloaded: %s", strings.Join(changes, "; "))
+	return nil
+}
+
+// readConfig reads the config file into a fresh viper with the same
+// environment and cmd flag overrides as the global one, so it can be
+// validated without the running config changing underneath. data is
+// the file as read; it is nil, and v the global viper, when no config
+// file is in use.
+func readConfig(cmd *cobra.Command) (v *viper.Viper, data []byte, err error) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return viper.GetViper(), nil, nil
+	}
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	v = viper.New()
+	v.SetConfigFile(path)
+	v.SetEnvPrefix("DWARFBOT")
+	v.AutomaticEnv()
+	if cmd != nil {
+		for _, key := range viper.AllKeys() {
+			if flag := cmd.Flag(strings.ReplaceAll(key, "_", "-")); flag != nil {
+				if err := v.BindPFlag(key, flag); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+	}
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, nil, fmt.Errorf("reading config file: %w", err)
+	}
+	return v, data, nil
+}
+
+// watchConfigFile calls onChange whenever the file at path is written or
+// replaced. Unlike viper.WatchConfig it doesn't read the file itself;
+// the reload does, and only makes it the config once it is valid.
+func watchConfigFile(path string, onChange func(fsnotify.Event)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	path = filepath.Clean(path)
+	// Watch the directory: editors and ConfigMap updates replace the
+	// file rather than writing to it.
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		return err
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == path && event.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+					onChange(event)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Config watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// formatDiff renders added/removed items as " +[a b] -[c]", or
+// " unchanged".
+func formatDiff(added, removed []string) string {
+	if len(added) == 0 && len(removed) == 0 {
+		return " unchanged"
+	}
+	var out string
+	if len(added) > 0 {
+		out += fmt.Sprintf(" +%v", added)
+	}
+	if len(removed) > 0 {
+		out += fmt.Sprintf(" -%v", removed)
+	}
+	return out
+}
@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"dwarfbot/pkg/dwarfbot"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type fakeReloadMetrics struct {
+	results []string
+}
+
+func (f *fakeReloadMetrics) RecordConfigReload(result string) {
+	f.results = append(f.results, result)
+}
+
+func writeTestConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+}
+
+func TestReloader_AppliesChanges(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	defer dwarfbot.SetAliases(nil)
+	defer dwarfbot.SetCatalog(nil)
+	defer dwarfbot.SetChannelConfigs(nil)
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, "twitch_channels: [one]\n")
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig: %v", err)
+	}
+
+	twitch := &dwarfbot.DwarfBot{Name: "bot", Channels: []string{"one"}}
+	discord := &dwarfbot.DiscordBot{ChannelIDs: []string{"1"}, AdminRole: "mods"}
+	acl, _ := dwarfbot.NewACL(nil)
+	m := &fakeReloadMetrics{}
+	triggers, _ := dwarfbot.NewTriggers(nil)
+	r := &reloader{twitch: twitch, discord: discord, acl: acl, triggers: triggers, metrics: m}
+
+	writeTestConfig(t, path, `twitch_channels: [one, two]
+discord_channels: ["1", "2"]
+discord_admin_role: admins
+aliases: [dwarfy]
+acl:
+  - command: quote
+    action: deny
+personas:
+  channels:
+    two: plain
+triggers:
+  - pattern: "(?i)^first"
+    responses: ["Nae, I was here first."]
+    cooldown: 1m
+channel_settings:
+  one:
+    muted: true
+`)
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if got := twitch.BotChannels(); len(got) != 2 || got[1] != "two" {
+		t.Errorf("expected twitch channels [one two], got %v", got)
+	}
+	if got := discord.BotChannels(); len(got) != 2 || got[1] != "2" {
+		t.Errorf("expected discord channels [1 2], got %v", got)
+	}
+	if discord.AdminRole != "admins" {
+		t.Errorf("expected admin role admins, got %q", discord.AdminRole)
+	}
+	if len(acl.Rules()) != 1 {
+		t.Errorf("expected 1 ACL rule, got %d", len(acl.Rules()))
+	}
+	if triggers.Len() != 1 {
+		t.Errorf("expected 1 trigger, got %d", triggers.Len())
+	}
+	if got := dwarfbot.Say("two", "ping.pong", nil); got != "Pong!" {
+		t.Errorf("expected plain persona in channel two, got %q", got)
+	}
+	if !dwarfbot.ChannelSettingsFor("one").Muted {
+		t.Error("expected channel one to be muted")
+	}
+	if len(m.results) != 1 || m.results[0] != "success" {
+		t.Errorf("expected one success, got %v", m.results)
+	}
+}
+
+func TestReloader_InvalidConfigAppliesNothing(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, `twitch_channels: [one, two]
+acl:
+  - command: quote
+    action: maybe
+`)
+	viper.SetConfigFile(path)
+
+	twitch := &dwarfbot.DwarfBot{Name: "bot", Channels: []string{"one"}}
+	acl, _ := dwarfbot.NewACL(nil)
+	m := &fakeReloadMetrics{}
+	r := &reloader{twitch: twitch, acl: acl, metrics: m}
+
+	if err := r.reload(); err == nil {
+		t.Fatal("expected invalid ACL to fail the reload")
+	}
+	if got := twitch.BotChannels(); len(got) != 1 {
+		t.Errorf("expected twitch channels unchanged, got %v", got)
+	}
+	if len(m.results) != 1 || m.results[0] != "failure" {
+		t.Errorf("expected one failure, got %v", m.results)
+	}
+}
+
+func TestReloader_InvalidConfigKeepsGlobalConfig(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, "twitch_channels: [one]\n")
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig: %v", err)
+	}
+
+	writeTestConfig(t, path, `twitch_channels: [one, two]
+triggers:
+  - pattern: "("
+    responses: [hi]
+`)
+	r := &reloader{}
+	if err := r.reload(); err == nil {
+		t.Fatal("expected invalid trigger to fail the reload")
+	}
+	if got := viper.GetStringSlice("twitch_channels"); len(got) != 1 {
+		t.Errorf("expected the global config to keep the old file, got %v", got)
+	}
+
+	writeTestConfig(t, path, "twitch_channels: [one, two]\n")
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := viper.GetStringSlice("twitch_channels"); len(got) != 2 {
+		t.Errorf("expected a valid reload to replace the global config, got %v", got)
+	}
+}
+
+func TestReloader_FlagsOverrideFile(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	defer dwarfbot.SetAliases(nil)
+
+	cmd := &cobra.Command{}
+	cmd.Flags().StringSlice("twitch-channels", nil, "")
+	if err := cmd.Flags().Set("twitch-channels", "flagged"); err != nil {
+		t.Fatal(err)
+	}
+	if err := viper.BindPFlag("twitch_channels", cmd.Flags().Lookup("twitch-channels")); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, "twitch_channels: [one, two]\n")
+	viper.SetConfigFile(path)
+
+	twitch := &dwarfbot.DwarfBot{Name: "bot", Channels: []string{"flagged"}}
+	r := &reloader{twitch: twitch, cmd: cmd}
+	if err := r.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := twitch.BotChannels(); len(got) != 1 || got[0] != "flagged" {
+		t.Errorf("expected the flag to win over the file, got %v", got)
+	}
+}
+
+func TestReloadConfig_RestoresPrevious(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+	defer func() { configData = nil }()
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, "name: good\n")
+	viper.SetConfigFile(path)
+	reloadConfig()
+
+	writeTestConfig(t, path, "name: bad\n")
+	fallback := reloadConfig()
+	if got := viper.GetString("name"); got != "bad" {
+		t.Fatalf("expected the restart to read the new file, got %q", got)
+	}
+	restoreConfig(fallback)
+	if got := viper.GetString("name"); got != "good" {
+		t.Errorf("expected the previous config back, got %q", got)
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "dwarfbot.yaml")
+	writeTestConfig(t, path, "name: one\n")
+
+	changed := make(chan struct{}, 10)
+	if err := watchConfigFile(path, func(fsnotify.Event) { changed <- struct{}{} }); err != nil {
+		t.Fatalf("watchConfigFile: %v", err)
+	}
+	writeTestConfig(t, filepath.Join(dir, "other.yaml"), "name: two\n")
+	writeTestConfig(t, path, "name: two\n")
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a change to be reported")
+	}
+}
+
+func TestReloader_InvalidPersonaAppliesNothing(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, `twitch_channels: [one, two]
+personas:
+  default: elf
+`)
+	viper.SetConfigFile(path)
+
+	twitch := &dwarfbot.DwarfBot{Name: "bot", Channels: []string{"one"}}
+	r := &reloader{twitch: twitch}
+
+	if err := r.reload(); err == nil {
+		t.Fatal("expected unknown persona to fail the reload")
+	}
+	if got := twitch.BotChannels(); len(got) != 1 {
+		t.Errorf("expected twitch channels unchanged, got %v", got)
+	}
+}
+
+func TestLoadCatalog_MessagesKeepCommas(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	path := filepath.Join(t.TempDir(), "dwarfbot.yaml")
+	writeTestConfig(t, path, `personas:
+  default: german
+  catalog:
+    german:
+      messages:
+        ping.heyo: "Hallo, du auch!"
+        ping.pong: ["Pong, Pong!", "Ping, Pong?"]
+`)
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("ReadInConfig: %v", err)
+	}
+
+	catalog, err := loadCatalog(viper.GetViper())
+	if err != nil {
+		t.Fatalf("loadCatalog: %v", err)
+	}
+	if got := catalog.Render("chan", "ping.heyo", nil); got != "Hallo, du auch!" {
+		t.Errorf("expected the message to stay whole, got %q", got)
+	}
+	if got := catalog.Render("chan", "ping.pong", nil); got != "Pong, Pong!" && got != "Ping, Pong?" {
+		t.Errorf("expected one of the listed variants, got %q", got)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	if got := formatDiff(nil, nil); got != " unchanged" {
+		t.Errorf("expected unchanged, got %q", got)
+	}
+	if got := formatDiff([]string{"a"}, []string{"b", "c"}); got != " +[a] -[b c]" {
+		t.Errorf("unexpected diff %q", got)
+	}
+}
@@ -26,19 +26,23 @@ package cmd
 import (
 	"context"
 	"dwarfbot/pkg/dwarfbot"
+	"dwarfbot/pkg/events"
 	"dwarfbot/pkg/metrics"
 	"dwarfbot/pkg/mqtt"
+	"dwarfbot/pkg/status"
+	"dwarfbot/pkg/store"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime/debug"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -60,220 +64,605 @@ var rootCmd = &cobra.Command{
 	Supports both Twitch IRC and Discord channels.`,
 
 	Run: func(cmd *cobra.Command, args []string) {
-		name := viper.GetString("name")
-		verbose := viper.GetBool("verbose")
-		metricsPort := viper.GetString("metrics_port")
-
-		// Twitch config
-		twitchToken := viper.GetString("twitch_token")
-		twitchChannels := getStringSlice("twitch_channels")
-		server := viper.GetString("twitch_server")
-		port := viper.GetString("twitch_port")
-
-		// Discord config
-		discordToken := viper.GetString("discord_token")
-		discordChannels := getStringSlice("discord_channels")
-		discordAdminRole := viper.GetString("discord_admin_role")
-
-		twitchEnabled := twitchToken != "" && len(twitchChannels) > 0
-		discordEnabled := discordToken != "" && len(discordChannels) > 0
-
-		if !twitchEnabled && !discordEnabled {
-			log.Fatal("At least one platform must be configured. Twitch: provide --twitch-token and --twitch-channels (or DWARFBOT_TWITCH_TOKEN and DWARFBOT_TWITCH_CHANNELS). Discord: provide --discord-token and --discord-channels (or DWARFBOT_DISCORD_TOKEN and DWARFBOT_DISCORD_CHANNELS).")
-		}
-
-		// MQTT config
-		mqttDiscordChannels := getStringSlice("mqtt_discord_channels")
-		if len(mqttDiscordChannels) == 0 {
-			mqttDiscordChannels = discordChannels
-		}
-		mqttConfig := mqtt.Config{
-			Enabled:          viper.GetBool("mqtt_enabled"),
-			Broker:           viper.GetString("mqtt_broker"),
-			Username:         viper.GetString("mqtt_username"),
-			Password:         viper.GetString("mqtt_password"),
-			ClientID:         viper.GetString("mqtt_client_id"),
-			Topics:           getStringSlice("mqtt_topics"),
-			DiscordChannels:  mqttDiscordChannels,
-			FlushSeconds:     viper.GetInt("mqtt_flush_seconds"),
-			MaxBuffer:        viper.GetInt("mqtt_max_buffer"),
-			MaxPayloadBytes:  viper.GetInt("mqtt_max_payload_bytes"),
-			MaxPostsPerFlush: viper.GetInt("mqtt_max_posts_per_flush"),
-		}
-
-		if err := mqtt.ValidateConfig(mqttConfig, discordEnabled); err != nil {
-			log.Fatalf("MQTT configuration error: %v", err)
-		}
-
-		if mqttConfig.Enabled && len(mqttConfig.Topics) == 0 {
-			log.Println("WARNING: mqtt_enabled=true but mqtt_topics is empty; bridge will connect but forward nothing")
-		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
 
-		// Initialize metrics
-		version := "dev"
-		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
-			version = info.Main.Version
-		}
-		m := metrics.New()
-		m.Init(version, time.Now())
-		m.SetConfigMetrics([]metrics.SourceConfig{
-			{Name: "twitch", Token: twitchToken, Channels: twitchChannels},
-			{Name: "discord", Token: discordToken, Channels: discordChannels},
-		})
-		recorder := metrics.NewRecorder(m)
+		proc := &process{startTime: time.Now(), cmd: cmd, reloadCh: make(chan struct{}, 1)}
 
-		// Start metrics HTTP server
-		metricsSrv := metrics.NewServer(":"+metricsPort, m.Registry)
+		// Hot reload on SIGHUP and, optionally, when the config file changes
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
 		go func() {
-			log.Printf("Metrics server listening on :%s", metricsPort)
-			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Printf("Metrics server error: %v", err)
+			for range hup {
+				log.Println("Received SIGHUP, reloading config")
+				proc.requestReload()
 			}
 		}()
+		if viper.GetBool("watch_config") && viper.ConfigFileUsed() != "" {
+			err := watchConfigFile(viper.ConfigFileUsed(), func(e fsnotify.Event) {
+				log.Printf("Config file changed (%s), reloading", e.Op)
+				proc.requestReload()
+			})
+			if err != nil {
+				log.Printf("WARNING: not watching the config file: %v", err)
+			}
+		}
 
-		// Start Discord bot if configured (non-fatal on failure)
-		var discordBot *dwarfbot.DiscordBot
-		discordRunning := false
-		if discordEnabled {
-			discordBot = &dwarfbot.DiscordBot{
-				Token:      discordToken,
-				ChannelIDs: discordChannels,
-				AdminRole:  discordAdminRole,
-				Name:       name,
-				Metrics:    recorder,
+		// A restart that fails goes back once to the config the previous
+		// run worked with, rather than taking the bot down over a bad edit.
+		var fallback []byte
+		restarted := false
+		for {
+			lc := dwarfbot.NewLifecycle(ctx)
+			if err := run(lc, proc); err != nil {
+				if !restarted {
+					log.Fatal(err)
+				}
+				log.Printf("ERROR: restart failed, starting again with the previous config: %v", err)
+				restoreConfig(fallback)
+				restarted = false
+				continue
 			}
 
-			if err := discordBot.Start(); err != nil {
-				log.Printf("WARNING: Failed to start Discord bot: %v", err)
-				discordBot = nil
-			} else {
-				discordRunning = true
-				defer func() {
-					if err := discordBot.Stop(); err != nil {
-						log.Printf("Failed to stop Discord bot: %v", err)
-					}
-				}()
-				log.Println("Discord bot is running")
+			action, exitCode := lc.Result()
+			if action != dwarfbot.ActionRestart {
+				if exitCode != 0 {
+					stop()
+					os.Exit(exitCode)
+				}
+				return
 			}
+			log.Println("Restarting...")
+			fallback = reloadConfig()
+			restarted = true
 		}
+	},
+}
 
-		// Start MQTT bridge after Discord (it depends on the Discord poster callback)
-		var mqttBridge *mqtt.Bridge
-		if mqttConfig.Enabled && discordRunning {
-			mqttMetrics := mqtt.NewBridgeMetrics(m.Registry)
-			postFunc := func(channelID, msg string) error {
-				return discordBot.SendMessage(channelID, msg)
-			}
-			mqttBridge = mqtt.NewBridge(mqttConfig, postFunc, mqttMetrics)
+// process holds state that outlives a single run, so a restart keeps
+// uptime and doesn't lose an in-memory store.
+type process struct {
+	startTime time.Time
+	store     *store.Store
 
-			// Register the admin command handler
-			dwarfbot.RegisterMQTTHandler(func(channelName string, platform dwarfbot.ChatPlatform, arguments []string) {
-				if len(arguments) == 0 {
-					_ = platform.SendMessage(channelName, "Usage: mqtt on|off|status")
-					return
-				}
-				switch strings.ToLower(arguments[0]) {
-				case "on":
-					mqttBridge.Enable()
-					_ = platform.SendMessage(channelName, "MQTT bridge enabled")
-				case "off":
-					mqttBridge.Disable()
-					_ = platform.SendMessage(channelName, "MQTT bridge disabled")
-				case "status":
-					status := mqttBridge.Status()
-					enabledStr := "disabled"
-					if status.Enabled {
-						enabledStr = "enabled"
-					}
-					connStr := "disconnected"
-					if status.Connected {
-						connStr = "connected"
-					}
-					msg := fmt.Sprintf("MQTT bridge: %s, %s, buffer: %d, topics: %s",
-						enabledStr, connStr, status.BufferDepth, strings.Join(status.Topics, ", "))
-					_ = platform.SendMessage(channelName, msg)
-				default:
-					_ = platform.SendMessage(channelName, "Usage: mqtt on|off|status")
-				}
-			})
+	// cmd supplies the command-line flags that override the config file.
+	cmd *cobra.Command
 
-			if err := mqttBridge.Start(); err != nil {
-				log.Printf("WARNING: Failed to start MQTT bridge: %v", err)
-			} else {
-				log.Println("MQTT bridge is running")
-			}
-		} else if mqttConfig.Enabled && !discordRunning {
-			log.Println("WARNING: MQTT bridge enabled but Discord is not running; bridge not started")
+	// reloadCh carries hot reload requests to the current run. It holds
+	// at most one pending request, so bursts of file events coalesce.
+	reloadCh chan struct{}
+}
+
+func (p *process) requestReload() {
+	select {
+	case p.reloadCh <- struct{}{}:
+	default:
+	}
+}
+
+// run starts every configured component, blocks until lc is done, then
+// stops them in order. Configuration errors are returned rather than
+// exiting so the caller decides how to fail.
+func run(lc *dwarfbot.Lifecycle, proc *process) error {
+	startTime := proc.startTime
+
+	// Start from a clean slate; a restart re-registers everything.
+	dwarfbot.ResetRegistrations()
+	dwarfbot.SetAliases(getStringSlice("aliases"))
+
+	name := viper.GetString("name")
+	verbose := viper.GetBool("verbose")
+	metricsPort := viper.GetString("metrics_port")
+	storePath := viper.GetString("store_path")
+
+	// Twitch config
+	twitchToken := viper.GetString("twitch_token")
+	twitchChannels := getStringSlice("twitch_channels")
+	server := viper.GetString("twitch_server")
+	port := viper.GetString("twitch_port")
+
+	// Discord config
+	discordToken := viper.GetString("discord_token")
+	discordChannels := getStringSlice("discord_channels")
+	discordAdminRole := viper.GetString("discord_admin_role")
+
+	twitchEnabled := twitchToken != "" && len(twitchChannels) > 0
+	discordEnabled := discordToken != "" && len(discordChannels) > 0
+
+	if !twitchEnabled && !discordEnabled {
+		return errors.New("at least one platform must be configured. Twitch: provide --twitch-token and --twitch-channels (or DWARFBOT_TWITCH_TOKEN and DWARFBOT_TWITCH_CHANNELS). Discord: provide --discord-token and --discord-channels (or DWARFBOT_DISCORD_TOKEN and DWARFBOT_DISCORD_CHANNELS).")
+	}
+
+	// Command ACL config (YAML only)
+	var aclRules []dwarfbot.ACLRule
+	if err := viper.UnmarshalKey("acl", &aclRules); err != nil {
+		return fmt.Errorf("ACL configuration: %w", err)
+	}
+	acl, err := dwarfbot.NewACL(aclRules)
+	if err != nil {
+		return fmt.Errorf("ACL configuration: %w", err)
+	}
+	if len(aclRules) > 0 {
+		log.Printf("Loaded %d command ACL rule(s)", len(aclRules))
+	}
+
+	// Persona message catalog (YAML only)
+	catalog, err := loadCatalog(viper.GetViper())
+	if err != nil {
+		return err
+	}
+	dwarfbot.SetCatalog(catalog)
+
+	// Per-channel overrides (YAML only)
+	channelConfigs, err := loadChannelConfigs(viper.GetViper(), catalog)
+	if err != nil {
+		return err
+	}
+	dwarfbot.SetChannelConfigs(channelConfigs)
+	if len(channelConfigs) > 0 {
+		log.Printf("Loaded settings for %d channel(s)", len(channelConfigs))
+	}
+
+	// Persistent state (quotes, etc.), reopened only if the path changed
+	if proc.store == nil || proc.store.Path() != storePath {
+		if storePath == "" {
+			log.Println("WARNING: store_path is not set; quotes and other bot state will not survive a process restart")
+		}
+		proc.store, err = store.Open(storePath)
+		if err != nil {
+			return fmt.Errorf("store: %w", err)
 		}
+	}
+	botStore := proc.store
+
+	// Timer config (YAML only)
+	var timerConfigs []dwarfbot.TimerConfig
+	if err := viper.UnmarshalKey("timers", &timerConfigs); err != nil {
+		return fmt.Errorf("timer configuration: %w", err)
+	}
+	timerManager, err := dwarfbot.NewTimerManager(timerConfigs)
+	if err != nil {
+		return fmt.Errorf("timer configuration: %w", err)
+	}
+	dwarfbot.RegisterMessageListener("timers", timerManager.HandleMessage)
+	dwarfbot.RegisterAdminCommand("timers", timerManager.HandleCommand)
+
+	// Cross-platform relay config (YAML only)
+	var relayRoutes []dwarfbot.RelayRoute
+	if err := viper.UnmarshalKey("relay", &relayRoutes); err != nil {
+		return fmt.Errorf("relay configuration: %w", err)
+	}
+	relay, err := dwarfbot.NewRelay(relayRoutes)
+	if err != nil {
+		return fmt.Errorf("relay configuration: %w", err)
+	}
+	dwarfbot.RegisterMessageListener("relay", relay.HandleMessage)
+
+	// Keyword/regex auto-responders (YAML only)
+	var triggerConfigs []dwarfbot.TriggerConfig
+	if err := viper.UnmarshalKey("triggers", &triggerConfigs); err != nil {
+		return fmt.Errorf("trigger configuration: %w", err)
+	}
+	triggers, err := dwarfbot.NewTriggers(triggerConfigs)
+	if err != nil {
+		return fmt.Errorf("trigger configuration: %w", err)
+	}
+	if len(triggerConfigs) > 0 {
+		log.Printf("Loaded %d auto-responder trigger(s)", len(triggerConfigs))
+	}
+	dwarfbot.RegisterMessageListener("triggers", triggers.HandleMessage)
+
+	// MQTT config
+	mqttDiscordChannels := getStringSlice("mqtt_discord_channels")
+	if len(mqttDiscordChannels) == 0 {
+		mqttDiscordChannels = discordChannels
+	}
+	mqttConfig := mqtt.Config{
+		Enabled:          viper.GetBool("mqtt_enabled"),
+		Broker:           viper.GetString("mqtt_broker"),
+		Username:         viper.GetString("mqtt_username"),
+		Password:         viper.GetString("mqtt_password"),
+		ClientID:         viper.GetString("mqtt_client_id"),
+		Topics:           getStringSlice("mqtt_topics"),
+		DiscordChannels:  mqttDiscordChannels,
+		FlushSeconds:     viper.GetInt("mqtt_flush_seconds"),
+		MaxBuffer:        viper.GetInt("mqtt_max_buffer"),
+		MaxPayloadBytes:  viper.GetInt("mqtt_max_payload_bytes"),
+		MaxPostsPerFlush: viper.GetInt("mqtt_max_posts_per_flush"),
+	}
+
+	if err := mqtt.ValidateConfig(mqttConfig, discordEnabled); err != nil {
+		return fmt.Errorf("MQTT configuration: %w", err)
+	}
+
+	if mqttConfig.Enabled && len(mqttConfig.Topics) == 0 {
+		log.Println("WARNING: mqtt_enabled=true but mqtt_topics is empty; bridge will connect but forward nothing")
+	}
 
-		// Handle graceful shutdown via signal
-		sc := make(chan os.Signal, 1)
-		signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM)
-
-		// Start Twitch bot if configured (non-fatal on failure)
-		twitchErrCh := make(chan error, 1)
-		var twitchBot *dwarfbot.DwarfBot
-		if twitchEnabled {
-			twitchBot = &dwarfbot.DwarfBot{
-				Credentials: &dwarfbot.OAuthCreds{
-					Name:  name,
-					Token: twitchToken,
-				},
-				Verbose:  verbose,
-				Server:   server,
-				Port:     port,
-				Channels: twitchChannels,
-				Name:     name,
-				Metrics:  recorder,
+	// Initialize metrics and the status tracker
+	version := status.Version()
+	m := metrics.New()
+	m.Init(version, startTime)
+	m.SetConfigMetrics([]metrics.SourceConfig{
+		{Name: "twitch", Token: twitchToken, Channels: twitchChannels},
+		{Name: "discord", Token: discordToken, Channels: discordChannels},
+	})
+	recorder := metrics.NewRecorder(m)
+
+	botStatus := status.New(version, startTime)
+	defer botStatus.Subscribe(events.Default)()
+	if twitchEnabled {
+		botStatus.Register("twitch")
+	}
+	if discordEnabled {
+		botStatus.Register("discord")
+	}
+	dwarfbot.RegisterCommand("status", dwarfbot.NewStatusCommand(botStatus))
+	dwarfbot.RegisterCommand("roll", dwarfbot.NewDiceRoller().HandleCommand)
+	dwarfbot.RegisterAdminCommand("restart", lc.HandleRestartCommand)
+
+	// External exec plugins (YAML only)
+	var pluginConfigs []dwarfbot.PluginConfig
+	if err := viper.UnmarshalKey("plugins", &pluginConfigs); err != nil {
+		return fmt.Errorf("plugin configuration: %w", err)
+	}
+	plugins, err := dwarfbot.NewPlugins(pluginConfigs)
+	if err != nil {
+		return fmt.Errorf("plugin configuration: %w", err)
+	}
+	if err := plugins.RegisterCommands(); err != nil {
+		return fmt.Errorf("plugin configuration: %w", err)
+	}
+	defer plugins.Stop()
+
+	// Outbound HTTP webhook commands (YAML only)
+	var webhookConfigs []dwarfbot.WebhookConfig
+	if err := viper.UnmarshalKey("webhooks", &webhookConfigs); err != nil {
+		return fmt.Errorf("webhook configuration: %w", err)
+	}
+	webhooks, err := dwarfbot.NewWebhooks(webhookConfigs)
+	if err != nil {
+		return fmt.Errorf("webhook configuration: %w", err)
+	}
+	if err := webhooks.RegisterCommands(); err != nil {
+		return fmt.Errorf("webhook configuration: %w", err)
+	}
+	defer webhooks.Stop()
+
+	// Automod (YAML only). Its middleware is registered before the
+	// ignore list's so ignored users are still moderated.
+	var moderationConfig dwarfbot.ModerationConfig
+	if err := viper.UnmarshalKey("moderation", &moderationConfig); err != nil {
+		return fmt.Errorf("moderation configuration: %w", err)
+	}
+	moderation, err := dwarfbot.NewModeration(moderationConfig)
+	if err != nil {
+		return fmt.Errorf("moderation configuration: %w", err)
+	}
+	moderation.RegisterCommands()
+
+	// Store-backed commands. Counters go last so they can't shadow
+	// any other command.
+	dwarfbot.RegisterCommand("quote", dwarfbot.NewQuoteBook(botStore).HandleCommand)
+	ignoreList := dwarfbot.NewIgnoreList(botStore, getStringSlice("ignore_users"))
+	ignoreList.RegisterCommands()
+	reminders, err := dwarfbot.NewReminders(botStore)
+	if err != nil {
+		return fmt.Errorf("reminder store: %w", err)
+	}
+	dwarfbot.RegisterCommand("remind", reminders.HandleCommand)
+	identities, err := dwarfbot.NewIdentities(botStore)
+	if err != nil {
+		return fmt.Errorf("identity store: %w", err)
+	}
+	identities.RegisterCommands()
+
+	// Loyalty gold (YAML only)
+	var loyaltyConfig dwarfbot.LoyaltyConfig
+	if err := viper.UnmarshalKey("loyalty", &loyaltyConfig); err != nil {
+		return fmt.Errorf("loyalty configuration: %w", err)
+	}
+	loyalty, err := dwarfbot.NewLoyalty(botStore, identities, recorder, loyaltyConfig)
+	if err != nil {
+		return fmt.Errorf("loyalty configuration: %w", err)
+	}
+	loyalty.RegisterCommands()
+	polls, err := dwarfbot.NewPolls(botStore, identities)
+	if err != nil {
+		return fmt.Errorf("poll store: %w", err)
+	}
+	polls.RegisterCommands()
+	counters := dwarfbot.NewCounters(botStore, recorder)
+
+	// Starlark script commands
+	scripts, err := dwarfbot.LoadScripts(viper.GetString("scripts_dir"), botStore, counters)
+	if err != nil {
+		return fmt.Errorf("scripts: %w", err)
+	}
+	if err := scripts.RegisterCommands(); err != nil {
+		return fmt.Errorf("scripts: %w", err)
+	}
+	defer scripts.Wait()
+
+	if err := counters.RegisterCommands(); err != nil {
+		return fmt.Errorf("counter store: %w", err)
+	}
+
+	// Start metrics HTTP server
+	metricsSrv := metrics.NewServer(":"+metricsPort, m.Registry)
+	go func() {
+		log.Printf("Metrics server listening on :%s", metricsPort)
+		if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Printf("Metrics server error: %v", err)
+		}
+	}()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := metricsSrv.Shutdown(ctx); err != nil {
+			log.Printf("Metrics server shutdown error: %v", err)
+		}
+	}()
+
+	// Admin command audit log, only when a file or mod-log channel is set
+	auditPath := viper.GetString("audit_log_path")
+	auditChannel := viper.GetString("discord_audit_channel")
+	var auditLog *dwarfbot.AuditLog
+	var auditSink dwarfbot.AuditSink
+	if auditPath != "" || auditChannel != "" {
+		auditLog, err = dwarfbot.NewAuditLog(auditPath)
+		if err != nil {
+			return fmt.Errorf("audit log: %w", err)
+		}
+		defer func() {
+			if err := auditLog.Close(); err != nil {
+				log.Printf("Failed to close audit log: %v", err)
 			}
+		}()
+		auditSink = auditLog
+	}
+	moderation.SetAudit(auditSink)
+
+	// Start Discord bot if configured (non-fatal on failure)
+	var discordBot *dwarfbot.DiscordBot
+	discordRunning := false
+	if discordEnabled {
+		discordBot = &dwarfbot.DiscordBot{
+			Token:      discordToken,
+			ChannelIDs: discordChannels,
+			AdminRole:  discordAdminRole,
+			AllowBots:  getStringSlice("discord_allow_bots"),
+			Name:       name,
+			Metrics:    recorder,
+			ACL:        acl,
+			Lifecycle:  lc,
+			Audit:      auditSink,
+			Identities: identities,
+		}
 
-			go func() {
-				twitchErrCh <- twitchBot.Start()
+		if err := discordBot.Start(); err != nil {
+			log.Printf("WARNING: Failed to start Discord bot: %v", err)
+			discordBot = nil
+		} else {
+			discordRunning = true
+			defer func() {
+				if err := discordBot.Stop(); err != nil {
+					log.Printf("Failed to stop Discord bot: %v", err)
+				}
 			}()
+			log.Println("Discord bot is running")
 		}
+	}
 
-		// Verify at least one platform started
-		if !discordRunning && !twitchEnabled {
-			log.Fatal("No platforms started successfully")
+	if auditLog != nil {
+		if auditChannel != "" && discordRunning {
+			auditLog.SetModLog(discordBot, auditChannel)
+		} else if auditChannel != "" {
+			log.Println("WARNING: Discord audit channel set but Discord is not running; audit events go to the file only")
 		}
+		auditLog.Start()
+	}
 
-		// Wait for signal or Twitch failure
-		select {
-		case <-sc:
-			log.Println("Shutting down...")
-			if mqttBridge != nil {
-				mqttBridge.Stop()
-			}
-			if twitchBot != nil {
-				twitchBot.Stop()
-				select {
-				case <-twitchErrCh:
-				case <-time.After(5 * time.Second):
-					log.Println("Twitch bot did not stop within timeout")
-				}
+	// Start MQTT bridge after Discord (it depends on the Discord poster callback)
+	var mqttBridge *mqtt.Bridge
+	if mqttConfig.Enabled && discordRunning {
+		mqttMetrics := mqtt.NewBridgeMetrics(m.Registry)
+		postFunc := func(channelID, msg string) error {
+			ctx, cancel := context.WithTimeout(lc.Context(), 10*time.Second)
+			defer cancel()
+			return discordBot.SendMessage(ctx, channelID, msg)
+		}
+		mqttBridge = mqtt.NewBridge(mqttConfig, postFunc, mqttMetrics)
+		scripts.SetPublisher(mqttBridge)
+
+		// Register the admin command handler
+		dwarfbot.RegisterMQTTHandler(func(ctx context.Context, channelName string, platform dwarfbot.ChatPlatform, arguments []string) {
+			if len(arguments) == 0 {
+				_ = platform.SendMessage(ctx, channelName, "Usage: mqtt on|off|status")
+				return
 			}
-		case err := <-twitchErrCh:
-			if err != nil {
-				log.Printf("Twitch bot exited with error: %v", err)
-				if !discordRunning {
-					log.Fatal("All platforms have failed, exiting")
+			switch strings.ToLower(arguments[0]) {
+			case "on":
+				mqttBridge.Enable()
+				_ = platform.SendMessage(ctx, channelName, "MQTT bridge enabled")
+			case "off":
+				mqttBridge.Disable()
+				_ = platform.SendMessage(ctx, channelName, "MQTT bridge disabled")
+			case "status":
+				status := mqttBridge.Status()
+				enabledStr := "disabled"
+				if status.Enabled {
+					enabledStr = "enabled"
 				}
-				log.Println("Continuing with Discord only")
-				<-sc
-				log.Println("Shutting down...")
-				if mqttBridge != nil {
-					mqttBridge.Stop()
+				connStr := "disconnected"
+				if status.Connected {
+					connStr = "connected"
 				}
+				msg := fmt.Sprintf("MQTT bridge: %s, %s, buffer: %d, topics: %s",
+					enabledStr, connStr, status.BufferDepth, strings.Join(status.Topics, ", "))
+				_ = platform.SendMessage(ctx, channelName, msg)
+			default:
+				_ = platform.SendMessage(ctx, channelName, "Usage: mqtt on|off|status")
 			}
+		})
+
+		if err := mqttBridge.Start(); err != nil {
+			log.Printf("WARNING: Failed to start MQTT bridge: %v", err)
+		} else {
+			log.Println("MQTT bridge is running")
 		}
+	} else if mqttConfig.Enabled && !discordRunning {
+		log.Println("WARNING: MQTT bridge enabled but Discord is not running; bridge not started")
+	}
 
-		// Gracefully shut down metrics server
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		if err := metricsSrv.Shutdown(ctx); err != nil {
-			log.Printf("Metrics server shutdown error: %v", err)
+	// Start Twitch bot if configured (non-fatal on failure)
+	twitchErrCh := make(chan error, 1)
+	var twitchBot *dwarfbot.DwarfBot
+	if twitchEnabled {
+		twitchBot = &dwarfbot.DwarfBot{
+			Credentials: &dwarfbot.OAuthCreds{
+				Name:  name,
+				Token: twitchToken,
+			},
+			Verbose:    verbose,
+			Server:     server,
+			Port:       port,
+			Channels:   twitchChannels,
+			Name:       name,
+			Metrics:    recorder,
+			ACL:        acl,
+			Lifecycle:  lc,
+			Audit:      auditSink,
+			Identities: identities,
 		}
-	},
+
+		go func() {
+			twitchErrCh <- twitchBot.Start()
+		}()
+	}
+
+	// Start timers, reminders, polls and the relay once platforms are known
+	if discordRunning {
+		timerManager.SetPlatform("discord", discordBot)
+		reminders.SetPlatform("discord", discordBot)
+		moderation.SetPlatform("discord", discordBot)
+		polls.SetPlatform("discord", discordBot)
+		relay.SetPlatform("discord", discordBot)
+		triggers.SetPlatform("discord", discordBot)
+	}
+	if twitchBot != nil {
+		timerManager.SetPlatform("twitch", twitchBot)
+		reminders.SetPlatform("twitch", twitchBot)
+		moderation.SetPlatform("twitch", twitchBot)
+		polls.SetPlatform("twitch", twitchBot)
+		relay.SetPlatform("twitch", twitchBot)
+		triggers.SetPlatform("twitch", twitchBot)
+	}
+	timerManager.Start()
+	defer timerManager.Stop()
+	reminders.Start()
+	defer reminders.Stop()
+	loyalty.Start()
+	defer loyalty.Stop()
+	polls.Start()
+	defer polls.Stop()
+	relay.Start()
+	defer relay.Stop()
+	triggers.Start()
+	defer triggers.Stop()
+
+	// Verify at least one platform started
+	if !discordRunning && !twitchEnabled {
+		return errors.New("no platforms started successfully")
+	}
+
+	// Apply hot reloads to whatever is running until this run ends
+	configReloader := &reloader{twitch: twitchBot, bridge: mqttBridge, acl: acl, triggers: triggers, ignore: ignoreList, moderation: moderation, metrics: recorder, cmd: proc.cmd}
+	if discordRunning {
+		configReloader.discord = discordBot
+	}
+	// The run waits for an in-flight reload before returning, so the
+	// next run doesn't read the config while it is being replaced.
+	stopReloads := make(chan struct{})
+	reloadsDone := make(chan struct{})
+	go func() {
+		defer close(reloadsDone)
+		for {
+			select {
+			case <-lc.Done():
+				return
+			case <-stopReloads:
+				return
+			case <-proc.reloadCh:
+				_ = configReloader.reload()
+			}
+		}
+	}()
+	defer func() {
+		close(stopReloads)
+		<-reloadsDone
+	}()
+
+	// Wait for a signal, a shutdown/restart command, or Twitch failure
+	select {
+	case <-lc.Done():
+	case err := <-twitchErrCh:
+		twitchBot = nil
+		if err != nil {
+			log.Printf("Twitch bot exited with error: %v", err)
+			if !discordRunning {
+				return errors.New("all platforms have failed, exiting")
+			}
+			log.Println("Continuing with Discord only")
+			<-lc.Done()
+		}
+	}
+
+	log.Println("Shutting down...")
+	if mqttBridge != nil {
+		mqttBridge.Stop()
+	}
+	if twitchBot != nil {
+		twitchBot.Stop()
+		select {
+		case <-twitchErrCh:
+		case <-time.After(5 * time.Second):
+			log.Println("Twitch bot did not stop within timeout")
+		}
+	}
+	return nil
+}
+
+// reloadConfig re-reads the config file before a restart and returns
+// the contents it replaced, to go back to if the restart fails. If it
+// can't be read the previous settings are kept.
+func reloadConfig() []byte {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("WARNING: failed to reload config file, keeping previous settings: %v", err)
+		return nil
+	}
+	previous, err := setConfig(data)
+	if err != nil {
+		log.Printf("WARNING: failed to reload config file, keeping previous settings: %v", err)
+		return nil
+	}
+	log.Printf("Reloaded config file: %s", path)
+	return previous
+}
+
+// restoreConfig puts back config file contents returned by reloadConfig.
+func restoreConfig(data []byte) {
+	if data == nil {
+		return
+	}
+	if _, err := setConfig(data); err != nil {
+		log.Printf("WARNING: failed to restore the previous config: %v", err)
+	}
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -307,6 +696,24 @@ func init() {
 	rootCmd.PersistentFlags().StringP("name", "n", "", "bot display name")
 	cobra.CheckErr(viper.BindPFlag("name", rootCmd.PersistentFlags().Lookup("name")))
 
+	rootCmd.PersistentFlags().StringSlice("aliases", []string{}, "names the bot answers to, e.g. !dwarfbot (default hammerdwarfbot,dwarfbot)")
+	cobra.CheckErr(viper.BindPFlag("aliases", rootCmd.PersistentFlags().Lookup("aliases")))
+
+	rootCmd.PersistentFlags().Bool("watch-config", false, "reload the config file automatically when it changes (SIGHUP always reloads)")
+	cobra.CheckErr(viper.BindPFlag("watch_config", rootCmd.PersistentFlags().Lookup("watch-config")))
+
+	rootCmd.PersistentFlags().String("scripts-dir", "", "directory of Starlark (*.star) scripts to register as commands")
+	cobra.CheckErr(viper.BindPFlag("scripts_dir", rootCmd.PersistentFlags().Lookup("scripts-dir")))
+
+	rootCmd.PersistentFlags().String("audit-log-path", "", "append-only JSONL file recording admin commands (disabled if empty)")
+	cobra.CheckErr(viper.BindPFlag("audit_log_path", rootCmd.PersistentFlags().Lookup("audit-log-path")))
+
+	rootCmd.PersistentFlags().StringSlice("ignore-users", []string{}, "Twitch logins or Discord user IDs the bot never reacts to")
+	cobra.CheckErr(viper.BindPFlag("ignore_users", rootCmd.PersistentFlags().Lookup("ignore-users")))
+
+	rootCmd.PersistentFlags().String("store-path", "", "path to the JSON file that persists quotes and other bot state (in-memory if empty)")
+	cobra.CheckErr(viper.BindPFlag("store_path", rootCmd.PersistentFlags().Lookup("store-path")))
+
 	// Discord configuration
 	rootCmd.PersistentFlags().String("discord-token", "", "Discord bot token")
 	cobra.CheckErr(viper.BindPFlag("discord_token", rootCmd.PersistentFlags().Lookup("discord-token")))
@@ -317,6 +724,12 @@ func init() {
 	rootCmd.PersistentFlags().String("discord-admin-role", "dwarfbot-admin", "Discord role name for admin commands")
 	cobra.CheckErr(viper.BindPFlag("discord_admin_role", rootCmd.PersistentFlags().Lookup("discord-admin-role")))
 
+	rootCmd.PersistentFlags().String("discord-audit-channel", "", "Discord channel ID to post admin command audit events to")
+	cobra.CheckErr(viper.BindPFlag("discord_audit_channel", rootCmd.PersistentFlags().Lookup("discord-audit-channel")))
+
+	rootCmd.PersistentFlags().StringSlice("discord-allow-bots", []string{}, "Discord bot user IDs allowed to run commands (other bots are skipped)")
+	cobra.CheckErr(viper.BindPFlag("discord_allow_bots", rootCmd.PersistentFlags().Lookup("discord-allow-bots")))
+
 	// Metrics configuration
 	rootCmd.PersistentFlags().String("metrics-port", "8080", "Port for Prometheus metrics HTTP server")
 	cobra.CheckErr(viper.BindPFlag("metrics_port", rootCmd.PersistentFlags().Lookup("metrics-port")))
@@ -357,17 +770,53 @@ func init() {
 }
 
 func getStringSlice(key string) []string {
-	v := viper.GetStringSlice(key)
-	if len(v) == 1 && strings.Contains(v[0], ",") {
+	return stringSlice(viper.GetViper(), key)
+}
+
+// stringSlice reads key from v, splitting a single comma-separated value
+// as given by a flag or environment variable.
+func stringSlice(v *viper.Viper, key string) []string {
+	values := v.GetStringSlice(key)
+	if len(values) == 1 && strings.Contains(values[0], ",") {
 		var out []string
-		for _, s := range strings.Split(v[0], ",") {
+		for _, s := range strings.Split(values[0], ",") {
 			if t := strings.TrimSpace(s); t != "" {
 				out = append(out, t)
 			}
 		}
 		return out
 	}
-	return v
+	return values
+}
+
+// loadCatalog builds the persona message catalog from the "personas"
+// config section of v.
+func loadCatalog(v *viper.Viper) (*dwarfbot.Catalog, error) {
+	// Personas are decoded without viper's default string-to-slice hook,
+	// which would split a single-string message on its commas. Weak
+	// typing still turns a lone string into a one-variant list.
+	var cfg dwarfbot.PersonaConfig
+	if err := v.UnmarshalKey("personas", &cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc())); err != nil {
+		return nil, fmt.Errorf("persona configuration: %w", err)
+	}
+	catalog, err := dwarfbot.NewCatalog(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("persona configuration: %w", err)
+	}
+	return catalog, nil
+}
+
+// loadChannelConfigs reads the "channel_settings" config section of v
+// and validates it against catalog.
+func loadChannelConfigs(v *viper.Viper, catalog *dwarfbot.Catalog) (map[string]dwarfbot.ChannelConfig, error) {
+	var configs map[string]dwarfbot.ChannelConfig
+	if err := v.UnmarshalKey("channel_settings", &configs); err != nil {
+		return nil, fmt.Errorf("channel settings: %w", err)
+	}
+	if err := dwarfbot.ValidateChannelConfigs(configs, catalog); err != nil {
+		return nil, err
+	}
+	return configs, nil
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -401,5 +850,7 @@ func initConfig() {
 		}
 	} else {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		// Kept so that a failed restart can go back to it.
+		configData, _ = os.ReadFile(viper.ConfigFileUsed())
 	}
 }
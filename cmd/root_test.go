@@ -54,6 +54,14 @@ func TestRootCommandHasFlags(t *testing.T) {
 		{"discord-channels", ""},
 		{"discord-admin-role", ""},
 		{"metrics-port", ""},
+		{"store-path", ""},
+		{"aliases", ""},
+		{"watch-config", ""},
+		{"scripts-dir", ""},
+		{"audit-log-path", ""},
+		{"discord-audit-channel", ""},
+		{"ignore-users", ""},
+		{"discord-allow-bots", ""},
 	}
 
 	for _, f := range flags {
@@ -323,10 +331,16 @@ func TestFlagsHaveUsageText(t *testing.T) {
 // the <provider>-<item> naming convention (provider-prefixed).
 func TestFlagNamingConvention(t *testing.T) {
 	generalFlags := map[string]bool{
-		"config":       true,
-		"verbose":      true,
-		"name":         true,
-		"metrics-port": true,
+		"config":         true,
+		"verbose":        true,
+		"name":           true,
+		"metrics-port":   true,
+		"store-path":     true,
+		"aliases":        true,
+		"watch-config":   true,
+		"scripts-dir":    true,
+		"audit-log-path": true,
+		"ignore-users":   true,
 	}
 	providerPrefixes := []string{"twitch-", "discord-", "mqtt-"}
 
@@ -0,0 +1,118 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, expr string) *Schedule {
+	t.Helper()
+	s, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", expr, err)
+	}
+	return s
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1,,2 * * * *",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected error for %q", expr)
+		}
+	}
+}
+
+func TestNext_EveryMinute(t *testing.T) {
+	s := mustParse(t, "* * * * *")
+	from := time.Date(2026, 1, 1, 10, 0, 30, 0, time.UTC)
+	want := time.Date(2026, 1, 1, 10, 1, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_Step(t *testing.T) {
+	s := mustParse(t, "*/20 * * * *")
+	from := time.Date(2026, 1, 1, 10, 21, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 1, 10, 40, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_StrictlyAfter(t *testing.T) {
+	s := mustParse(t, "0 * * * *")
+	from := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_RollsOverDayAndMonth(t *testing.T) {
+	s := mustParse(t, "30 20 1 * *")
+	from := time.Date(2026, 1, 31, 21, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 2, 1, 20, 30, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_DayOfWeekRangeAndList(t *testing.T) {
+	// Weekdays at 09:00 and 17:00
+	s := mustParse(t, "0 9,17 * * 1-5")
+	// Saturday 2026-01-03
+	from := time.Date(2026, 1, 3, 12, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_SundayAsSeven(t *testing.T) {
+	s := mustParse(t, "0 12 * * 7")
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_DomOrDow(t *testing.T) {
+	// The 15th of the month or any Monday
+	s := mustParse(t, "0 0 15 * 1")
+	from := time.Date(2026, 1, 6, 0, 0, 0, 0, time.UTC) // Tuesday
+	want := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_LeapDay(t *testing.T) {
+	s := mustParse(t, "0 0 29 2 *")
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	want := time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNext_Impossible(t *testing.T) {
+	s := mustParse(t, "0 0 31 2 *")
+	if got := s.Next(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("expected zero time, got %v", got)
+	}
+}
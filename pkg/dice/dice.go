@@ -0,0 +1,501 @@
+// Package dice parses and rolls tabletop dice expressions such as
+// "4d6kh3+2", "d20" or "(2d8+3)*2". It supports keep/drop highest and
+// lowest ("kh", "kl", "dh", "dl"; "k" is "kh"), exploding dice ("!"),
+// percentile dice ("d%"), the four integer arithmetic operators and
+// parentheses. Limits on dice, sides and expression length keep a
+// single roll cheap.
+package dice
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Limits enforced by Parse and Roll.
+const (
+	// MaxDice is the most dice an expression may roll before explosions.
+	MaxDice = 100
+
+	// MaxSides is the most sides a die may have.
+	MaxSides = 1000
+
+	// MaxRolls caps the dice rolled including explosions, so exploding
+	// dice can't run forever.
+	MaxRolls = 500
+
+	// MaxLength is the longest expression Parse accepts.
+	MaxLength = 100
+
+	maxNumber = 1_000_000
+	maxValue  = 1_000_000_000_000
+)
+
+// Expr is a parsed dice expression.
+type Expr struct {
+	root node
+}
+
+// Result is one evaluation of an Expr.
+type Result struct {
+	// Total is the value of the expression.
+	Total int
+
+	// Breakdown shows every die rolled, e.g. "4d6kh3 [6, 5, 3, (2)] + 2".
+	// Dropped dice are in parentheses and exploded dice end in "!".
+	Breakdown string
+
+	// Summary shows each dice group's subtotal instead of every die,
+	// e.g. "4d6kh3 (14) + 2", for when Breakdown is too long to post.
+	Summary string
+}
+
+// Parse parses a dice expression. Whitespace is ignored.
+func Parse(input string) (*Expr, error) {
+	s := strings.ToLower(strings.Join(strings.Fields(input), ""))
+	if s == "" {
+		return nil, fmt.Errorf("empty dice expression")
+	}
+	if len(s) > MaxLength {
+		return nil, fmt.Errorf("dice expression longer than %d characters", MaxLength)
+	}
+	p := &parser{s: s}
+	root, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.s[p.pos], p.pos+1)
+	}
+	return &Expr{root: root}, nil
+}
+
+// Advantage rewrites a lone single die, as in "d20+5", to roll twice
+// and keep the higher ("adv") or lower ("dis") result. It fails if the
+// expression doesn't have exactly one dice group of one die.
+func (e *Expr) Advantage(keepHigh bool) error {
+	var groups []*diceNode
+	walk(e.root, func(d *diceNode) { groups = append(groups, d) })
+	if len(groups) != 1 || groups[0].count != 1 || groups[0].keep != 0 {
+		return fmt.Errorf("advantage needs exactly one single die, like d20")
+	}
+	d := groups[0]
+	d.count, d.keep, d.keepLow, d.label = 2, 1, false, "kh1"
+	if !keepHigh {
+		d.keepLow, d.label = true, "kl1"
+	}
+	return nil
+}
+
+// Roll evaluates the expression. intN must return a uniform value in
+// [0, n), like math/rand/v2.IntN.
+func (e *Expr) Roll(intN func(n int) int) (Result, error) {
+	ev := &evaluator{intN: intN}
+	v, err := e.root.eval(ev)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Total: v.total, Breakdown: v.breakdown, Summary: v.summary}, nil
+}
+
+// String returns the normalized expression, e.g. "2d20kh1+5".
+func (e *Expr) String() string {
+	return e.root.String()
+}
+
+type value struct {
+	total              int
+	breakdown, summary string
+}
+
+type evaluator struct {
+	intN  func(n int) int
+	rolls int
+}
+
+type node interface {
+	eval(ev *evaluator) (value, error)
+	String() string
+}
+
+func walk(n node, fn func(*diceNode)) {
+	switch n := n.(type) {
+	case *diceNode:
+		fn(n)
+	case *binaryNode:
+		walk(n.left, fn)
+		walk(n.right, fn)
+	case *negNode:
+		walk(n.x, fn)
+	case *parenNode:
+		walk(n.x, fn)
+	}
+}
+
+type numberNode struct {
+	n int
+}
+
+func (n *numberNode) eval(*evaluator) (value, error) {
+	s := strconv.Itoa(n.n)
+	return value{total: n.n, breakdown: s, summary: s}, nil
+}
+
+func (n *numberNode) String() string { return strconv.Itoa(n.n) }
+
+type diceNode struct {
+	count, sides int
+
+	// keep is how many dice count towards the total; 0 keeps all.
+	// drop instead removes that many from the pool once explosions have
+	// added their dice. keepLow keeps the lowest instead of the highest.
+	keep    int
+	drop    int
+	keepLow bool
+	explode bool
+
+	// label is the modifier as written ("kh3", "dl1"), for String.
+	label string
+}
+
+func (d *diceNode) String() string {
+	var b strings.Builder
+	if d.count != 1 {
+		b.WriteString(strconv.Itoa(d.count))
+	}
+	b.WriteString("d")
+	if d.sides == 100 {
+		b.WriteString("%")
+	} else {
+		b.WriteString(strconv.Itoa(d.sides))
+	}
+	if d.explode {
+		b.WriteString("!")
+	}
+	b.WriteString(d.label)
+	return b.String()
+}
+
+type die struct {
+	value    int
+	exploded bool
+	dropped  bool
+}
+
+func (d *diceNode) eval(ev *evaluator) (value, error) {
+	var pool []die
+	for i := 0; i < d.count; i++ {
+		for {
+			if ev.rolls >= MaxRolls {
+				return value{}, fmt.Errorf("too many dice rolled (max %d including explosions)", MaxRolls)
+			}
+			ev.rolls++
+			r := die{value: ev.intN(d.sides) + 1}
+			r.exploded = d.explode && r.value == d.sides
+			pool = append(pool, r)
+			if !r.exploded {
+				break
+			}
+		}
+	}
+
+	keep := d.keep
+	if d.drop > 0 {
+		keep = len(pool) - d.drop
+	}
+	if keep > 0 && keep < len(pool) {
+		order := make([]int, len(pool))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			if d.keepLow {
+				return pool[order[a]].value < pool[order[b]].value
+			}
+			return pool[order[a]].value > pool[order[b]].value
+		})
+		for _, i := range order[keep:] {
+			pool[i].dropped = true
+		}
+	}
+
+	total := 0
+	parts := make([]string, len(pool))
+	for i, r := range pool {
+		s := strconv.Itoa(r.value)
+		if r.exploded {
+			s += "!"
+		}
+		if r.dropped {
+			s = "(" + s + ")"
+		} else {
+			total += r.value
+		}
+		parts[i] = s
+	}
+	name := d.String()
+	return value{
+		total:     total,
+		breakdown: name + " [" + strings.Join(parts, ", ") + "]",
+		summary:   name + " (" + strconv.Itoa(total) + ")",
+	}, nil
+}
+
+type binaryNode struct {
+	op          byte
+	left, right node
+}
+
+func (n *binaryNode) String() string {
+	return n.left.String() + string(n.op) + n.right.String()
+}
+
+func (n *binaryNode) eval(ev *evaluator) (value, error) {
+	l, err := n.left.eval(ev)
+	if err != nil {
+		return value{}, err
+	}
+	r, err := n.right.eval(ev)
+	if err != nil {
+		return value{}, err
+	}
+	var total int
+	switch n.op {
+	case '+':
+		total = l.total + r.total
+	case '-':
+		total = l.total - r.total
+	case '*':
+		if l.total != 0 && abs(r.total) > maxValue/abs(l.total) {
+			return value{}, fmt.Errorf("result too large")
+		}
+		total = l.total * r.total
+	case '/':
+		if r.total == 0 {
+			return value{}, fmt.Errorf("division by zero")
+		}
+		total = l.total / r.total
+	}
+	if abs(total) > maxValue {
+		return value{}, fmt.Errorf("result too large")
+	}
+	op := " " + string(n.op) + " "
+	return value{
+		total:     total,
+		breakdown: l.breakdown + op + r.breakdown,
+		summary:   l.summary + op + r.summary,
+	}, nil
+}
+
+type negNode struct {
+	x node
+}
+
+func (n *negNode) String() string { return "-" + n.x.String() }
+
+func (n *negNode) eval(ev *evaluator) (value, error) {
+	v, err := n.x.eval(ev)
+	if err != nil {
+		return value{}, err
+	}
+	return value{total: -v.total, breakdown: "-" + v.breakdown, summary: "-" + v.summary}, nil
+}
+
+type parenNode struct {
+	x node
+}
+
+func (n *parenNode) String() string { return "(" + n.x.String() + ")" }
+
+func (n *parenNode) eval(ev *evaluator) (value, error) {
+	v, err := n.x.eval(ev)
+	if err != nil {
+		return value{}, err
+	}
+	return value{total: v.total, breakdown: "(" + v.breakdown + ")", summary: "(" + v.summary + ")"}, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// parser is a recursive-descent parser over the whitespace-free,
+// lowercased expression:
+//
+//	expr   = term { ("+" | "-") term }
+//	term   = factor { ("*" | "/") factor }
+//	factor = "-" factor | "(" expr ")" | dice | number
+//	dice   = [number] "d" (number | "%") ["!"] [("k" | "kh" | "kl" | "dh" | "dl") number]
+type parser struct {
+	s     string
+	pos   int
+	rolls int
+}
+
+func (p *parser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *parser) expr() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (node, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) factor() (node, error) {
+	switch c := p.peek(); {
+	case c == '-':
+		p.pos++
+		x, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		return &negNode{x: x}, nil
+	case c == '(':
+		p.pos++
+		x, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ) at position %d", p.pos+1)
+		}
+		p.pos++
+		return &parenNode{x: x}, nil
+	case c == 'd':
+		return p.dice(1)
+	case c >= '0' && c <= '9':
+		n, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == 'd' {
+			return p.dice(n)
+		}
+		return &numberNode{n: n}, nil
+	case c == 0:
+		return nil, fmt.Errorf("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+	}
+}
+
+func (p *parser) number() (int, error) {
+	start := p.pos
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.s) {
+			return 0, fmt.Errorf("expected a number at end of expression")
+		}
+		return 0, fmt.Errorf("expected a number at position %d", p.pos+1)
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil || n > maxNumber {
+		return 0, fmt.Errorf("number %s is too large (max %d)", p.s[start:p.pos], maxNumber)
+	}
+	return n, nil
+}
+
+// dice parses from the "d" of a dice group whose count has been read.
+func (p *parser) dice(count int) (node, error) {
+	p.pos++ // "d"
+	if count < 1 {
+		return nil, fmt.Errorf("dice count must be at least 1")
+	}
+	d := &diceNode{count: count}
+	if p.peek() == '%' {
+		p.pos++
+		d.sides = 100
+	} else {
+		sides, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if sides < 1 || sides > MaxSides {
+			return nil, fmt.Errorf("dice must have 1 to %d sides, got %d", MaxSides, sides)
+		}
+		d.sides = sides
+	}
+	if p.peek() == '!' {
+		p.pos++
+		if d.sides == 1 {
+			return nil, fmt.Errorf("a d1 can't explode")
+		}
+		d.explode = true
+	}
+
+	start := p.pos
+	var mod string
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "kh"), strings.HasPrefix(p.s[p.pos:], "kl"),
+		strings.HasPrefix(p.s[p.pos:], "dh"), strings.HasPrefix(p.s[p.pos:], "dl"):
+		mod = p.s[p.pos : p.pos+2]
+	case p.peek() == 'k':
+		mod = "k"
+	}
+	if mod != "" {
+		p.pos += len(mod)
+		n, err := p.number()
+		if err != nil {
+			return nil, err
+		}
+		if n > count {
+			return nil, fmt.Errorf("%s%d: only %d dice rolled", mod, n, count)
+		}
+		switch mod {
+		case "k", "kh":
+			d.keep = n
+		case "kl":
+			d.keep, d.keepLow = n, true
+		case "dh":
+			d.drop, d.keepLow = n, true
+		case "dl":
+			d.drop = n
+		}
+		if mod[0] == 'k' && d.keep == 0 || d.drop == count {
+			return nil, fmt.Errorf("must keep at least one die")
+		}
+		d.label = p.s[start:p.pos]
+	}
+
+	p.rolls += count
+	if p.rolls > MaxDice {
+		return nil, fmt.Errorf("too many dice (max %d)", MaxDice)
+	}
+	return d, nil
+}
@@ -0,0 +1,141 @@
+package dice
+
+import (
+	"strings"
+	"testing"
+)
+
+// faces returns an RNG that rolls the given die faces in order.
+func faces(values ...int) func(n int) int {
+	i := 0
+	return func(n int) int {
+		v := values[i%len(values)]
+		i++
+		return v - 1
+	}
+}
+
+func mustRoll(t *testing.T, expr string, rng func(int) int) Result {
+	t.Helper()
+	e, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", expr, err)
+	}
+	r, err := e.Roll(rng)
+	if err != nil {
+		t.Fatalf("Roll(%q): %v", expr, err)
+	}
+	return r
+}
+
+func TestRoll(t *testing.T) {
+	tests := []struct {
+		expr      string
+		faces     []int
+		total     int
+		breakdown string
+	}{
+		{"d20", []int{17}, 17, "d20 [17]"},
+		{"4d6kh3+2", []int{6, 2, 5, 3}, 16, "4d6kh3 [6, (2), 5, 3] + 2"},
+		{"4d6k3", []int{6, 2, 5, 3}, 14, "4d6k3 [6, (2), 5, 3]"},
+		{"2d20kl1", []int{15, 4}, 4, "2d20kl1 [(15), 4]"},
+		{"4d6dl1", []int{1, 4, 4, 6}, 14, "4d6dl1 [(1), 4, 4, 6]"},
+		{"3d6dh1", []int{6, 6, 2}, 8, "3d6dh1 [6, (6), 2]"},
+		{"3d6!", []int{6, 6, 1, 2, 3}, 18, "3d6! [6!, 6!, 1, 2, 3]"},
+		{"4d6!dl1", []int{6, 6, 1, 2, 3, 6, 6, 1}, 30, "4d6!dl1 [6!, 6!, 1, 2, 3, 6!, 6!, (1)]"},
+		{"3d6!dh1", []int{6, 2, 4, 5}, 11, "3d6!dh1 [(6!), 2, 4, 5]"},
+		{"d%", []int{42}, 42, "d% [42]"},
+		{"(2d8+3)*2", []int{5, 7}, 30, "(2d8 [5, 7] + 3) * 2"},
+		{"10-2*3", nil, 4, "10 - 2 * 3"},
+		{"-d4+ 1", []int{3}, -2, "-d4 [3] + 1"},
+		{"7/2", nil, 3, "7 / 2"},
+		{"2D6 + 1D4", []int{1, 2, 3}, 6, "2d6 [1, 2] + d4 [3]"},
+	}
+	for _, tt := range tests {
+		rng := faces(1)
+		if tt.faces != nil {
+			rng = faces(tt.faces...)
+		}
+		r := mustRoll(t, tt.expr, rng)
+		if r.Total != tt.total || r.Breakdown != tt.breakdown {
+			t.Errorf("%s: got %d %q, want %d %q", tt.expr, r.Total, r.Breakdown, tt.total, tt.breakdown)
+		}
+	}
+}
+
+func TestRoll_Summary(t *testing.T) {
+	r := mustRoll(t, "4d6kh3+2", faces(6, 2, 5, 3))
+	if r.Summary != "4d6kh3 (14) + 2" {
+		t.Errorf("unexpected summary %q", r.Summary)
+	}
+}
+
+func TestAdvantage(t *testing.T) {
+	e, err := Parse("d20+5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Advantage(true); err != nil {
+		t.Fatalf("Advantage: %v", err)
+	}
+	r, _ := e.Roll(faces(3, 18))
+	if r.Total != 23 || r.Breakdown != "2d20kh1 [(3), 18] + 5" {
+		t.Errorf("advantage: got %d %q", r.Total, r.Breakdown)
+	}
+
+	e, _ = Parse("d20")
+	_ = e.Advantage(false)
+	if r, _ := e.Roll(faces(3, 18)); r.Total != 3 {
+		t.Errorf("disadvantage: expected 3, got %d", r.Total)
+	}
+
+	for _, expr := range []string{"2d20", "d20+d4", "5", "2d20kh1"} {
+		e, _ := Parse(expr)
+		if err := e.Advantage(true); err == nil {
+			t.Errorf("%s: expected advantage to be rejected", expr)
+		}
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"d",
+		"0d6",
+		"d0",
+		"d1001",
+		"101d6",
+		"60d6+60d6",
+		"4d6kh5",
+		"4d6dl4",
+		"4d6x",
+		"2d6+",
+		"(2d6",
+		"2d6)",
+		"d1!",
+		"9999999",
+		strings.Repeat("1+", 60) + "1",
+		"adv",
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q): expected error", expr)
+		}
+	}
+}
+
+func TestRoll_Errors(t *testing.T) {
+	e, _ := Parse("d6/(d6-1)")
+	if _, err := e.Roll(faces(4, 1)); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Errorf("expected division by zero, got %v", err)
+	}
+
+	e, _ = Parse("100d2!")
+	if _, err := e.Roll(faces(2)); err == nil || !strings.Contains(err.Error(), "too many dice") {
+		t.Errorf("expected endless explosions to be capped, got %v", err)
+	}
+
+	e, _ = Parse("1000000*1000000*1000000")
+	if _, err := e.Roll(faces(1)); err == nil {
+		t.Error("expected overflow to be rejected")
+	}
+}
@@ -0,0 +1,165 @@
+package dwarfbot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Roles a user can hold when a command is evaluated. Admin is whatever
+// the platform's IsAdmin reports (channel owner on Twitch, the configured
+// admin role on Discord); everyone else is a user.
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// ACL actions.
+const (
+	ACLAllow = "allow"
+	ACLDeny  = "deny"
+)
+
+// ACLRule is a single allow or deny entry. Empty selector lists match
+// everything, so a rule with only Command and Action set applies to every
+// platform, channel, user and role.
+type ACLRule struct {
+	// Command name the rule applies to, or "*" for every command.
+	Command string `mapstructure:"command"`
+
+	// Action is either "allow" or "deny".
+	Action string `mapstructure:"action"`
+
+	// Platforms limits the rule to the given platforms (twitch, discord).
+	Platforms []string `mapstructure:"platforms"`
+
+	// Channels limits the rule to Twitch channel names or Discord channel IDs.
+	Channels []string `mapstructure:"channels"`
+
+	// Users limits the rule to Twitch logins, Discord user IDs or
+	// identities ("discord:123456789012345678" also matches accounts
+	// linked to it).
+	Users []string `mapstructure:"users"`
+
+	// Roles limits the rule to the given roles (admin, user).
+	Roles []string `mapstructure:"roles"`
+}
+
+// ACLRequest describes the command invocation being checked.
+type ACLRequest struct {
+	Platform string
+	Channel  string
+	User     string
+	Identity string
+	Role     string
+	Command  string
+}
+
+// ACL evaluates ordered allow/deny rules before command dispatch.
+// The first matching rule wins; if no rule matches the command is allowed.
+// A nil *ACL allows everything.
+type ACL struct {
+	mu    sync.RWMutex
+	rules []ACLRule
+}
+
+// NewACL validates the rules and returns an ACL that evaluates them.
+func NewACL(rules []ACLRule) (*ACL, error) {
+	a := &ACL{}
+	if err := a.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// SetRules validates and atomically replaces the rule set.
+func (a *ACL) SetRules(rules []ACLRule) error {
+	if err := ValidateACLRules(rules); err != nil {
+		return err
+	}
+	normalized := make([]ACLRule, len(rules))
+	for i, r := range rules {
+		r.Command = strings.ToLower(strings.TrimSpace(r.Command))
+		r.Action = strings.ToLower(strings.TrimSpace(r.Action))
+		normalized[i] = r
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rules = normalized
+	return nil
+}
+
+// Rules returns a copy of the current rule set.
+func (a *ACL) Rules() []ACLRule {
+	if a == nil {
+		return nil
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	out := make([]ACLRule, len(a.rules))
+	copy(out, a.rules)
+	return out
+}
+
+// Allowed reports whether the request may be dispatched.
+func (a *ACL) Allowed(req ACLRequest) bool {
+	if a == nil {
+		return true
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, r := range a.rules {
+		if r.matches(req) {
+			return r.Action == ACLAllow
+		}
+	}
+	return true
+}
+
+func (r ACLRule) matches(req ACLRequest) bool {
+	if r.Command != "" && r.Command != "*" && r.Command != strings.ToLower(req.Command) {
+		return false
+	}
+	return matchesAny(r.Platforms, req.Platform) &&
+		matchesAny(r.Channels, req.Channel) &&
+		(matchesAny(r.Users, req.User) || (req.Identity != "" && matchesAny(r.Users, req.Identity))) &&
+		matchesAny(r.Roles, req.Role)
+}
+
+// matchesAny reports whether value is in list, ignoring case. An empty
+// list matches every value.
+func matchesAny(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, x := range list {
+		if strings.EqualFold(strings.TrimPrefix(x, "#"), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateACLRules checks that every rule names a command and a known action.
+func ValidateACLRules(rules []ACLRule) error {
+	for i, r := range rules {
+		if strings.TrimSpace(r.Command) == "" {
+			return fmt.Errorf("acl rule %d: command must be set (use \"*\" for all commands)", i)
+		}
+		switch strings.ToLower(strings.TrimSpace(r.Action)) {
+		case ACLAllow, ACLDeny:
+		default:
+			return fmt.Errorf("acl rule %d: action must be %q or %q, got %q", i, ACLAllow, ACLDeny, r.Action)
+		}
+		for _, role := range r.Roles {
+			switch strings.ToLower(role) {
+			case RoleAdmin, RoleUser:
+			default:
+				return fmt.Errorf("acl rule %d: unknown role %q", i, role)
+			}
+		}
+	}
+	return nil
+}
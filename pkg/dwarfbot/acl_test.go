@@ -0,0 +1,204 @@
+package dwarfbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewACL_RejectsMissingCommand(t *testing.T) {
+	_, err := NewACL([]ACLRule{{Action: "deny"}})
+	if err == nil {
+		t.Fatal("expected error for rule without command")
+	}
+}
+
+func TestNewACL_RejectsUnknownAction(t *testing.T) {
+	_, err := NewACL([]ACLRule{{Command: "ping", Action: "maybe"}})
+	if err == nil {
+		t.Fatal("expected error for unknown action")
+	}
+}
+
+func TestNewACL_RejectsUnknownRole(t *testing.T) {
+	_, err := NewACL([]ACLRule{{Command: "ping", Action: "deny", Roles: []string{"wizard"}}})
+	if err == nil {
+		t.Fatal("expected error for unknown role")
+	}
+}
+
+func TestACL_NilAllowsEverything(t *testing.T) {
+	var acl *ACL
+	if !acl.Allowed(ACLRequest{Platform: "twitch", Command: "shutdown"}) {
+		t.Error("expected nil ACL to allow")
+	}
+}
+
+func TestACL_NoMatchingRuleAllows(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "mqtt", Action: "deny"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acl.Allowed(ACLRequest{Platform: "twitch", Command: "ping"}) {
+		t.Error("expected ping to be allowed when only mqtt is restricted")
+	}
+}
+
+func TestACL_DenyByPlatform(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "shutdown", Action: "deny", Platforms: []string{"twitch"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.Allowed(ACLRequest{Platform: "twitch", Role: RoleAdmin, Command: "shutdown"}) {
+		t.Error("expected shutdown to be denied on twitch")
+	}
+	if !acl.Allowed(ACLRequest{Platform: "discord", Role: RoleAdmin, Command: "shutdown"}) {
+		t.Error("expected shutdown to be allowed on discord")
+	}
+}
+
+func TestACL_AllowUsersThenDenyRest(t *testing.T) {
+	acl, err := NewACL([]ACLRule{
+		{Command: "mqtt", Action: "allow", Platforms: []string{"discord"}, Users: []string{"111", "222"}},
+		{Command: "mqtt", Action: "deny"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !acl.Allowed(ACLRequest{Platform: "discord", User: "222", Command: "mqtt"}) {
+		t.Error("expected listed discord user to be allowed")
+	}
+	if acl.Allowed(ACLRequest{Platform: "discord", User: "333", Command: "mqtt"}) {
+		t.Error("expected unlisted discord user to be denied")
+	}
+	if acl.Allowed(ACLRequest{Platform: "twitch", User: "222", Command: "mqtt"}) {
+		t.Error("expected twitch user with same ID to be denied")
+	}
+}
+
+func TestACL_ChannelAndRoleSelectors(t *testing.T) {
+	acl, err := NewACL([]ACLRule{
+		{Command: "*", Action: "deny", Channels: []string{"#Quiet"}, Roles: []string{"user"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.Allowed(ACLRequest{Channel: "quiet", Role: RoleUser, Command: "ping"}) {
+		t.Error("expected users to be denied in quiet channel")
+	}
+	if !acl.Allowed(ACLRequest{Channel: "quiet", Role: RoleAdmin, Command: "ping"}) {
+		t.Error("expected admins to be allowed in quiet channel")
+	}
+	if !acl.Allowed(ACLRequest{Channel: "loud", Role: RoleUser, Command: "ping"}) {
+		t.Error("expected users to be allowed in other channels")
+	}
+}
+
+func TestACL_CommandCaseInsensitive(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "PING", Action: "DENY"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acl.Allowed(ACLRequest{Command: "ping"}) {
+		t.Error("expected rule to match regardless of case")
+	}
+}
+
+func TestACL_SetRulesReplaces(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "ping", Action: "deny"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := acl.SetRules(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !acl.Allowed(ACLRequest{Command: "ping"}) {
+		t.Error("expected ping to be allowed after rules cleared")
+	}
+	if err := acl.SetRules([]ACLRule{{Command: "ping"}}); err == nil {
+		t.Error("expected invalid rules to be rejected")
+	}
+	if len(acl.Rules()) != 0 {
+		t.Error("expected rejected rules not to be applied")
+	}
+}
+
+// --- parseCommand ACL integration ---
+
+func TestParseCommand_ACLDenied(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "ping", Action: "deny", Platforms: []string{"twitch"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newMockMetricsRecorder()
+	mock := newMockPlatform("testbot", []string{"ch1"})
+
+	_ = parseCommand(context.Background(), mock, "ch1", "someuser", "ping", nil, parseCommandOpts{metrics: rec, platformName: "twitch", acl: acl})
+
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected 1 refusal message, got %d", len(mock.messages))
+	}
+	if mock.messages[0].msg != Say("chan", "acl.denied", nil) {
+		t.Errorf("expected refusal, got %q", mock.messages[0].msg)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.commandsDenied) != 1 || rec.commandsDenied[0] != (mockDenied{"twitch", "ping"}) {
+		t.Errorf("expected one denied metric for twitch/ping, got %v", rec.commandsDenied)
+	}
+	if len(rec.commandsProcessed) != 0 {
+		t.Errorf("expected denied command not to be counted as processed, got %v", rec.commandsProcessed)
+	}
+}
+
+func TestParseCommand_ACLDeniesAdminShutdown(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "shutdown", Action: "deny", Platforms: []string{"twitch"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool { return true })
+
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "shutdown", nil, parseCommandOpts{platformName: "twitch", acl: acl})
+
+	if len(mock.shutdownLog) != 0 {
+		t.Error("expected shutdown not to run when denied by ACL")
+	}
+	if len(mock.messages) != 1 || !strings.Contains(mock.messages[0].msg, "cannae") {
+		t.Errorf("expected in-character refusal, got %v", mock.messages)
+	}
+}
+
+func TestParseCommand_ACLRoleSelector(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "channels", Action: "allow", Roles: []string{"admin"}}, {Command: "channels", Action: "deny"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool { return user == "boss" })
+
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "channels", nil, parseCommandOpts{acl: acl})
+	_ = parseCommand(context.Background(), mock, "ch1", "pleb", "channels", nil, parseCommandOpts{acl: acl})
+
+	if len(mock.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(mock.messages))
+	}
+	if !strings.Contains(mock.messages[0].msg, "hang about") {
+		t.Errorf("expected admin to get channels list, got %q", mock.messages[0].msg)
+	}
+	if mock.messages[1].msg != Say("chan", "acl.denied", nil) {
+		t.Errorf("expected user to be refused, got %q", mock.messages[1].msg)
+	}
+}
+
+func TestParseCommand_ACLIgnoresUnknownCommands(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "*", Action: "deny"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatform("testbot", []string{"ch1"})
+
+	_ = parseCommand(context.Background(), mock, "ch1", "someuser", "notacommand", nil, parseCommandOpts{acl: acl})
+
+	if len(mock.messages) != 0 {
+		t.Errorf("expected no refusal for unknown commands, got %v", mock.messages)
+	}
+}
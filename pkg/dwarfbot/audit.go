@@ -0,0 +1,155 @@
+package dwarfbot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditQueueSize bounds mod-log posts waiting to be sent so a slow
+// Discord API never blocks command dispatch.
+const auditQueueSize = 100
+
+// Audit results other than "ok" and "error: ...".
+const (
+	AuditDeniedACL      = "denied: acl"
+	AuditDeniedNotAdmin = "denied: not admin"
+	AuditDeniedChannel  = "denied: disabled in channel"
+)
+
+// AuditEvent records one admin-level command invocation.
+type AuditEvent struct {
+	Time     time.Time `json:"time"`
+	Platform string    `json:"platform"`
+	Channel  string    `json:"channel"`
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Command  string    `json:"command"`
+	Args     []string  `json:"args"`
+	Result   string    `json:"result"`
+}
+
+// AuditSink receives audit events from the command dispatcher.
+type AuditSink interface {
+	RecordAudit(event AuditEvent)
+}
+
+// AuditLog appends audit events to a JSONL file and optionally posts
+// them to a mod-log channel.
+type AuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+
+	modLog        ChatPlatform
+	modLogChannel string
+	queue         chan AuditEvent
+	done          chan struct{}
+}
+
+// NewAuditLog opens path for appending, creating it if needed. An empty
+// path disables the file.
+func NewAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{}
+	if path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("opening audit log: %w", err)
+		}
+		a.file = f
+	}
+	return a, nil
+}
+
+// SetModLog posts every event to channel on platform. Call before Start.
+func (a *AuditLog) SetModLog(platform ChatPlatform, channel string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.modLog = platform
+	a.modLogChannel = channel
+}
+
+// Start begins posting to the mod-log channel, if one is set.
+func (a *AuditLog) Start() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.modLog == nil || a.queue != nil {
+		return
+	}
+	a.queue = make(chan AuditEvent, auditQueueSize)
+	a.done = make(chan struct{})
+	go a.post(a.modLog, a.modLogChannel, a.queue, a.done)
+}
+
+// Close flushes pending mod-log posts and closes the file.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	queue, done := a.queue, a.done
+	a.queue = nil
+	a.mu.Unlock()
+	if queue != nil {
+		close(queue)
+		<-done
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// RecordAudit implements AuditSink. File write errors are logged, never
+// returned, so auditing can't break a command.
+func (a *AuditLog) RecordAudit(event AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.file != nil {
+		line, err := json.Marshal(event)
+		if err == nil {
+			_, err = a.file.Write(append(line, '\n'))
+		}
+		if err != nil {
+			log.Printf("Audit: failed to write event: %v", err)
+		}
+	}
+	if a.queue != nil {
+		select {
+		case a.queue <- event:
+		default:
+			log.Printf("Audit: mod-log queue full, dropped event for %q", event.Command)
+		}
+	}
+}
+
+func (a *AuditLog) post(platform ChatPlatform, channel string, queue <-chan AuditEvent, done chan<- struct{}) {
+	defer close(done)
+	for event := range queue {
+		ctx, cancel := sendContext(context.Background())
+		err := platform.SendMessage(ctx, channel, formatAuditEvent(event))
+		cancel()
+		if err != nil {
+			log.Printf("Audit: failed to post to mod-log channel: %v", err)
+		}
+	}
+}
+
+// formatAuditEvent renders an event for the mod-log channel, e.g.
+// "[twitch #chan] boss (boss) ran: mqtt off -> ok".
+func formatAuditEvent(e AuditEvent) string {
+	channel := e.Channel
+	if e.Platform == "twitch" {
+		channel = "#" + channel
+	}
+	cmd := strings.TrimSpace(e.Command + " " + strings.Join(e.Args, " "))
+	msg := fmt.Sprintf("[%s %s] %s (%s) ran: %s -> %s", e.Platform, channel, e.UserName, e.UserID, cmd, e.Result)
+	return sanitizeDiscordMentions(msg)
+}
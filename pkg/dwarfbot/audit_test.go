@@ -0,0 +1,160 @@
+package dwarfbot
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type recordingAudit struct {
+	events []AuditEvent
+}
+
+func (r *recordingAudit) RecordAudit(event AuditEvent) {
+	r.events = append(r.events, event)
+}
+
+func adminIs(name string) func(string, string) bool {
+	return func(_, user string) bool { return user == name }
+}
+
+func TestAudit_AdminCommandRecorded(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterMQTTHandler(func(ctx context.Context, channel string, platform ChatPlatform, arguments []string) {
+		_ = platform.SendMessage(ctx, channel, "MQTT bridge disabled")
+	})
+	audit := &recordingAudit{}
+	mock := newMockPlatformWithAdmin("testbot", []string{"chan"}, adminIs("boss"))
+
+	if err := parseCommand(context.Background(), mock, "chan", "boss", "mqtt", []string{"off"}, parseCommandOpts{platformName: "twitch", audit: audit, displayName: "Boss"}); err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	if len(audit.events) != 1 {
+		t.Fatalf("expected 1 audit event, got %v", audit.events)
+	}
+	e := audit.events[0]
+	if e.Platform != "twitch" || e.Channel != "chan" || e.UserID != "boss" || e.UserName != "Boss" ||
+		e.Command != "mqtt" || len(e.Args) != 1 || e.Args[0] != "off" || e.Result != "ok" || e.Time.IsZero() {
+		t.Errorf("unexpected audit event %+v", e)
+	}
+}
+
+func TestAudit_NonAdminAttemptsRecorded(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterAdminCommand("secret", func(req CommandRequest) error { return nil })
+	audit := &recordingAudit{}
+	mock := newMockPlatformWithAdmin("testbot", []string{"chan"}, adminIs("boss"))
+	opts := parseCommandOpts{platformName: "twitch", audit: audit}
+
+	for _, cmd := range []string{"shutdown", "secret"} {
+		if err := parseCommand(context.Background(), mock, "chan", "viewer", cmd, nil, opts); err != nil {
+			t.Fatalf("parseCommand %s: %v", cmd, err)
+		}
+	}
+	if len(mock.shutdownLog) != 0 {
+		t.Error("expected non-admin shutdown to be ignored")
+	}
+	if len(audit.events) != 2 {
+		t.Fatalf("expected 2 audit events, got %v", audit.events)
+	}
+	for _, e := range audit.events {
+		if e.Result != AuditDeniedNotAdmin {
+			t.Errorf("expected %s to be recorded as %q, got %q", e.Command, AuditDeniedNotAdmin, e.Result)
+		}
+	}
+}
+
+func TestAudit_ACLDenialRecorded(t *testing.T) {
+	acl, err := NewACL([]ACLRule{{Command: "shutdown", Action: "deny", Platforms: []string{"twitch"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := &recordingAudit{}
+	mock := newMockPlatformWithAdmin("testbot", []string{"chan"}, adminIs("boss"))
+
+	if err := parseCommand(context.Background(), mock, "chan", "boss", "shutdown", nil, parseCommandOpts{platformName: "twitch", acl: acl, audit: audit}); err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	if len(audit.events) != 1 || audit.events[0].Result != AuditDeniedACL {
+		t.Errorf("expected an ACL denial event, got %v", audit.events)
+	}
+}
+
+func TestAudit_UserCommandsNotRecorded(t *testing.T) {
+	audit := &recordingAudit{}
+	mock := newMockPlatformWithAdmin("testbot", []string{"chan"}, adminIs("boss"))
+	opts := parseCommandOpts{platformName: "twitch", audit: audit}
+
+	if err := parseCommand(context.Background(), mock, "chan", "viewer", "ping", nil, opts); err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	if err := parseCommand(context.Background(), mock, "chan", "boss", "nosuchcommand", nil, opts); err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	if len(audit.events) != 0 {
+		t.Errorf("expected no audit events, got %v", audit.events)
+	}
+}
+
+func TestAuditLog_AppendsJSONL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for _, cmd := range []string{"mqtt", "shutdown"} {
+		a, err := NewAuditLog(path)
+		if err != nil {
+			t.Fatalf("NewAuditLog: %v", err)
+		}
+		a.RecordAudit(AuditEvent{Platform: "discord", Channel: "123", UserID: "42", UserName: "Boss", Command: cmd, Result: "ok"})
+		if err := a.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, e.Command)
+	}
+	if len(got) != 2 || got[0] != "mqtt" || got[1] != "shutdown" {
+		t.Errorf("expected both events appended in order, got %v", got)
+	}
+}
+
+func TestAuditLog_PostsToModLog(t *testing.T) {
+	a, err := NewAuditLog("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modLog := &syncPlatform{mockPlatform: newMockPlatform("testbot", []string{"mods"})}
+	a.SetModLog(modLog, "mods")
+	a.Start()
+	a.RecordAudit(AuditEvent{Platform: "twitch", Channel: "chan", UserID: "boss", UserName: "Boss", Command: "mqtt", Args: []string{"off"}, Result: "ok"})
+	a.RecordAudit(AuditEvent{Platform: "discord", Channel: "123", UserID: "42", UserName: "@everyone", Command: "shutdown", Result: AuditDeniedNotAdmin})
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := []string{
+		"[twitch #chan] Boss (boss) ran: mqtt off -> ok",
+		"[discord 123] " + sanitizeDiscordMentions("@everyone") + " (42) ran: shutdown -> denied: not admin",
+	}
+	if len(modLog.messages) != len(want) {
+		t.Fatalf("expected %v, got %v", want, modLog.messages)
+	}
+	for i, w := range want {
+		if modLog.messages[i].channel != "mods" || modLog.messages[i].msg != w {
+			t.Errorf("message %d: expected %q in mods, got %+v", i, w, modLog.messages[i])
+		}
+	}
+}
@@ -0,0 +1,447 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultPersona is the built-in persona every lookup falls back to.
+const DefaultPersona = "dwarf"
+
+// Vars holds the values a catalog template may reference, e.g. {{.ID}}.
+type Vars map[string]any
+
+// builtinPersonas hold the bot's stock responses. The dwarf persona must
+// define every key the bot uses; other personas may leave keys out and
+// fall back to it.
+var builtinPersonas = map[string]PersonaEntry{
+	DefaultPersona: {
+		Messages: map[string][]string{
+			"ping.heyo":             {"Heyo, yourself boy-o!"},
+			"ping.pong":             {"Ach! I dunnae own 'n Atari, but nevertheless: \"Pong\""},
+			"shutdown.ack":          {"Yah, boss! Shuttin' 'er doon!"},
+			"restart.ack":           {"Aye, boss! Back in a tick!"},
+			"channels.list":         {"Aye, I like ta hang about here: {{.Bot}}{{range .Channels}} {{.}}{{end}}"},
+			"mqtt.not_configured":   {"MQTT bridge is not configured"},
+			"acl.denied":            {"Och, sorry friend, but I cannae do that fer ye here."},
+			"quote.none":            {"I've nae quotes yet. Add one with: quote add <text>"},
+			"quote.too_long":        {"Ach, that's a saga, not a quote! Keep it under {{.Max}} characters."},
+			"quote.saved":           {"Aye, I'll remember that one! Quote #{{.ID}} saved."},
+			"quote.no_match":        {"Nae quotes mention {{printf \"%q\" .Word}}."},
+			"quote.not_found":       {"There's nae quote #{{.ID}}."},
+			"quote.delete_denied":   {"Only the boss can strike quotes from the record."},
+			"quote.deleted":         {"Quote #{{.ID}} is gone, boss."},
+			"counter.none":          {"Nae counters yet."},
+			"counter.create_denied": {"Only the boss can make new counters."},
+			"counter.create_failed": {"Ach, cannae make that counter: {{.Error}}"},
+			"counter.created":       {"Counter {{.Name}} is ready. Use: {{.Name}} +1"},
+			"counter.delete_denied": {"Only the boss can remove counters."},
+			"counter.deleted":       {"Counter {{.Name}} is gone."},
+			"counter.not_found":     {"There's nae counter called {{.Name}}."},
+			"counter.change_denied": {"Only the boss can change the count."},
+			"timer.none":            {"No timers configured, boss."},
+			"timer.not_found":       {"Ach, I've nae timer called {{printf \"%q\" .Name}}."},
+			"timer.toggled":         {"Timer {{.Name}} {{.State}}, boss."},
+			"plugin.busy":           {"Hold yer horses, that one's still workin'."},
+			"plugin.failed":         {"Ach, that contraption's jammed. Try again later."},
+			"script.busy":           {"Hold yer horses, that one's still workin'."},
+			"script.failed":         {"Ach, that rune's gone wonky. Tell the boss."},
+			"webhook.busy":          {"Hold yer horses, that one's still workin'."},
+			"webhook.failed":        {"Ach, the messenger raven didnae come back."},
+			"webhook.done":          {"Aye, done!"},
+			"ignore.none":           {"I'm listenin' tae everyone, boss."},
+			"ignore.list":           {"I'm payin' nae mind tae:{{range .Users}} {{.}}{{end}}"},
+			"ignore.added":          {"Aye, I'll pay {{.User}} nae mind."},
+			"ignore.removed":        {"{{.User}} can talk tae me again."},
+			"ignore.not_found":      {"I wasnae ignorin' {{.User}}."},
+			"ignore.configured":     {"{{.User}} is ignored in me config file, boss. Change it there."},
+			"roll.usage":            {"Roll what? Try: roll 4d6kh3+2, roll d20 adv or roll 2d10 # damage"},
+			"roll.invalid":          {"Ach, I cannae read them runes: {{.Error}}"},
+			"roll.result":           {"{{.User}} rolls{{if .Label}} fer {{.Label}}{{end}}: {{.Breakdown}} = {{.Total}}"},
+			"remind.usage":          {"Try: remind me in 45m to stretch, remind #channel at 20:00 raid time, remind list, remind cancel <id>"},
+			"remind.invalid":        {"Ach, I cannae make sense o' that reminder: {{.Error}}"},
+			"remind.saved":          {"Aye, I'll remind {{if .Self}}ye{{else}}the channel{{end}} in {{.In}} (#{{.ID}})."},
+			"remind.too_many":       {"Ye've already got {{.Max}} reminders waitin'. Cancel one first."},
+			"remind.none":           {"Ye've nae reminders waitin'."},
+			"remind.list":           {"Yer reminders:{{range $i, $r := .Reminders}}{{if $i}};{{end}} {{$r}}{{end}}"},
+			"remind.not_found":      {"There's nae reminder {{.ID}}."},
+			"remind.cancel_denied":  {"That's no' yer reminder tae cancel."},
+			"remind.cancelled":      {"Reminder #{{.ID}} is forgotten."},
+			"remind.due":            {"{{.Mention}}, ye asked me tae remind ye: {{.Text}}"},
+			"remind.due_channel":    {"Oi! {{.Mention}} wanted ye all tae know: {{.Text}}"},
+			"link.no_dm":            {"I cannae whisper ye a code here. Run link on Discord and bring the code back."},
+			"link.code":             {"Yer link code is {{.Code}}. Say \"link {{.Code}}\" on yer other platform within {{.Minutes}} minutes."},
+			"link.sent":             {"I've whispered ye a link code. Check yer messages."},
+			"link.dm_failed":        {"I couldnae whisper ye. Open yer direct messages and try again."},
+			"link.invalid":          {"That code's no' one o' mine, or it's gone stale."},
+			"link.same_platform":    {"Ye have tae use that code on yer other platform."},
+			"link.already":          {"This account's already linked. Unlink it first."},
+			"link.platform_taken":   {"Ye've already linked a {{.Platform}} account. Unlink it first."},
+			"link.linked":           {"Aye, ye're one and the same now:{{range $i, $a := .Accounts}}{{if $i}},{{end}} {{$a}}{{end}}."},
+			"link.none":             {"This account isnae linked tae anything."},
+			"link.status":           {"Yer linked accounts:{{range $i, $a := .Accounts}}{{if $i}},{{end}} {{$a}}{{end}}."},
+			"link.unlinked":         {"Unlinked. Ye're strangers again."},
+			"gold.usage":            {"Try: gold, gold top, gold give <user> <amount>"},
+			"gold.balance":          {"{{.User}} has {{.Balance}} dwarf gold in the hoard."},
+			"gold.top_empty":        {"Nae one's got any gold yet. Get chattin'!"},
+			"gold.top":              {"The richest dwarves:{{range $i, $e := .Entries}}{{if $i}},{{end}} {{$e}}{{end}}"},
+			"gold.grant_denied":     {"Only the boss can mint gold."},
+			"gold.granted":          {"Aye, {{.Amount}} gold added tae {{.User}}'s hoard."},
+			"gold.insufficient":     {"Ye've only got {{.Balance}} gold, ye cannae give that much."},
+			"gold.self":             {"Movin' gold from one pocket tae the other, eh? Nae."},
+			"gold.given":            {"{{.User}} hands {{.Amount}} gold tae {{.To}}. Generous!"},
+			"moderation.warning":    {"{{.Mention}}, mind yer tongue! ({{.Reason}}{{if eq .Action \"timeout\"}}, timed out{{end}})"},
+			"permit.usage":          {"Tell me who, boss: permit <user>"},
+			"permit.granted":        {"Aye, {{.User}} can share one link in the next {{.Seconds}} seconds."},
+			"poll.open":             {"Hear ye! {{.Question}}{{range .Options}} {{.}}{{end}}. Vote wi' \"vote 1\" tae \"vote {{.Max}}\" (or the reactions on Discord) within {{.In}}."},
+			"poll.status":           {"{{.Question}}{{range .Options}} {{.}}{{end}}. {{.Votes}} vote(s) so far, {{.Left}} tae go."},
+			"poll.none":             {"There's nae poll runnin'."},
+			"poll.denied":           {"Only the boss can start or end a poll."},
+			"poll.invalid":          {"Ach, that poll makes nae sense: {{.Error}}"},
+			"poll.already_open":     {"Hold yer horses, there's already a poll runnin': {{.Question}}"},
+			"poll.cancelled":        {"The poll's been scrapped. Nae winner."},
+			"poll.results":          {"The votes are in! {{.Question}}{{range $i, $r := .Results}}{{if $i}},{{end}} {{$r}}{{end}}. {{if .Tie}}A tie between {{.Winner}}!{{else}}{{.Winner}} wins!{{end}}"},
+			"poll.no_votes":         {"The poll's closed: {{.Question}} Nae one voted. Shameful."},
+			"vote.invalid":          {"Pick a number from 1 tae {{.Max}}, ye numpty."},
+		},
+	},
+	"plain": {
+		Messages: map[string][]string{
+			"ping.heyo":             {"Hello to you too!"},
+			"ping.pong":             {"Pong!"},
+			"shutdown.ack":          {"Shutting down."},
+			"restart.ack":           {"Restarting, back shortly."},
+			"channels.list":         {"I'm in: {{.Bot}}{{range .Channels}} {{.}}{{end}}"},
+			"acl.denied":            {"Sorry, that command isn't allowed here."},
+			"quote.none":            {"No quotes yet. Add one with: quote add <text>"},
+			"quote.too_long":        {"That quote is too long. Keep it under {{.Max}} characters."},
+			"quote.saved":           {"Quote #{{.ID}} saved."},
+			"quote.no_match":        {"No quotes mention {{printf \"%q\" .Word}}."},
+			"quote.not_found":       {"Quote #{{.ID}} doesn't exist."},
+			"quote.delete_denied":   {"Only admins can delete quotes."},
+			"quote.deleted":         {"Quote #{{.ID}} deleted."},
+			"counter.none":          {"No counters yet."},
+			"counter.create_denied": {"Only admins can create counters."},
+			"counter.create_failed": {"Couldn't create that counter: {{.Error}}"},
+			"counter.created":       {"Counter {{.Name}} created. Use: {{.Name}} +1"},
+			"counter.delete_denied": {"Only admins can delete counters."},
+			"counter.deleted":       {"Counter {{.Name}} deleted."},
+			"counter.not_found":     {"There's no counter called {{.Name}}."},
+			"counter.change_denied": {"Only admins can change counters."},
+			"timer.none":            {"No timers configured."},
+			"timer.not_found":       {"There's no timer called {{printf \"%q\" .Name}}."},
+			"timer.toggled":         {"Timer {{.Name}} {{.State}}."},
+			"plugin.busy":           {"That command is still running, try again shortly."},
+			"plugin.failed":         {"That command failed. Try again later."},
+			"script.busy":           {"That command is still running, try again shortly."},
+			"script.failed":         {"That command failed. Please tell an admin."},
+			"webhook.busy":          {"That command is still running, try again shortly."},
+			"webhook.failed":        {"That request failed. Try again later."},
+			"webhook.done":          {"Done."},
+			"ignore.none":           {"I'm not ignoring anyone."},
+			"ignore.list":           {"Ignoring:{{range .Users}} {{.}}{{end}}"},
+			"ignore.added":          {"Ignoring {{.User}}."},
+			"ignore.removed":        {"No longer ignoring {{.User}}."},
+			"ignore.not_found":      {"{{.User}} isn't being ignored."},
+			"ignore.configured":     {"{{.User}} is ignored in the config file; change it there."},
+			"roll.usage":            {"Usage: roll <dice>, e.g. roll 4d6kh3+2, roll d20 adv, roll 2d10 # damage"},
+			"roll.invalid":          {"Invalid roll: {{.Error}}"},
+			"roll.result":           {"{{.User}} rolled{{if .Label}} for {{.Label}}{{end}}: {{.Breakdown}} = {{.Total}}"},
+			"remind.usage":          {"Usage: remind me in 45m to stretch, remind #channel at 20:00 raid time, remind list, remind cancel <id>"},
+			"remind.invalid":        {"Invalid reminder: {{.Error}}"},
+			"remind.saved":          {"I'll remind {{if .Self}}you{{else}}the channel{{end}} in {{.In}} (#{{.ID}})."},
+			"remind.too_many":       {"You already have {{.Max}} pending reminders. Cancel one first."},
+			"remind.none":           {"You have no pending reminders."},
+			"remind.list":           {"Your reminders:{{range $i, $r := .Reminders}}{{if $i}};{{end}} {{$r}}{{end}}"},
+			"remind.not_found":      {"There's no reminder {{.ID}}."},
+			"remind.cancel_denied":  {"You can only cancel your own reminders."},
+			"remind.cancelled":      {"Reminder #{{.ID}} cancelled."},
+			"remind.due":            {"{{.Mention}}, reminder: {{.Text}}"},
+			"remind.due_channel":    {"Reminder from {{.Mention}}: {{.Text}}"},
+			"link.no_dm":            {"I can't send you a code privately here. Run link on Discord and enter the code here."},
+			"link.code":             {"Your link code is {{.Code}}. Say \"link {{.Code}}\" on your other platform within {{.Minutes}} minutes."},
+			"link.sent":             {"I've sent you a link code in a direct message."},
+			"link.dm_failed":        {"I couldn't send you a direct message. Allow direct messages and try again."},
+			"link.invalid":          {"That link code is unknown or expired."},
+			"link.same_platform":    {"Use that code on your other platform."},
+			"link.already":          {"This account is already linked. Unlink it first."},
+			"link.platform_taken":   {"You've already linked a {{.Platform}} account. Unlink it first."},
+			"link.linked":           {"Linked:{{range $i, $a := .Accounts}}{{if $i}},{{end}} {{$a}}{{end}}."},
+			"link.none":             {"This account isn't linked."},
+			"link.status":           {"Linked accounts:{{range $i, $a := .Accounts}}{{if $i}},{{end}} {{$a}}{{end}}."},
+			"link.unlinked":         {"Account unlinked."},
+			"gold.usage":            {"Usage: gold, gold top, gold give <user> <amount>"},
+			"gold.balance":          {"{{.User}} has {{.Balance}} gold."},
+			"gold.top_empty":        {"Nobody has any gold yet."},
+			"gold.top":              {"Top balances:{{range $i, $e := .Entries}}{{if $i}},{{end}} {{$e}}{{end}}"},
+			"gold.grant_denied":     {"Only admins can grant gold."},
+			"gold.granted":          {"Granted {{.Amount}} gold to {{.User}}."},
+			"gold.insufficient":     {"You only have {{.Balance}} gold."},
+			"gold.self":             {"You can't give gold to yourself."},
+			"gold.given":            {"{{.User}} gave {{.Amount}} gold to {{.To}}."},
+			"moderation.warning":    {"{{.Mention}}, your message was removed: {{.Reason}}{{if eq .Action \"timeout\"}} (timed out){{end}}."},
+			"permit.usage":          {"Usage: permit <user>"},
+			"permit.granted":        {"{{.User}} may post one link in the next {{.Seconds}} seconds."},
+			"poll.open":             {"Poll: {{.Question}}{{range .Options}} {{.}}{{end}}. Vote with \"vote 1\" to \"vote {{.Max}}\" (or the reactions on Discord) within {{.In}}."},
+			"poll.status":           {"{{.Question}}{{range .Options}} {{.}}{{end}}. {{.Votes}} vote(s) so far, {{.Left}} left."},
+			"poll.none":             {"There is no poll running."},
+			"poll.denied":           {"Only admins can start or end a poll."},
+			"poll.invalid":          {"Invalid poll: {{.Error}}"},
+			"poll.already_open":     {"A poll is already running: {{.Question}}"},
+			"poll.cancelled":        {"The poll was cancelled."},
+			"poll.results":          {"Poll closed: {{.Question}}{{range $i, $r := .Results}}{{if $i}},{{end}} {{$r}}{{end}}. {{if .Tie}}Tie between {{.Winner}}.{{else}}Winner: {{.Winner}}.{{end}}"},
+			"poll.no_votes":         {"Poll closed: {{.Question}} Nobody voted."},
+			"vote.invalid":          {"Pick a number from 1 to {{.Max}}."},
+		},
+	},
+}
+
+// PersonaConfig is the "personas" section of the config file.
+type PersonaConfig struct {
+	// Default is the persona used in channels without an entry in
+	// Channels. Defaults to "dwarf".
+	Default string `mapstructure:"default"`
+
+	// Channels maps Twitch channel names or Discord channel IDs to a
+	// persona name.
+	Channels map[string]string `mapstructure:"channels"`
+
+	// Catalog defines new personas or overrides keys of the built-in ones.
+	Catalog map[string]PersonaEntry `mapstructure:"catalog"`
+}
+
+// PersonaEntry is a single persona: its messages by response ID, each
+// with one or more variants, and the persona to try when a key is missing.
+type PersonaEntry struct {
+	Fallback string              `mapstructure:"fallback"`
+	Messages map[string][]string `mapstructure:"messages"`
+}
+
+type persona struct {
+	fallback string
+	messages map[string][]*template.Template
+}
+
+// Catalog renders chat responses by response ID for the persona assigned
+// to a channel. A key missing from a persona is looked up in its fallback,
+// then the default persona, then the built-in dwarf persona.
+type Catalog struct {
+	defaultPersona string
+	channels       map[string]string
+	personas       map[string]*persona
+	randIntN       func(n int) int
+}
+
+// NewCatalog merges cfg over the built-in personas and validates it:
+// every template must parse and every referenced persona must exist.
+func NewCatalog(cfg PersonaConfig) (*Catalog, error) {
+	entries := make(map[string]PersonaEntry, len(builtinPersonas)+len(cfg.Catalog))
+	for name, e := range builtinPersonas {
+		entries[name] = e
+	}
+	for name, e := range cfg.Catalog {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, fmt.Errorf("persona with empty name")
+		}
+		base, ok := entries[name]
+		if !ok {
+			entries[name] = e
+			continue
+		}
+		// Overriding a built-in persona replaces only the keys given.
+		merged := PersonaEntry{Fallback: base.Fallback, Messages: make(map[string][]string)}
+		for k, v := range base.Messages {
+			merged.Messages[k] = v
+		}
+		for k, v := range e.Messages {
+			merged.Messages[k] = v
+		}
+		if e.Fallback != "" {
+			merged.Fallback = e.Fallback
+		}
+		entries[name] = merged
+	}
+
+	c := &Catalog{
+		defaultPersona: DefaultPersona,
+		channels:       make(map[string]string, len(cfg.Channels)),
+		personas:       make(map[string]*persona, len(entries)),
+		randIntN:       rand.IntN,
+	}
+	for name, e := range entries {
+		p := &persona{
+			fallback: strings.ToLower(strings.TrimSpace(e.Fallback)),
+			messages: make(map[string][]*template.Template, len(e.Messages)),
+		}
+		for key, variants := range e.Messages {
+			key = strings.ToLower(strings.TrimSpace(key))
+			if len(variants) == 0 {
+				return nil, fmt.Errorf("persona %q: message %q has no variants", name, key)
+			}
+			for i, text := range variants {
+				tmpl, err := template.New(key).Parse(text)
+				if err != nil {
+					return nil, fmt.Errorf("persona %q: message %q variant %d: %w", name, key, i+1, err)
+				}
+				p.messages[key] = append(p.messages[key], tmpl)
+			}
+		}
+		c.personas[name] = p
+	}
+
+	for name, p := range c.personas {
+		if err := c.checkFallbacks(name, p); err != nil {
+			return nil, err
+		}
+	}
+	if d := strings.ToLower(strings.TrimSpace(cfg.Default)); d != "" {
+		if _, ok := c.personas[d]; !ok {
+			return nil, fmt.Errorf("default persona %q is not defined", d)
+		}
+		c.defaultPersona = d
+	}
+	for ch, name := range cfg.Channels {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := c.personas[name]; !ok {
+			return nil, fmt.Errorf("channel %q: persona %q is not defined", ch, name)
+		}
+		c.channels[normalizeCatalogChannel(ch)] = name
+	}
+	return c, nil
+}
+
+// checkFallbacks rejects fallbacks to undefined personas and cycles.
+func (c *Catalog) checkFallbacks(name string, p *persona) error {
+	seen := map[string]bool{name: true}
+	for p.fallback != "" {
+		next, ok := c.personas[p.fallback]
+		if !ok {
+			return fmt.Errorf("persona %q: fallback %q is not defined", name, p.fallback)
+		}
+		if seen[p.fallback] {
+			return fmt.Errorf("persona %q: fallback cycle through %q", name, p.fallback)
+		}
+		seen[p.fallback] = true
+		p = next
+	}
+	return nil
+}
+
+// Personas returns the names of all defined personas, sorted.
+func (c *Catalog) Personas() []string {
+	out := make([]string, 0, len(c.personas))
+	for name := range c.personas {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// PersonaFor returns the persona assigned to the channel.
+func (c *Catalog) PersonaFor(channel string) string {
+	if name, ok := c.channels[normalizeCatalogChannel(channel)]; ok {
+		return name
+	}
+	return c.defaultPersona
+}
+
+// Render returns a random variant of key for the channel's persona,
+// walking the fallback chain until a variant renders. If no persona
+// defines the key, the key itself is returned so the gap is visible.
+func (c *Catalog) Render(channel, key string, vars Vars) string {
+	return c.renderAs(c.PersonaFor(channel), key, vars)
+}
+
+// HasPersona reports whether name is a defined persona.
+func (c *Catalog) HasPersona(name string) bool {
+	_, ok := c.personas[strings.ToLower(strings.TrimSpace(name))]
+	return ok
+}
+
+// renderAs renders key starting at the named persona.
+func (c *Catalog) renderAs(persona, key string, vars Vars) string {
+	for _, name := range c.chain(persona) {
+		variants := c.personas[name].messages[key]
+		if len(variants) == 0 {
+			continue
+		}
+		tmpl := variants[c.randIntN(len(variants))]
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, vars); err != nil {
+			log.Printf("persona %q: rendering %q: %v", name, key, err)
+			continue
+		}
+		return buf.String()
+	}
+	log.Printf("No persona defines message %q", key)
+	return key
+}
+
+// chain lists the personas to try, in order, starting at name.
+func (c *Catalog) chain(name string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(n string) {
+		for n != "" && !seen[n] {
+			p, ok := c.personas[n]
+			if !ok {
+				return
+			}
+			seen[n] = true
+			out = append(out, n)
+			n = p.fallback
+		}
+	}
+	add(name)
+	add(c.defaultPersona)
+	add(DefaultPersona)
+	return out
+}
+
+func normalizeCatalogChannel(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+}
+
+var (
+	catalog   = mustBuiltinCatalog()
+	catalogMu sync.RWMutex
+)
+
+func mustBuiltinCatalog() *Catalog {
+	c, err := NewCatalog(PersonaConfig{})
+	if err != nil {
+		panic(fmt.Sprintf("built-in message catalog: %v", err))
+	}
+	return c
+}
+
+// SetCatalog replaces the catalog used for chat responses. A nil catalog
+// restores the built-in personas.
+func SetCatalog(c *Catalog) {
+	if c == nil {
+		c = mustBuiltinCatalog()
+	}
+	catalogMu.Lock()
+	defer catalogMu.Unlock()
+	catalog = c
+}
+
+// Say renders the response key for the given channel using the current
+// catalog. A persona set in the channel's settings wins over the
+// catalog's own channel mapping.
+func Say(channel, key string, vars Vars) string {
+	catalogMu.RLock()
+	c := catalog
+	catalogMu.RUnlock()
+	if p := ChannelSettingsFor(channel).Persona; p != "" {
+		return c.renderAs(p, key, vars)
+	}
+	return c.Render(channel, key, vars)
+}
@@ -0,0 +1,148 @@
+package dwarfbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCatalog_BuiltinDwarf(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	if got := c.Render("chan", "shutdown.ack", nil); got != "Yah, boss! Shuttin' 'er doon!" {
+		t.Errorf("unexpected shutdown ack %q", got)
+	}
+	got := c.Render("chan", "channels.list", Vars{"Bot": "bot", "Channels": []string{"a", "b"}})
+	if got != "Aye, I like ta hang about here: bot a b" {
+		t.Errorf("unexpected channels list %q", got)
+	}
+}
+
+func TestCatalog_PersonaPerChannel(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{
+		Channels: map[string]string{"#Plainchan": "plain", "12345": "plain"},
+	})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	if got := c.Render("plainchan", "ping.pong", nil); got != "Pong!" {
+		t.Errorf("expected plain persona on Twitch channel, got %q", got)
+	}
+	if got := c.Render("12345", "ping.pong", nil); got != "Pong!" {
+		t.Errorf("expected plain persona on Discord channel, got %q", got)
+	}
+	if got := c.Render("other", "ping.pong", nil); !strings.Contains(got, "Atari") {
+		t.Errorf("expected dwarf persona elsewhere, got %q", got)
+	}
+}
+
+func TestCatalog_FallbackChain(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{
+		Default: "plain",
+		Catalog: map[string]PersonaEntry{
+			"pirate":  {Fallback: "plain", Messages: map[string][]string{"ping.pong": {"Arr, pong!"}}},
+			"deutsch": {Fallback: "pirate", Messages: map[string][]string{"ping.heyo": {"Hallo!"}}},
+		},
+		Channels: map[string]string{"de": "deutsch"},
+	})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"ping.heyo", "Hallo!"},
+		{"ping.pong", "Arr, pong!"},
+		{"shutdown.ack", "Shutting down."},
+		{"mqtt.not_configured", "MQTT bridge is not configured"},
+		{"no.such.key", "no.such.key"},
+	}
+	for _, tt := range tests {
+		if got := c.Render("de", tt.key, nil); got != tt.want {
+			t.Errorf("Render(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestCatalog_OverrideBuiltinKeepsOtherKeys(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{
+		Catalog: map[string]PersonaEntry{
+			"dwarf": {Messages: map[string][]string{"ping.pong": {"Pong, lad."}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	if got := c.Render("chan", "ping.pong", nil); got != "Pong, lad." {
+		t.Errorf("expected override, got %q", got)
+	}
+	if got := c.Render("chan", "ping.heyo", nil); got != "Heyo, yourself boy-o!" {
+		t.Errorf("expected built-in key to survive override, got %q", got)
+	}
+}
+
+func TestCatalog_RandomVariants(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{
+		Catalog: map[string]PersonaEntry{
+			"dwarf": {Messages: map[string][]string{"ping.pong": {"one", "two", "three"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	c.randIntN = func(n int) int { return n - 1 }
+	if got := c.Render("chan", "ping.pong", nil); got != "three" {
+		t.Errorf("expected last variant, got %q", got)
+	}
+}
+
+func TestNewCatalog_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  PersonaConfig
+	}{
+		{"unknown default", PersonaConfig{Default: "elf"}},
+		{"unknown channel persona", PersonaConfig{Channels: map[string]string{"chan": "elf"}}},
+		{"unknown fallback", PersonaConfig{Catalog: map[string]PersonaEntry{"a": {Fallback: "elf"}}}},
+		{"fallback cycle", PersonaConfig{Catalog: map[string]PersonaEntry{
+			"a": {Fallback: "b"},
+			"b": {Fallback: "a"},
+		}}},
+		{"bad template", PersonaConfig{Catalog: map[string]PersonaEntry{
+			"a": {Messages: map[string][]string{"ping.pong": {"{{.Oops"}}},
+		}}},
+		{"no variants", PersonaConfig{Catalog: map[string]PersonaEntry{
+			"a": {Messages: map[string][]string{"ping.pong": {}}},
+		}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewCatalog(tt.cfg); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestSetCatalog_UsedByCommands(t *testing.T) {
+	c, err := NewCatalog(PersonaConfig{Default: "plain"})
+	if err != nil {
+		t.Fatalf("NewCatalog: %v", err)
+	}
+	SetCatalog(c)
+	defer SetCatalog(nil)
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	if err := parseCommand(context.Background(), mock, "chan", "viewer", "ping", nil); err != nil {
+		t.Fatalf("parseCommand: %v", err)
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != "Pong!" {
+		t.Errorf("expected plain pong, got %v", mock.messages)
+	}
+
+	SetCatalog(nil)
+	if got := Say("chan", "ping.pong", nil); !strings.Contains(got, "Atari") {
+		t.Errorf("expected nil catalog to restore the dwarf, got %q", got)
+	}
+}
@@ -0,0 +1,107 @@
+package dwarfbot
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChannelConfig overrides the bot's behavior in one Twitch channel or
+// Discord channel. The zero value changes nothing.
+type ChannelConfig struct {
+	// Commands, when set, lists the only commands that run in the
+	// channel. Admin-only commands always run for admins so a channel
+	// can't lock its owner out.
+	Commands []string `mapstructure:"commands"`
+
+	// Persona overrides the persona picked by the "personas" section.
+	Persona string `mapstructure:"persona"`
+
+	// CooldownMultiplier scales auto-responder cooldowns. Zero means 1.
+	CooldownMultiplier float64 `mapstructure:"cooldown_multiplier"`
+
+	// AutoResponders turns triggers off in the channel when false.
+	// Unset means on.
+	AutoResponders *bool `mapstructure:"auto_responders"`
+
+	// Muted stops the bot sending anything to the channel. Commands
+	// still run, so their side effects (saved quotes, counters) happen.
+	Muted bool `mapstructure:"muted"`
+}
+
+// CommandEnabled reports whether cmd may run in the channel.
+func (c ChannelConfig) CommandEnabled(cmd string) bool {
+	if len(c.Commands) == 0 {
+		return true
+	}
+	return contains(c.Commands, strings.ToLower(cmd))
+}
+
+// AutoRespondersEnabled reports whether triggers may fire in the channel.
+func (c ChannelConfig) AutoRespondersEnabled() bool {
+	return c.AutoResponders == nil || *c.AutoResponders
+}
+
+// Cooldown scales d by the channel's cooldown multiplier.
+func (c ChannelConfig) Cooldown(d time.Duration) time.Duration {
+	if c.CooldownMultiplier == 0 {
+		return d
+	}
+	return time.Duration(float64(d) * c.CooldownMultiplier)
+}
+
+// ValidateChannelConfigs checks every override. Personas are checked
+// against catalog.
+func ValidateChannelConfigs(configs map[string]ChannelConfig, catalog *Catalog) error {
+	for ch, c := range configs {
+		if normalizeCatalogChannel(ch) == "" {
+			return fmt.Errorf("channel settings: empty channel name")
+		}
+		for _, cmd := range c.Commands {
+			if strings.TrimSpace(cmd) == "" {
+				return fmt.Errorf("channel settings %s: empty command name", ch)
+			}
+		}
+		if c.Persona != "" && !catalog.HasPersona(c.Persona) {
+			return fmt.Errorf("channel settings %s: persona %q is not defined", ch, c.Persona)
+		}
+		if c.CooldownMultiplier < 0 {
+			return fmt.Errorf("channel settings %s: cooldown_multiplier must be >= 0, got %v", ch, c.CooldownMultiplier)
+		}
+	}
+	return nil
+}
+
+var (
+	channelSettings   map[string]ChannelConfig
+	channelSettingsMu sync.RWMutex
+)
+
+// SetChannelConfigs replaces the per-channel overrides. Keys are Twitch
+// channel names (with or without "#") or Discord channel IDs. Nil
+// clears every override.
+func SetChannelConfigs(configs map[string]ChannelConfig) {
+	normalized := make(map[string]ChannelConfig, len(configs))
+	for ch, c := range configs {
+		cmds := make([]string, len(c.Commands))
+		for i, cmd := range c.Commands {
+			cmds[i] = strings.ToLower(strings.TrimSpace(cmd))
+		}
+		c.Commands = cmds
+		c.Persona = strings.ToLower(strings.TrimSpace(c.Persona))
+		normalized[normalizeCatalogChannel(ch)] = c
+	}
+
+	channelSettingsMu.Lock()
+	defer channelSettingsMu.Unlock()
+	channelSettings = normalized
+}
+
+// ChannelSettingsFor returns the overrides for channel, or the zero
+// ChannelConfig if it has none.
+func ChannelSettingsFor(channel string) ChannelConfig {
+	channelSettingsMu.RLock()
+	defer channelSettingsMu.RUnlock()
+	return channelSettings[normalizeCatalogChannel(channel)]
+}
@@ -0,0 +1,127 @@
+package dwarfbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestValidateChannelConfigs(t *testing.T) {
+	catalog := mustBuiltinCatalog()
+	tests := []struct {
+		name    string
+		configs map[string]ChannelConfig
+	}{
+		{"empty channel", map[string]ChannelConfig{"#": {Muted: true}}},
+		{"empty command", map[string]ChannelConfig{"chan": {Commands: []string{"ping", " "}}}},
+		{"unknown persona", map[string]ChannelConfig{"chan": {Persona: "elf"}}},
+		{"negative multiplier", map[string]ChannelConfig{"chan": {CooldownMultiplier: -1}}},
+	}
+	for _, tt := range tests {
+		if err := ValidateChannelConfigs(tt.configs, catalog); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	valid := map[string]ChannelConfig{"chan": {Commands: []string{"ping"}, Persona: "Plain", CooldownMultiplier: 2}}
+	if err := ValidateChannelConfigs(valid, catalog); err != nil {
+		t.Errorf("expected valid settings, got %v", err)
+	}
+}
+
+func TestChannelSettings_EnabledCommands(t *testing.T) {
+	defer ResetRegistrations()
+	defer SetChannelConfigs(nil)
+	RegisterCommand("quote", func(req CommandRequest) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, "a quote")
+	})
+	RegisterAdminCommand("restart", func(req CommandRequest) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, "restarting")
+	})
+	SetChannelConfigs(map[string]ChannelConfig{"#Quiet": {Commands: []string{"PING"}}})
+	mock := newMockPlatformWithAdmin("testbot", []string{"quiet", "loud"}, adminIs("boss"))
+
+	for _, cmd := range []string{"ping", "quote", "restart"} {
+		if err := parseCommand(context.Background(), mock, "quiet", "viewer", cmd, nil); err != nil {
+			t.Fatalf("parseCommand %s: %v", cmd, err)
+		}
+	}
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected only ping to run in quiet, got %v", mock.messages)
+	}
+	if err := parseCommand(context.Background(), mock, "quiet", "boss", "restart", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.messages) != 2 || mock.messages[1].msg != "restarting" {
+		t.Errorf("expected admin-only command to run for admins, got %v", mock.messages)
+	}
+	if err := parseCommand(context.Background(), mock, "loud", "viewer", "quote", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(mock.messages) != 3 {
+		t.Errorf("expected quote to run in other channels, got %v", mock.messages)
+	}
+}
+
+func TestChannelSettings_Persona(t *testing.T) {
+	defer SetChannelConfigs(nil)
+	SetChannelConfigs(map[string]ChannelConfig{"12345": {Persona: "plain"}})
+
+	if got := Say("12345", "ping.pong", nil); got != "Pong!" {
+		t.Errorf("expected plain persona, got %q", got)
+	}
+	if got := Say("other", "ping.pong", nil); got == "Pong!" {
+		t.Errorf("expected default persona elsewhere, got %q", got)
+	}
+}
+
+func TestChannelSettings_Muted(t *testing.T) {
+	defer SetChannelConfigs(nil)
+	SetChannelConfigs(map[string]ChannelConfig{"quiet": {Muted: true}})
+	var sent []string
+	h := outboundPipeline(nil, "testbot", func(_ context.Context, msg OutboundMessage) error {
+		sent = append(sent, msg.Channel)
+		return nil
+	})
+
+	for _, ch := range []string{"quiet", "loud"} {
+		if err := h(context.Background(), OutboundMessage{Platform: "twitch", Channel: ch, Text: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(sent) != 1 || sent[0] != "loud" {
+		t.Errorf("expected only loud to be sent to, got %v", sent)
+	}
+}
+
+func TestChannelSettings_AutoResponders(t *testing.T) {
+	defer SetChannelConfigs(nil)
+	off := false
+	SetChannelConfigs(map[string]ChannelConfig{
+		"chan":  {CooldownMultiplier: 2},
+		"other": {AutoResponders: &off},
+	})
+	tr, mock, now := newTestTriggers(t, []TriggerConfig{{
+		Pattern:   `(?i)^first$`,
+		Responses: []string{"Nae."},
+		Cooldown:  time.Minute,
+	}})
+
+	other := chat("first")
+	other.Channel = "other"
+	handleTrigger(tr, other)
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected auto-responders off in other, got %v", mock.messages)
+	}
+
+	handleTrigger(tr, chat("first"))
+	*now = now.Add(time.Minute)
+	handleTrigger(tr, chat("first"))
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected doubled cooldown to suppress the response, got %v", mock.messages)
+	}
+	*now = now.Add(time.Minute)
+	handleTrigger(tr, chat("first"))
+	if len(mock.messages) != 2 {
+		t.Errorf("expected a response after the doubled cooldown, got %v", mock.messages)
+	}
+}
@@ -25,14 +25,16 @@ SOFTWARE.
 package dwarfbot
 
 import (
-	"fmt"
+	"context"
+	"dwarfbot/pkg/events"
 	"log"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
-type MQTTHandlerFunc func(channelName string, platform ChatPlatform, arguments []string)
+type MQTTHandlerFunc func(ctx context.Context, channelName string, platform ChatPlatform, arguments []string)
 
 var (
 	mqttHandler   MQTTHandlerFunc
@@ -51,21 +53,85 @@ func getMQTTHandler() MQTTHandlerFunc {
 	return mqttHandler
 }
 
-func parseAdminCommand(platform ChatPlatform, channelName string, cmd string, arguments []string) error {
+// CommandRequest describes a single invocation of a registered command.
+type CommandRequest struct {
+	// Context carries the dispatcher's deadline and is cancelled on
+	// shutdown. Handlers that reply after returning must use their own.
+	Context context.Context
+
+	Platform     ChatPlatform
+	PlatformName string
+	Channel      string
+	User         string
+	UserName     string
+	Admin        bool
+	Command      string
+	Arguments    []string
+
+	// Identity is the author's linked identity (see Identities), or
+	// their own AccountKey if they haven't linked accounts. Per-user
+	// state should be keyed by it.
+	Identity string
+}
+
+// CommandHandlerFunc handles a registered command.
+type CommandHandlerFunc func(req CommandRequest) error
+
+type registeredCommand struct {
+	adminOnly bool
+	handler   CommandHandlerFunc
+}
+
+var (
+	commandRegistry   = map[string]registeredCommand{}
+	commandRegistryMu sync.RWMutex
+)
+
+// RegisterCommand makes a command available to every user. Registering
+// a nil handler removes the command.
+func RegisterCommand(name string, handler CommandHandlerFunc) {
+	registerCommand(name, false, handler)
+}
+
+// RegisterAdminCommand makes a command available to admins only.
+// Registering a nil handler removes the command.
+func RegisterAdminCommand(name string, handler CommandHandlerFunc) {
+	registerCommand(name, true, handler)
+}
+
+func registerCommand(name string, adminOnly bool, handler CommandHandlerFunc) {
+	name = strings.ToLower(name)
+	commandRegistryMu.Lock()
+	defer commandRegistryMu.Unlock()
+	if handler == nil {
+		delete(commandRegistry, name)
+		return
+	}
+	commandRegistry[name] = registeredCommand{adminOnly: adminOnly, handler: handler}
+}
+
+func getCommand(name string) (registeredCommand, bool) {
+	commandRegistryMu.RLock()
+	defer commandRegistryMu.RUnlock()
+	c, ok := commandRegistry[name]
+	return c, ok
+}
+
+func parseAdminCommand(ctx context.Context, platform ChatPlatform, channelName string, cmd string, arguments []string) error {
 	switch cmd {
 	case "shutdown":
-		if err := platform.SendMessage(channelName, "Yah, boss! Shuttin' 'er doon!"); err != nil {
+		if err := platform.SendMessage(ctx, channelName, Say(channelName, "shutdown.ack", nil)); err != nil {
 			log.Printf("failed to send shutdown message to channel %s: %v", channelName, err)
 		}
-		platform.Shutdown(0)
+		platform.Shutdown(ctx, 0)
 		return nil
 	case "mqtt":
 		handler := getMQTTHandler()
 		if handler == nil {
-			_ = platform.SendMessage(channelName, "MQTT bridge is not configured")
+			_ = platform.SendMessage(ctx, channelName, Say(channelName, "mqtt.not_configured", nil))
 			return nil
 		}
-		handler(channelName, platform, arguments)
+		handler(ctx, channelName, platform, arguments)
 		return nil
 	}
 	return nil
@@ -75,36 +141,142 @@ func parseAdminCommand(platform ChatPlatform, channelName string, cmd string, ar
 type parseCommandOpts struct {
 	metrics      PlatformMetrics
 	platformName string
+	acl          *ACL
+	audit        AuditSink
+	identities   *Identities
+
+	// displayName is the author's human-readable name. Defaults to
+	// userName when empty (Twitch logins are already readable).
+	displayName string
+
+	// allowBots lists bot accounts whose commands dispatchInbound runs.
+	allowBots []string
 }
 
-func parseCommand(platform ChatPlatform, channelName string, userName string, cmd string, arguments []string, opts ...parseCommandOpts) error {
-	isAdmin := platform.IsAdmin(channelName, userName)
+// parseCommand dispatches one command. ctx is the platform's base
+// context; every call the command makes on the platform shares a
+// commandTimeout deadline derived from it.
+func parseCommand(ctx context.Context, platform ChatPlatform, channelName string, userName string, cmd string, arguments []string, opts ...parseCommandOpts) (err error) {
+	var o parseCommandOpts
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	displayName := o.displayName
+	if displayName == "" {
+		displayName = userName
+	}
+
+	isAdmin := platform.IsAdmin(ctx, channelName, userName)
+	identity := o.identities.Resolve(o.platformName, userName)
+
+	// Audit every command an admin runs, and attempts at admin-only
+	// commands by anyone else. auditResult overrides the outcome.
+	var auditResult string
+	if o.audit != nil && isKnownCommand(cmd) && (isAdmin || isAdminOnlyCommand(cmd)) {
+		defer func() {
+			result := auditResult
+			switch {
+			case result != "":
+			case err != nil:
+				result = "error: " + err.Error()
+			default:
+				result = "ok"
+			}
+			o.audit.RecordAudit(AuditEvent{
+				Time:     time.Now().UTC(),
+				Platform: o.platformName,
+				Channel:  channelName,
+				UserID:   userName,
+				UserName: displayName,
+				Command:  cmd,
+				Args:     arguments,
+				Result:   result,
+			})
+		}()
+	}
+
+	if isKnownCommand(cmd) {
+		if !ChannelSettingsFor(channelName).CommandEnabled(cmd) && !(isAdmin && isAdminOnlyCommand(cmd)) {
+			log.Printf("Command %q is disabled in channel %s", cmd, channelName)
+			auditResult = AuditDeniedChannel
+			return nil
+		}
+		role := RoleUser
+		if isAdmin {
+			role = RoleAdmin
+		}
+		req := ACLRequest{Platform: o.platformName, Channel: channelName, User: userName, Identity: identity, Role: role, Command: cmd}
+		if !o.acl.Allowed(req) {
+			log.Printf("ACL denied %q for user %s in channel %s on %s", cmd, userName, channelName, o.platformName)
+			if o.metrics != nil {
+				o.metrics.RecordCommandDenied(o.platformName, cmd)
+			}
+			auditResult = AuditDeniedACL
+			return platform.SendMessage(ctx, channelName, Say(channelName, "acl.denied", nil))
+		}
+		defer func() {
+			events.Publish(events.Default, events.CommandExecuted{
+				Platform: o.platformName,
+				Channel:  channelName,
+				UserID:   userName,
+				UserName: displayName,
+				Command:  cmd,
+				Args:     arguments,
+				Admin:    isAdmin,
+				Err:      err,
+			})
+		}()
+	}
+
 	if isAdmin {
 		log.Printf("Received orders from the boss...")
-		if err := parseAdminCommand(platform, channelName, cmd, arguments); err != nil {
+		if err := parseAdminCommand(ctx, platform, channelName, cmd, arguments); err != nil {
 			return err
 		}
+	} else if builtinAdminCommands[cmd] {
+		auditResult = AuditDeniedNotAdmin
 	}
 
-	if len(opts) > 0 && opts[0].metrics != nil {
+	if o.metrics != nil {
 		adminStr := "false"
 		if isAdmin {
 			adminStr = "true"
 		}
-		opts[0].metrics.RecordCommandProcessed(opts[0].platformName, normalizeCommandLabel(cmd), adminStr)
+		o.metrics.RecordCommandProcessed(o.platformName, normalizeCommandLabel(cmd), adminStr)
 	}
 
 	switch cmd {
 	case "ping":
-		return ping(platform, channelName, arguments)
+		return ping(ctx, platform, channelName, arguments)
 	case "channels":
-		return channels(platform, channelName, arguments)
+		return channels(ctx, platform, channelName, arguments)
+	}
+
+	if c, ok := getCommand(cmd); ok {
+		if c.adminOnly && !isAdmin {
+			auditResult = AuditDeniedNotAdmin
+			return nil
+		}
+		return c.handler(CommandRequest{
+			Context:      ctx,
+			Platform:     platform,
+			PlatformName: o.platformName,
+			Channel:      channelName,
+			User:         userName,
+			UserName:     displayName,
+			Admin:        isAdmin,
+			Identity:     identity,
+			Command:      cmd,
+			Arguments:    arguments,
+		})
 	}
 
 	return nil
 }
 
-func ping(platform ChatPlatform, channelName string, arguments []string) error {
+func ping(ctx context.Context, platform ChatPlatform, channelName string, arguments []string) error {
 	re := regexp.MustCompile(`(?i)heyo.+`)
 
 	lowerArgs := make([]string, len(arguments))
@@ -114,11 +286,11 @@ func ping(platform ChatPlatform, channelName string, arguments []string) error {
 
 	switch {
 	case contains(lowerArgs, "heyo"):
-		return platform.SendMessage(channelName, "Heyo, yourself boy-o!")
+		return platform.SendMessage(ctx, channelName, Say(channelName, "ping.heyo", nil))
 	case reContains(lowerArgs, re):
-		return platform.SendMessage(channelName, "Heyo, yourself boy-o!")
+		return platform.SendMessage(ctx, channelName, Say(channelName, "ping.heyo", nil))
 	default:
-		return platform.SendMessage(channelName, "Ach! I dunnae own 'n Atari, but nevertheless: \"Pong\"")
+		return platform.SendMessage(ctx, channelName, Say(channelName, "ping.pong", nil))
 	}
 }
 
@@ -149,17 +321,38 @@ var knownCommands = map[string]bool{
 	"mqtt":     true,
 }
 
-func normalizeCommandLabel(cmd string) string {
+// builtinAdminCommands are the built-in commands only admins may run.
+var builtinAdminCommands = map[string]bool{
+	"shutdown": true,
+	"mqtt":     true,
+}
+
+// isAdminOnlyCommand reports whether cmd is restricted to admins.
+func isAdminOnlyCommand(cmd string) bool {
+	if builtinAdminCommands[cmd] {
+		return true
+	}
+	c, ok := getCommand(cmd)
+	return ok && c.adminOnly
+}
+
+// isKnownCommand reports whether cmd is a built-in or registered command.
+func isKnownCommand(cmd string) bool {
 	if knownCommands[cmd] {
+		return true
+	}
+	_, ok := getCommand(cmd)
+	return ok
+}
+
+func normalizeCommandLabel(cmd string) string {
+	if isKnownCommand(cmd) {
 		return cmd
 	}
 	return "unknown"
 }
 
-func channels(platform ChatPlatform, channelName string, arguments []string) error {
-	msg := fmt.Sprintf("Aye, I like ta hang about here: %s", platform.BotName())
-	for _, channel := range platform.BotChannels() {
-		msg = msg + fmt.Sprintf(" %s", channel)
-	}
-	return platform.SendMessage(channelName, msg)
+func channels(ctx context.Context, platform ChatPlatform, channelName string, arguments []string) error {
+	msg := Say(channelName, "channels.list", Vars{"Bot": platform.BotName(), "Channels": platform.BotChannels()})
+	return platform.SendMessage(ctx, channelName, msg)
 }
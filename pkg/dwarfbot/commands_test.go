@@ -1,6 +1,8 @@
 package dwarfbot
 
 import (
+	"context"
+	"errors"
 	"net"
 	"regexp"
 	"strings"
@@ -123,7 +125,7 @@ func TestPing_Default(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = ping(bot, "testchannel", []string{})
+		_ = ping(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	got := readFromConn(t, server)
@@ -140,7 +142,7 @@ func TestPing_Heyo(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = ping(bot, "testchannel", []string{"heyo"})
+		_ = ping(context.Background(), bot, "testchannel", []string{"heyo"})
 	}()
 
 	got := readFromConn(t, server)
@@ -154,7 +156,7 @@ func TestPing_HeyoExtended(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = ping(bot, "testchannel", []string{"heyooo"})
+		_ = ping(context.Background(), bot, "testchannel", []string{"heyooo"})
 	}()
 
 	got := readFromConn(t, server)
@@ -168,7 +170,7 @@ func TestPing_HeyoAmongOtherArgs(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = ping(bot, "testchannel", []string{"something", "heyo"})
+		_ = ping(context.Background(), bot, "testchannel", []string{"something", "heyo"})
 	}()
 
 	got := readFromConn(t, server)
@@ -182,7 +184,7 @@ func TestPing_NonHeyoArgs(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = ping(bot, "testchannel", []string{"something", "else"})
+		_ = ping(context.Background(), bot, "testchannel", []string{"something", "else"})
 	}()
 
 	got := readFromConn(t, server)
@@ -197,7 +199,7 @@ func TestPing_ReturnsNil(t *testing.T) {
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- ping(bot, "testchannel", []string{})
+		errCh <- ping(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	readFromConn(t, server)
@@ -213,7 +215,7 @@ func TestChannels(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = channels(bot, "testchannel", []string{})
+		_ = channels(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	got := readFromConn(t, server)
@@ -242,7 +244,7 @@ func TestChannels_NoExtra(t *testing.T) {
 	}()
 
 	go func() {
-		_ = channels(bot, "testchannel", []string{})
+		_ = channels(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
@@ -260,7 +262,7 @@ func TestChannels_ReturnsNil(t *testing.T) {
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- channels(bot, "testchannel", []string{})
+		errCh <- channels(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	readFromConn(t, server)
@@ -274,7 +276,7 @@ func TestChannels_MessageFormat(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = channels(bot, "testchannel", []string{})
+		_ = channels(context.Background(), bot, "testchannel", []string{})
 	}()
 
 	got := readFromConn(t, server)
@@ -290,7 +292,7 @@ func TestParseCommand_Ping(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseCommand(bot, "testchannel", "someuser", "ping", []string{})
+		_ = parseCommand(context.Background(), bot, "testchannel", "someuser", "ping", []string{})
 	}()
 
 	got := readFromConn(t, server)
@@ -304,7 +306,7 @@ func TestParseCommand_Channels(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseCommand(bot, "testchannel", "someuser", "channels", []string{})
+		_ = parseCommand(context.Background(), bot, "testchannel", "someuser", "channels", []string{})
 	}()
 
 	got := readFromConn(t, server)
@@ -318,7 +320,7 @@ func TestParseCommand_Unknown(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseCommand(bot, "testchannel", "someuser", "unknowncmd", []string{})
+		_ = parseCommand(context.Background(), bot, "testchannel", "someuser", "unknowncmd", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
@@ -335,7 +337,7 @@ func TestParseCommand_ReturnsNoError(t *testing.T) {
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- parseCommand(bot, "testchannel", "someuser", "ping", []string{})
+		errCh <- parseCommand(context.Background(), bot, "testchannel", "someuser", "ping", []string{})
 	}()
 
 	readFromConn(t, server)
@@ -353,7 +355,7 @@ func TestParseCommand_AdminFromOwner(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseCommand(bot, "owneruser", "owneruser", "shutdown", []string{})
+		_ = parseCommand(context.Background(), bot, "owneruser", "owneruser", "shutdown", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
@@ -378,7 +380,7 @@ func TestParseCommand_AdminFromNonOwner(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseCommand(bot, "owneruser", "regularuser", "shutdown", []string{})
+		_ = parseCommand(context.Background(), bot, "owneruser", "regularuser", "shutdown", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
@@ -399,7 +401,7 @@ func TestParseAdminCommand_Shutdown(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseAdminCommand(bot, "testchannel", "shutdown", []string{})
+		_ = parseAdminCommand(context.Background(), bot, "testchannel", "shutdown", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
@@ -423,7 +425,7 @@ func TestParseAdminCommand_ShutdownReturnsNil(t *testing.T) {
 
 	errCh := make(chan error, 1)
 	go func() {
-		errCh <- parseAdminCommand(bot, "testchannel", "shutdown", []string{})
+		errCh <- parseAdminCommand(context.Background(), bot, "testchannel", "shutdown", []string{})
 	}()
 
 	readFromConn(t, server)
@@ -437,7 +439,7 @@ func TestParseAdminCommand_UnknownCommand(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = parseAdminCommand(bot, "testchannel", "unknownadmin", []string{})
+		_ = parseAdminCommand(context.Background(), bot, "testchannel", "unknownadmin", []string{})
 	}()
 
 	_ = server.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
@@ -452,7 +454,7 @@ func TestParseAdminCommand_UnknownReturnsNilError(t *testing.T) {
 	bot, _, cleanup := newTestBot(t)
 	defer cleanup()
 
-	err := parseAdminCommand(bot, "testchannel", "nonexistent", []string{})
+	err := parseAdminCommand(context.Background(), bot, "testchannel", "nonexistent", []string{})
 	if err != nil {
 		t.Errorf("expected nil error for unknown admin command, got %v", err)
 	}
@@ -465,7 +467,7 @@ func TestParseAdminCommand_MQTT_NoHandler(t *testing.T) {
 	defer RegisterMQTTHandler(nil)
 
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	err := parseAdminCommand(mock, "ch1", "mqtt", []string{"status"})
+	err := parseAdminCommand(context.Background(), mock, "ch1", "mqtt", []string{"status"})
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
@@ -480,14 +482,14 @@ func TestParseAdminCommand_MQTT_NoHandler(t *testing.T) {
 func TestParseAdminCommand_MQTT_WithHandler(t *testing.T) {
 	var handlerCalled bool
 	var receivedArgs []string
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		handlerCalled = true
 		receivedArgs = arguments
 	})
 	defer RegisterMQTTHandler(nil)
 
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	err := parseAdminCommand(mock, "ch1", "mqtt", []string{"on"})
+	err := parseAdminCommand(context.Background(), mock, "ch1", "mqtt", []string{"on"})
 	if err != nil {
 		t.Errorf("expected nil error, got %v", err)
 	}
@@ -501,13 +503,13 @@ func TestParseAdminCommand_MQTT_WithHandler(t *testing.T) {
 
 func TestParseAdminCommand_MQTT_StatusArgs(t *testing.T) {
 	var receivedArgs []string
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		receivedArgs = arguments
 	})
 	defer RegisterMQTTHandler(nil)
 
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseAdminCommand(mock, "ch1", "mqtt", []string{"status"})
+	_ = parseAdminCommand(context.Background(), mock, "ch1", "mqtt", []string{"status"})
 	if len(receivedArgs) != 1 || receivedArgs[0] != "status" {
 		t.Errorf("expected args [status], got %v", receivedArgs)
 	}
@@ -515,13 +517,13 @@ func TestParseAdminCommand_MQTT_StatusArgs(t *testing.T) {
 
 func TestParseAdminCommand_MQTT_EmptyArgs(t *testing.T) {
 	var receivedArgs []string
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		receivedArgs = arguments
 	})
 	defer RegisterMQTTHandler(nil)
 
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseAdminCommand(mock, "ch1", "mqtt", []string{})
+	_ = parseAdminCommand(context.Background(), mock, "ch1", "mqtt", []string{})
 	if len(receivedArgs) != 0 {
 		t.Errorf("expected empty args, got %v", receivedArgs)
 	}
@@ -529,7 +531,7 @@ func TestParseAdminCommand_MQTT_EmptyArgs(t *testing.T) {
 
 func TestParseCommand_AdminMQTT(t *testing.T) {
 	var handlerCalled bool
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		handlerCalled = true
 	})
 	defer RegisterMQTTHandler(nil)
@@ -537,7 +539,7 @@ func TestParseCommand_AdminMQTT(t *testing.T) {
 	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool {
 		return user == "admin"
 	})
-	_ = parseCommand(mock, "ch1", "admin", "mqtt", []string{"on"})
+	_ = parseCommand(context.Background(), mock, "ch1", "admin", "mqtt", []string{"on"})
 	if !handlerCalled {
 		t.Error("expected MQTT handler to be called via parseCommand for admin")
 	}
@@ -545,7 +547,7 @@ func TestParseCommand_AdminMQTT(t *testing.T) {
 
 func TestParseCommand_NonAdminMQTT(t *testing.T) {
 	handlerCalled := false
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		handlerCalled = true
 	})
 	defer RegisterMQTTHandler(nil)
@@ -553,7 +555,7 @@ func TestParseCommand_NonAdminMQTT(t *testing.T) {
 	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool {
 		return false
 	})
-	_ = parseCommand(mock, "ch1", "regular", "mqtt", []string{"on"})
+	_ = parseCommand(context.Background(), mock, "ch1", "regular", "mqtt", []string{"on"})
 	if handlerCalled {
 		t.Error("expected MQTT handler NOT to be called for non-admin")
 	}
@@ -566,7 +568,7 @@ func TestRegisterMQTTHandler_GetMQTTHandler(t *testing.T) {
 	}
 
 	called := false
-	RegisterMQTTHandler(func(channelName string, platform ChatPlatform, arguments []string) {
+	RegisterMQTTHandler(func(_ context.Context, channelName string, platform ChatPlatform, arguments []string) {
 		called = true
 	})
 	defer RegisterMQTTHandler(nil)
@@ -575,7 +577,7 @@ func TestRegisterMQTTHandler_GetMQTTHandler(t *testing.T) {
 	if h == nil {
 		t.Fatal("expected non-nil handler")
 	}
-	h("ch", nil, nil)
+	h(context.Background(), "ch", nil, nil)
 	if !called {
 		t.Error("expected handler to be called")
 	}
@@ -586,3 +588,76 @@ func TestNormalizeCommandLabel_MQTT(t *testing.T) {
 		t.Errorf("expected 'mqtt', got %q", got)
 	}
 }
+
+// --- Command registry tests ---
+
+func TestRegisterCommand_Dispatch(t *testing.T) {
+	var got CommandRequest
+	RegisterCommand("Greet", func(req CommandRequest) error {
+		got = req
+		return nil
+	})
+	defer RegisterCommand("greet", nil)
+
+	mock := newMockPlatform("testbot", []string{"ch1"})
+	_ = parseCommand(context.Background(), mock, "ch1", "someuser", "greet", []string{"a"}, parseCommandOpts{platformName: "discord"})
+
+	if got.Channel != "ch1" || got.User != "someuser" || got.PlatformName != "discord" || got.Command != "greet" {
+		t.Errorf("unexpected request %+v", got)
+	}
+	if len(got.Arguments) != 1 || got.Arguments[0] != "a" {
+		t.Errorf("expected arguments [a], got %v", got.Arguments)
+	}
+	if normalizeCommandLabel("greet") != "greet" {
+		t.Error("expected registered command to be a known metrics label")
+	}
+}
+
+func TestRegisterCommand_NilRemoves(t *testing.T) {
+	RegisterCommand("temp", func(req CommandRequest) error { return nil })
+	RegisterCommand("temp", nil)
+	if _, ok := getCommand("temp"); ok {
+		t.Error("expected nil handler to remove command")
+	}
+	if normalizeCommandLabel("temp") != "unknown" {
+		t.Error("expected removed command to be unknown")
+	}
+}
+
+func TestParseCommand_HandlerGetsDeadline(t *testing.T) {
+	defer ResetRegistrations()
+	var got context.Context
+	RegisterCommand("probe", func(req CommandRequest) error {
+		got = req.Context
+		return nil
+	})
+	mock := newMockPlatform("testbot", []string{"ch"})
+	if err := parseCommand(context.Background(), mock, "ch", "user", "probe", nil); err != nil {
+		t.Fatal(err)
+	}
+	deadline, ok := got.Deadline()
+	if !ok || time.Until(deadline) > commandTimeout {
+		t.Errorf("expected a deadline within %v, got %v (set: %v)", commandTimeout, deadline, ok)
+	}
+	if got.Err() == nil {
+		t.Error("expected the command context to end when dispatch returns")
+	}
+}
+
+func TestParseCommand_ShutdownCancels(t *testing.T) {
+	defer ResetRegistrations()
+	var got error
+	RegisterCommand("probe", func(req CommandRequest) error {
+		got = req.Context.Err()
+		return nil
+	})
+	lc := NewLifecycle(context.Background())
+	lc.Shutdown(0)
+	mock := newMockPlatform("testbot", []string{"ch"})
+	if err := parseCommand(lc.Context(), mock, "ch", "user", "probe", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(got, context.Canceled) {
+		t.Errorf("expected shutdown to cancel the command context, got %v", got)
+	}
+}
@@ -0,0 +1,312 @@
+package dwarfbot
+
+import (
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// countersBucket is the store bucket counters are kept in.
+const countersBucket = "counters"
+
+// counterNameRegex limits counter names to what cmdRegex can match as a
+// command word.
+var counterNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// CounterMetrics exports counter values without coupling to a specific
+// metrics implementation.
+type CounterMetrics interface {
+	SetCounterValue(name string, value int)
+	DeleteCounterValue(name string)
+}
+
+// Counter is a named, persistent integer such as a death counter.
+type Counter struct {
+	Name      string    `json:"name"`
+	Value     int       `json:"value"`
+	CreatedBy string    `json:"created_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Counters manages named counters. Each counter is registered as its own
+// command, so "!dwarfbot deaths +1" works once a mod has created "deaths".
+type Counters struct {
+	// mu serializes create/delete against command registration.
+	mu      sync.Mutex
+	store   *store.Store
+	metrics CounterMetrics
+	nowFunc func() time.Time
+
+	// registered tracks which counter commands this Counters owns, so
+	// deleting a counter never unregisters someone else's command.
+	registered map[string]bool
+}
+
+// NewCounters returns a Counters backed by s. Metrics may be nil.
+func NewCounters(s *store.Store, metrics CounterMetrics) *Counters {
+	return &Counters{
+		store:      s,
+		metrics:    metrics,
+		nowFunc:    time.Now,
+		registered: map[string]bool{},
+	}
+}
+
+// RegisterCommands registers the "counter" management command and one
+// command per stored counter, and publishes current values to metrics.
+// Call it after every other command is registered so a stored counter
+// can never shadow a real command.
+func (c *Counters) RegisterCommands() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	RegisterCommand("counter", c.HandleManageCommand)
+	counters, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, ctr := range counters {
+		c.publish(ctr)
+		if isKnownCommand(ctr.Name) {
+			log.Printf("Counters: %q clashes with an existing command; read it with: counter list", ctr.Name)
+			continue
+		}
+		RegisterCommand(ctr.Name, c.HandleCounterCommand)
+		c.registered[ctr.Name] = true
+	}
+	return nil
+}
+
+func (c *Counters) publish(ctr Counter) {
+	if c.metrics != nil {
+		c.metrics.SetCounterValue(ctr.Name, ctr.Value)
+	}
+}
+
+// Create adds a new counter starting at zero and registers its command.
+func (c *Counters) Create(name, createdBy string) error {
+	name = strings.ToLower(name)
+	if !counterNameRegex.MatchString(name) {
+		return fmt.Errorf("counter names may only use a-z, 0-9 and _ (max 32)")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if isKnownCommand(name) {
+		return fmt.Errorf("%q is already a command", name)
+	}
+	ctr := Counter{Name: name, CreatedBy: createdBy, UpdatedAt: c.nowFunc()}
+	err := c.store.Update(func(tx *store.Tx) error {
+		var existing Counter
+		if err := tx.Get(countersBucket, name, &existing); err == nil {
+			return fmt.Errorf("counter %q already exists", name)
+		}
+		return tx.Put(countersBucket, name, ctr)
+	})
+	if err != nil {
+		return err
+	}
+	RegisterCommand(name, c.HandleCounterCommand)
+	c.registered[name] = true
+	c.publish(ctr)
+	return nil
+}
+
+// Delete removes a counter and its command.
+func (c *Counters) Delete(name string) error {
+	name = strings.ToLower(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	err := c.store.Update(func(tx *store.Tx) error {
+		var existing Counter
+		if err := tx.Get(countersBucket, name, &existing); err != nil {
+			return err
+		}
+		return tx.Delete(countersBucket, name)
+	})
+	if err != nil {
+		return err
+	}
+	if c.registered[name] {
+		RegisterCommand(name, nil)
+		delete(c.registered, name)
+	}
+	if c.metrics != nil {
+		c.metrics.DeleteCounterValue(name)
+	}
+	return nil
+}
+
+// Get returns the named counter.
+func (c *Counters) Get(name string) (Counter, error) {
+	var ctr Counter
+	err := c.store.Get(countersBucket, strings.ToLower(name), &ctr)
+	return ctr, err
+}
+
+// Add changes a counter by delta and returns the new value.
+func (c *Counters) Add(name string, delta int) (Counter, error) {
+	return c.modify(name, func(v int) int { return v + delta })
+}
+
+// Set assigns a counter's value.
+func (c *Counters) Set(name string, value int) (Counter, error) {
+	return c.modify(name, func(int) int { return value })
+}
+
+func (c *Counters) modify(name string, fn func(int) int) (Counter, error) {
+	name = strings.ToLower(name)
+	var ctr Counter
+	err := c.store.Update(func(tx *store.Tx) error {
+		if err := tx.Get(countersBucket, name, &ctr); err != nil {
+			return err
+		}
+		ctr.Value = fn(ctr.Value)
+		ctr.UpdatedAt = c.nowFunc()
+		return tx.Put(countersBucket, name, ctr)
+	})
+	if err != nil {
+		return Counter{}, err
+	}
+	c.publish(ctr)
+	return ctr, nil
+}
+
+// List returns every counter sorted by name.
+func (c *Counters) List() ([]Counter, error) {
+	var out []Counter
+	err := c.store.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(countersBucket) {
+			var ctr Counter
+			if err := tx.Get(countersBucket, key, &ctr); err != nil {
+				return err
+			}
+			out = append(out, ctr)
+		}
+		return nil
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, err
+}
+
+// HandleManageCommand implements "counter add|del <name>" (admins) and
+// "counter list" (everyone).
+func (c *Counters) HandleManageCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	usage := "Usage: counter list|add <name>|del <name>"
+	if len(req.Arguments) == 0 {
+		return reply(usage)
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "list":
+		counters, err := c.List()
+		if err != nil {
+			return fmt.Errorf("listing counters: %w", err)
+		}
+		if len(counters) == 0 {
+			return reply(Say(req.Channel, "counter.none", nil))
+		}
+		parts := make([]string, 0, len(counters))
+		for _, ctr := range counters {
+			parts = append(parts, fmt.Sprintf("%s: %d", ctr.Name, ctr.Value))
+		}
+		return reply("Counters: " + strings.Join(parts, ", "))
+	case "add", "create":
+		if !req.Admin {
+			return reply(Say(req.Channel, "counter.create_denied", nil))
+		}
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		if err := c.Create(req.Arguments[1], req.UserName); err != nil {
+			return reply(Say(req.Channel, "counter.create_failed", Vars{"Error": err}))
+		}
+		return reply(Say(req.Channel, "counter.created", Vars{"Name": strings.ToLower(req.Arguments[1])}))
+	case "del", "delete":
+		if !req.Admin {
+			return reply(Say(req.Channel, "counter.delete_denied", nil))
+		}
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		if err := c.Delete(req.Arguments[1]); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return reply(Say(req.Channel, "counter.not_found", Vars{"Name": req.Arguments[1]}))
+			}
+			return fmt.Errorf("deleting counter: %w", err)
+		}
+		return reply(Say(req.Channel, "counter.deleted", Vars{"Name": strings.ToLower(req.Arguments[1])}))
+	default:
+		return reply(usage)
+	}
+}
+
+// HandleCounterCommand implements "<name>", "<name> +N", "<name> -N" and
+// "<name> set N". Reading is open to everyone; changes require admin.
+func (c *Counters) HandleCounterCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	name := req.Command
+	usage := fmt.Sprintf("Usage: %s [+N|-N|set N]", name)
+
+	if len(req.Arguments) == 0 {
+		ctr, err := c.Get(name)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return reply(Say(req.Channel, "counter.not_found", Vars{"Name": name}))
+			}
+			return fmt.Errorf("reading counter: %w", err)
+		}
+		return reply(fmt.Sprintf("%s: %d", ctr.Name, ctr.Value))
+	}
+
+	if !req.Admin {
+		return reply(Say(req.Channel, "counter.change_denied", nil))
+	}
+
+	var (
+		ctr Counter
+		err error
+	)
+	arg := req.Arguments[0]
+	switch {
+	case strings.EqualFold(arg, "set"):
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		n, convErr := strconv.Atoi(req.Arguments[1])
+		if convErr != nil {
+			return reply(usage)
+		}
+		ctr, err = c.Set(name, n)
+	case strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-"):
+		n, convErr := strconv.Atoi(arg)
+		if convErr != nil {
+			return reply(usage)
+		}
+		ctr, err = c.Add(name, n)
+	default:
+		return reply(usage)
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return reply(Say(req.Channel, "counter.not_found", Vars{"Name": name}))
+		}
+		return fmt.Errorf("updating counter: %w", err)
+	}
+	return reply(fmt.Sprintf("%s: %d", ctr.Name, ctr.Value))
+}
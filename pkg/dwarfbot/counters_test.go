@@ -0,0 +1,196 @@
+package dwarfbot
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockCounterMetrics records counter gauge updates.
+type mockCounterMetrics struct {
+	mu     sync.Mutex
+	values map[string]int
+}
+
+func newMockCounterMetrics() *mockCounterMetrics {
+	return &mockCounterMetrics{values: map[string]int{}}
+}
+
+func (m *mockCounterMetrics) SetCounterValue(name string, value int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.values[name] = value
+}
+
+func (m *mockCounterMetrics) DeleteCounterValue(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, name)
+}
+
+var _ CounterMetrics = (*mockCounterMetrics)(nil)
+
+func newTestCounters(t *testing.T, path string) (*Counters, *mockCounterMetrics) {
+	t.Helper()
+	m := newMockCounterMetrics()
+	c := NewCounters(openTestStore(t, path), m)
+	if err := c.RegisterCommands(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		counters, _ := c.List()
+		for _, ctr := range counters {
+			RegisterCommand(ctr.Name, nil)
+		}
+		RegisterCommand("counter", nil)
+	})
+	return c, m
+}
+
+func TestCounters_CreateAndModifyViaChat(t *testing.T) {
+	_, m := newTestCounters(t, "")
+	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool { return user == "boss" })
+
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "counter", []string{"add", "Deaths"})
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "deaths", []string{"+1"})
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "deaths", []string{"+1"})
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "deaths", []string{"-1"})
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "deaths", []string{"set", "10"})
+	_ = parseCommand(context.Background(), mock, "ch1", "viewer", "deaths", nil)
+
+	want := []string{"Counter deaths is ready", "deaths: 1", "deaths: 2", "deaths: 1", "deaths: 10", "deaths: 10"}
+	if len(mock.messages) != len(want) {
+		t.Fatalf("expected %d replies, got %v", len(want), mock.messages)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(mock.messages[i].msg, w) {
+			t.Errorf("reply %d: got %q, want prefix %q", i, mock.messages[i].msg, w)
+		}
+	}
+	if m.values["deaths"] != 10 {
+		t.Errorf("expected gauge deaths=10, got %d", m.values["deaths"])
+	}
+}
+
+func TestCounters_ViewersCannotModify(t *testing.T) {
+	c, _ := newTestCounters(t, "")
+	if err := c.Create("deaths", "boss"); err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatform("testbot", nil)
+
+	_ = parseCommand(context.Background(), mock, "ch1", "viewer", "deaths", []string{"+1"})
+	_ = parseCommand(context.Background(), mock, "ch1", "viewer", "counter", []string{"add", "wins"})
+
+	if ctr, _ := c.Get("deaths"); ctr.Value != 0 {
+		t.Errorf("expected viewer change to be refused, got %d", ctr.Value)
+	}
+	if _, err := c.Get("wins"); err == nil {
+		t.Error("expected viewer to be unable to create counters")
+	}
+	for _, m := range mock.messages {
+		if !strings.Contains(m.msg, "Only the boss") {
+			t.Errorf("expected refusal, got %q", m.msg)
+		}
+	}
+}
+
+func TestCounters_PersistAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	c, _ := newTestCounters(t, path)
+	_ = c.Create("deaths", "boss")
+	_, _ = c.Set("deaths", 7)
+	RegisterCommand("deaths", nil)
+
+	_, m := newTestCounters(t, path)
+	if m.values["deaths"] != 7 {
+		t.Errorf("expected restored gauge deaths=7, got %v", m.values)
+	}
+	mock := newMockPlatform("testbot", nil)
+	_ = parseCommand(context.Background(), mock, "ch1", "viewer", "deaths", nil)
+	if len(mock.messages) != 1 || mock.messages[0].msg != "deaths: 7" {
+		t.Errorf("expected restored counter to be readable, got %v", mock.messages)
+	}
+}
+
+func TestCounters_CreateRejectsBadNames(t *testing.T) {
+	c, _ := newTestCounters(t, "")
+	for _, name := range []string{"", "has space", "ping", "counter", strings.Repeat("x", 33), "dé"} {
+		if err := c.Create(name, "boss"); err == nil {
+			t.Errorf("expected error creating counter %q", name)
+		}
+	}
+	_ = c.Create("deaths", "boss")
+	if err := c.Create("deaths", "boss"); err == nil {
+		t.Error("expected duplicate counter to be rejected")
+	}
+}
+
+func TestCounters_Delete(t *testing.T) {
+	c, m := newTestCounters(t, "")
+	_ = c.Create("deaths", "boss")
+	mock := newMockPlatformWithAdmin("testbot", nil, func(ch, user string) bool { return true })
+
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "counter", []string{"del", "deaths"})
+	if _, ok := getCommand("deaths"); ok {
+		t.Error("expected counter command to be unregistered")
+	}
+	if _, ok := m.values["deaths"]; ok {
+		t.Error("expected gauge to be removed")
+	}
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "counter", []string{"del", "deaths"})
+	if !strings.Contains(mock.messages[1].msg, "nae counter") {
+		t.Errorf("expected missing counter reply, got %q", mock.messages[1].msg)
+	}
+}
+
+func TestCounters_ClashingStoredCounterNotRegistered(t *testing.T) {
+	s := openTestStore(t, "")
+	_ = s.Put(countersBucket, "ping", Counter{Name: "ping", Value: 3})
+	c := NewCounters(s, nil)
+	if err := c.RegisterCommands(); err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterCommand("counter", nil)
+
+	mock := newMockPlatform("testbot", nil)
+	_ = parseCommand(context.Background(), mock, "ch1", "viewer", "ping", nil)
+	if len(mock.messages) != 1 || !strings.Contains(mock.messages[0].msg, "Pong") {
+		t.Errorf("expected built-in ping to win, got %v", mock.messages)
+	}
+}
+
+func TestCounters_List(t *testing.T) {
+	c, _ := newTestCounters(t, "")
+	mock := newMockPlatform("testbot", nil)
+
+	_ = c.HandleManageCommand(CommandRequest{Platform: mock, Channel: "ch1", Arguments: []string{"list"}})
+	_ = c.Create("wins", "boss")
+	_ = c.Create("deaths", "boss")
+	_, _ = c.Add("wins", 3)
+	_ = c.HandleManageCommand(CommandRequest{Platform: mock, Channel: "ch1", Arguments: []string{"list"}})
+
+	if mock.messages[0].msg != "Nae counters yet." {
+		t.Errorf("unexpected empty list reply %q", mock.messages[0].msg)
+	}
+	if mock.messages[1].msg != "Counters: deaths: 0, wins: 3" {
+		t.Errorf("unexpected list reply %q", mock.messages[1].msg)
+	}
+}
+
+func TestCounters_BadModifyArgs(t *testing.T) {
+	c, _ := newTestCounters(t, "")
+	_ = c.Create("deaths", "boss")
+	mock := newMockPlatform("testbot", nil)
+
+	for _, args := range [][]string{{"lots"}, {"set"}, {"set", "x"}, {"+x"}} {
+		_ = c.HandleCounterCommand(CommandRequest{Platform: mock, Channel: "ch1", Admin: true, Command: "deaths", Arguments: args})
+	}
+	for _, m := range mock.messages {
+		if !strings.HasPrefix(m.msg, "Usage:") {
+			t.Errorf("expected usage, got %q", m.msg)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package dwarfbot
+
+import (
+	"dwarfbot/pkg/dice"
+	"math/rand/v2"
+	"strings"
+)
+
+// maxRollBreakdown is the longest per-die breakdown posted to chat;
+// longer rolls show each group's subtotal instead. Twitch caps messages
+// at 500 characters.
+const maxRollBreakdown = 300
+
+// DiceRoller implements the "roll" command.
+type DiceRoller struct {
+	randIntN func(n int) int
+}
+
+// NewDiceRoller returns a DiceRoller using math/rand.
+func NewDiceRoller() *DiceRoller {
+	return &DiceRoller{randIntN: rand.IntN}
+}
+
+// HandleCommand implements "roll <dice> [adv|dis] [# label]", e.g.
+// "roll 4d6kh3+2", "roll d20 adv" or "roll 2d10 # damage".
+func (d *DiceRoller) HandleCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+
+	input, label, _ := strings.Cut(strings.Join(req.Arguments, " "), "#")
+	label = strings.TrimSpace(label)
+	words := strings.Fields(input)
+	if len(words) == 0 {
+		return reply(Say(req.Channel, "roll.usage", nil))
+	}
+
+	advantage := ""
+	switch last := strings.ToLower(words[len(words)-1]); last {
+	case "adv", "advantage", "dis", "disadvantage":
+		advantage = last
+		words = words[:len(words)-1]
+	}
+
+	expr, err := dice.Parse(strings.Join(words, ""))
+	if err == nil && advantage != "" {
+		err = expr.Advantage(strings.HasPrefix(advantage, "adv"))
+	}
+	if err != nil {
+		return reply(Say(req.Channel, "roll.invalid", Vars{"Error": err}))
+	}
+	result, err := expr.Roll(d.randIntN)
+	if err != nil {
+		return reply(Say(req.Channel, "roll.invalid", Vars{"Error": err}))
+	}
+
+	breakdown := result.Breakdown
+	if len(breakdown) > maxRollBreakdown {
+		breakdown = result.Summary
+	}
+	return reply(Say(req.Channel, "roll.result", Vars{
+		"User":      req.UserName,
+		"Label":     label,
+		"Breakdown": breakdown,
+		"Total":     result.Total,
+	}))
+}
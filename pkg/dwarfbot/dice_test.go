@@ -0,0 +1,80 @@
+package dwarfbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func newTestDiceRoller(faces ...int) *DiceRoller {
+	i := 0
+	return &DiceRoller{randIntN: func(n int) int {
+		v := faces[i%len(faces)]
+		i++
+		return v - 1
+	}}
+}
+
+func TestDiceRoller_HandleCommand(t *testing.T) {
+	defer ResetRegistrations()
+	mock := newMockPlatform("testbot", []string{"chan"})
+	opts := parseCommandOpts{platformName: "twitch", displayName: "Gimli"}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"4d6kh3+2"}, "Gimli rolls: 4d6kh3 [6, (2), 5, 3] + 2 = 16"},
+		{[]string{"d20", "adv"}, "Gimli rolls: 2d20kh1 [6, (2)] = 6"},
+		{[]string{"2d10", "#", "axe", "damage"}, "Gimli rolls fer axe damage: 2d10 [6, 2] = 8"},
+		{[]string{"2d6", "+", "1"}, "Gimli rolls: 2d6 [6, 2] + 1 = 9"},
+		{[]string{"d20", "dis"}, "Gimli rolls: 2d20kl1 [(6), 2] = 2"},
+	}
+	for _, tt := range tests {
+		RegisterCommand("roll", newTestDiceRoller(6, 2, 5, 3).HandleCommand)
+		mock.messages = nil
+		if err := parseCommand(context.Background(), mock, "chan", "gimli", "roll", tt.args, opts); err != nil {
+			t.Fatalf("roll %v: %v", tt.args, err)
+		}
+		if len(mock.messages) != 1 || mock.messages[0].msg != tt.want {
+			t.Errorf("roll %v: expected %q, got %v", tt.args, tt.want, mock.messages)
+		}
+	}
+}
+
+func TestDiceRoller_Invalid(t *testing.T) {
+	mock := newMockPlatform("testbot", []string{"chan"})
+	d := newTestDiceRoller(1)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "Roll what?"},
+		{[]string{"#", "damage"}, "Roll what?"},
+		{[]string{"1000d6"}, "too many dice"},
+		{[]string{"2d6", "adv"}, "advantage needs"},
+	}
+	for _, tt := range tests {
+		mock.messages = nil
+		if err := d.HandleCommand(CommandRequest{Context: context.Background(), Platform: mock, Channel: "chan", Arguments: tt.args}); err != nil {
+			t.Fatal(err)
+		}
+		if len(mock.messages) != 1 || !strings.Contains(mock.messages[0].msg, tt.want) {
+			t.Errorf("%v: expected a reply containing %q, got %v", tt.args, tt.want, mock.messages)
+		}
+	}
+}
+
+func TestDiceRoller_LongRollsSummarized(t *testing.T) {
+	mock := newMockPlatform("testbot", []string{"chan"})
+	d := newTestDiceRoller(3)
+
+	err := d.HandleCommand(CommandRequest{Context: context.Background(), Platform: mock, Channel: "chan", UserName: "Gimli", Arguments: []string{"100d20"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mock.messages[0].msg; got != "Gimli rolls: 100d20 (300) = 300" {
+		t.Errorf("expected a summary for long rolls, got %q", got)
+	}
+}
@@ -1,11 +1,14 @@
 package dwarfbot
 
 import (
+	"context"
+	"dwarfbot/pkg/events"
 	"fmt"
 	"log"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,6 +24,10 @@ type DiscordBot struct {
 	// Discord role name required for admin commands
 	AdminRole string
 
+	// AllowBots lists bot user IDs that may run commands. Other bot
+	// accounts are skipped so two bots can't answer each other forever.
+	AllowBots []string
+
 	// Name of the bot used in responses
 	Name string
 
@@ -32,12 +39,30 @@ type DiscordBot struct {
 	exitFunc func(int)
 
 	// adminRoleCache maps guild ID to resolved admin role ID to avoid
-	// repeated REST lookups on every admin check.
+	// repeated REST lookups on every admin check. adminRoleMu also
+	// guards AdminRole once the bot is running.
 	adminRoleCache map[string]string
 	adminRoleMu    sync.RWMutex
 
+	// channelsMu guards ChannelIDs once the bot is running.
+	channelsMu sync.RWMutex
+
 	// Metrics records platform-level metrics. Nil means no metrics.
 	Metrics PlatformMetrics
+
+	// ACL restricts which commands may run. Nil allows everything.
+	ACL *ACL
+
+	// Lifecycle receives shutdown requests. Nil means Shutdown exits the
+	// process directly.
+	Lifecycle *Lifecycle
+
+	// Audit records admin-level commands. Nil means no auditing.
+	Audit AuditSink
+
+	// Identities resolves linked accounts. Nil means every account is
+	// its own identity.
+	Identities *Identities
 }
 
 // Start creates the Discord session, registers handlers, and opens the connection.
@@ -51,11 +76,15 @@ func (d *DiscordBot) Start() error {
 		return fmt.Errorf("error creating Discord session: %w", err)
 	}
 
-	d.session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent
+	d.session.Identify.Intents = discordgo.IntentsGuildMessages | discordgo.IntentsMessageContent | discordgo.IntentsGuildMessageReactions
 
 	d.adminRoleCache = make(map[string]string)
 
 	d.session.AddHandler(d.messageHandler)
+	d.session.AddHandler(d.reactionAddHandler)
+	d.session.AddHandler(d.reactionRemoveHandler)
+	d.session.AddHandler(d.connectHandler)
+	d.session.AddHandler(d.disconnectHandler)
 
 	err = d.session.Open()
 	if err != nil {
@@ -69,10 +98,12 @@ func (d *DiscordBot) Start() error {
 		d.Metrics.RecordConnectionAttempt("discord", "success")
 		d.Metrics.RecordConnected("discord")
 	}
+	events.Publish(events.Default, events.Connected{Component: "discord"})
 
 	log.Printf("Discord bot connected as %s", d.Name)
-	for _, ch := range d.ChannelIDs {
+	for _, ch := range d.BotChannels() {
 		log.Printf("Discord: listening in channel %s", ch)
+		events.Publish(events.Default, events.ChannelJoined{Platform: "discord", Channel: ch})
 	}
 
 	return nil
@@ -84,11 +115,23 @@ func (d *DiscordBot) Stop() error {
 		if d.Metrics != nil {
 			d.Metrics.RecordDisconnected("discord", "shutdown")
 		}
-		return d.session.Close()
+		err := d.session.Close()
+		events.Publish(events.Default, events.Disconnected{Component: "discord", Reason: "shutdown"})
+		return err
 	}
 	return nil
 }
 
+// connectHandler records gateway (re)connections.
+func (d *DiscordBot) connectHandler(_ *discordgo.Session, _ *discordgo.Connect) {
+	events.Publish(events.Default, events.Connected{Component: "discord"})
+}
+
+// disconnectHandler records gateway drops; discordgo reconnects on its own.
+func (d *DiscordBot) disconnectHandler(_ *discordgo.Session, _ *discordgo.Disconnect) {
+	events.Publish(events.Default, events.Disconnected{Component: "discord", Reason: "gateway disconnected"})
+}
+
 // messageHandler processes incoming Discord messages.
 func (d *DiscordBot) messageHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore messages from the bot itself
@@ -97,61 +140,222 @@ func (d *DiscordBot) messageHandler(s *discordgo.Session, m *discordgo.MessageCr
 	}
 
 	// Only listen in configured channels
-	if !contains(d.ChannelIDs, m.ChannelID) {
+	if !contains(d.BotChannels(), m.ChannelID) {
 		return
 	}
 
-	// Parse command using the same regex as Twitch
-	cmdMatches := cmdRegex.FindStringSubmatch(m.Content)
-	if cmdMatches == nil {
-		return
+	if err := d.handleInbound(Message{
+		Platform: "discord",
+		Channel:  m.ChannelID,
+		UserID:   m.Author.ID,
+		UserName: m.Author.Username,
+		Text:     m.Content,
+		ID:       m.ID,
+		IsBot:    m.Author.Bot,
+		Time:     time.Now(),
+	}); err != nil {
+		log.Printf("Discord: error handling message from user %s in channel %s: %v", m.Author.ID, m.ChannelID, err)
 	}
+}
 
-	botId, cmd := strings.ToLower(cmdMatches[1]), strings.ToLower(cmdMatches[2])
-	arguments := strings.Fields(cmdMatches[3])
-
-	// Ignore if not directed at this bot
-	if !contains(aliases, botId) {
+// reactionAddHandler passes reactions from people, not bots, to the
+// reaction listeners.
+func (d *DiscordBot) reactionAddHandler(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+	if r.Member != nil && r.Member.User != nil && r.Member.User.Bot {
 		return
 	}
+	d.handleReaction(s, r.MessageReaction, true)
+}
 
-	log.Printf("Discord: %s #%s: %s", m.Author.Username, m.ChannelID, m.Content)
-	if d.Metrics != nil {
-		d.Metrics.RecordMessageReceived("discord")
+// reactionRemoveHandler passes removed reactions to the reaction
+// listeners.
+func (d *DiscordBot) reactionRemoveHandler(s *discordgo.Session, r *discordgo.MessageReactionRemove) {
+	d.handleReaction(s, r.MessageReaction, false)
+}
+
+func (d *DiscordBot) handleReaction(s *discordgo.Session, r *discordgo.MessageReaction, added bool) {
+	if r.UserID == s.State.User.ID || !contains(d.BotChannels(), r.ChannelID) {
+		return
 	}
+	notifyReactionListeners(Reaction{
+		Platform:  "discord",
+		Channel:   r.ChannelID,
+		MessageID: r.MessageID,
+		UserID:    r.UserID,
+		Emoji:     r.Emoji.Name,
+		Added:     added,
+	})
+}
 
-	if err := parseCommand(d, m.ChannelID, m.Author.ID, cmd, arguments, parseCommandOpts{metrics: d.Metrics, platformName: "discord"}); err != nil {
-		log.Printf("Discord: error handling command %q from user %s in channel %s: %v", cmd, m.Author.ID, m.ChannelID, err)
+// handleInbound runs a received message through the inbound pipeline,
+// ending in message listeners and command dispatch.
+func (d *DiscordBot) handleInbound(msg Message) error {
+	dispatch := func(ctx context.Context, msg Message) error {
+		return dispatchInbound(ctx, d, msg, parseCommandOpts{metrics: d.Metrics, platformName: "discord", acl: d.ACL, audit: d.Audit, identities: d.Identities, allowBots: d.AllowBots})
 	}
+	return inboundPipeline(d.Metrics, dispatch)(d.Lifecycle.Context(), msg)
 }
 
 // ChatPlatform interface implementation for DiscordBot.
 
-func (d *DiscordBot) SendMessage(channel, msg string) error {
+// SendMessage runs msg through the outbound pipeline, which logs it and
+// records metrics before posting it over REST.
+func (d *DiscordBot) SendMessage(ctx context.Context, channel, msg string) error {
 	if d.session == nil {
 		return fmt.Errorf("discord session not initialized")
 	}
-	_, err := d.session.ChannelMessageSend(channel, msg)
+	send := func(ctx context.Context, out OutboundMessage) error {
+		_, err := d.post(ctx, out)
+		return err
+	}
+	return outboundPipeline(d.Metrics, d.Name, send)(ctx, OutboundMessage{Platform: "discord", Channel: channel, Text: msg})
+}
+
+// post sends out over REST. Only user mentions ping anyone: bot output
+// often carries text from viewers, scripts or remote services, and none
+// of it may reach @everyone, @here or a role.
+func (d *DiscordBot) post(ctx context.Context, out OutboundMessage) (*discordgo.Message, error) {
+	m, err := d.session.ChannelMessageSendComplex(out.Channel, &discordgo.MessageSend{
+		Content: out.Text,
+		AllowedMentions: &discordgo.MessageAllowedMentions{
+			Parse: []discordgo.AllowedMentionType{discordgo.AllowedMentionTypeUsers},
+		},
+	}, discordgo.WithContext(ctx))
 	if err != nil {
-		if d.Metrics != nil {
-			d.Metrics.RecordMessageSent("discord", "failure")
+		return nil, fmt.Errorf("error sending Discord message: %w", err)
+	}
+	return m, nil
+}
+
+// SendMessageWithReactions sends msg through the outbound pipeline and
+// adds each emoji to it as a reaction. It returns the message ID, which
+// is empty when the pipeline dropped the message.
+func (d *DiscordBot) SendMessageWithReactions(ctx context.Context, channel, msg string, emoji []string) (string, error) {
+	if d.session == nil {
+		return "", fmt.Errorf("discord session not initialized")
+	}
+	var messageID string
+	send := func(ctx context.Context, out OutboundMessage) error {
+		m, err := d.post(ctx, out)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("error sending Discord message: %w", err)
+		messageID = m.ID
+		return nil
 	}
-	if d.Metrics != nil {
-		d.Metrics.RecordMessageSent("discord", "success")
+	if err := outboundPipeline(d.Metrics, d.Name, send)(ctx, OutboundMessage{Platform: "discord", Channel: channel, Text: msg}); err != nil {
+		return "", err
+	}
+	if messageID == "" {
+		return "", nil
+	}
+	for _, e := range emoji {
+		if err := d.session.MessageReactionAdd(channel, messageID, e, discordgo.WithContext(ctx)); err != nil {
+			return messageID, fmt.Errorf("error adding Discord reaction: %w", err)
+		}
+	}
+	return messageID, nil
+}
+
+// SendDirectMessage opens (or reuses) a DM channel with the user and
+// sends msg through the outbound pipeline.
+func (d *DiscordBot) SendDirectMessage(ctx context.Context, userID, msg string) error {
+	if d.session == nil {
+		return fmt.Errorf("discord session not initialized")
+	}
+	ch, err := d.session.UserChannelCreate(userID, discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error opening Discord DM channel: %w", err)
+	}
+	return d.SendMessage(ctx, ch.ID, msg)
+}
+
+// DeleteMessage deletes msg over REST.
+func (d *DiscordBot) DeleteMessage(ctx context.Context, msg Message) error {
+	if d.session == nil {
+		return fmt.Errorf("discord session not initialized")
+	}
+	if err := d.session.ChannelMessageDelete(msg.Channel, msg.ID, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("error deleting Discord message: %w", err)
+	}
+	return nil
+}
+
+// TimeoutUser applies a member timeout in the channel's guild.
+func (d *DiscordBot) TimeoutUser(ctx context.Context, channel, userID string, duration time.Duration, _ string) error {
+	guildID, err := d.guildID(ctx, channel)
+	if err != nil {
+		return err
+	}
+	until := time.Now().Add(duration)
+	if err := d.session.GuildMemberTimeout(guildID, userID, &until, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("error timing out Discord member: %w", err)
 	}
-	log.Printf("%s #%s: %s", d.Name, channel, msg)
 	return nil
 }
 
-func (d *DiscordBot) IsAdmin(channel, userID string) bool {
-	if d.session == nil || d.AdminRole == "" {
+// BanUser bans the member from the channel's guild without deleting
+// their message history.
+func (d *DiscordBot) BanUser(ctx context.Context, channel, userID, reason string) error {
+	guildID, err := d.guildID(ctx, channel)
+	if err != nil {
+		return err
+	}
+	if err := d.session.GuildBanCreateWithReason(guildID, userID, reason, 0, discordgo.WithContext(ctx)); err != nil {
+		return fmt.Errorf("error banning Discord member: %w", err)
+	}
+	return nil
+}
+
+// HasRole reports whether the member holds a guild role named role.
+func (d *DiscordBot) HasRole(ctx context.Context, channel, userID, role string) bool {
+	guildID, err := d.guildID(ctx, channel)
+	if err != nil {
+		log.Printf("Discord: %v", err)
+		return false
+	}
+	roles, err := d.session.GuildRoles(guildID, discordgo.WithContext(ctx))
+	if err != nil {
+		log.Printf("Discord: error getting guild roles: %v", err)
+		return false
+	}
+	member, err := d.session.GuildMember(guildID, userID, discordgo.WithContext(ctx))
+	if err != nil {
+		log.Printf("Discord: error getting member info: %v", err)
+		return false
+	}
+	for _, r := range roles {
+		if strings.EqualFold(r.Name, role) && contains(member.Roles, r.ID) {
+			return true
+		}
+	}
+	return false
+}
+
+// guildID looks up the guild a channel belongs to.
+func (d *DiscordBot) guildID(ctx context.Context, channel string) (string, error) {
+	if d.session == nil {
+		return "", fmt.Errorf("discord session not initialized")
+	}
+	ch, err := d.session.Channel(channel, discordgo.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("error getting channel info: %w", err)
+	}
+	return ch.GuildID, nil
+}
+
+// IsAdmin resolves the member's roles over REST; every request is bound
+// to ctx.
+func (d *DiscordBot) IsAdmin(ctx context.Context, channel, userID string) bool {
+	d.adminRoleMu.RLock()
+	adminRole := d.AdminRole
+	d.adminRoleMu.RUnlock()
+	if d.session == nil || adminRole == "" {
 		return false
 	}
 
 	// Get the channel to find the guild ID
-	ch, err := d.session.Channel(channel)
+	ch, err := d.session.Channel(channel, discordgo.WithContext(ctx))
 	if err != nil {
 		log.Printf("Discord: error getting channel info: %v", err)
 		return false
@@ -163,20 +367,23 @@ func (d *DiscordBot) IsAdmin(channel, userID string) bool {
 	adminRoleID, cached := d.adminRoleCache[ch.GuildID]
 	d.adminRoleMu.RUnlock()
 	if !cached {
-		roles, err := d.session.GuildRoles(ch.GuildID)
+		roles, err := d.session.GuildRoles(ch.GuildID, discordgo.WithContext(ctx))
 		if err != nil {
 			log.Printf("Discord: error getting guild roles: %v", err)
 			return false
 		}
 		for _, role := range roles {
-			if strings.EqualFold(role.Name, d.AdminRole) {
+			if strings.EqualFold(role.Name, adminRole) {
 				adminRoleID = role.ID
 				break
 			}
 		}
 		if adminRoleID != "" {
 			d.adminRoleMu.Lock()
-			d.adminRoleCache[ch.GuildID] = adminRoleID
+			// Skip caching if the role was changed by a reload meanwhile.
+			if d.AdminRole == adminRole && d.adminRoleCache != nil {
+				d.adminRoleCache[ch.GuildID] = adminRoleID
+			}
 			d.adminRoleMu.Unlock()
 		}
 	}
@@ -186,7 +393,7 @@ func (d *DiscordBot) IsAdmin(channel, userID string) bool {
 	}
 
 	// Get the member's roles in this guild
-	member, err := d.session.GuildMember(ch.GuildID, userID)
+	member, err := d.session.GuildMember(ch.GuildID, userID, discordgo.WithContext(ctx))
 	if err != nil {
 		log.Printf("Discord: error getting member info: %v", err)
 		return false
@@ -207,12 +414,64 @@ func (d *DiscordBot) BotName() string {
 }
 
 func (d *DiscordBot) BotChannels() []string {
-	return d.ChannelIDs
+	d.channelsMu.RLock()
+	defer d.channelsMu.RUnlock()
+	return append([]string(nil), d.ChannelIDs...)
+}
+
+// SetChannelIDs replaces the channels the bot listens in and returns
+// what changed.
+func (d *DiscordBot) SetChannelIDs(ids []string) (added, removed []string) {
+	d.channelsMu.Lock()
+	old := d.ChannelIDs
+	d.ChannelIDs = append([]string(nil), ids...)
+	d.channelsMu.Unlock()
+
+	for _, id := range ids {
+		if !contains(old, id) {
+			added = append(added, id)
+			events.Publish(events.Default, events.ChannelJoined{Platform: "discord", Channel: id})
+		}
+	}
+	for _, id := range old {
+		if !contains(ids, id) {
+			removed = append(removed, id)
+			events.Publish(events.Default, events.ChannelParted{Platform: "discord", Channel: id})
+		}
+	}
+	return added, removed
+}
+
+// SetAdminRole changes the admin role name and invalidates the resolved
+// role cache. It reports whether the role changed.
+func (d *DiscordBot) SetAdminRole(role string) bool {
+	d.adminRoleMu.Lock()
+	defer d.adminRoleMu.Unlock()
+	if d.AdminRole == role {
+		return false
+	}
+	d.AdminRole = role
+	d.adminRoleCache = make(map[string]string)
+	return true
 }
 
-func (d *DiscordBot) Shutdown(exitCode int) {
-	if err := d.Stop(); err != nil {
-		log.Printf("Error stopping Discord session: %v", err)
+// Shutdown hands off to the Lifecycle when one is set, so the process
+// owner can stop every component in order. Without one it stops the
+// session, waiting no longer than ctx allows, and exits directly.
+func (d *DiscordBot) Shutdown(ctx context.Context, exitCode int) {
+	if d.Lifecycle != nil {
+		d.Lifecycle.Shutdown(exitCode)
+		return
+	}
+	stopped := make(chan error, 1)
+	go func() { stopped <- d.Stop() }()
+	select {
+	case err := <-stopped:
+		if err != nil {
+			log.Printf("Error stopping Discord session: %v", err)
+		}
+	case <-ctx.Done():
+		log.Printf("Discord: gave up waiting for the session to close: %v", ctx.Err())
 	}
 	if d.exitFunc != nil {
 		d.exitFunc(exitCode)
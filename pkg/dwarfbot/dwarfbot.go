@@ -25,6 +25,8 @@ package dwarfbot
 
 import (
 	"bufio"
+	"context"
+	"dwarfbot/pkg/events"
 	"errors"
 	"fmt"
 	"log"
@@ -37,15 +39,75 @@ import (
 	"time"
 )
 
+// ircLineBreaks flattens a message onto one IRC line.
+var ircLineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
 // Bot aliases
 var aliases = []string{"hammerdwarfbot", "dwarfbot"}
 
+// defaultAliases are restored when SetAliases is given an empty list.
+var defaultAliases = append([]string(nil), aliases...)
+
+var aliasesMu sync.RWMutex
+
+// SetAliases replaces the names the bot answers to, e.g. "!dwarfbot".
+// An empty list restores the defaults.
+func SetAliases(names []string) {
+	cleaned := make([]string, 0, len(names))
+	for _, n := range names {
+		if n = strings.ToLower(strings.TrimSpace(n)); n != "" {
+			cleaned = append(cleaned, n)
+		}
+	}
+	if len(cleaned) == 0 {
+		cleaned = append(cleaned, defaultAliases...)
+	}
+	aliasesMu.Lock()
+	defer aliasesMu.Unlock()
+	aliases = cleaned
+}
+
+func isAlias(name string) bool {
+	aliasesMu.RLock()
+	defer aliasesMu.RUnlock()
+	return contains(aliases, name)
+}
+
 // Regex for parsing PRIVMSG strings.
 //
 // First matched group is the user's name, the second matched is the message type (PRIVMSG),
 // the third group is the incomming channel, and the fourth is the content of the user's message.
 var msgRegex *regexp.Regexp = regexp.MustCompile(`^:(\w+)!\w+@\w+\.tmi\.twitch\.tv (PRIVMSG) #(\w+)(?: :(.*))?$`)
 
+// splitIRCTags separates a line's IRCv3 tags ("@key=value;...") from the
+// rest of it. Lines without tags come back unchanged.
+func splitIRCTags(line string) (tags, rest string) {
+	if !strings.HasPrefix(line, "@") {
+		return "", line
+	}
+	tags, rest, _ = strings.Cut(line[1:], " ")
+	return tags, rest
+}
+
+// twitchBadges returns the badge names from a Twitch message's tags,
+// e.g. "badges=moderator/1,subscriber/12" gives moderator and subscriber.
+func twitchBadges(tags string) []string {
+	for _, tag := range strings.Split(tags, ";") {
+		value, ok := strings.CutPrefix(tag, "badges=")
+		if !ok || value == "" {
+			continue
+		}
+		var badges []string
+		for _, b := range strings.Split(value, ",") {
+			if name, _, _ := strings.Cut(b, "/"); name != "" {
+				badges = append(badges, name)
+			}
+		}
+		return badges
+	}
+	return nil
+}
+
 // Regex for parsing user commands, from already parsed PRIVMSG strings.
 //
 // First matched group is the command name and the second matched group is the argument for the
@@ -79,7 +141,7 @@ type DwarfBot struct {
 	// Domain of the IRC Server
 	Server string
 
-	// Start time (useful?)
+	// startTime is when the current connection was established.
 	startTime time.Time
 
 	// Verbose output
@@ -92,6 +154,20 @@ type DwarfBot struct {
 	// Metrics records platform-level metrics. Nil means no metrics.
 	Metrics PlatformMetrics
 
+	// ACL restricts which commands may run. Nil allows everything.
+	ACL *ACL
+
+	// Lifecycle receives shutdown requests. Nil means Shutdown exits the
+	// process directly.
+	Lifecycle *Lifecycle
+
+	// Audit records admin-level commands. Nil means no auditing.
+	Audit AuditSink
+
+	// Identities resolves linked accounts. Nil means every account is
+	// its own identity.
+	Identities *Identities
+
 	// lastDisconnectReason tracks why the connection was lost for metrics.
 	lastDisconnectReason string
 
@@ -100,6 +176,10 @@ type DwarfBot struct {
 	mu      sync.Mutex
 	stopped bool
 	stopCh  chan struct{}
+
+	// writeMu serializes Say so one message's write deadline can't
+	// cut another short.
+	writeMu sync.Mutex
 }
 
 // Stop signals the bot to shut down cleanly by closing the connection,
@@ -136,6 +216,12 @@ func (db *DwarfBot) setDisconnectReason(reason string) {
 	}
 }
 
+func (db *DwarfBot) getConn() net.Conn {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.conn
+}
+
 func (db *DwarfBot) setConn(conn net.Conn) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -171,7 +257,7 @@ func (db *DwarfBot) Start() error {
 		db.Authenticate()
 
 		db.JoinChannel(db.Name)
-		for _, channel := range db.Channels {
+		for _, channel := range db.BotChannels() {
 			db.JoinChannel(channel)
 		}
 
@@ -208,6 +294,7 @@ func (db *DwarfBot) Connect() error {
 				db.Metrics.RecordConnectionAttempt("twitch", "success")
 				db.Metrics.RecordConnected("twitch")
 			}
+			events.Publish(events.Default, events.Connected{Component: "twitch"})
 			return nil
 		}
 		if db.Metrics != nil {
@@ -258,6 +345,7 @@ func (db *DwarfBot) Disconnect() {
 		db.Metrics.RecordDisconnected("twitch", reason)
 		db.Metrics.RecordConnectionDuration("twitch", duration)
 	}
+	events.Publish(events.Default, events.Disconnected{Component: "twitch", Reason: reason})
 }
 
 func (db *DwarfBot) Authenticate() {
@@ -267,6 +355,10 @@ func (db *DwarfBot) Authenticate() {
 	if _, err := db.conn.Write([]byte("NICK " + db.Name + "\r\n")); err != nil {
 		log.Printf("Failed to send NICK during authentication: %v", err)
 	}
+	// Tags carry the author's badges, which moderation exemptions use.
+	if _, err := db.conn.Write([]byte("CAP REQ :twitch.tv/tags\r\n")); err != nil {
+		log.Printf("Failed to request tags during authentication: %v", err)
+	}
 }
 
 // JoinChannel joins a specific IRC Channel
@@ -275,13 +367,19 @@ func (db *DwarfBot) JoinChannel(channel string) {
 		return
 	}
 
+	conn := db.getConn()
+	if conn == nil {
+		return
+	}
+
 	// Channel login must be lowercase (https://dev.twitch.tv/docs/irc/guide#syntax-notes)
-	if _, err := db.conn.Write([]byte("JOIN #" + strings.ToLower(channel) + "\r\n")); err != nil {
+	if _, err := conn.Write([]byte("JOIN #" + strings.ToLower(channel) + "\r\n")); err != nil {
 		log.Printf("Failed to join channel #%s: %v", channel, err)
 		return
 	}
 
 	log.Printf("Joined channel #%s as @%s", channel, db.Name)
+	events.Publish(events.Default, events.ChannelJoined{Platform: "twitch", Channel: strings.ToLower(channel)})
 }
 
 func (db *DwarfBot) PartChannel(channel string) {
@@ -289,11 +387,17 @@ func (db *DwarfBot) PartChannel(channel string) {
 		return
 	}
 
-	if _, err := db.conn.Write([]byte("PART #" + strings.ToLower(channel) + "\r\n")); err != nil {
+	conn := db.getConn()
+	if conn == nil {
+		return
+	}
+
+	if _, err := conn.Write([]byte("PART #" + strings.ToLower(channel) + "\r\n")); err != nil {
 		log.Printf("Failed to part from channel #%s: %v", channel, err)
 		return
 	}
 	log.Printf("Parted from channel #%s", channel)
+	events.Publish(events.Default, events.ChannelParted{Platform: "twitch", Channel: strings.ToLower(channel)})
 }
 
 // Handle shutdown for good commands
@@ -337,6 +441,7 @@ func (db *DwarfBot) HandleChat() error {
 		} else {
 
 			// handle a PRIVMSG message
+			tags, line := splitIRCTags(line)
 			matches := msgRegex.FindStringSubmatch(line)
 			if matches != nil {
 				userName := matches[1]
@@ -349,27 +454,19 @@ func (db *DwarfBot) HandleChat() error {
 
 				switch msgType {
 				case "PRIVMSG":
-					msg := matches[4]
-					log.Printf("%s #%s: %s", userName, channelName, msg)
-					if db.Metrics != nil {
-						db.Metrics.RecordMessageReceived("twitch")
-					}
-
-					cmdMatches := cmdRegex.FindStringSubmatch(msg)
-					if cmdMatches != nil {
-						botId, cmd := strings.ToLower(cmdMatches[1]), strings.ToLower(cmdMatches[2])
-						// Split the third match into a slice on whitespace for arguments
-						arguments := strings.Fields(cmdMatches[3])
-
-						// Ignore the command if it's not directed at this bot
-						if !contains(aliases, botId) {
-							break
-						}
-
-						if cmdErr := parseCommand(db, channelName, userName, cmd, arguments, parseCommandOpts{metrics: db.Metrics, platformName: "twitch"}); cmdErr != nil {
-							return cmdErr
-						}
+					// Never react to our own echoed messages.
+					if strings.EqualFold(userName, db.Name) {
+						continue
 					}
+					db.handleInbound(Message{
+						Platform: "twitch",
+						Channel:  channelName,
+						UserID:   userName,
+						UserName: userName,
+						Text:     matches[4],
+						Badges:   twitchBadges(tags),
+						Time:     time.Now(),
+					})
 				default:
 					// do nothing
 				}
@@ -378,36 +475,93 @@ func (db *DwarfBot) HandleChat() error {
 	}
 }
 
-// Makes the bot send a message to the chat channel.
-func (db *DwarfBot) Say(channelName, msg string) error {
+// handleInbound runs a received message through the inbound pipeline,
+// ending in message listeners and command dispatch. A failing handler
+// is logged rather than returned, so a store or network error in one
+// command can't drop the IRC session.
+func (db *DwarfBot) handleInbound(msg Message) {
+	dispatch := func(ctx context.Context, msg Message) error {
+		return dispatchInbound(ctx, db, msg, parseCommandOpts{metrics: db.Metrics, platformName: "twitch", acl: db.ACL, audit: db.Audit, identities: db.Identities})
+	}
+	if err := inboundPipeline(db.Metrics, dispatch)(db.Lifecycle.Context(), msg); err != nil {
+		log.Printf("Twitch: error handling message from user %s in channel %s: %v", msg.UserID, msg.Channel, err)
+	}
+}
+
+// Makes the bot send a message to the chat channel, bypassing the
+// outbound pipeline; use SendMessage for anything user-facing. Line breaks
+// become spaces, so text from scripts, plugins or webhooks can't smuggle
+// in extra IRC commands. The write is abandoned when ctx ends, or after
+// sendTimeout if ctx has no deadline, so a stuck socket can't block the
+// caller forever.
+func (db *DwarfBot) Say(ctx context.Context, channelName, msg string) error {
 	if msg == "" {
 		return errors.New("msg was empty")
 	}
+	msg = ircLineBreaks.Replace(msg)
+
+	// Snapshot conn under the mutex: Say may be called from other
+	// goroutines (timers) while the bot loop reconnects.
+	db.mu.Lock()
+	conn := db.conn
+	db.mu.Unlock()
+	if conn == nil {
+		return errors.New("not connected")
+	}
+
+	db.writeMu.Lock()
+	defer db.writeMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	deadline, hasDeadline := ctx.Deadline()
+	if !hasDeadline {
+		deadline = time.Now().Add(sendTimeout)
+	}
+	_ = conn.SetWriteDeadline(deadline)
+	defer func() { _ = conn.SetWriteDeadline(time.Time{}) }()
 
-	_, err := fmt.Fprintf(db.conn, "PRIVMSG #%s :%s\r\n", channelName, msg)
+	// Cancellation interrupts the write by moving the deadline to now.
+	done, watched := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(watched)
+		select {
+		case <-ctx.Done():
+			_ = conn.SetWriteDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	_, err := fmt.Fprintf(conn, "PRIVMSG #%s :%s\r\n", channelName, msg)
+	close(done)
+	<-watched
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("sending to #%s: %w", channelName, ctxErr)
+		}
+		// The socket deadline is ctx's, so the write can time out just
+		// before ctx's own timer fires.
+		if hasDeadline && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(deadline) {
+			return fmt.Errorf("sending to #%s: %w", channelName, context.DeadlineExceeded)
+		}
 		return err
 	}
-	log.Printf("%s #%s: %s", db.Name, channelName, msg)
 
 	return nil
 }
 
 // ChatPlatform interface implementation for DwarfBot (Twitch).
 
-func (db *DwarfBot) SendMessage(channel, msg string) error {
-	err := db.Say(channel, msg)
-	if db.Metrics != nil {
-		if err != nil {
-			db.Metrics.RecordMessageSent("twitch", "failure")
-		} else {
-			db.Metrics.RecordMessageSent("twitch", "success")
-		}
+// SendMessage runs msg through the outbound pipeline, which logs it,
+// records metrics and writes it with Say.
+func (db *DwarfBot) SendMessage(ctx context.Context, channel, msg string) error {
+	send := func(ctx context.Context, out OutboundMessage) error {
+		return db.Say(ctx, out.Channel, out.Text)
 	}
-	return err
+	return outboundPipeline(db.Metrics, db.Name, send)(ctx, OutboundMessage{Platform: "twitch", Channel: channel, Text: msg})
 }
 
-func (db *DwarfBot) IsAdmin(channel, user string) bool {
+// IsAdmin needs no network call on Twitch: the channel owner is the admin.
+func (db *DwarfBot) IsAdmin(_ context.Context, channel, user string) bool {
 	return user == channel
 }
 
@@ -416,10 +570,66 @@ func (db *DwarfBot) BotName() string {
 }
 
 func (db *DwarfBot) BotChannels() []string {
-	return db.Channels
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return append([]string(nil), db.Channels...)
 }
 
-func (db *DwarfBot) Shutdown(exitCode int) {
+// SetChannels replaces the channel list, joining added channels and
+// parting removed ones on the live connection. It returns what changed.
+func (db *DwarfBot) SetChannels(channels []string) (joined, parted []string) {
+	normalized := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		if ch = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ch), "#")); ch != "" && !contains(normalized, ch) {
+			normalized = append(normalized, ch)
+		}
+	}
+
+	db.mu.Lock()
+	old := db.Channels
+	db.Channels = normalized
+	db.mu.Unlock()
+
+	for _, ch := range normalized {
+		if !containsFold(old, ch) {
+			joined = append(joined, ch)
+		}
+	}
+	for _, ch := range old {
+		if !containsFold(normalized, ch) {
+			parted = append(parted, ch)
+		}
+	}
+
+	// The bot's own channel is always joined, so never part it.
+	for _, ch := range joined {
+		db.JoinChannel(ch)
+	}
+	for _, ch := range parted {
+		if !strings.EqualFold(ch, db.Name) {
+			db.PartChannel(ch)
+		}
+	}
+	return joined, parted
+}
+
+func containsFold(list []string, item string) bool {
+	for _, x := range list {
+		if strings.EqualFold(strings.TrimPrefix(x, "#"), item) {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown hands off to the Lifecycle when one is set, so the process
+// owner can stop every component in order. Without one it disconnects
+// and exits directly; closing the socket never blocks, so ctx is unused.
+func (db *DwarfBot) Shutdown(_ context.Context, exitCode int) {
+	if db.Lifecycle != nil {
+		db.Lifecycle.Shutdown(exitCode)
+		return
+	}
 	db.Disconnect()
 	db.Die(exitCode)
 }
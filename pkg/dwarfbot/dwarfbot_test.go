@@ -2,6 +2,9 @@ package dwarfbot
 
 import (
 	"bufio"
+	"context"
+	"dwarfbot/pkg/events"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -127,6 +130,26 @@ func TestMsgRegex_NoMatch(t *testing.T) {
 	}
 }
 
+func TestTwitchBadges(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{"@badge-info=;badges=moderator/1,subscriber/12;color= :mod!mod@mod.tmi.twitch.tv PRIVMSG #channel :hi", []string{"moderator", "subscriber"}},
+		{"@badges=;display-name=Viewer :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #channel :hi", nil},
+		{":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #channel :hi", nil},
+	}
+	for _, tt := range tests {
+		tags, rest := splitIRCTags(tt.line)
+		if msgRegex.FindStringSubmatch(rest) == nil {
+			t.Errorf("%q: expected the rest of the line to match msgRegex, got %q", tt.line, rest)
+		}
+		if got := twitchBadges(tags); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: badges = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
 func TestCmdRegex_ValidCommand(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -286,7 +309,7 @@ func TestSay_Success(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		if err := bot.Say("testchannel", "Hello!"); err != nil {
+		if err := bot.Say(context.Background(), "testchannel", "Hello!"); err != nil {
 			t.Errorf("Say returned error: %v", err)
 		}
 	}()
@@ -302,7 +325,7 @@ func TestSay_EmptyMessage(t *testing.T) {
 	bot, _, cleanup := newTestBot(t)
 	defer cleanup()
 
-	err := bot.Say("channel", "")
+	err := bot.Say(context.Background(), "channel", "")
 	if err == nil {
 		t.Error("expected error for empty message")
 	}
@@ -316,7 +339,7 @@ func TestSay_SpecialCharacters(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		_ = bot.Say("testchannel", "Hello! @user #channel :colon")
+		_ = bot.Say(context.Background(), "testchannel", "Hello! @user #channel :colon")
 	}()
 
 	got := readFromConn(t, server)
@@ -325,6 +348,21 @@ func TestSay_SpecialCharacters(t *testing.T) {
 	}
 }
 
+func TestSay_MultiLineStaysOneCommand(t *testing.T) {
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	go func() {
+		_ = bot.Say(context.Background(), "testchannel", "first\r\nPRIVMSG #other :second\nPART #testchannel\rthird")
+	}()
+
+	got := readFromConn(t, server)
+	expected := "PRIVMSG #testchannel :first PRIVMSG #other :second PART #testchannel third\r\n"
+	if got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+}
+
 func TestSay_ClosedConn(t *testing.T) {
 	server, client := net.Pipe()
 	bot := &DwarfBot{
@@ -334,12 +372,44 @@ func TestSay_ClosedConn(t *testing.T) {
 	_ = client.Close()
 	_ = server.Close()
 
-	err := bot.Say("channel", "test message")
+	err := bot.Say(context.Background(), "channel", "test message")
 	if err == nil {
 		t.Error("expected error writing to closed connection")
 	}
 }
 
+func TestSay_StuckSocketHonorsDeadline(t *testing.T) {
+	// Nobody reads the server end, so the write blocks.
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	bot := &DwarfBot{conn: client, Name: "testbot"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := bot.Say(ctx, "channel", "hello")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("expected Say to give up at the deadline")
+	}
+}
+
+func TestSay_Cancelled(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+	bot := &DwarfBot{conn: client, Name: "testbot"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := bot.Say(ctx, "channel", "hello"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to abort the write, got %v", err)
+	}
+}
+
 // --- Authenticate Tests ---
 
 func TestAuthenticate(t *testing.T) {
@@ -385,6 +455,26 @@ func TestJoinChannel(t *testing.T) {
 	}
 }
 
+func TestJoinChannel_PublishesEvent(t *testing.T) {
+	defer ResetRegistrations()
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+	joined := make(chan events.ChannelJoined, 1)
+	events.Subscribe(events.Default, func(e events.ChannelJoined) { joined <- e })
+
+	go bot.JoinChannel("TestChannel")
+	readFromConn(t, server)
+
+	select {
+	case e := <-joined:
+		if e.Platform != "twitch" || e.Channel != "testchannel" {
+			t.Errorf("unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a ChannelJoined event")
+	}
+}
+
 func TestJoinChannel_AlreadyLowercase(t *testing.T) {
 	bot, server, cleanup := newTestBot(t)
 	defer cleanup()
@@ -654,6 +744,29 @@ func TestHandleChat_ErrorMessage(t *testing.T) {
 	}
 }
 
+func TestHandleChat_SurvivesFailingCommand(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterCommand("broken", func(CommandRequest) error { return errors.New("store is on fire") })
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	done := make(chan error, 1)
+	go func() { done <- bot.HandleChat() }()
+	_ = server.SetWriteDeadline(time.Now().Add(time.Second))
+	_, _ = server.Write([]byte(":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #channel1 :!dwarfbot broken\r\n"))
+	_, _ = server.Write([]byte(":viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #channel1 :!dwarfbot ping\r\n"))
+	if got := readFromConn(t, server); !strings.Contains(got, "PRIVMSG #channel1") {
+		t.Errorf("expected the bot to keep answering after a failed command, got %q", got)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("HandleChat returned after a failed command: %v", err)
+	default:
+	}
+	_ = server.Close()
+	<-done
+}
+
 func TestHandleChat_ChannelsCommand(t *testing.T) {
 	bot, server, cleanup := newTestBot(t)
 	defer cleanup()
@@ -763,7 +876,7 @@ func TestDwarfBot_SendMessage(t *testing.T) {
 	defer cleanup()
 
 	go func() {
-		if err := bot.SendMessage("testchannel", "Hello via interface!"); err != nil {
+		if err := bot.SendMessage(context.Background(), "testchannel", "Hello via interface!"); err != nil {
 			t.Errorf("SendMessage returned error: %v", err)
 		}
 	}()
@@ -779,7 +892,7 @@ func TestDwarfBot_SendMessage_Empty(t *testing.T) {
 	bot, _, cleanup := newTestBot(t)
 	defer cleanup()
 
-	err := bot.SendMessage("ch", "")
+	err := bot.SendMessage(context.Background(), "ch", "")
 	if err == nil {
 		t.Error("expected error for empty message via SendMessage")
 	}
@@ -788,14 +901,14 @@ func TestDwarfBot_SendMessage_Empty(t *testing.T) {
 func TestDwarfBot_IsAdmin(t *testing.T) {
 	bot := &DwarfBot{Name: "testbot"}
 
-	if !bot.IsAdmin("owner", "owner") {
+	if !bot.IsAdmin(context.Background(), "owner", "owner") {
 		t.Error("expected IsAdmin to return true when user == channel")
 	}
-	if bot.IsAdmin("owner", "other") {
+	if bot.IsAdmin(context.Background(), "owner", "other") {
 		t.Error("expected IsAdmin to return false when user != channel")
 	}
 	// Note: empty == empty is true by design, matching Twitch behavior
-	if !bot.IsAdmin("", "") {
+	if !bot.IsAdmin(context.Background(), "", "") {
 		t.Error("expected IsAdmin to return true when both are empty (user == channel)")
 	}
 }
@@ -845,7 +958,7 @@ func TestDwarfBot_Shutdown(t *testing.T) {
 		},
 	}
 
-	bot.Shutdown(0)
+	bot.Shutdown(context.Background(), 0)
 
 	if !exitCalled {
 		t.Error("expected exitFunc to be called during Shutdown")
@@ -954,7 +1067,7 @@ func TestDwarfBot_Shutdown_NilConn(t *testing.T) {
 		},
 	}
 	// Should not panic with nil conn
-	bot.Shutdown(0)
+	bot.Shutdown(context.Background(), 0)
 	if !exitCalled {
 		t.Error("expected exitFunc to be called")
 	}
@@ -969,7 +1082,7 @@ func TestDwarfBot_SendMessage_ClosedConn(t *testing.T) {
 		conn: client,
 		Name: "testbot",
 	}
-	err := bot.SendMessage("ch", "hello")
+	err := bot.SendMessage(context.Background(), "ch", "hello")
 	if err == nil {
 		t.Error("expected error sending to closed connection")
 	}
@@ -1032,7 +1145,7 @@ func TestDwarfBot_SendMessage_RecordsSuccessMetric(t *testing.T) {
 	done := make(chan struct{})
 	go func() {
 		defer close(done)
-		if err := bot.SendMessage("testchannel", "hello"); err != nil {
+		if err := bot.SendMessage(context.Background(), "testchannel", "hello"); err != nil {
 			t.Errorf("SendMessage error: %v", err)
 		}
 	}()
@@ -1060,7 +1173,7 @@ func TestDwarfBot_SendMessage_RecordsFailureMetric(t *testing.T) {
 		Metrics: rec,
 	}
 
-	_ = bot.SendMessage("ch", "hello")
+	_ = bot.SendMessage(context.Background(), "ch", "hello")
 
 	rec.mu.Lock()
 	defer rec.mu.Unlock()
@@ -1101,7 +1214,7 @@ func TestParseCommand_RecordsCommandMetric(t *testing.T) {
 	rec := newMockMetricsRecorder()
 	mock := newMockPlatform("testbot", []string{"ch1"})
 
-	_ = parseCommand(mock, "ch1", "user", "ping", []string{}, parseCommandOpts{
+	_ = parseCommand(context.Background(), mock, "ch1", "user", "ping", []string{}, parseCommandOpts{
 		metrics:      rec,
 		platformName: "twitch",
 	})
@@ -1128,7 +1241,7 @@ func TestParseCommand_RecordsAdminMetric(t *testing.T) {
 		return user == "admin"
 	})
 
-	_ = parseCommand(mock, "ch1", "admin", "ping", []string{}, parseCommandOpts{
+	_ = parseCommand(context.Background(), mock, "ch1", "admin", "ping", []string{}, parseCommandOpts{
 		metrics:      rec,
 		platformName: "discord",
 	})
@@ -1147,7 +1260,7 @@ func TestParseCommand_UnknownCommandNormalized(t *testing.T) {
 	rec := newMockMetricsRecorder()
 	mock := newMockPlatform("testbot", []string{"ch1"})
 
-	_ = parseCommand(mock, "ch1", "user", "xyzgarbage", []string{}, parseCommandOpts{
+	_ = parseCommand(context.Background(), mock, "ch1", "user", "xyzgarbage", []string{}, parseCommandOpts{
 		metrics:      rec,
 		platformName: "twitch",
 	})
@@ -1325,3 +1438,52 @@ func TestStart_ReturnsNilOnStop(t *testing.T) {
 
 // Verify DwarfBot satisfies ChatPlatform at compile time
 var _ ChatPlatform = (*DwarfBot)(nil)
+
+// --- SetChannels Tests ---
+
+func TestSetChannels_JoinsAndParts(t *testing.T) {
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	type result struct{ joined, parted []string }
+	done := make(chan result, 1)
+	go func() {
+		j, p := bot.SetChannels([]string{"#Channel2", "channel3", "channel3"})
+		done <- result{j, p}
+	}()
+
+	all := readAllFromConn(t, server, 2)
+	res := <-done
+
+	if fmt.Sprint(res.joined) != "[channel3]" || fmt.Sprint(res.parted) != "[channel1]" {
+		t.Errorf("unexpected diff: joined=%v parted=%v", res.joined, res.parted)
+	}
+	if !strings.Contains(all, "JOIN #channel3\r\n") || !strings.Contains(all, "PART #channel1\r\n") {
+		t.Errorf("expected JOIN and PART, got %q", all)
+	}
+	if got := bot.BotChannels(); fmt.Sprint(got) != "[channel2 channel3]" {
+		t.Errorf("expected channels [channel2 channel3], got %v", got)
+	}
+}
+
+func TestSetChannels_NotConnected(t *testing.T) {
+	bot := &DwarfBot{Name: "testbot", Channels: []string{"a"}}
+	joined, parted := bot.SetChannels([]string{"b"})
+	if fmt.Sprint(joined) != "[b]" || fmt.Sprint(parted) != "[a]" {
+		t.Errorf("unexpected diff: joined=%v parted=%v", joined, parted)
+	}
+}
+
+func TestSetAliases(t *testing.T) {
+	defer SetAliases(nil)
+
+	SetAliases([]string{" Dwarfy ", ""})
+	if !isAlias("dwarfy") || isAlias("dwarfbot") {
+		t.Error("expected only the configured alias to match")
+	}
+
+	SetAliases(nil)
+	if !isAlias("dwarfbot") || !isAlias("hammerdwarfbot") {
+		t.Error("expected empty aliases to restore the defaults")
+	}
+}
@@ -0,0 +1,51 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestStore opens the store at path, or an in-memory one if path is
+// empty.
+func openTestStore(t *testing.T, path string) *store.Store {
+	t.Helper()
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// pinClock stops the clock in *nowFunc at at. Tests move it forward
+// through the returned pointer.
+func pinClock(nowFunc *func() time.Time, at time.Time) *time.Time {
+	now := at
+	*nowFunc = func() time.Time { return now }
+	return &now
+}
+
+// commandRequest is what a handler receives when user runs command with
+// args in "chan" on the given platform.
+func commandRequest(platform ChatPlatform, platformName, user string, admin bool, command, args string) CommandRequest {
+	return CommandRequest{
+		Context:      context.Background(),
+		Platform:     platform,
+		PlatformName: platformName,
+		Channel:      "chan",
+		User:         user,
+		UserName:     user,
+		Admin:        admin,
+		Command:      command,
+		Arguments:    strings.Fields(args),
+	}
+}
+
+func lastMessage(m *mockPlatform) string {
+	if len(m.messages) == 0 {
+		return ""
+	}
+	return m.messages[len(m.messages)-1].msg
+}
@@ -0,0 +1,390 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// identitiesBucket is the store bucket linked accounts are kept in, one
+// record per account keyed by AccountKey.
+const identitiesBucket = "identities"
+
+// Link codes are short enough to type and expire quickly; each one can
+// be redeemed once.
+const (
+	linkCodeLength   = 6
+	linkCodeTTL      = 10 * time.Minute
+	linkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	errLinkCodeInvalid   = errors.New("link code is unknown or expired")
+	errLinkSamePlatform  = errors.New("link code must be redeemed on another platform")
+	errAlreadyLinked     = errors.New("account is already linked")
+	errLinkPlatformTaken = errors.New("identity already has an account on this platform")
+)
+
+// DirectMessenger is implemented by platforms that can message a user
+// privately. Link codes are only issued where they can be sent in
+// private, so nobody watching chat can redeem them first.
+type DirectMessenger interface {
+	SendDirectMessage(ctx context.Context, userID, msg string) error
+}
+
+// LinkHook moves per-user state kept under an account's own key into
+// the identity it has just joined. It runs inside the store transaction
+// that links the accounts, and the func it returns, if any, runs once
+// that transaction has committed.
+type LinkHook func(tx *store.Tx, identity string, accounts []string) (func(), error)
+
+// LinkedAccount is one platform account that belongs to an identity.
+type LinkedAccount struct {
+	Identity string    `json:"identity"`
+	Platform string    `json:"platform"`
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// pendingLink is an issued link code waiting to be redeemed.
+type pendingLink struct {
+	platform string
+	userID   string
+	userName string
+	expires  time.Time
+}
+
+// Identities links accounts on different platforms into one identity,
+// so features keyed by user (ACL rules, quote attribution, anything
+// keeping per-user state) treat them as the same person. An account
+// that was never linked is its own identity, named by AccountKey.
+// Link codes are kept in memory; a restart invalidates them.
+type Identities struct {
+	mu       sync.RWMutex
+	store    *store.Store
+	accounts map[string]LinkedAccount
+	codes    map[string]pendingLink
+	hooks    []LinkHook
+	nowFunc  func() time.Time
+	randIntN func(n int) int
+}
+
+// NewIdentities loads linked accounts from s.
+func NewIdentities(s *store.Store) (*Identities, error) {
+	ids := &Identities{
+		store:    s,
+		accounts: map[string]LinkedAccount{},
+		codes:    map[string]pendingLink{},
+		nowFunc:  time.Now,
+		randIntN: rand.IntN,
+	}
+	err := s.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(identitiesBucket) {
+			var acct LinkedAccount
+			if err := tx.Get(identitiesBucket, key, &acct); err != nil {
+				return err
+			}
+			ids.accounts[key] = acct
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading identities: %w", err)
+	}
+	return ids, nil
+}
+
+// AccountKey names a platform account: "twitch:hammerdwarf" or
+// "discord:123456789012345678".
+func AccountKey(platform, userID string) string {
+	return strings.ToLower(platform) + ":" + strings.ToLower(userID)
+}
+
+// discordUserMentionRegex matches a Discord user mention, "<@123>" or
+// "<@!123>".
+var discordUserMentionRegex = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// userArgument turns a command argument naming a user into their ID: a
+// Twitch login (with or without "@"), or a Discord mention or user ID.
+func userArgument(platform, arg string) (string, bool) {
+	if platform == "discord" {
+		if m := discordUserMentionRegex.FindStringSubmatch(arg); m != nil {
+			return m[1], true
+		}
+		if _, err := strconv.ParseUint(arg, 10, 64); err == nil {
+			return arg, true
+		}
+		return "", false
+	}
+	user := normalizeIgnoredUser(arg)
+	return user, user != ""
+}
+
+// OnLink adds a hook run whenever an account joins an identity.
+func (ids *Identities) OnLink(hook LinkHook) {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+	ids.hooks = append(ids.hooks, hook)
+}
+
+// Resolve returns the identity the account belongs to. Unlinked
+// accounts, and every account when ids is nil, resolve to their own
+// AccountKey.
+func (ids *Identities) Resolve(platform, userID string) string {
+	key := AccountKey(platform, userID)
+	if ids == nil {
+		return key
+	}
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	if acct, ok := ids.accounts[key]; ok {
+		return acct.Identity
+	}
+	return key
+}
+
+// Accounts returns the accounts linked to identity, sorted by platform.
+// It is empty for an identity with no links.
+func (ids *Identities) Accounts(identity string) []LinkedAccount {
+	ids.mu.RLock()
+	defer ids.mu.RUnlock()
+	var out []LinkedAccount
+	for _, acct := range ids.accounts {
+		if acct.Identity == identity {
+			out = append(out, acct)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Platform < out[j].Platform })
+	return out
+}
+
+// IssueCode returns a new one-time code for the account, replacing any
+// code it was issued before.
+func (ids *Identities) IssueCode(platform, userID, userName string) string {
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+
+	now := ids.nowFunc()
+	key := AccountKey(platform, userID)
+	for code, p := range ids.codes {
+		if now.After(p.expires) || AccountKey(p.platform, p.userID) == key {
+			delete(ids.codes, code)
+		}
+	}
+
+	for {
+		b := make([]byte, linkCodeLength)
+		for i := range b {
+			b[i] = linkCodeAlphabet[ids.randIntN(len(linkCodeAlphabet))]
+		}
+		code := string(b)
+		if _, taken := ids.codes[code]; taken {
+			continue
+		}
+		ids.codes[code] = pendingLink{
+			platform: strings.ToLower(platform),
+			userID:   userID,
+			userName: userName,
+			expires:  now.Add(linkCodeTTL),
+		}
+		return code
+	}
+}
+
+// Redeem links the redeeming account to the identity of the account the
+// code was issued to, and returns the identity.
+func (ids *Identities) Redeem(code, platform, userID, userName string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	platform = strings.ToLower(platform)
+
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+
+	now := ids.nowFunc()
+	p, ok := ids.codes[code]
+	if !ok || now.After(p.expires) {
+		return "", errLinkCodeInvalid
+	}
+	if p.platform == platform {
+		return "", errLinkSamePlatform
+	}
+	redeemerKey := AccountKey(platform, userID)
+	if _, linked := ids.accounts[redeemerKey]; linked {
+		return "", errAlreadyLinked
+	}
+
+	issuerKey := AccountKey(p.platform, p.userID)
+	issuer, issuerLinked := ids.accounts[issuerKey]
+	if !issuerLinked {
+		issuer = LinkedAccount{Identity: issuerKey, Platform: p.platform, UserID: p.userID, UserName: p.userName, LinkedAt: now}
+	}
+	for _, acct := range ids.accounts {
+		if acct.Identity == issuer.Identity && acct.Platform == platform {
+			return "", errLinkPlatformTaken
+		}
+	}
+	redeemer := LinkedAccount{Identity: issuer.Identity, Platform: platform, UserID: userID, UserName: userName, LinkedAt: now}
+
+	var committed []func()
+	err := ids.store.Update(func(tx *store.Tx) error {
+		if !issuerLinked {
+			if err := tx.Put(identitiesBucket, issuerKey, issuer); err != nil {
+				return err
+			}
+		}
+		if err := tx.Put(identitiesBucket, redeemerKey, redeemer); err != nil {
+			return err
+		}
+		// An unlinked issuer's key is the identity, so only the
+		// redeemer's state needs moving.
+		for _, hook := range ids.hooks {
+			done, err := hook(tx, issuer.Identity, []string{redeemerKey})
+			if err != nil {
+				return err
+			}
+			if done != nil {
+				committed = append(committed, done)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, done := range committed {
+		done()
+	}
+	ids.accounts[issuerKey] = issuer
+	ids.accounts[redeemerKey] = redeemer
+	delete(ids.codes, code)
+	return issuer.Identity, nil
+}
+
+// Unlink removes the account from its identity. When that leaves a
+// single account, it is unlinked too. It returns store.ErrNotFound if
+// the account isn't linked.
+func (ids *Identities) Unlink(platform, userID string) error {
+	key := AccountKey(platform, userID)
+
+	ids.mu.Lock()
+	defer ids.mu.Unlock()
+
+	acct, ok := ids.accounts[key]
+	if !ok {
+		return store.ErrNotFound
+	}
+	remove := []string{key}
+	var rest []string
+	for k, other := range ids.accounts {
+		if k != key && other.Identity == acct.Identity {
+			rest = append(rest, k)
+		}
+	}
+	if len(rest) == 1 {
+		remove = append(remove, rest[0])
+	}
+
+	err := ids.store.Update(func(tx *store.Tx) error {
+		for _, k := range remove {
+			if err := tx.Delete(identitiesBucket, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range remove {
+		delete(ids.accounts, k)
+	}
+	return nil
+}
+
+// RegisterCommands registers the "link" and "unlink" commands.
+func (ids *Identities) RegisterCommands() {
+	RegisterCommand("link", ids.HandleLinkCommand)
+	RegisterCommand("unlink", ids.HandleUnlinkCommand)
+}
+
+// HandleLinkCommand implements "link", which sends the author a code in
+// private, "link <code>", which redeems it on another platform, and
+// "link status".
+func (ids *Identities) HandleLinkCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+
+	if len(req.Arguments) == 0 {
+		dm, ok := req.Platform.(DirectMessenger)
+		if !ok {
+			return reply(Say(req.Channel, "link.no_dm", nil))
+		}
+		code := ids.IssueCode(req.PlatformName, req.User, req.UserName)
+		msg := Say(req.Channel, "link.code", Vars{"Code": code, "Minutes": int(linkCodeTTL / time.Minute)})
+		if err := dm.SendDirectMessage(req.Context, req.User, msg); err != nil {
+			log.Printf("Identity: sending link code to %s on %s: %v", req.User, req.PlatformName, err)
+			return reply(Say(req.Channel, "link.dm_failed", nil))
+		}
+		return reply(Say(req.Channel, "link.sent", nil))
+	}
+
+	if strings.EqualFold(req.Arguments[0], "status") {
+		accounts := ids.Accounts(ids.Resolve(req.PlatformName, req.User))
+		if len(accounts) == 0 {
+			return reply(Say(req.Channel, "link.none", nil))
+		}
+		return reply(Say(req.Channel, "link.status", Vars{"Accounts": describeAccounts(accounts)}))
+	}
+
+	identity, err := ids.Redeem(req.Arguments[0], req.PlatformName, req.User, req.UserName)
+	switch {
+	case errors.Is(err, errLinkCodeInvalid):
+		return reply(Say(req.Channel, "link.invalid", nil))
+	case errors.Is(err, errLinkSamePlatform):
+		return reply(Say(req.Channel, "link.same_platform", nil))
+	case errors.Is(err, errAlreadyLinked):
+		return reply(Say(req.Channel, "link.already", nil))
+	case errors.Is(err, errLinkPlatformTaken):
+		return reply(Say(req.Channel, "link.platform_taken", Vars{"Platform": req.PlatformName}))
+	case err != nil:
+		return fmt.Errorf("linking account: %w", err)
+	}
+	log.Printf("Identity: linked %s to %s", AccountKey(req.PlatformName, req.User), identity)
+	return reply(Say(req.Channel, "link.linked", Vars{"Accounts": describeAccounts(ids.Accounts(identity))}))
+}
+
+// HandleUnlinkCommand implements "unlink", which removes the author's
+// account from its identity.
+func (ids *Identities) HandleUnlinkCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	if err := ids.Unlink(req.PlatformName, req.User); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return reply(Say(req.Channel, "link.none", nil))
+		}
+		return fmt.Errorf("unlinking account: %w", err)
+	}
+	log.Printf("Identity: unlinked %s", AccountKey(req.PlatformName, req.User))
+	return reply(Say(req.Channel, "link.unlinked", nil))
+}
+
+// describeAccounts renders accounts as "twitch hammerdwarf".
+func describeAccounts(accounts []LinkedAccount) []string {
+	out := make([]string, len(accounts))
+	for i, acct := range accounts {
+		out[i] = acct.Platform + " " + acct.UserName
+	}
+	return out
+}
@@ -0,0 +1,201 @@
+package dwarfbot
+
+import (
+	"context"
+	"math/rand/v2"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dmPlatform records direct messages, like Discord.
+type dmPlatform struct {
+	*mockPlatform
+	dms []mockMessage
+}
+
+func (d *dmPlatform) SendDirectMessage(_ context.Context, userID, msg string) error {
+	d.dms = append(d.dms, mockMessage{channel: userID, msg: msg})
+	return nil
+}
+
+func newTestIdentities(t *testing.T, path string) *Identities {
+	t.Helper()
+	ids, err := NewIdentities(openTestStore(t, path))
+	if err != nil {
+		t.Fatalf("NewIdentities: %v", err)
+	}
+	return ids
+}
+
+func link(t *testing.T, ids *Identities, platform ChatPlatform, platformName, user string, args string) {
+	t.Helper()
+	if err := ids.HandleLinkCommand(commandRequest(platform, platformName, user, false, "link", args)); err != nil {
+		t.Fatalf("link %s: %v", args, err)
+	}
+}
+
+func TestIdentities_LinkFlow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	ids := newTestIdentities(t, path)
+	discord := &dmPlatform{mockPlatform: newMockPlatform("testbot", []string{"chan"})}
+	twitch := newMockPlatform("testbot", []string{"chan"})
+
+	link(t, ids, discord, "discord", "42", "")
+	if len(discord.dms) != 1 || discord.dms[0].channel != "42" {
+		t.Fatalf("expected the code in a DM to 42, got %v", discord.dms)
+	}
+	var code string
+	for _, f := range strings.Fields(discord.dms[0].msg) {
+		if len(f) == linkCodeLength+1 && strings.HasSuffix(f, ".") {
+			code = strings.TrimSuffix(f, ".")
+		}
+	}
+	if code == "" {
+		t.Fatalf("no code in %q", discord.dms[0].msg)
+	}
+	if strings.Contains(lastMessage(discord.mockPlatform), code) {
+		t.Errorf("expected the code to stay out of the channel, got %q", lastMessage(discord.mockPlatform))
+	}
+
+	link(t, ids, discord, "discord", "42", code)
+	if !strings.Contains(lastMessage(discord.mockPlatform), "other platform") {
+		t.Errorf("expected same-platform redemption to be refused, got %q", lastMessage(discord.mockPlatform))
+	}
+
+	link(t, ids, twitch, "twitch", "HammerDwarf", strings.ToLower(code))
+	if got := lastMessage(twitch); !strings.Contains(got, "discord 42") || !strings.Contains(got, "twitch HammerDwarf") {
+		t.Errorf("unexpected link reply %q", got)
+	}
+	if got, want := ids.Resolve("twitch", "hammerdwarf"), "discord:42"; got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := ids.Resolve("discord", "42"); got != "discord:42" {
+		t.Errorf("expected the issuer to keep its identity, got %s", got)
+	}
+
+	link(t, ids, twitch, "twitch", "someoneelse", code)
+	if !strings.Contains(lastMessage(twitch), "stale") {
+		t.Errorf("expected a used code to be rejected, got %q", lastMessage(twitch))
+	}
+
+	reloaded := newTestIdentities(t, path)
+	if got := reloaded.Resolve("twitch", "hammerdwarf"); got != "discord:42" {
+		t.Errorf("expected the link to persist, got %s", got)
+	}
+}
+
+func TestIdentities_NoDMPlatform(t *testing.T) {
+	ids := newTestIdentities(t, "")
+	twitch := newMockPlatform("testbot", []string{"chan"})
+	link(t, ids, twitch, "twitch", "viewer", "")
+	if len(ids.codes) != 0 {
+		t.Error("expected no code to be issued where it can't be sent privately")
+	}
+	if !strings.Contains(lastMessage(twitch), "Discord") {
+		t.Errorf("unexpected reply %q", lastMessage(twitch))
+	}
+}
+
+func TestIdentities_Redeem(t *testing.T) {
+	ids := newTestIdentities(t, "")
+	now := pinClock(&ids.nowFunc, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	code := ids.IssueCode("discord", "42", "gimli")
+	if _, err := ids.Redeem("NOPE00", "twitch", "gimli", "gimli"); err != errLinkCodeInvalid {
+		t.Errorf("expected invalid code, got %v", err)
+	}
+
+	ids.randIntN = func(n int) int { return n - 1 }
+	reissued := ids.IssueCode("discord", "42", "gimli")
+	if reissued == code {
+		t.Fatal("expected a different code")
+	}
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != errLinkCodeInvalid {
+		t.Errorf("expected reissuing to replace the old code, got %v", err)
+	}
+	ids.randIntN = rand.IntN
+
+	*now = now.Add(linkCodeTTL + time.Second)
+	if _, err := ids.Redeem(reissued, "twitch", "gimli", "gimli"); err != errLinkCodeInvalid {
+		t.Errorf("expected expired code, got %v", err)
+	}
+
+	code = ids.IssueCode("discord", "42", "gimli")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != nil {
+		t.Fatal(err)
+	}
+
+	code = ids.IssueCode("discord", "42", "gimli")
+	if _, err := ids.Redeem(code, "twitch", "legolas", "legolas"); err != errLinkPlatformTaken {
+		t.Errorf("expected one account per platform, got %v", err)
+	}
+	code = ids.IssueCode("discord", "99", "legolas")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != errAlreadyLinked {
+		t.Errorf("expected linked accounts to be refused, got %v", err)
+	}
+}
+
+func TestIdentities_Unlink(t *testing.T) {
+	ids := newTestIdentities(t, "")
+	code := ids.IssueCode("discord", "42", "gimli")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != nil {
+		t.Fatal(err)
+	}
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	req := commandRequest(mock, "twitch", "gimli", false, "unlink", "")
+	if err := ids.HandleUnlinkCommand(req); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids.Resolve("twitch", "gimli"); got != "twitch:gimli" {
+		t.Errorf("expected twitch account to be its own identity again, got %s", got)
+	}
+	if got := ids.Accounts("discord:42"); len(got) != 0 {
+		t.Errorf("expected the lone remaining account to be unlinked, got %v", got)
+	}
+	if err := ids.HandleUnlinkCommand(req); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(lastMessage(mock), "isn") {
+		t.Errorf("expected not linked reply, got %q", lastMessage(mock))
+	}
+}
+
+func TestParseCommand_ResolvesIdentity(t *testing.T) {
+	ResetRegistrations()
+	t.Cleanup(ResetRegistrations)
+
+	ids := newTestIdentities(t, "")
+	code := ids.IssueCode("discord", "42", "gimli")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != nil {
+		t.Fatal(err)
+	}
+
+	var got CommandRequest
+	RegisterCommand("whoami", func(req CommandRequest) error {
+		got = req
+		return nil
+	})
+	acl, err := NewACL([]ACLRule{{Command: "whoami", Action: "deny", Users: []string{"discord:42"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	if err := parseCommand(context.Background(), mock, "chan", "someone", "whoami", nil, parseCommandOpts{platformName: "twitch", identities: ids, acl: acl}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Identity != "twitch:someone" {
+		t.Errorf("expected an unlinked account to be its own identity, got %q", got.Identity)
+	}
+
+	got = CommandRequest{}
+	if err := parseCommand(context.Background(), mock, "chan", "gimli", "whoami", nil, parseCommandOpts{platformName: "twitch", identities: ids, acl: acl}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Command != "" {
+		t.Error("expected an ACL rule on the identity to cover the linked Twitch account")
+	}
+}
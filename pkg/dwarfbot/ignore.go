@@ -0,0 +1,195 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ignoreBucket is the store bucket users ignored from chat are kept in.
+const ignoreBucket = "ignore"
+
+// IgnoredUser is a user added to the ignore list from chat.
+type IgnoredUser struct {
+	User    string    `json:"user"`
+	AddedBy string    `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+// IgnoreList drops messages from listed users before listeners and
+// command dispatch see them. Users come from config (read-only) and from
+// the "ignore" chat command (persisted in the store). Entries are Twitch
+// logins or Discord user IDs.
+type IgnoreList struct {
+	mu         sync.RWMutex
+	store      *store.Store
+	configured map[string]bool
+	added      map[string]bool
+	nowFunc    func() time.Time
+}
+
+// NewIgnoreList returns an IgnoreList backed by s, seeded with the
+// configured users and everything previously added from chat.
+func NewIgnoreList(s *store.Store, users []string) *IgnoreList {
+	l := &IgnoreList{store: s, added: map[string]bool{}, nowFunc: time.Now}
+	l.SetConfigured(users)
+	for _, key := range s.Keys(ignoreBucket) {
+		l.added[key] = true
+	}
+	return l
+}
+
+func normalizeIgnoredUser(user string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(user), "@"))
+}
+
+// SetConfigured replaces the users ignored by config. Users added from
+// chat are kept.
+func (l *IgnoreList) SetConfigured(users []string) {
+	configured := make(map[string]bool, len(users))
+	for _, u := range users {
+		if u = normalizeIgnoredUser(u); u != "" {
+			configured[u] = true
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.configured = configured
+}
+
+// Ignored reports whether messages from user are dropped.
+func (l *IgnoreList) Ignored(user string) bool {
+	user = normalizeIgnoredUser(user)
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.configured[user] || l.added[user]
+}
+
+// Add ignores user and persists it.
+func (l *IgnoreList) Add(user, addedBy string) error {
+	user = normalizeIgnoredUser(user)
+	if user == "" {
+		return fmt.Errorf("user must not be empty")
+	}
+	entry := IgnoredUser{User: user, AddedBy: addedBy, AddedAt: l.nowFunc()}
+	if err := l.store.Put(ignoreBucket, user, entry); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.added[user] = true
+	return nil
+}
+
+// Remove stops ignoring a user added from chat. It returns
+// store.ErrNotFound if the user wasn't added from chat.
+func (l *IgnoreList) Remove(user string) error {
+	user = normalizeIgnoredUser(user)
+	err := l.store.Update(func(tx *store.Tx) error {
+		var existing IgnoredUser
+		if err := tx.Get(ignoreBucket, user, &existing); err != nil {
+			return err
+		}
+		return tx.Delete(ignoreBucket, user)
+	})
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.added, user)
+	return nil
+}
+
+// Users returns every ignored user, sorted.
+func (l *IgnoreList) Users() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	seen := make(map[string]bool, len(l.configured)+len(l.added))
+	for u := range l.configured {
+		seen[u] = true
+	}
+	for u := range l.added {
+		seen[u] = true
+	}
+	out := make([]string, 0, len(seen))
+	for u := range seen {
+		out = append(out, u)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Middleware drops messages from ignored users.
+func (l *IgnoreList) Middleware(next InboundHandler) InboundHandler {
+	return func(ctx context.Context, msg Message) error {
+		if l.Ignored(msg.UserID) {
+			return nil
+		}
+		return next(ctx, msg)
+	}
+}
+
+// RegisterCommands installs the inbound middleware and registers the
+// admin-only "ignore" command.
+func (l *IgnoreList) RegisterCommands() {
+	RegisterInboundMiddleware("ignore", l.Middleware)
+	RegisterAdminCommand("ignore", l.HandleCommand)
+}
+
+// HandleCommand implements "ignore list|add <user>|del <user>".
+func (l *IgnoreList) HandleCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	usage := "Usage: ignore list|add <user>|del <user>"
+	if len(req.Arguments) == 0 {
+		return reply(usage)
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "list":
+		users := l.Users()
+		if len(users) == 0 {
+			return reply(Say(req.Channel, "ignore.none", nil))
+		}
+		return reply(Say(req.Channel, "ignore.list", Vars{"Users": users}))
+	case "add":
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		user := normalizeIgnoredUser(req.Arguments[1])
+		if err := l.Add(user, req.UserName); err != nil {
+			return fmt.Errorf("ignoring user: %w", err)
+		}
+		log.Printf("Ignore: %s added %s", req.UserName, user)
+		return reply(Say(req.Channel, "ignore.added", Vars{"User": user}))
+	case "del", "delete", "remove":
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		user := normalizeIgnoredUser(req.Arguments[1])
+		if err := l.Remove(user); err != nil {
+			if !errors.Is(err, store.ErrNotFound) {
+				return fmt.Errorf("unignoring user: %w", err)
+			}
+			l.mu.RLock()
+			configured := l.configured[user]
+			l.mu.RUnlock()
+			if configured {
+				return reply(Say(req.Channel, "ignore.configured", Vars{"User": user}))
+			}
+			return reply(Say(req.Channel, "ignore.not_found", Vars{"User": user}))
+		}
+		log.Printf("Ignore: %s removed %s", req.UserName, user)
+		return reply(Say(req.Channel, "ignore.removed", Vars{"User": user}))
+	default:
+		return reply(usage)
+	}
+}
@@ -0,0 +1,116 @@
+package dwarfbot
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIgnoreList_DropsBeforeDispatch(t *testing.T) {
+	defer ResetRegistrations()
+	NewIgnoreList(openTestStore(t, ""), []string{"@Nightbot"}).RegisterCommands()
+	var heard []string
+	RegisterMessageListener("test", func(msg Message) { heard = append(heard, msg.UserID) })
+	mock := newMockPlatform("testbot", []string{"ch"})
+	h := inboundPipeline(nil, func(ctx context.Context, msg Message) error {
+		return dispatchInbound(ctx, mock, msg, parseCommandOpts{platformName: "twitch"})
+	})
+
+	for _, user := range []string{"nightbot", "viewer"} {
+		if err := h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserID: user, Text: "!dwarfbot ping"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(heard) != 1 || heard[0] != "viewer" {
+		t.Errorf("expected only viewer to reach listeners, got %v", heard)
+	}
+	if len(mock.messages) != 1 {
+		t.Errorf("expected only viewer's command to run, got %v", mock.messages)
+	}
+}
+
+func TestIgnoreList_ManageFromChat(t *testing.T) {
+	defer ResetRegistrations()
+	path := filepath.Join(t.TempDir(), "state.json")
+	l := NewIgnoreList(openTestStore(t, path), []string{"nightbot"})
+	l.RegisterCommands()
+	mock := newMockPlatformWithAdmin("testbot", []string{"ch"}, adminIs("boss"))
+	run := func(user string, args ...string) string {
+		t.Helper()
+		before := len(mock.messages)
+		if err := parseCommand(context.Background(), mock, "ch", user, "ignore", args); err != nil {
+			t.Fatalf("ignore %v: %v", args, err)
+		}
+		if len(mock.messages) == before {
+			return ""
+		}
+		return mock.messages[len(mock.messages)-1].msg
+	}
+
+	if got := run("viewer", "add", "boss"); got != "" {
+		t.Errorf("expected non-admins to be ignored, got %q", got)
+	}
+	run("boss", "add", "@StreamElements")
+	if !l.Ignored("streamelements") {
+		t.Error("expected streamelements to be ignored")
+	}
+	if got := run("boss", "list"); !strings.Contains(got, "nightbot streamelements") {
+		t.Errorf("expected both users listed, got %q", got)
+	}
+	if got := run("boss", "del", "nightbot"); !strings.Contains(got, "config") {
+		t.Errorf("expected configured users to be refused, got %q", got)
+	}
+
+	if !NewIgnoreList(openTestStore(t, path), nil).Ignored("streamelements") {
+		t.Error("expected chat additions to persist")
+	}
+
+	run("boss", "del", "streamelements")
+	if l.Ignored("streamelements") {
+		t.Error("expected streamelements to be removed")
+	}
+	if got := run("boss", "del", "streamelements"); !strings.Contains(got, "wasnae") {
+		t.Errorf("expected not-found reply, got %q", got)
+	}
+}
+
+func TestDispatchInbound_SkipsBotsUnlessAllowed(t *testing.T) {
+	defer ResetRegistrations()
+	mock := newMockPlatform("testbot", []string{"1"})
+	var heard int
+	RegisterMessageListener("test", func(Message) { heard++ })
+	opts := parseCommandOpts{platformName: "discord", allowBots: []string{"42"}}
+
+	for _, id := range []string{"13", "42"} {
+		msg := Message{Platform: "discord", Channel: "1", UserID: id, Text: "!dwarfbot ping", IsBot: true}
+		if err := dispatchInbound(context.Background(), mock, msg, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if heard != 2 {
+		t.Errorf("expected listeners to still see bot messages, got %d", heard)
+	}
+	if len(mock.messages) != 1 {
+		t.Errorf("expected only the allowlisted bot's command to run, got %v", mock.messages)
+	}
+}
+
+func TestHandleChat_IgnoresOwnMessages(t *testing.T) {
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	done := make(chan error, 1)
+	go func() { done <- bot.HandleChat() }()
+	_, _ = server.Write([]byte(":testbot!testbot@testbot.tmi.twitch.tv PRIVMSG #channel1 :!dwarfbot ping\r\n"))
+	if got := readFromConn(t, server); got != "" {
+		t.Errorf("expected no reply to the bot's own message, got %q", got)
+	}
+	_ = server.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleChat did not return")
+	}
+}
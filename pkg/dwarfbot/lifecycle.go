@@ -0,0 +1,116 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/events"
+	"sync"
+)
+
+// LifecycleAction is what the process should do once a run ends.
+type LifecycleAction int
+
+const (
+	// ActionNone means the run ended without a request (e.g. a signal
+	// cancelled the parent context), which is treated as a shutdown.
+	ActionNone LifecycleAction = iota
+
+	// ActionShutdown exits the process after a graceful stop.
+	ActionShutdown
+
+	// ActionRestart stops everything gracefully, reloads config and
+	// starts again without exiting.
+	ActionRestart
+)
+
+// Lifecycle coordinates stopping the bot. Chat handlers request a
+// shutdown or restart; the owner of the run (cmd/root.go) waits on Done,
+// tears everything down in order, then acts on Result. This keeps
+// os.Exit out of chat handlers so the graceful path always runs.
+type Lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	action   LifecycleAction
+	exitCode int
+}
+
+// NewLifecycle returns a Lifecycle whose context is cancelled when
+// parent is, or when Shutdown or Restart is called.
+func NewLifecycle(parent context.Context) *Lifecycle {
+	ctx, cancel := context.WithCancel(parent)
+	return &Lifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Context is cancelled when the current run should stop. A nil
+// Lifecycle returns context.Background().
+func (l *Lifecycle) Context() context.Context {
+	if l == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// Done is closed when the current run should stop.
+func (l *Lifecycle) Done() <-chan struct{} {
+	return l.ctx.Done()
+}
+
+// Shutdown requests a graceful stop followed by exit with exitCode.
+func (l *Lifecycle) Shutdown(exitCode int) {
+	l.request(ActionShutdown, exitCode)
+}
+
+// Restart requests a graceful stop followed by a fresh start.
+func (l *Lifecycle) Restart() {
+	l.request(ActionRestart, 0)
+}
+
+// request records the first action asked for; later requests in the same
+// run are ignored so a restart can't be turned into a shutdown halfway.
+func (l *Lifecycle) request(action LifecycleAction, exitCode int) {
+	l.mu.Lock()
+	if l.action == ActionNone {
+		l.action = action
+		l.exitCode = exitCode
+	}
+	l.mu.Unlock()
+	l.cancel()
+}
+
+// Result returns the requested action and exit code.
+func (l *Lifecycle) Result() (LifecycleAction, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.action, l.exitCode
+}
+
+// HandleRestartCommand implements the "restart" admin command.
+func (l *Lifecycle) HandleRestartCommand(req CommandRequest) error {
+	if err := req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "restart.ack", nil)); err != nil {
+		return err
+	}
+	l.Restart()
+	return nil
+}
+
+// ResetRegistrations clears every registered command, message and
+// reaction listener, middleware, event subscription and the MQTT handler
+// so a restarted run can register them afresh.
+func ResetRegistrations() {
+	commandRegistryMu.Lock()
+	commandRegistry = map[string]registeredCommand{}
+	commandRegistryMu.Unlock()
+
+	messageListenersMu.Lock()
+	messageListeners = nil
+	messageListenersMu.Unlock()
+
+	reactionListenersMu.Lock()
+	reactionListeners = map[string]ReactionListenerFunc{}
+	reactionListenersMu.Unlock()
+
+	resetMiddleware()
+	events.Default.Reset()
+	RegisterMQTTHandler(nil)
+}
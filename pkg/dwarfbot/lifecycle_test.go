@@ -0,0 +1,124 @@
+package dwarfbot
+
+import (
+	"context"
+	"testing"
+)
+
+func isDone(l *Lifecycle) bool {
+	select {
+	case <-l.Done():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestLifecycle_Shutdown(t *testing.T) {
+	l := NewLifecycle(context.Background())
+	if isDone(l) {
+		t.Fatal("expected new lifecycle not to be done")
+	}
+
+	l.Shutdown(3)
+	if !isDone(l) {
+		t.Fatal("expected lifecycle to be done after Shutdown")
+	}
+	if action, code := l.Result(); action != ActionShutdown || code != 3 {
+		t.Errorf("expected (ActionShutdown, 3), got (%v, %d)", action, code)
+	}
+}
+
+func TestLifecycle_FirstRequestWins(t *testing.T) {
+	l := NewLifecycle(context.Background())
+	l.Restart()
+	l.Shutdown(1)
+	if action, code := l.Result(); action != ActionRestart || code != 0 {
+		t.Errorf("expected (ActionRestart, 0), got (%v, %d)", action, code)
+	}
+}
+
+func TestLifecycle_ParentCancel(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	l := NewLifecycle(parent)
+	cancel()
+	if !isDone(l) {
+		t.Fatal("expected lifecycle to be done when parent is cancelled")
+	}
+	if action, _ := l.Result(); action != ActionNone {
+		t.Errorf("expected ActionNone, got %v", action)
+	}
+}
+
+func TestLifecycle_RestartCommand(t *testing.T) {
+	l := NewLifecycle(context.Background())
+	RegisterAdminCommand("restart", l.HandleRestartCommand)
+	defer RegisterAdminCommand("restart", nil)
+
+	mock := newMockPlatformWithAdmin("testbot", []string{"chan"}, func(_, user string) bool { return user == "boss" })
+
+	_ = parseCommand(context.Background(), mock, "chan", "viewer", "restart", nil)
+	if isDone(l) {
+		t.Fatal("expected non-admin restart to be ignored")
+	}
+
+	_ = parseCommand(context.Background(), mock, "chan", "boss", "restart", nil)
+	if !isDone(l) {
+		t.Fatal("expected admin restart to end the run")
+	}
+	if action, _ := l.Result(); action != ActionRestart {
+		t.Errorf("expected ActionRestart, got %v", action)
+	}
+	if len(mock.messages) != 1 {
+		t.Errorf("expected one acknowledgement, got %v", mock.messages)
+	}
+}
+
+func TestDwarfBot_Shutdown_UsesLifecycle(t *testing.T) {
+	l := NewLifecycle(context.Background())
+	exited := false
+	bot := &DwarfBot{Lifecycle: l, exitFunc: func(int) { exited = true }}
+
+	bot.Shutdown(context.Background(), 0)
+	if exited {
+		t.Error("expected exitFunc not to be called when a Lifecycle is set")
+	}
+	if action, _ := l.Result(); action != ActionShutdown {
+		t.Errorf("expected ActionShutdown, got %v", action)
+	}
+}
+
+func TestDiscordBot_Shutdown_UsesLifecycle(t *testing.T) {
+	l := NewLifecycle(context.Background())
+	exited := false
+	bot := &DiscordBot{Lifecycle: l, exitFunc: func(int) { exited = true }}
+
+	bot.Shutdown(context.Background(), 2)
+	if exited {
+		t.Error("expected exitFunc not to be called when a Lifecycle is set")
+	}
+	if action, code := l.Result(); action != ActionShutdown || code != 2 {
+		t.Errorf("expected (ActionShutdown, 2), got (%v, %d)", action, code)
+	}
+}
+
+func TestResetRegistrations(t *testing.T) {
+	RegisterCommand("resetme", func(CommandRequest) error { return nil })
+	RegisterMessageListener("resetme", func(Message) {})
+	RegisterMQTTHandler(func(context.Context, string, ChatPlatform, []string) {})
+
+	ResetRegistrations()
+
+	if _, ok := getCommand("resetme"); ok {
+		t.Error("expected commands to be cleared")
+	}
+	messageListenersMu.RLock()
+	n := len(messageListeners)
+	messageListenersMu.RUnlock()
+	if n != 0 {
+		t.Errorf("expected listeners to be cleared, got %d", n)
+	}
+	if getMQTTHandler() != nil {
+		t.Error("expected MQTT handler to be cleared")
+	}
+}
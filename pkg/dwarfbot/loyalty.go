@@ -0,0 +1,527 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// goldBucket is the store bucket balances are kept in, keyed by identity.
+const goldBucket = "gold"
+
+// Loyalty limits and defaults.
+const (
+	goldMaxAmount               = 1_000_000
+	goldTopSize                 = 5
+	defaultGoldChatCooldown     = time.Minute
+	defaultGoldPresenceInterval = 10 * time.Minute
+	minGoldPresenceInterval     = time.Minute
+)
+
+var (
+	errInsufficientGold = errors.New("insufficient gold")
+	errGoldSelf         = errors.New("cannot give gold to yourself")
+)
+
+// LoyaltyConfig controls how gold is earned. Zero points turn that way
+// of earning off.
+type LoyaltyConfig struct {
+	// ChatPoints is awarded for a chat message, at most once per
+	// ChatCooldown per user.
+	ChatPoints   int           `mapstructure:"chat_points"`
+	ChatCooldown time.Duration `mapstructure:"chat_cooldown"`
+
+	// PresencePoints is awarded every PresenceInterval to everyone who
+	// chatted during the previous interval.
+	PresencePoints   int           `mapstructure:"presence_points"`
+	PresenceInterval time.Duration `mapstructure:"presence_interval"`
+}
+
+// ValidateLoyaltyConfig checks points and intervals.
+func ValidateLoyaltyConfig(c LoyaltyConfig) error {
+	if c.ChatPoints < 0 || c.ChatPoints > goldMaxAmount {
+		return fmt.Errorf("loyalty: chat_points must be between 0 and %d, got %d", goldMaxAmount, c.ChatPoints)
+	}
+	if c.PresencePoints < 0 || c.PresencePoints > goldMaxAmount {
+		return fmt.Errorf("loyalty: presence_points must be between 0 and %d, got %d", goldMaxAmount, c.PresencePoints)
+	}
+	if c.ChatCooldown < 0 {
+		return fmt.Errorf("loyalty: chat_cooldown must be >= 0, got %v", c.ChatCooldown)
+	}
+	if c.PresenceInterval != 0 && c.PresenceInterval < minGoldPresenceInterval {
+		return fmt.Errorf("loyalty: presence_interval must be at least %v, got %v", minGoldPresenceInterval, c.PresenceInterval)
+	}
+	return nil
+}
+
+// GoldMetrics records ledger-wide totals. Nothing is labelled per user.
+type GoldMetrics interface {
+	RecordGoldMinted(reason string, amount int)
+	RecordGoldTransferred(amount int)
+	SetGoldSupply(total, holders int)
+}
+
+// GoldBalance is one identity's entry in the ledger.
+type GoldBalance struct {
+	Identity  string    `json:"identity"`
+	Name      string    `json:"name"`
+	Balance   int       `json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Loyalty keeps the "gold" ledger. Balances are keyed by identity, so
+// linked Twitch and Discord accounts share one purse. Every change is a
+// single store transaction and no balance can go below zero.
+type Loyalty struct {
+	mu         sync.Mutex
+	store      *store.Store
+	identities *Identities
+	metrics    GoldMetrics
+	config     LoyaltyConfig
+
+	// lastEarned is when each identity last earned gold for chatting;
+	// present is when each identity last chatted, with its name.
+	lastEarned map[string]time.Time
+	present    map[string]presence
+
+	// supply and holders mirror the ledger's totals for metrics.
+	supply  int
+	holders int
+
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	nowFunc func() time.Time
+}
+
+type presence struct {
+	name string
+	seen time.Time
+}
+
+// NewLoyalty validates config and loads the ledger totals from s.
+// identities and metrics may be nil.
+func NewLoyalty(s *store.Store, identities *Identities, metrics GoldMetrics, config LoyaltyConfig) (*Loyalty, error) {
+	if err := ValidateLoyaltyConfig(config); err != nil {
+		return nil, err
+	}
+	if config.ChatCooldown == 0 {
+		config.ChatCooldown = defaultGoldChatCooldown
+	}
+	if config.PresenceInterval == 0 {
+		config.PresenceInterval = defaultGoldPresenceInterval
+	}
+	l := &Loyalty{
+		store:      s,
+		identities: identities,
+		metrics:    metrics,
+		config:     config,
+		lastEarned: map[string]time.Time{},
+		present:    map[string]presence{},
+		nowFunc:    time.Now,
+	}
+	err := s.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(goldBucket) {
+			var b GoldBalance
+			if err := tx.Get(goldBucket, key, &b); err != nil {
+				return err
+			}
+			l.supply += b.Balance
+			if b.Balance > 0 {
+				l.holders++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading gold ledger: %w", err)
+	}
+	l.publish()
+	if identities != nil {
+		identities.OnLink(l.mergeLinked)
+	}
+	return l, nil
+}
+
+// mergeLinked is a LinkHook that moves the balances of accounts joining
+// identity into the identity's purse, so gold earned before linking
+// isn't stranded under the old account key.
+func (l *Loyalty) mergeLinked(tx *store.Tx, identity string, accounts []string) (func(), error) {
+	var into GoldBalance
+	if err := tx.Get(goldBucket, identity, &into); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	lostHolders := 0
+	merged := false
+	for _, key := range accounts {
+		if key == identity {
+			continue
+		}
+		var from GoldBalance
+		err := tx.Get(goldBucket, key, &from)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if into.Balance > 0 && from.Balance > 0 {
+			lostHolders++
+		}
+		if into.Name == "" {
+			into.Name = from.Name
+		}
+		into.Balance += from.Balance
+		if err := tx.Delete(goldBucket, key); err != nil {
+			return nil, err
+		}
+		merged = true
+	}
+	if !merged {
+		return nil, nil
+	}
+	into.Identity = identity
+	into.UpdatedAt = l.nowFunc()
+	if err := tx.Put(goldBucket, identity, into); err != nil {
+		return nil, err
+	}
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.holders -= lostHolders
+		for _, key := range accounts {
+			delete(l.present, key)
+			delete(l.lastEarned, key)
+		}
+		l.publishLocked()
+	}, nil
+}
+
+// RegisterCommands registers the "gold" command and the chat listener
+// that pays for messages.
+func (l *Loyalty) RegisterCommands() {
+	RegisterCommand("gold", l.HandleCommand)
+	RegisterMessageListener("gold", l.HandleMessage)
+}
+
+// Start begins paying presence rewards. It does nothing if they're off.
+func (l *Loyalty) Start() {
+	if l.config.PresencePoints == 0 {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	l.mu.Lock()
+	l.cancel = cancel
+	l.mu.Unlock()
+
+	l.wg.Add(1)
+	go l.run(ctx)
+}
+
+// Stop halts presence rewards and waits for the loop to exit.
+func (l *Loyalty) Stop() {
+	l.mu.Lock()
+	if l.cancel != nil {
+		l.cancel()
+		l.cancel = nil
+	}
+	l.mu.Unlock()
+	l.wg.Wait()
+}
+
+func (l *Loyalty) run(ctx context.Context) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(l.config.PresenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.payPresence(); err != nil {
+				log.Printf("Loyalty: paying presence rewards: %v", err)
+			}
+		}
+	}
+}
+
+// HandleMessage pays ChatPoints for a message, at most once per
+// ChatCooldown per identity, and marks the author present.
+func (l *Loyalty) HandleMessage(msg Message) {
+	if msg.IsBot {
+		return
+	}
+	identity := l.identities.Resolve(msg.Platform, msg.UserID)
+
+	l.mu.Lock()
+	now := l.nowFunc()
+	l.present[identity] = presence{name: msg.UserName, seen: now}
+	earn := l.config.ChatPoints > 0 && now.Sub(l.lastEarned[identity]) >= l.config.ChatCooldown
+	if earn {
+		l.lastEarned[identity] = now
+	}
+	l.mu.Unlock()
+
+	if !earn {
+		return
+	}
+	if err := l.mint(map[string]string{identity: msg.UserName}, l.config.ChatPoints, "chat"); err != nil {
+		log.Printf("Loyalty: paying %s for chat: %v", identity, err)
+	}
+}
+
+// payPresence pays PresencePoints to everyone who chatted during the
+// last PresenceInterval and forgets everyone else. It can't tell whether
+// the stream is live, so off-stream chatter is paid too.
+func (l *Loyalty) payPresence() error {
+	l.mu.Lock()
+	now := l.nowFunc()
+	names := map[string]string{}
+	for identity, p := range l.present {
+		if now.Sub(p.seen) <= l.config.PresenceInterval {
+			names[identity] = p.name
+		} else {
+			delete(l.present, identity)
+			delete(l.lastEarned, identity)
+		}
+	}
+	l.mu.Unlock()
+
+	if len(names) == 0 {
+		return nil
+	}
+	return l.mint(names, l.config.PresencePoints, "presence")
+}
+
+// mint adds amount to every identity in names (identity to display name)
+// in one transaction.
+func (l *Loyalty) mint(names map[string]string, amount int, reason string) error {
+	err := l.update(func(change func(identity, name string, delta int) error) error {
+		for identity, name := range names {
+			if err := change(identity, name, amount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if l.metrics != nil {
+		l.metrics.RecordGoldMinted(reason, amount*len(names))
+	}
+	return nil
+}
+
+// Grant adds amount to identity's balance.
+func (l *Loyalty) Grant(identity, name string, amount int) error {
+	if amount <= 0 || amount > goldMaxAmount {
+		return fmt.Errorf("amount must be between 1 and %d", goldMaxAmount)
+	}
+	return l.mint(map[string]string{identity: name}, amount, "grant")
+}
+
+// Transfer moves amount from one identity to another. It returns
+// errInsufficientGold, leaving both balances unchanged, if from can't
+// cover it.
+func (l *Loyalty) Transfer(from, fromName, to, toName string, amount int) error {
+	if amount <= 0 || amount > goldMaxAmount {
+		return fmt.Errorf("amount must be between 1 and %d", goldMaxAmount)
+	}
+	if from == to {
+		return errGoldSelf
+	}
+	err := l.update(func(change func(identity, name string, delta int) error) error {
+		if err := change(from, fromName, -amount); err != nil {
+			return err
+		}
+		return change(to, toName, amount)
+	})
+	if err != nil {
+		return err
+	}
+	if l.metrics != nil {
+		l.metrics.RecordGoldTransferred(amount)
+	}
+	return nil
+}
+
+// update runs fn in a store transaction. fn applies deltas through
+// change, which refuses to take a balance below zero and aborts the
+// whole transaction. Ledger totals are only updated if it commits.
+func (l *Loyalty) update(fn func(change func(identity, name string, delta int) error) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.nowFunc()
+	supply, holders := l.supply, l.holders
+	err := l.store.Update(func(tx *store.Tx) error {
+		return fn(func(identity, name string, delta int) error {
+			var b GoldBalance
+			if err := tx.Get(goldBucket, identity, &b); err != nil && !errors.Is(err, store.ErrNotFound) {
+				return err
+			}
+			if b.Balance+delta < 0 {
+				return errInsufficientGold
+			}
+			if b.Balance == 0 && b.Balance+delta > 0 {
+				holders++
+			} else if b.Balance > 0 && b.Balance+delta == 0 {
+				holders--
+			}
+			b.Identity = identity
+			if name != "" {
+				b.Name = name
+			}
+			b.Balance += delta
+			b.UpdatedAt = now
+			supply += delta
+			return tx.Put(goldBucket, identity, b)
+		})
+	})
+	if err != nil {
+		return err
+	}
+	l.supply, l.holders = supply, holders
+	l.publishLocked()
+	return nil
+}
+
+// Balance returns identity's balance, zero if it has none.
+func (l *Loyalty) Balance(identity string) (int, error) {
+	var b GoldBalance
+	if err := l.store.Get(goldBucket, identity, &b); err != nil && !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+	return b.Balance, nil
+}
+
+// Top returns the n largest balances, richest first.
+func (l *Loyalty) Top(n int) ([]GoldBalance, error) {
+	var all []GoldBalance
+	err := l.store.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(goldBucket) {
+			var b GoldBalance
+			if err := tx.Get(goldBucket, key, &b); err != nil {
+				return err
+			}
+			if b.Balance > 0 {
+				all = append(all, b)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Balance != all[j].Balance {
+			return all[i].Balance > all[j].Balance
+		}
+		return all[i].Identity < all[j].Identity
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all, nil
+}
+
+func (l *Loyalty) publish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.publishLocked()
+}
+
+func (l *Loyalty) publishLocked() {
+	if l.metrics != nil {
+		l.metrics.SetGoldSupply(l.supply, l.holders)
+	}
+}
+
+// HandleCommand implements "gold", "gold top", "gold give <user> <n>"
+// and, for admins, "gold grant <user> <n>".
+func (l *Loyalty) HandleCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	identity := req.Identity
+	if identity == "" {
+		identity = l.identities.Resolve(req.PlatformName, req.User)
+	}
+
+	if len(req.Arguments) == 0 {
+		balance, err := l.Balance(identity)
+		if err != nil {
+			return fmt.Errorf("reading gold balance: %w", err)
+		}
+		return reply(Say(req.Channel, "gold.balance", Vars{"User": req.UserName, "Balance": balance}))
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "top":
+		top, err := l.Top(goldTopSize)
+		if err != nil {
+			return fmt.Errorf("reading gold ledger: %w", err)
+		}
+		if len(top) == 0 {
+			return reply(Say(req.Channel, "gold.top_empty", nil))
+		}
+		entries := make([]string, len(top))
+		for i, b := range top {
+			name := b.Name
+			if name == "" {
+				name = b.Identity
+			}
+			entries[i] = fmt.Sprintf("%d. %s (%d)", i+1, name, b.Balance)
+		}
+		return reply(Say(req.Channel, "gold.top", Vars{"Entries": entries}))
+
+	case "give", "grant":
+		grant := strings.EqualFold(req.Arguments[0], "grant")
+		if grant && !req.Admin {
+			return reply(Say(req.Channel, "gold.grant_denied", nil))
+		}
+		if len(req.Arguments) != 3 {
+			return reply(Say(req.Channel, "gold.usage", nil))
+		}
+		userID, ok := userArgument(req.PlatformName, req.Arguments[1])
+		amount, err := strconv.Atoi(req.Arguments[2])
+		if !ok || err != nil || amount <= 0 || amount > goldMaxAmount {
+			return reply(Say(req.Channel, "gold.usage", nil))
+		}
+		to := l.identities.Resolve(req.PlatformName, userID)
+		toName := strings.TrimPrefix(req.Arguments[1], "@")
+		if req.PlatformName == "discord" {
+			// Keep the stored name if there is one rather than a mention.
+			toName = ""
+		}
+
+		if grant {
+			if err := l.Grant(to, toName, amount); err != nil {
+				return fmt.Errorf("granting gold: %w", err)
+			}
+			log.Printf("Loyalty: %s granted %d gold to %s", req.UserName, amount, to)
+			return reply(Say(req.Channel, "gold.granted", Vars{"User": req.Arguments[1], "Amount": amount}))
+		}
+
+		switch err := l.Transfer(identity, req.UserName, to, toName, amount); {
+		case errors.Is(err, errInsufficientGold):
+			balance, _ := l.Balance(identity)
+			return reply(Say(req.Channel, "gold.insufficient", Vars{"Balance": balance}))
+		case errors.Is(err, errGoldSelf):
+			return reply(Say(req.Channel, "gold.self", nil))
+		case err != nil:
+			return fmt.Errorf("giving gold: %w", err)
+		}
+		return reply(Say(req.Channel, "gold.given", Vars{"User": req.UserName, "To": req.Arguments[1], "Amount": amount}))
+
+	default:
+		return reply(Say(req.Channel, "gold.usage", nil))
+	}
+}
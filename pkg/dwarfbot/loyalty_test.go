@@ -0,0 +1,276 @@
+package dwarfbot
+
+import (
+	"dwarfbot/pkg/store"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockGoldMetrics struct {
+	minted      map[string]int
+	transferred int
+	supply      int
+	holders     int
+}
+
+func (m *mockGoldMetrics) RecordGoldMinted(reason string, amount int) { m.minted[reason] += amount }
+func (m *mockGoldMetrics) RecordGoldTransferred(amount int)           { m.transferred += amount }
+func (m *mockGoldMetrics) SetGoldSupply(total, holders int) {
+	m.supply, m.holders = total, holders
+}
+
+func newTestLoyalty(t *testing.T, s *store.Store, ids *Identities, config LoyaltyConfig) (*Loyalty, *mockGoldMetrics, *time.Time) {
+	t.Helper()
+	metrics := &mockGoldMetrics{minted: map[string]int{}}
+	l, err := NewLoyalty(s, ids, metrics, config)
+	if err != nil {
+		t.Fatalf("NewLoyalty: %v", err)
+	}
+	return l, metrics, pinClock(&l.nowFunc, time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC))
+}
+
+func gold(t *testing.T, l *Loyalty, mock ChatPlatform, user string, admin bool, args string) {
+	t.Helper()
+	if err := l.HandleCommand(commandRequest(mock, "twitch", user, admin, "gold", args)); err != nil {
+		t.Fatalf("gold %s: %v", args, err)
+	}
+}
+
+func balance(t *testing.T, l *Loyalty, identity string) int {
+	t.Helper()
+	b, err := l.Balance(identity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestLoyalty_ChatEarnsWithCooldown(t *testing.T) {
+	l, metrics, now := newTestLoyalty(t, openTestStore(t, ""), nil, LoyaltyConfig{ChatPoints: 2, ChatCooldown: time.Minute})
+
+	msg := Message{Platform: "twitch", Channel: "chan", UserID: "viewer", UserName: "Viewer", Text: "hi"}
+	l.HandleMessage(msg)
+	l.HandleMessage(msg)
+	*now = now.Add(59 * time.Second)
+	l.HandleMessage(msg)
+	if got := balance(t, l, "twitch:viewer"); got != 2 {
+		t.Fatalf("expected one payment inside the cooldown, got %d", got)
+	}
+	*now = now.Add(time.Second)
+	l.HandleMessage(msg)
+	if got := balance(t, l, "twitch:viewer"); got != 4 {
+		t.Errorf("expected a second payment after the cooldown, got %d", got)
+	}
+
+	l.HandleMessage(Message{Platform: "discord", UserID: "1", UserName: "otherbot", IsBot: true})
+	if got := balance(t, l, "discord:1"); got != 0 {
+		t.Errorf("expected bots to earn nothing, got %d", got)
+	}
+	if metrics.minted["chat"] != 4 || metrics.supply != 4 || metrics.holders != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestLoyalty_LinkedAccountsSharePurse(t *testing.T) {
+	s := openTestStore(t, "")
+	ids, err := NewIdentities(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := ids.IssueCode("discord", "42", "Gimli")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != nil {
+		t.Fatal(err)
+	}
+	l, _, now := newTestLoyalty(t, s, ids, LoyaltyConfig{ChatPoints: 1, ChatCooldown: time.Minute})
+
+	l.HandleMessage(Message{Platform: "twitch", UserID: "gimli", UserName: "gimli"})
+	l.HandleMessage(Message{Platform: "discord", UserID: "42", UserName: "Gimli"})
+	if got := balance(t, l, "discord:42"); got != 1 {
+		t.Errorf("expected the cooldown to span linked accounts, got %d", got)
+	}
+	*now = now.Add(time.Minute)
+	l.HandleMessage(Message{Platform: "discord", UserID: "42", UserName: "Gimli"})
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	gold(t, l, mock, "gimli", false, "")
+	if got := lastMessage(mock); !strings.Contains(got, "2 dwarf gold") {
+		t.Errorf("expected the Twitch account to see the shared balance, got %q", got)
+	}
+}
+
+func TestLoyalty_LinkMergesBalances(t *testing.T) {
+	s := openTestStore(t, "")
+	ids, err := NewIdentities(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, metrics, _ := newTestLoyalty(t, s, ids, LoyaltyConfig{ChatPoints: 5})
+
+	l.HandleMessage(Message{Platform: "twitch", UserID: "gimli", UserName: "gimli"})
+	l.HandleMessage(Message{Platform: "discord", UserID: "42", UserName: "Gimli"})
+	if metrics.supply != 10 || metrics.holders != 2 {
+		t.Fatalf("unexpected metrics before linking %+v", metrics)
+	}
+
+	code := ids.IssueCode("discord", "42", "Gimli")
+	if _, err := ids.Redeem(code, "twitch", "gimli", "gimli"); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(t, l, "discord:42"); got != 10 {
+		t.Errorf("expected the Twitch balance to join the identity, got %d", got)
+	}
+	if got := balance(t, l, "twitch:gimli"); got != 0 {
+		t.Errorf("expected the old Twitch entry to be emptied, got %d", got)
+	}
+	if metrics.supply != 10 || metrics.holders != 1 {
+		t.Errorf("unexpected metrics after linking %+v", metrics)
+	}
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	gold(t, l, mock, "gimli", false, "")
+	if got := lastMessage(mock); !strings.Contains(got, "10 dwarf gold") {
+		t.Errorf("expected the Twitch account to see the merged balance, got %q", got)
+	}
+}
+
+func TestLoyalty_Presence(t *testing.T) {
+	l, metrics, now := newTestLoyalty(t, openTestStore(t, ""), nil, LoyaltyConfig{PresencePoints: 5, PresenceInterval: 10 * time.Minute})
+
+	l.HandleMessage(Message{Platform: "twitch", UserID: "early", UserName: "early"})
+	*now = now.Add(5 * time.Minute)
+	l.HandleMessage(Message{Platform: "twitch", UserID: "late", UserName: "late"})
+	*now = now.Add(6 * time.Minute)
+	if err := l.payPresence(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(t, l, "twitch:early"); got != 0 {
+		t.Errorf("expected nothing for someone quiet all interval, got %d", got)
+	}
+	if got := balance(t, l, "twitch:late"); got != 5 {
+		t.Errorf("expected presence reward, got %d", got)
+	}
+	if metrics.minted["chat"] != 0 || metrics.minted["presence"] != 5 {
+		t.Errorf("expected only presence rewards, got %+v", metrics.minted)
+	}
+
+	*now = now.Add(20 * time.Minute)
+	if err := l.payPresence(); err != nil {
+		t.Fatal(err)
+	}
+	if got := balance(t, l, "twitch:late"); got != 5 {
+		t.Errorf("expected a quiet chat to pay nobody, got %d", got)
+	}
+}
+
+func TestLoyalty_Give(t *testing.T) {
+	l, metrics, _ := newTestLoyalty(t, openTestStore(t, ""), nil, LoyaltyConfig{})
+	if err := l.Grant("twitch:rich", "rich", 10); err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	gold(t, l, mock, "rich", false, "give @Poor 4")
+	if balance(t, l, "twitch:rich") != 6 || balance(t, l, "twitch:poor") != 4 {
+		t.Fatalf("unexpected balances after give: %q", lastMessage(mock))
+	}
+
+	gold(t, l, mock, "rich", false, "give poor 7")
+	if !strings.Contains(lastMessage(mock), "only got 6") {
+		t.Errorf("expected insufficient funds, got %q", lastMessage(mock))
+	}
+	if balance(t, l, "twitch:rich") != 6 || balance(t, l, "twitch:poor") != 4 {
+		t.Error("expected a failed give to change nothing")
+	}
+
+	gold(t, l, mock, "rich", false, "give rich 1")
+	if !strings.Contains(lastMessage(mock), "pocket") {
+		t.Errorf("expected self-gifts to be refused, got %q", lastMessage(mock))
+	}
+	for _, args := range []string{"give poor -3", "give poor 0", "give poor lots", "give poor", "give poor 99999999"} {
+		gold(t, l, mock, "rich", false, args)
+		if !strings.Contains(lastMessage(mock), "Try:") {
+			t.Errorf("%q: expected usage, got %q", args, lastMessage(mock))
+		}
+	}
+
+	gold(t, l, mock, "poor", false, "give rich 4")
+	if metrics.transferred != 8 || metrics.supply != 10 || metrics.holders != 1 {
+		t.Errorf("unexpected metrics %+v", metrics)
+	}
+}
+
+func TestLoyalty_GrantIsAdminOnly(t *testing.T) {
+	l, metrics, _ := newTestLoyalty(t, openTestStore(t, ""), nil, LoyaltyConfig{})
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	gold(t, l, mock, "viewer", false, "grant viewer 100")
+	if balance(t, l, "twitch:viewer") != 0 {
+		t.Error("expected non-admins to be refused")
+	}
+	gold(t, l, mock, "boss", true, "grant viewer 100")
+	if balance(t, l, "twitch:viewer") != 100 || metrics.minted["grant"] != 100 {
+		t.Errorf("expected grant to mint 100, got %q", lastMessage(mock))
+	}
+}
+
+func TestLoyalty_DiscordRecipient(t *testing.T) {
+	l, _, _ := newTestLoyalty(t, openTestStore(t, ""), nil, LoyaltyConfig{})
+	if err := l.Grant("discord:42", "Gimli", 5); err != nil {
+		t.Fatal(err)
+	}
+	mock := newMockPlatform("testbot", []string{"123"})
+	req := commandRequest(mock, "discord", "42", false, "gold", "")
+	req.Channel, req.UserName = "123", "Gimli"
+
+	for _, args := range []string{"give <@!7> 1", "give 7 1", "give legolas 1"} {
+		req.Arguments = strings.Fields(args)
+		if err := l.HandleCommand(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := balance(t, l, "discord:7"); got != 2 {
+		t.Errorf("expected mentions and IDs to work and names to be refused, got %d", got)
+	}
+}
+
+func TestLoyalty_Top(t *testing.T) {
+	s := openTestStore(t, "")
+	l, _, _ := newTestLoyalty(t, s, nil, LoyaltyConfig{})
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	gold(t, l, mock, "viewer", false, "top")
+	if !strings.Contains(lastMessage(mock), "Nae one") {
+		t.Errorf("expected empty top, got %q", lastMessage(mock))
+	}
+	for i, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := l.Grant("twitch:"+name, name, (i+1)*10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gold(t, l, mock, "viewer", false, "top")
+	if got, want := lastMessage(mock), "The richest dwarves: 1. f (60), 2. e (50), 3. d (40), 4. c (30), 5. b (20)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	_, metrics, _ := newTestLoyalty(t, s, nil, LoyaltyConfig{})
+	if metrics.supply != 210 || metrics.holders != 6 {
+		t.Errorf("expected totals to be rebuilt from the store, got %+v", metrics)
+	}
+}
+
+func TestValidateLoyaltyConfig(t *testing.T) {
+	for _, c := range []LoyaltyConfig{
+		{ChatPoints: -1},
+		{PresencePoints: -1},
+		{ChatCooldown: -time.Second},
+		{PresenceInterval: time.Second},
+	} {
+		if err := ValidateLoyaltyConfig(c); err == nil {
+			t.Errorf("expected %+v to be rejected", c)
+		}
+	}
+	if err := ValidateLoyaltyConfig(LoyaltyConfig{ChatPoints: 1, PresencePoints: 5, PresenceInterval: 10 * time.Minute}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
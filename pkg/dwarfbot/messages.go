@@ -0,0 +1,139 @@
+package dwarfbot
+
+import (
+	"sync"
+	"time"
+)
+
+// Message is a chat message received on any platform, normalized so
+// that features such as timers can observe chat without caring which
+// platform it came from.
+type Message struct {
+	// Platform is the source platform name ("twitch" or "discord").
+	Platform string
+
+	// Channel is the Twitch channel name or Discord channel ID.
+	Channel string
+
+	// UserID is the Twitch login or Discord user ID.
+	UserID string
+
+	// UserName is the display name of the author.
+	UserName string
+
+	// Text is the raw message content.
+	Text string
+
+	// ID is the platform's message ID. Twitch messages have none.
+	ID string
+
+	// IsBot is true when the platform flags the author as a bot account.
+	IsBot bool
+
+	// Badges are the author's Twitch chat badges without versions, such
+	// as "broadcaster", "moderator" or "vip". Discord messages have none.
+	Badges []string
+
+	// Time is when the message was received.
+	Time time.Time
+}
+
+// MessageListenerFunc observes every chat message received in a
+// configured channel, commands included.
+type MessageListenerFunc func(msg Message)
+
+type namedMessageListener struct {
+	name     string
+	listener MessageListenerFunc
+}
+
+var (
+	messageListeners   []namedMessageListener
+	messageListenersMu sync.RWMutex
+)
+
+// RegisterMessageListener adds a named listener for inbound chat
+// messages. Listeners run in registration order. Registering a name
+// again replaces it in place; registering nil removes it.
+func RegisterMessageListener(name string, listener MessageListenerFunc) {
+	messageListenersMu.Lock()
+	defer messageListenersMu.Unlock()
+	for i, l := range messageListeners {
+		if l.name == name {
+			if listener == nil {
+				messageListeners = append(messageListeners[:i:i], messageListeners[i+1:]...)
+			} else {
+				messageListeners[i].listener = listener
+			}
+			return
+		}
+	}
+	if listener != nil {
+		messageListeners = append(messageListeners, namedMessageListener{name: name, listener: listener})
+	}
+}
+
+func notifyMessageListeners(msg Message) {
+	messageListenersMu.RLock()
+	listeners := append([]namedMessageListener(nil), messageListeners...)
+	messageListenersMu.RUnlock()
+
+	for _, l := range listeners {
+		l.listener(msg)
+	}
+}
+
+// Reaction is an emoji reaction added to or removed from a message.
+type Reaction struct {
+	// Platform is the source platform name. Only Discord has reactions.
+	Platform string
+
+	// Channel is the Discord channel ID.
+	Channel string
+
+	// MessageID is the ID of the message reacted to.
+	MessageID string
+
+	// UserID is the Discord user ID of whoever reacted.
+	UserID string
+
+	// Emoji is the reaction's Unicode emoji, or the name of a custom
+	// emoji.
+	Emoji string
+
+	// Added is true when the reaction was added, false when removed.
+	Added bool
+}
+
+// ReactionListenerFunc observes reactions in a configured channel.
+type ReactionListenerFunc func(r Reaction)
+
+var (
+	reactionListeners   = map[string]ReactionListenerFunc{}
+	reactionListenersMu sync.RWMutex
+)
+
+// RegisterReactionListener adds a named listener for reactions.
+// Registering a nil listener removes it.
+func RegisterReactionListener(name string, listener ReactionListenerFunc) {
+	reactionListenersMu.Lock()
+	defer reactionListenersMu.Unlock()
+	if listener == nil {
+		delete(reactionListeners, name)
+		return
+	}
+	reactionListeners[name] = listener
+}
+
+func notifyReactionListeners(r Reaction) {
+	reactionListenersMu.RLock()
+	listeners := make([]ReactionListenerFunc, 0, len(reactionListeners))
+	for _, l := range reactionListeners {
+		listeners = append(listeners, l)
+	}
+	reactionListenersMu.RUnlock()
+
+	for _, l := range listeners {
+		l(r)
+	}
+}
@@ -0,0 +1,88 @@
+package dwarfbot
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterMessageListener(t *testing.T) {
+	var got []Message
+	RegisterMessageListener("test", func(msg Message) { got = append(got, msg) })
+	defer RegisterMessageListener("test", nil)
+
+	notifyMessageListeners(Message{Platform: "twitch", Channel: "ch", Text: "hi"})
+	if len(got) != 1 || got[0].Text != "hi" {
+		t.Fatalf("expected listener to receive message, got %v", got)
+	}
+
+	RegisterMessageListener("test", nil)
+	notifyMessageListeners(Message{Text: "again"})
+	if len(got) != 1 {
+		t.Errorf("expected removed listener not to be called, got %v", got)
+	}
+}
+
+func TestMessageListeners_RunInRegistrationOrder(t *testing.T) {
+	defer ResetRegistrations()
+	var order []string
+	for _, name := range []string{"triggers", "gold", "timers", "relay", "quotes"} {
+		RegisterMessageListener(name, func(Message) { order = append(order, name) })
+	}
+	RegisterMessageListener("gold", func(Message) { order = append(order, "gold again") })
+	RegisterMessageListener("relay", nil)
+
+	for i := 0; i < 5; i++ {
+		order = nil
+		notifyMessageListeners(Message{Text: "hi"})
+		if got := strings.Join(order, ","); got != "triggers,gold again,timers,quotes" {
+			t.Fatalf("unexpected order %s", got)
+		}
+	}
+}
+
+func TestHandleChat_NotifiesMessageListeners(t *testing.T) {
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	gotCh := make(chan Message, 1)
+	RegisterMessageListener("test", func(msg Message) { gotCh <- msg })
+	defer RegisterMessageListener("test", nil)
+
+	go func() {
+		_, _ = server.Write([]byte(":someuser!someuser@someuser.tmi.twitch.tv PRIVMSG #testchannel :just chatting\r\n"))
+	}()
+	go func() { _ = bot.HandleChat() }()
+
+	select {
+	case msg := <-gotCh:
+		if msg.Platform != "twitch" || msg.Channel != "testchannel" || msg.UserID != "someuser" || msg.Text != "just chatting" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected listener to be notified")
+	}
+}
+
+func TestHandleChat_TaggedMessageCarriesBadges(t *testing.T) {
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+
+	gotCh := make(chan Message, 1)
+	RegisterMessageListener("test", func(msg Message) { gotCh <- msg })
+	defer RegisterMessageListener("test", nil)
+
+	go func() {
+		_, _ = server.Write([]byte("@badges=vip/1;mod=0 :someuser!someuser@someuser.tmi.twitch.tv PRIVMSG #testchannel :just chatting\r\n"))
+	}()
+	go func() { _ = bot.HandleChat() }()
+
+	select {
+	case msg := <-gotCh:
+		if msg.UserID != "someuser" || msg.Text != "just chatting" || len(msg.Badges) != 1 || msg.Badges[0] != "vip" {
+			t.Errorf("unexpected message %+v", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected listener to be notified")
+	}
+}
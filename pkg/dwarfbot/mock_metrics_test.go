@@ -15,6 +15,7 @@ type mockMetricsRecorder struct {
 	messagesReceived    []string
 	messagesSent        []mockSent
 	commandsProcessed   []mockCommand
+	commandsDenied      []mockDenied
 }
 
 type mockAttempt struct {
@@ -38,6 +39,10 @@ type mockCommand struct {
 	platform, command, admin string
 }
 
+type mockDenied struct {
+	platform, command string
+}
+
 func newMockMetricsRecorder() *mockMetricsRecorder {
 	return &mockMetricsRecorder{}
 }
@@ -84,5 +89,11 @@ func (m *mockMetricsRecorder) RecordCommandProcessed(platform, command, admin st
 	m.commandsProcessed = append(m.commandsProcessed, mockCommand{platform, command, admin})
 }
 
+func (m *mockMetricsRecorder) RecordCommandDenied(platform, command string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.commandsDenied = append(m.commandsDenied, mockDenied{platform, command})
+}
+
 // Verify mockMetricsRecorder satisfies PlatformMetrics at compile time
 var _ PlatformMetrics = (*mockMetricsRecorder)(nil)
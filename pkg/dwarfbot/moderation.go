@@ -0,0 +1,522 @@
+package dwarfbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Moderation actions, in the order they usually escalate.
+const (
+	ModActionDelete  = "delete"
+	ModActionTimeout = "timeout"
+	ModActionBan     = "ban"
+)
+
+// Reasons a message breaks the rules.
+const (
+	modReasonBannedWord = "banned word"
+	modReasonLink       = "link"
+	modReasonCaps       = "excessive caps"
+	modReasonSpam       = "repeated message"
+)
+
+// Moderation defaults, applied when a setting is zero.
+const (
+	defaultModPermitDuration = 2 * time.Minute
+	defaultModCapsMinLength  = 10
+	defaultModRepeatWindow   = 30 * time.Second
+	defaultModTimeout        = 10 * time.Minute
+	defaultModStrikeWindow   = time.Hour
+	maxModTimeout            = 28 * 24 * time.Hour
+)
+
+// linkRegex finds URLs and bare domain names such as "example.com/x".
+// The groups are the scheme, the host and its top-level domain.
+var linkRegex = regexp.MustCompile(`(?i)\b(https?://)?((?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+([a-z]{2,24}))\b`)
+
+// linkTLDs are the top-level domains a bare name needs to count as a
+// link, so "node.js" or "great.Thanks" in chat don't. Names with a
+// scheme or "www." count whatever their TLD.
+var linkTLDs = map[string]bool{
+	"app": true, "biz": true, "cc": true, "club": true, "co": true,
+	"com": true, "de": true, "dev": true, "eu": true, "fr": true,
+	"gg": true, "info": true, "io": true, "link": true, "live": true,
+	"ly": true, "me": true, "net": true, "nl": true, "online": true,
+	"org": true, "ru": true, "shop": true, "site": true, "store": true,
+	"to": true, "top": true, "tv": true, "uk": true, "us": true,
+	"xyz": true,
+}
+
+// ModerationConfig configures automod. Each filter is off unless set.
+type ModerationConfig struct {
+	// BannedWords are matched case-insensitively as whole words or
+	// phrases.
+	BannedWords []string `mapstructure:"banned_words"`
+
+	// BlockLinks removes messages containing links, except to
+	// AllowedDomains (and their subdomains) or from users permitted
+	// with the "permit" command within PermitDuration.
+	BlockLinks     bool          `mapstructure:"block_links"`
+	AllowedDomains []string      `mapstructure:"allowed_domains"`
+	PermitDuration time.Duration `mapstructure:"permit_duration"`
+
+	// CapsMaxRatio is the largest share of upper-case letters allowed
+	// in a message with at least CapsMinLength letters. Zero is off.
+	CapsMaxRatio  float64 `mapstructure:"caps_max_ratio"`
+	CapsMinLength int     `mapstructure:"caps_min_length"`
+
+	// RepeatLimit is how many times one user may send the same message
+	// within RepeatWindow. Zero is off.
+	RepeatLimit  int           `mapstructure:"repeat_limit"`
+	RepeatWindow time.Duration `mapstructure:"repeat_window"`
+
+	// Actions is the escalation ladder: the first strike gets
+	// Actions[0], the second Actions[1], and so on, staying on the last.
+	// Defaults to delete, timeout, ban. Strikes expire after
+	// StrikeWindow.
+	Actions      []string      `mapstructure:"actions"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	StrikeWindow time.Duration `mapstructure:"strike_window"`
+
+	// ExemptRoles are platform role names (Discord guild roles) whose
+	// holders are never moderated. Admins are always exempt.
+	ExemptRoles []string `mapstructure:"exempt_roles"`
+}
+
+// enabled reports whether any filter is on.
+func (c ModerationConfig) enabled() bool {
+	return len(c.BannedWords) > 0 || c.BlockLinks || c.CapsMaxRatio > 0 || c.RepeatLimit > 0
+}
+
+// ValidateModerationConfig checks every setting.
+func ValidateModerationConfig(c ModerationConfig) error {
+	for _, w := range c.BannedWords {
+		if strings.TrimSpace(w) == "" {
+			return fmt.Errorf("moderation: empty banned word")
+		}
+	}
+	for _, d := range c.AllowedDomains {
+		if strings.TrimSpace(d) == "" {
+			return fmt.Errorf("moderation: empty allowed domain")
+		}
+	}
+	if c.CapsMaxRatio < 0 || c.CapsMaxRatio >= 1 {
+		return fmt.Errorf("moderation: caps_max_ratio must be between 0 and 1, got %v", c.CapsMaxRatio)
+	}
+	if c.CapsMinLength < 0 || c.RepeatLimit < 0 {
+		return fmt.Errorf("moderation: caps_min_length and repeat_limit must be >= 0")
+	}
+	if c.PermitDuration < 0 || c.RepeatWindow < 0 || c.StrikeWindow < 0 || c.Timeout < 0 {
+		return fmt.Errorf("moderation: durations must be >= 0")
+	}
+	if c.Timeout > maxModTimeout {
+		return fmt.Errorf("moderation: timeout must be at most %v, got %v", maxModTimeout, c.Timeout)
+	}
+	for _, a := range c.Actions {
+		switch strings.ToLower(a) {
+		case ModActionDelete, ModActionTimeout, ModActionBan:
+		default:
+			return fmt.Errorf("moderation: unknown action %q (use %s, %s or %s)", a, ModActionDelete, ModActionTimeout, ModActionBan)
+		}
+	}
+	return nil
+}
+
+// moderationRules is a validated config with defaults applied and
+// matchers compiled.
+type moderationRules struct {
+	config      ModerationConfig
+	bannedWords *regexp.Regexp
+}
+
+func newModerationRules(c ModerationConfig) *moderationRules {
+	if c.PermitDuration == 0 {
+		c.PermitDuration = defaultModPermitDuration
+	}
+	if c.CapsMinLength == 0 {
+		c.CapsMinLength = defaultModCapsMinLength
+	}
+	if c.RepeatWindow == 0 {
+		c.RepeatWindow = defaultModRepeatWindow
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultModTimeout
+	}
+	if c.StrikeWindow == 0 {
+		c.StrikeWindow = defaultModStrikeWindow
+	}
+	if len(c.Actions) == 0 {
+		c.Actions = []string{ModActionDelete, ModActionTimeout, ModActionBan}
+	}
+	actions := make([]string, len(c.Actions))
+	for i, a := range c.Actions {
+		actions[i] = strings.ToLower(a)
+	}
+	c.Actions = actions
+	domains := make([]string, len(c.AllowedDomains))
+	for i, d := range c.AllowedDomains {
+		domains[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(d), "."))
+	}
+	c.AllowedDomains = domains
+
+	r := &moderationRules{config: c}
+	if len(c.BannedWords) > 0 {
+		words := make([]string, len(c.BannedWords))
+		for i, w := range c.BannedWords {
+			words[i] = regexp.QuoteMeta(strings.TrimSpace(w))
+		}
+		r.bannedWords = regexp.MustCompile(`(?i)(?:^|\W)(?:` + strings.Join(words, "|") + `)(?:\W|$)`)
+	}
+	return r
+}
+
+// domainAllowed reports whether host is an allowed domain or one of
+// its subdomains.
+func (r *moderationRules) domainAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, d := range r.config.AllowedDomains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBlockedLink reports whether text links to a domain that isn't
+// allowed.
+func (r *moderationRules) hasBlockedLink(text string) bool {
+	for _, m := range linkRegex.FindAllStringSubmatch(text, -1) {
+		scheme, host, tld := m[1], strings.ToLower(m[2]), strings.ToLower(m[3])
+		if scheme == "" && !strings.HasPrefix(host, "www.") && !linkTLDs[tld] {
+			continue
+		}
+		if !r.domainAllowed(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// tooManyCaps reports whether text is mostly upper case.
+func (r *moderationRules) tooManyCaps(text string) bool {
+	var letters, upper int
+	for _, c := range text {
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	return letters >= r.config.CapsMinLength && float64(upper)/float64(letters) > r.config.CapsMaxRatio
+}
+
+// recentMessage is one message kept for the repeat check.
+type recentMessage struct {
+	text string
+	at   time.Time
+}
+
+// Moderation filters chat as inbound middleware. A message that breaks
+// a rule is dropped before listeners and commands see it, and the
+// author gets a strike. Strikes escalate through the configured actions,
+// which are enforced through the platform's Moderator implementation.
+type Moderation struct {
+	mu        sync.Mutex
+	rules     *moderationRules
+	platforms map[string]ChatPlatform
+	audit     AuditSink
+
+	// Keyed by AccountKey: moderation acts on platform accounts, so
+	// linked identities are struck separately.
+	recent  map[string][]recentMessage
+	strikes map[string][]time.Time
+	permits map[string]time.Time
+
+	// swept is when recent was last cleared of users who went quiet.
+	swept time.Time
+
+	nowFunc func() time.Time
+}
+
+// NewModeration validates config and returns a Moderation. Call
+// SetPlatform for each running platform.
+func NewModeration(config ModerationConfig) (*Moderation, error) {
+	m := &Moderation{
+		platforms: map[string]ChatPlatform{},
+		recent:    map[string][]recentMessage{},
+		strikes:   map[string][]time.Time{},
+		permits:   map[string]time.Time{},
+		nowFunc:   time.Now,
+	}
+	if err := m.SetConfig(config); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SetConfig validates and replaces the rules. Strikes and permits are
+// kept.
+func (m *Moderation) SetConfig(config ModerationConfig) error {
+	if err := ValidateModerationConfig(config); err != nil {
+		return err
+	}
+	rules := newModerationRules(config)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+	return nil
+}
+
+// SetPlatform registers the platform messages from name are enforced
+// through.
+func (m *Moderation) SetPlatform(name string, platform ChatPlatform) {
+	if _, ok := platform.(Moderator); !ok {
+		log.Printf("Moderation: %s can't enforce moderation; violations there are dropped and logged only", name)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.platforms[name] = platform
+}
+
+// SetAudit records every action taken to sink. Nil stops recording.
+func (m *Moderation) SetAudit(sink AuditSink) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.audit = sink
+}
+
+// RegisterCommands installs the inbound middleware and registers the
+// admin-only "permit" command.
+func (m *Moderation) RegisterCommands() {
+	RegisterInboundMiddleware("moderation", m.Middleware)
+	RegisterAdminCommand("permit", m.HandlePermitCommand)
+}
+
+// Permit lets the user post one link within the permit duration.
+func (m *Moderation) Permit(platform, userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.permits[AccountKey(platform, userID)] = m.nowFunc().Add(m.rules.config.PermitDuration)
+}
+
+// HandlePermitCommand implements "permit <user>".
+func (m *Moderation) HandlePermitCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	if len(req.Arguments) != 1 {
+		return reply(Say(req.Channel, "permit.usage", nil))
+	}
+	userID, ok := userArgument(req.PlatformName, req.Arguments[0])
+	if !ok {
+		return reply(Say(req.Channel, "permit.usage", nil))
+	}
+	m.Permit(req.PlatformName, userID)
+
+	m.mu.Lock()
+	d := m.rules.config.PermitDuration
+	m.mu.Unlock()
+	return reply(Say(req.Channel, "permit.granted", Vars{"User": req.Arguments[0], "Seconds": int(d.Seconds())}))
+}
+
+// Middleware drops messages that break a rule and punishes the author.
+func (m *Moderation) Middleware(next InboundHandler) InboundHandler {
+	return func(ctx context.Context, msg Message) error {
+		reason := m.check(msg)
+		if reason == "" {
+			return next(ctx, msg)
+		}
+
+		m.mu.Lock()
+		platform := m.platforms[msg.Platform]
+		exemptRoles := m.rules.config.ExemptRoles
+		m.mu.Unlock()
+		if platform == nil {
+			return next(ctx, msg)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		if m.exempt(ctx, platform, msg, exemptRoles) {
+			return next(ctx, msg)
+		}
+		m.enforce(ctx, platform, msg, reason)
+		return nil
+	}
+}
+
+// check returns why msg breaks a rule, or "" if it doesn't. It also
+// records msg for the repeat check and uses up a link permit.
+func (m *Moderation) check(msg Message) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.rules
+	if !r.config.enabled() {
+		return ""
+	}
+	key := AccountKey(msg.Platform, msg.UserID)
+	now := m.nowFunc()
+
+	repeated := false
+	if r.config.RepeatLimit > 0 {
+		repeated = m.recordRecent(key, msg.Text, now)
+	}
+
+	if r.bannedWords != nil && r.bannedWords.MatchString(msg.Text) {
+		return modReasonBannedWord
+	}
+	if r.config.BlockLinks && r.hasBlockedLink(msg.Text) {
+		if until, ok := m.permits[key]; !ok || now.After(until) {
+			return modReasonLink
+		}
+		delete(m.permits, key)
+	}
+	if r.config.CapsMaxRatio > 0 && r.tooManyCaps(msg.Text) {
+		return modReasonCaps
+	}
+	if repeated {
+		return modReasonSpam
+	}
+	return ""
+}
+
+// recordRecent remembers text and reports whether key has now sent it
+// more than RepeatLimit times within RepeatWindow. Callers hold m.mu.
+func (m *Moderation) recordRecent(key, text string, now time.Time) bool {
+	window := m.rules.config.RepeatWindow
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+
+	// Once per window, forget users whose messages have all left it, so
+	// the map doesn't grow with everyone who ever chatted.
+	if now.Sub(m.swept) > window {
+		for k, msgs := range m.recent {
+			if now.Sub(msgs[len(msgs)-1].at) > window {
+				delete(m.recent, k)
+			}
+		}
+		m.swept = now
+	}
+
+	kept := m.recent[key][:0]
+	for _, rm := range m.recent[key] {
+		if now.Sub(rm.at) <= window {
+			kept = append(kept, rm)
+		}
+	}
+	kept = append(kept, recentMessage{text: text, at: now})
+	m.recent[key] = kept
+	count := 0
+	for _, rm := range kept {
+		if rm.text == text {
+			count++
+		}
+	}
+	return count > m.rules.config.RepeatLimit
+}
+
+// exempt reports whether the author is an admin, a Twitch broadcaster or
+// moderator, or holds an exempt role. On Twitch, roles are matched
+// against the author's badges.
+func (m *Moderation) exempt(ctx context.Context, platform ChatPlatform, msg Message, roles []string) bool {
+	if platform.IsAdmin(ctx, msg.Channel, msg.UserID) {
+		return true
+	}
+	for _, badge := range msg.Badges {
+		if badge == "broadcaster" || badge == "moderator" || containsFold(roles, badge) {
+			return true
+		}
+	}
+	if rc, ok := platform.(RoleChecker); ok {
+		for _, role := range roles {
+			if rc.HasRole(ctx, msg.Channel, msg.UserID, role) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// strike records a strike for key and returns the action it earns.
+func (m *Moderation) strike(key string) (string, int, time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.nowFunc()
+	c := m.rules.config
+
+	var kept []time.Time
+	for _, t := range m.strikes[key] {
+		if now.Sub(t) <= c.StrikeWindow {
+			kept = append(kept, t)
+		}
+	}
+	kept = append(kept, now)
+	m.strikes[key] = kept
+
+	n := len(kept)
+	return c.Actions[min(n, len(c.Actions))-1], n, c.Timeout
+}
+
+// enforce applies the author's next action, warns them in chat and
+// logs what was done.
+func (m *Moderation) enforce(ctx context.Context, platform ChatPlatform, msg Message, reason string) {
+	action, strikes, timeout := m.strike(AccountKey(msg.Platform, msg.UserID))
+
+	// Every action removes the message; timeouts and bans come on top.
+	var err error
+	mod, canModerate := platform.(Moderator)
+	if !canModerate {
+		err = fmt.Errorf("%s can't moderate", msg.Platform)
+	} else {
+		err = mod.DeleteMessage(ctx, msg)
+		switch action {
+		case ModActionTimeout:
+			err = errors.Join(err, mod.TimeoutUser(ctx, msg.Channel, msg.UserID, timeout, "automod: "+reason))
+		case ModActionBan:
+			err = errors.Join(err, mod.BanUser(ctx, msg.Channel, msg.UserID, "automod: "+reason))
+		}
+	}
+
+	result := "ok"
+	if err != nil {
+		result = "error: " + err.Error()
+	}
+	log.Printf("Moderation: %s %s (%s) in %s on %s for %s, strike %d: %s",
+		action, msg.UserName, msg.UserID, msg.Channel, msg.Platform, reason, strikes, result)
+
+	m.mu.Lock()
+	audit := m.audit
+	m.mu.Unlock()
+	if audit != nil {
+		audit.RecordAudit(AuditEvent{
+			Time:     m.nowFunc().UTC(),
+			Platform: msg.Platform,
+			Channel:  msg.Channel,
+			UserID:   msg.UserID,
+			UserName: msg.UserName,
+			Command:  "automod",
+			Args:     []string{action, reason},
+			Result:   result,
+		})
+	}
+
+	// Nothing was removed where the platform can't moderate, so there is
+	// nothing to warn about.
+	if canModerate && action != ModActionBan {
+		warning := Say(msg.Channel, "moderation.warning", Vars{
+			"Mention": mentionUser(msg.Platform, msg.UserID, msg.UserName),
+			"Reason":  reason,
+			"Action":  action,
+		})
+		if err := platform.SendMessage(ctx, msg.Channel, warning); err != nil {
+			log.Printf("Moderation: warning %s: %v", msg.UserName, err)
+		}
+	}
+}
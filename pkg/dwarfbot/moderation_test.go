@@ -0,0 +1,306 @@
+package dwarfbot
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// modPlatform records the moderation actions taken through it.
+type modPlatform struct {
+	*mockPlatform
+	actions []string
+	roles   map[string]string
+}
+
+func (p *modPlatform) DeleteMessage(_ context.Context, msg Message) error {
+	p.actions = append(p.actions, "delete "+msg.UserID)
+	return nil
+}
+
+func (p *modPlatform) TimeoutUser(_ context.Context, _, userID string, d time.Duration, _ string) error {
+	p.actions = append(p.actions, "timeout "+userID+" "+d.String())
+	return nil
+}
+
+func (p *modPlatform) BanUser(_ context.Context, _, userID, _ string) error {
+	p.actions = append(p.actions, "ban "+userID)
+	return nil
+}
+
+func (p *modPlatform) HasRole(_ context.Context, _, userID, role string) bool {
+	return p.roles[userID] == role
+}
+
+func newTestModeration(t *testing.T, config ModerationConfig) (*Moderation, *modPlatform, *time.Time) {
+	t.Helper()
+	m, err := NewModeration(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := pinClock(&m.nowFunc, time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC))
+	p := &modPlatform{mockPlatform: newMockPlatformWithAdmin("testbot", []string{"ch"}, adminIs("boss"))}
+	m.SetPlatform("discord", p)
+	return m, p, now
+}
+
+func TestModeration_Check(t *testing.T) {
+	m, _, _ := newTestModeration(t, ModerationConfig{
+		BannedWords:    []string{"elf", "pointy ears"},
+		BlockLinks:     true,
+		AllowedDomains: []string{"twitch.tv", ".YouTube.com"},
+		CapsMaxRatio:   0.7,
+	})
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"hail the mountain king", ""},
+		{"an ELF stole my axe", modReasonBannedWord},
+		{"those pointy ears!", modReasonBannedWord},
+		{"self-sufficient dwarves", ""},
+		{"buy gold at cheapgold.biz now", modReasonLink},
+		{"watch https://evil.example.com/x", modReasonLink},
+		{"follow https://twitch.tv/dwarf", ""},
+		{"clip at www.youtube.com/watch?v=1", ""},
+		{"notyoutube.com is fine?", modReasonLink},
+		{"mined 3.5 tons today", ""},
+		{"bring tools, e.g. a pick", ""},
+		{"patch v1.2 is out", ""},
+		{"node.js and main.go", ""},
+		{"great.Thanks for the raid", ""},
+		{"see www.example.dwarf", modReasonLink},
+		{"grab it at http://mine.dwarf/ore", modReasonLink},
+		{"THIS IS SO MUCH GOLD", modReasonCaps},
+		{"GG WP", ""},
+	}
+	for i, tt := range tests {
+		msg := Message{Platform: "discord", Channel: "ch", UserID: string(rune('a' + i)), Text: tt.text}
+		if got := m.check(msg); got != tt.want {
+			t.Errorf("check(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestModeration_PermitAllowsOneLink(t *testing.T) {
+	defer ResetRegistrations()
+	m, p, now := newTestModeration(t, ModerationConfig{BlockLinks: true})
+	m.RegisterCommands()
+	link := Message{Platform: "discord", Channel: "ch", UserID: "42", Text: "see example.com"}
+
+	if err := parseCommand(context.Background(), p, "ch", "viewer", "permit", []string{"<@42>"}); err != nil {
+		t.Fatal(err)
+	}
+	if m.permits["discord:42"] != (time.Time{}) {
+		t.Fatal("expected non-admins to be refused")
+	}
+	if err := parseCommand(context.Background(), p, "ch", "boss", "permit", []string{"<@42>"}, parseCommandOpts{platformName: "discord"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastMessage(p.mockPlatform); !strings.Contains(got, "120 seconds") {
+		t.Errorf("expected permit confirmation, got %q", got)
+	}
+	if got := m.check(link); got != "" {
+		t.Errorf("expected permitted link to pass, got %q", got)
+	}
+	if got := m.check(link); got != modReasonLink {
+		t.Errorf("expected permit to be used up, got %q", got)
+	}
+
+	m.Permit("discord", "42")
+	*now = now.Add(3 * time.Minute)
+	if got := m.check(link); got != modReasonLink {
+		t.Errorf("expected expired permit to be ignored, got %q", got)
+	}
+}
+
+func TestModeration_RepeatedMessages(t *testing.T) {
+	m, _, now := newTestModeration(t, ModerationConfig{RepeatLimit: 2, RepeatWindow: 10 * time.Second})
+	msg := Message{Platform: "discord", Channel: "ch", UserID: "42", Text: "Diggy diggy hole"}
+	other := Message{Platform: "discord", Channel: "ch", UserID: "43", Text: "diggy diggy hole"}
+
+	for i := 0; i < 2; i++ {
+		if got := m.check(msg); got != "" {
+			t.Fatalf("message %d: expected no violation, got %q", i+1, got)
+		}
+	}
+	if got := m.check(other); got != "" {
+		t.Errorf("expected other users to be counted separately, got %q", got)
+	}
+	if got := m.check(Message{Platform: "discord", Channel: "ch", UserID: "42", Text: "diggy  DIGGY hole"}); got != modReasonSpam {
+		t.Errorf("expected third repeat to be spam, got %q", got)
+	}
+
+	*now = now.Add(11 * time.Second)
+	if got := m.check(msg); got != "" {
+		t.Errorf("expected repeats to expire, got %q", got)
+	}
+
+	// Users who went quiet are forgotten once the window has passed.
+	*now = now.Add(11 * time.Second)
+	m.check(other)
+	if _, ok := m.recent["discord:42"]; ok || len(m.recent) != 1 {
+		t.Errorf("expected only the active user to be tracked, got %v", m.recent)
+	}
+}
+
+func TestModeration_Escalates(t *testing.T) {
+	defer ResetRegistrations()
+	m, p, now := newTestModeration(t, ModerationConfig{BannedWords: []string{"elf"}, Timeout: 5 * time.Minute})
+	audit := &recordingAudit{}
+	m.SetAudit(audit)
+	m.RegisterCommands()
+	var heard []string
+	RegisterMessageListener("test", func(msg Message) { heard = append(heard, msg.Text) })
+	h := inboundPipeline(nil, func(ctx context.Context, msg Message) error {
+		return dispatchInbound(ctx, p, msg, parseCommandOpts{platformName: "discord"})
+	})
+	send := func(text string) {
+		t.Helper()
+		if err := h(context.Background(), Message{Platform: "discord", Channel: "ch", UserID: "42", UserName: "Grim", Text: text}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	send("hello")
+	send("elf")
+	send("elf")
+	send("elf")
+	want := []string{"delete 42", "delete 42", "timeout 42 5m0s", "delete 42", "ban 42"}
+	if strings.Join(p.actions, ",") != strings.Join(want, ",") {
+		t.Errorf("actions = %v, want %v", p.actions, want)
+	}
+	if len(heard) != 1 || heard[0] != "hello" {
+		t.Errorf("expected only the clean message to reach listeners, got %v", heard)
+	}
+	if len(p.messages) != 2 || !strings.Contains(p.messages[0].msg, "<@42>") || !strings.Contains(p.messages[1].msg, "timed out") {
+		t.Errorf("expected warnings for the delete and timeout only, got %v", p.messages)
+	}
+	if len(audit.events) != 3 {
+		t.Fatalf("expected 3 audit events, got %d", len(audit.events))
+	}
+	if e := audit.events[2]; e.Command != "automod" || e.Args[0] != ModActionBan || e.Args[1] != modReasonBannedWord || e.Result != "ok" {
+		t.Errorf("unexpected audit event %+v", e)
+	}
+
+	*now = now.Add(2 * time.Hour)
+	p.actions = nil
+	send("elf")
+	if len(p.actions) != 1 || p.actions[0] != "delete 42" {
+		t.Errorf("expected strikes to expire, got %v", p.actions)
+	}
+}
+
+func TestModeration_Exempt(t *testing.T) {
+	m, p, _ := newTestModeration(t, ModerationConfig{BannedWords: []string{"elf"}, ExemptRoles: []string{"Miner"}})
+	p.roles = map[string]string{"7": "Miner"}
+	var passed []string
+	h := m.Middleware(func(_ context.Context, msg Message) error {
+		passed = append(passed, msg.UserID)
+		return nil
+	})
+
+	for _, user := range []string{"boss", "7", "8"} {
+		if err := h(context.Background(), Message{Platform: "discord", Channel: "ch", UserID: user, Text: "elf"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(passed, ",") != "boss,7" {
+		t.Errorf("expected admin and role holder to pass, got %v", passed)
+	}
+	if len(p.actions) != 1 || p.actions[0] != "delete 8" {
+		t.Errorf("expected only user 8 to be moderated, got %v", p.actions)
+	}
+}
+
+func TestModeration_PlatformWithoutModerator(t *testing.T) {
+	m, _, _ := newTestModeration(t, ModerationConfig{BannedWords: []string{"elf"}})
+	audit := &recordingAudit{}
+	m.SetAudit(audit)
+	twitch := newMockPlatform("testbot", []string{"ch"})
+	m.SetPlatform("twitch", twitch)
+	h := m.Middleware(func(context.Context, Message) error {
+		t.Error("expected message to be dropped")
+		return nil
+	})
+
+	if err := h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserID: "grim", Text: "elf"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.events) != 1 || !strings.HasPrefix(audit.events[0].Result, "error:") {
+		t.Errorf("expected the failure to be audited, got %+v", audit.events)
+	}
+	if got := lastMessage(twitch); got != "" {
+		t.Errorf("expected no warning when nothing was removed, got %q", got)
+	}
+}
+
+func TestModeration_TwitchBadgesExempt(t *testing.T) {
+	m, _, _ := newTestModeration(t, ModerationConfig{BannedWords: []string{"elf"}, ExemptRoles: []string{"VIP"}})
+	m.SetPlatform("twitch", newMockPlatform("testbot", []string{"ch"}))
+	var passed []string
+	h := m.Middleware(func(_ context.Context, msg Message) error {
+		passed = append(passed, msg.UserID)
+		return nil
+	})
+
+	for user, badges := range map[string][]string{
+		"owner":  {"broadcaster"},
+		"mod":    {"subscriber", "moderator"},
+		"vip":    {"vip"},
+		"viewer": {"subscriber"},
+	} {
+		if err := h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserID: user, Text: "elf", Badges: badges}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(passed)
+	if strings.Join(passed, ",") != "mod,owner,vip" {
+		t.Errorf("expected broadcaster, moderator and exempt badge to pass, got %v", passed)
+	}
+}
+
+func TestModeration_SetConfig(t *testing.T) {
+	m, _, _ := newTestModeration(t, ModerationConfig{})
+	msg := Message{Platform: "discord", Channel: "ch", UserID: "42", Text: "elf"}
+	if got := m.check(msg); got != "" {
+		t.Errorf("expected no filters by default, got %q", got)
+	}
+	if err := m.SetConfig(ModerationConfig{BannedWords: []string{"elf"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.check(msg); got != modReasonBannedWord {
+		t.Errorf("expected new config to apply, got %q", got)
+	}
+	if err := m.SetConfig(ModerationConfig{Actions: []string{"flog"}}); err == nil {
+		t.Error("expected invalid config to be rejected")
+	}
+	if got := m.check(msg); got != modReasonBannedWord {
+		t.Errorf("expected rejected config to leave the rules alone, got %q", got)
+	}
+}
+
+func TestValidateModerationConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config ModerationConfig
+	}{
+		{"empty word", ModerationConfig{BannedWords: []string{" "}}},
+		{"empty domain", ModerationConfig{AllowedDomains: []string{""}}},
+		{"caps ratio", ModerationConfig{CapsMaxRatio: 1}},
+		{"negative limit", ModerationConfig{RepeatLimit: -1}},
+		{"negative duration", ModerationConfig{StrikeWindow: -time.Second}},
+		{"long timeout", ModerationConfig{Timeout: 30 * 24 * time.Hour}},
+		{"unknown action", ModerationConfig{Actions: []string{"delete", "flog"}}},
+	}
+	for _, tt := range tests {
+		if err := ValidateModerationConfig(tt.config); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+	if err := ValidateModerationConfig(ModerationConfig{Actions: []string{"Delete", "BAN"}, CapsMaxRatio: 0.5}); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/events"
+	"log"
+	"strings"
+	"sync"
+)
+
+// InboundHandler processes a chat message received on any platform.
+type InboundHandler func(ctx context.Context, msg Message) error
+
+// InboundMiddleware wraps an InboundHandler. It may inspect or rewrite
+// the message before calling next, or drop it by not calling next.
+type InboundMiddleware func(next InboundHandler) InboundHandler
+
+// OutboundMessage is a message about to be sent to a platform.
+type OutboundMessage struct {
+	// Platform is the destination platform name ("twitch" or "discord").
+	Platform string
+
+	// Channel is the Twitch channel name or Discord channel ID.
+	Channel string
+
+	// Text is the message content.
+	Text string
+}
+
+// OutboundHandler sends a message.
+type OutboundHandler func(ctx context.Context, msg OutboundMessage) error
+
+// OutboundMiddleware wraps an OutboundHandler. It may rewrite the
+// message before calling next, or drop it by returning without calling
+// next.
+type OutboundMiddleware func(next OutboundHandler) OutboundHandler
+
+type namedInbound struct {
+	name string
+	mw   InboundMiddleware
+}
+
+type namedOutbound struct {
+	name string
+	mw   OutboundMiddleware
+}
+
+var (
+	inboundMiddleware  []namedInbound
+	outboundMiddleware []namedOutbound
+	middlewareMu       sync.RWMutex
+)
+
+// RegisterInboundMiddleware adds a named middleware to every platform's
+// inbound pipeline. Middleware runs in registration order, after the
+// built-in logging and metrics and before message listeners and command
+// dispatch. Registering a name again replaces it in place; registering
+// nil removes it.
+func RegisterInboundMiddleware(name string, mw InboundMiddleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	for i, m := range inboundMiddleware {
+		if m.name == name {
+			if mw == nil {
+				inboundMiddleware = append(inboundMiddleware[:i:i], inboundMiddleware[i+1:]...)
+			} else {
+				inboundMiddleware[i].mw = mw
+			}
+			return
+		}
+	}
+	if mw != nil {
+		inboundMiddleware = append(inboundMiddleware, namedInbound{name: name, mw: mw})
+	}
+}
+
+// RegisterOutboundMiddleware adds a named middleware to every platform's
+// outbound pipeline. Middleware runs in registration order, before the
+// built-in logging and metrics, so those record what is actually sent.
+// Registering a name again replaces it in place; registering nil
+// removes it.
+func RegisterOutboundMiddleware(name string, mw OutboundMiddleware) {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	for i, m := range outboundMiddleware {
+		if m.name == name {
+			if mw == nil {
+				outboundMiddleware = append(outboundMiddleware[:i:i], outboundMiddleware[i+1:]...)
+			} else {
+				outboundMiddleware[i].mw = mw
+			}
+			return
+		}
+	}
+	if mw != nil {
+		outboundMiddleware = append(outboundMiddleware, namedOutbound{name: name, mw: mw})
+	}
+}
+
+func resetMiddleware() {
+	middlewareMu.Lock()
+	defer middlewareMu.Unlock()
+	inboundMiddleware = nil
+	outboundMiddleware = nil
+}
+
+// inboundPipeline builds the chain a platform feeds received messages
+// into: metrics, logging, registered middleware, then final.
+func inboundPipeline(metrics PlatformMetrics, final InboundHandler) InboundHandler {
+	middlewareMu.RLock()
+	registered := append([]namedInbound(nil), inboundMiddleware...)
+	middlewareMu.RUnlock()
+
+	h := final
+	for i := len(registered) - 1; i >= 0; i-- {
+		h = registered[i].mw(h)
+	}
+	h = logInbound(h)
+	if metrics != nil {
+		h = metricsInbound(metrics)(h)
+	}
+	return h
+}
+
+// outboundPipeline builds the chain a platform's SendMessage runs:
+// registered middleware, the muted-channel check, metrics, logging,
+// then send.
+func outboundPipeline(metrics PlatformMetrics, botName string, send OutboundHandler) OutboundHandler {
+	middlewareMu.RLock()
+	registered := append([]namedOutbound(nil), outboundMiddleware...)
+	middlewareMu.RUnlock()
+
+	h := logOutbound(botName)(send)
+	if metrics != nil {
+		h = metricsOutbound(metrics)(h)
+	}
+	h = mutedOutbound(h)
+	for i := len(registered) - 1; i >= 0; i-- {
+		h = registered[i].mw(h)
+	}
+	return h
+}
+
+// dispatchInbound ends every inbound pipeline: message listeners see the
+// message, then a command addressed to one of the bot's aliases runs.
+// Commands from bot accounts only run if the bot is in opts.allowBots.
+// Listeners see bot messages either way and skip them if they need to.
+func dispatchInbound(ctx context.Context, platform ChatPlatform, msg Message, opts parseCommandOpts) error {
+	events.Publish(events.Default, events.MessageReceived{
+		Platform: msg.Platform,
+		Channel:  msg.Channel,
+		UserID:   msg.UserID,
+		UserName: msg.UserName,
+		Text:     msg.Text,
+		IsBot:    msg.IsBot,
+		Time:     msg.Time,
+	})
+	notifyMessageListeners(msg)
+
+	if msg.IsBot && !contains(opts.allowBots, msg.UserID) {
+		return nil
+	}
+	cmdMatches := cmdRegex.FindStringSubmatch(msg.Text)
+	if cmdMatches == nil {
+		return nil
+	}
+	botID, cmd := strings.ToLower(cmdMatches[1]), strings.ToLower(cmdMatches[2])
+	// Split the third match into a slice on whitespace for arguments
+	arguments := strings.Fields(cmdMatches[3])
+
+	// Ignore the command if it's not directed at this bot
+	if !isAlias(botID) {
+		return nil
+	}
+	opts.displayName = msg.UserName
+	return parseCommand(ctx, platform, msg.Channel, msg.UserID, cmd, arguments, opts)
+}
+
+// logInbound logs commands only, so ordinary chat stays out of the log.
+// Twitch's verbose mode still logs every raw line.
+func logInbound(next InboundHandler) InboundHandler {
+	return func(ctx context.Context, msg Message) error {
+		if cmdRegex.MatchString(msg.Text) {
+			log.Printf("%s: %s #%s: %s", msg.Platform, msg.UserName, msg.Channel, msg.Text)
+		}
+		return next(ctx, msg)
+	}
+}
+
+func metricsInbound(metrics PlatformMetrics) InboundMiddleware {
+	return func(next InboundHandler) InboundHandler {
+		return func(ctx context.Context, msg Message) error {
+			metrics.RecordMessageReceived(msg.Platform)
+			return next(ctx, msg)
+		}
+	}
+}
+
+func logOutbound(botName string) OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(ctx context.Context, msg OutboundMessage) error {
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+			log.Printf("%s #%s: %s", botName, msg.Channel, msg.Text)
+			return nil
+		}
+	}
+}
+
+// mutedOutbound drops messages to channels whose settings mute the bot.
+func mutedOutbound(next OutboundHandler) OutboundHandler {
+	return func(ctx context.Context, msg OutboundMessage) error {
+		if ChannelSettingsFor(msg.Channel).Muted {
+			return nil
+		}
+		return next(ctx, msg)
+	}
+}
+
+func metricsOutbound(metrics PlatformMetrics) OutboundMiddleware {
+	return func(next OutboundHandler) OutboundHandler {
+		return func(ctx context.Context, msg OutboundMessage) error {
+			err := next(ctx, msg)
+			result := "success"
+			if err != nil {
+				result = "failure"
+			}
+			metrics.RecordMessageSent(msg.Platform, result)
+			return err
+		}
+	}
+}
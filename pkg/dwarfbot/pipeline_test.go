@@ -0,0 +1,184 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"context"
+	"dwarfbot/pkg/events"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func recordInbound(name string, order *[]string) InboundMiddleware {
+	return func(next InboundHandler) InboundHandler {
+		return func(ctx context.Context, msg Message) error {
+			*order = append(*order, name)
+			return next(ctx, msg)
+		}
+	}
+}
+
+func TestInboundPipeline_OrderAndListeners(t *testing.T) {
+	defer ResetRegistrations()
+	var order []string
+	RegisterInboundMiddleware("first", recordInbound("first", &order))
+	RegisterInboundMiddleware("second", recordInbound("second", &order))
+	RegisterMessageListener("listener", func(msg Message) { order = append(order, "listener") })
+	metrics := newMockMetricsRecorder()
+
+	h := inboundPipeline(metrics, func(ctx context.Context, msg Message) error {
+		notifyMessageListeners(msg)
+		return nil
+	})
+	if err := h(context.Background(), Message{Platform: "twitch", Channel: "ch", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ",") != "first,second,listener" {
+		t.Errorf("unexpected order %v", order)
+	}
+	if len(metrics.messagesReceived) != 1 || metrics.messagesReceived[0] != "twitch" {
+		t.Errorf("expected one received message recorded, got %v", metrics.messagesReceived)
+	}
+}
+
+func TestInboundPipeline_DropStopsDispatch(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterInboundMiddleware("drop", func(next InboundHandler) InboundHandler {
+		return func(ctx context.Context, msg Message) error {
+			if msg.UserID == "spammer" {
+				return nil
+			}
+			return next(ctx, msg)
+		}
+	})
+	mock := newMockPlatform("testbot", []string{"ch"})
+	dispatch := func(ctx context.Context, msg Message) error {
+		return dispatchInbound(ctx, mock, msg, parseCommandOpts{platformName: "twitch"})
+	}
+
+	h := inboundPipeline(nil, dispatch)
+	_ = h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserID: "spammer", Text: "!dwarfbot ping"})
+	if len(mock.messages) != 0 {
+		t.Errorf("expected dropped message not to run a command, got %v", mock.messages)
+	}
+	_ = h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserID: "viewer", Text: "!dwarfbot ping"})
+	if len(mock.messages) != 1 {
+		t.Errorf("expected ping to be answered, got %v", mock.messages)
+	}
+}
+
+func TestInboundPipeline_LogsOnlyCommands(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	h := inboundPipeline(nil, func(context.Context, Message) error { return nil })
+	_ = h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserName: "viewer", Text: "just chatting"})
+	_ = h(context.Background(), Message{Platform: "twitch", Channel: "ch", UserName: "viewer", Text: "!dwarfbot ping"})
+	if got := buf.String(); strings.Contains(got, "just chatting") || !strings.Contains(got, "!dwarfbot ping") {
+		t.Errorf("expected only the command to be logged, got %q", got)
+	}
+}
+
+func TestRegisterMiddleware_ReplaceAndRemove(t *testing.T) {
+	defer ResetRegistrations()
+	var order []string
+	RegisterInboundMiddleware("a", recordInbound("a", &order))
+	RegisterInboundMiddleware("b", recordInbound("b", &order))
+	RegisterInboundMiddleware("a", recordInbound("a2", &order))
+
+	h := inboundPipeline(nil, func(context.Context, Message) error { return nil })
+	_ = h(context.Background(), Message{})
+	if strings.Join(order, ",") != "a2,b" {
+		t.Errorf("expected replacement to keep its position, got %v", order)
+	}
+
+	order = nil
+	RegisterInboundMiddleware("a", nil)
+	h = inboundPipeline(nil, func(context.Context, Message) error { return nil })
+	_ = h(context.Background(), Message{})
+	if strings.Join(order, ",") != "b" {
+		t.Errorf("expected a to be removed, got %v", order)
+	}
+
+	ResetRegistrations()
+	order = nil
+	h = inboundPipeline(nil, func(context.Context, Message) error { return nil })
+	_ = h(context.Background(), Message{})
+	if len(order) != 0 {
+		t.Errorf("expected ResetRegistrations to clear middleware, got %v", order)
+	}
+}
+
+func TestOutboundPipeline_RewritesBeforeSend(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterOutboundMiddleware("redact", func(next OutboundHandler) OutboundHandler {
+		return func(ctx context.Context, msg OutboundMessage) error {
+			msg.Text = strings.ReplaceAll(msg.Text, "hunter2", "*******")
+			return next(ctx, msg)
+		}
+	})
+	bot, server, cleanup := newTestBot(t)
+	defer cleanup()
+	metrics := newMockMetricsRecorder()
+	bot.Metrics = metrics
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- bot.SendMessage(context.Background(), "ch", "the password is hunter2") }()
+	got := readFromConn(t, server)
+	if err := <-errCh; err != nil {
+		t.Fatalf("SendMessage: %v", err)
+	}
+	if got != "PRIVMSG #ch :the password is *******\r\n" {
+		t.Errorf("expected redacted message on the wire, got %q", got)
+	}
+	if len(metrics.messagesSent) != 1 || metrics.messagesSent[0].result != "success" {
+		t.Errorf("expected one successful send recorded, got %v", metrics.messagesSent)
+	}
+}
+
+func TestOutboundPipeline_Drop(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterOutboundMiddleware("mute", func(next OutboundHandler) OutboundHandler {
+		return func(ctx context.Context, msg OutboundMessage) error { return nil }
+	})
+	sent := false
+	h := outboundPipeline(nil, "testbot", func(context.Context, OutboundMessage) error {
+		sent = true
+		return nil
+	})
+	if err := h(context.Background(), OutboundMessage{Platform: "discord", Channel: "1", Text: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if sent {
+		t.Error("expected muted message not to be sent")
+	}
+}
+
+func TestDispatchInbound_PublishesEvents(t *testing.T) {
+	defer ResetRegistrations()
+	var received []events.MessageReceived
+	var executed []events.CommandExecuted
+	events.Subscribe(events.Default, func(e events.MessageReceived) { received = append(received, e) })
+	events.Subscribe(events.Default, func(e events.CommandExecuted) { executed = append(executed, e) })
+	mock := newMockPlatform("testbot", []string{"ch"})
+	opts := parseCommandOpts{platformName: "twitch"}
+
+	for _, text := range []string{"hello", "!dwarfbot ping", "!dwarfbot nosuchcommand"} {
+		msg := Message{Platform: "twitch", Channel: "ch", UserID: "viewer", UserName: "Viewer", Text: text}
+		if err := dispatchInbound(context.Background(), mock, msg, opts); err != nil {
+			t.Fatalf("dispatchInbound %q: %v", text, err)
+		}
+	}
+	if len(received) != 3 || received[0].Text != "hello" || received[0].UserName != "Viewer" {
+		t.Errorf("expected every message published, got %+v", received)
+	}
+	if len(executed) != 1 {
+		t.Fatalf("expected only the known command published, got %+v", executed)
+	}
+	if e := executed[0]; e.Platform != "twitch" || e.Channel != "ch" || e.UserID != "viewer" ||
+		e.UserName != "Viewer" || e.Command != "ping" || e.Admin || e.Err != nil {
+		t.Errorf("unexpected command event %+v", e)
+	}
+}
@@ -1,6 +1,17 @@
 package dwarfbot
 
-import "time"
+import (
+	"context"
+	"time"
+)
+
+// commandTimeout bounds everything a command does on the platform
+// synchronously: role lookups and replies.
+const commandTimeout = 10 * time.Second
+
+// sendTimeout bounds a message sent outside command dispatch, e.g. a
+// timer announcement or an async command reply.
+const sendTimeout = 10 * time.Second
 
 // PlatformMetrics provides hooks for recording platform-level metrics
 // without coupling to a specific metrics implementation.
@@ -12,16 +23,20 @@ type PlatformMetrics interface {
 	RecordMessageReceived(platform string)
 	RecordMessageSent(platform, result string)
 	RecordCommandProcessed(platform, command, admin string)
+	RecordCommandDenied(platform, command string)
 }
 
 // ChatPlatform abstracts a chat service (Twitch, Discord, etc.)
-// so that command handlers can work across platforms.
+// so that command handlers can work across platforms. Calls that touch
+// the network take a context: the dispatcher sets a deadline per
+// command and root cancels everything on shutdown.
 type ChatPlatform interface {
 	// SendMessage sends a message to the specified channel.
-	SendMessage(channel, msg string) error
+	SendMessage(ctx context.Context, channel, msg string) error
 
 	// IsAdmin checks if the user has admin privileges on this platform.
-	IsAdmin(channel, user string) bool
+	// It reports false if ctx ends before the lookup completes.
+	IsAdmin(ctx context.Context, channel, user string) bool
 
 	// BotName returns the bot's display name.
 	BotName() string
@@ -30,5 +45,30 @@ type ChatPlatform interface {
 	BotChannels() []string
 
 	// Shutdown performs a clean shutdown of the platform connection.
-	Shutdown(exitCode int)
+	// ctx bounds the disconnect when the platform stops itself.
+	Shutdown(ctx context.Context, exitCode int)
+}
+
+// Moderator is implemented by platforms that can enforce moderation.
+type Moderator interface {
+	// DeleteMessage removes msg from chat.
+	DeleteMessage(ctx context.Context, msg Message) error
+
+	// TimeoutUser stops the user chatting in channel for duration.
+	TimeoutUser(ctx context.Context, channel, userID string, duration time.Duration, reason string) error
+
+	// BanUser bans the user from channel.
+	BanUser(ctx context.Context, channel, userID, reason string) error
+}
+
+// RoleChecker is implemented by platforms with named roles, such as
+// Discord guild roles.
+type RoleChecker interface {
+	HasRole(ctx context.Context, channel, userID, role string) bool
+}
+
+// sendContext returns a context for a message sent outside command
+// dispatch, cancelled when parent is or after sendTimeout.
+func sendContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(parent, sendTimeout)
 }
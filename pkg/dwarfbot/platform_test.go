@@ -1,8 +1,14 @@
 package dwarfbot
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
 	"strings"
 	"testing"
+
+	"github.com/bwmarrin/discordgo"
 )
 
 // mockPlatform implements ChatPlatform for testing command routing
@@ -35,12 +41,12 @@ func newMockPlatformWithAdmin(name string, channels []string, adminFunc func(str
 	}
 }
 
-func (m *mockPlatform) SendMessage(channel, msg string) error {
+func (m *mockPlatform) SendMessage(_ context.Context, channel, msg string) error {
 	m.messages = append(m.messages, mockMessage{channel: channel, msg: msg})
 	return nil
 }
 
-func (m *mockPlatform) IsAdmin(channel, user string) bool {
+func (m *mockPlatform) IsAdmin(_ context.Context, channel, user string) bool {
 	if m.isAdminFunc != nil {
 		return m.isAdminFunc(channel, user)
 	}
@@ -55,7 +61,7 @@ func (m *mockPlatform) BotChannels() []string {
 	return m.channels
 }
 
-func (m *mockPlatform) Shutdown(exitCode int) {
+func (m *mockPlatform) Shutdown(_ context.Context, exitCode int) {
 	m.shutdownLog = append(m.shutdownLog, exitCode)
 }
 
@@ -69,7 +75,7 @@ var _ ChatPlatform = (*mockPlatform)(nil)
 
 func TestMockPlatform_SendMessage(t *testing.T) {
 	mock := newMockPlatform("bot", nil)
-	err := mock.SendMessage("ch1", "hello")
+	err := mock.SendMessage(context.Background(), "ch1", "hello")
 	if err != nil {
 		t.Errorf("expected no error, got %v", err)
 	}
@@ -83,7 +89,7 @@ func TestMockPlatform_SendMessage(t *testing.T) {
 
 func TestMockPlatform_IsAdmin_Default(t *testing.T) {
 	mock := newMockPlatform("bot", nil)
-	if mock.IsAdmin("ch", "user") {
+	if mock.IsAdmin(context.Background(), "ch", "user") {
 		t.Error("expected default IsAdmin to return false")
 	}
 }
@@ -92,10 +98,10 @@ func TestMockPlatform_IsAdmin_Custom(t *testing.T) {
 	mock := newMockPlatformWithAdmin("bot", nil, func(ch, user string) bool {
 		return user == "admin"
 	})
-	if !mock.IsAdmin("ch", "admin") {
+	if !mock.IsAdmin(context.Background(), "ch", "admin") {
 		t.Error("expected admin user to be admin")
 	}
-	if mock.IsAdmin("ch", "regular") {
+	if mock.IsAdmin(context.Background(), "ch", "regular") {
 		t.Error("expected regular user to not be admin")
 	}
 }
@@ -124,8 +130,8 @@ func TestMockPlatform_BotChannels_Empty(t *testing.T) {
 
 func TestMockPlatform_Shutdown(t *testing.T) {
 	mock := newMockPlatform("bot", nil)
-	mock.Shutdown(0)
-	mock.Shutdown(1)
+	mock.Shutdown(context.Background(), 0)
+	mock.Shutdown(context.Background(), 1)
 	if len(mock.shutdownLog) != 2 {
 		t.Fatalf("expected 2 shutdown calls, got %d", len(mock.shutdownLog))
 	}
@@ -138,7 +144,7 @@ func TestMockPlatform_Shutdown(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_Ping(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "someuser", "ping", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "someuser", "ping", []string{})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -153,7 +159,7 @@ func TestMockPlatform_ParseCommand_Ping(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_PingHeyo(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "user1", "ping", []string{"heyo"})
+	_ = parseCommand(context.Background(), mock, "ch1", "user1", "ping", []string{"heyo"})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -165,7 +171,7 @@ func TestMockPlatform_ParseCommand_PingHeyo(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_PingHeyoExtended(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "user1", "ping", []string{"heyoooo"})
+	_ = parseCommand(context.Background(), mock, "ch1", "user1", "ping", []string{"heyoooo"})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -177,7 +183,7 @@ func TestMockPlatform_ParseCommand_PingHeyoExtended(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_Channels(t *testing.T) {
 	mock := newMockPlatform("dwarfbot", []string{"general", "gaming"})
-	_ = parseCommand(mock, "general", "user1", "channels", []string{})
+	_ = parseCommand(context.Background(), mock, "general", "user1", "channels", []string{})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -193,7 +199,7 @@ func TestMockPlatform_ParseCommand_Channels(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_Channels_EmptyList(t *testing.T) {
 	mock := newMockPlatform("solobot", []string{})
-	_ = parseCommand(mock, "ch1", "user1", "channels", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "user1", "channels", []string{})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -205,7 +211,7 @@ func TestMockPlatform_ParseCommand_Channels_EmptyList(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_Unknown(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "user1", "nonexistent", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "user1", "nonexistent", []string{})
 
 	if len(mock.messages) != 0 {
 		t.Errorf("expected no messages for unknown command, got %d", len(mock.messages))
@@ -217,7 +223,7 @@ func TestMockPlatform_AdminShutdown(t *testing.T) {
 		return user == "admin_user"
 	})
 
-	_ = parseCommand(mock, "ch1", "admin_user", "shutdown", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "admin_user", "shutdown", []string{})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 shutdown message, got %d messages", len(mock.messages))
@@ -235,7 +241,7 @@ func TestMockPlatform_NonAdminCannotShutdown(t *testing.T) {
 		return false
 	})
 
-	_ = parseCommand(mock, "ch1", "regular_user", "shutdown", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "regular_user", "shutdown", []string{})
 
 	if len(mock.shutdownLog) != 0 {
 		t.Error("expected Shutdown NOT to be called for non-admin")
@@ -250,7 +256,7 @@ func TestMockPlatform_AdminPingStillWorks(t *testing.T) {
 		return user == "admin_user"
 	})
 
-	_ = parseCommand(mock, "ch1", "admin_user", "ping", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "admin_user", "ping", []string{})
 
 	// Admin users can also use regular commands
 	if len(mock.messages) != 1 {
@@ -294,28 +300,28 @@ func TestDiscordBot_BotChannels_Empty(t *testing.T) {
 
 func TestDiscordBot_IsAdmin_NoSession(t *testing.T) {
 	bot := &DiscordBot{AdminRole: "admin"}
-	if bot.IsAdmin("channel", "user") {
+	if bot.IsAdmin(context.Background(), "channel", "user") {
 		t.Error("expected IsAdmin to return false without session")
 	}
 }
 
 func TestDiscordBot_IsAdmin_NoRole(t *testing.T) {
 	bot := &DiscordBot{}
-	if bot.IsAdmin("channel", "user") {
+	if bot.IsAdmin(context.Background(), "channel", "user") {
 		t.Error("expected IsAdmin to return false without admin role")
 	}
 }
 
 func TestDiscordBot_IsAdmin_EmptyRole(t *testing.T) {
 	bot := &DiscordBot{AdminRole: ""}
-	if bot.IsAdmin("channel", "user") {
+	if bot.IsAdmin(context.Background(), "channel", "user") {
 		t.Error("expected IsAdmin to return false with empty admin role")
 	}
 }
 
 func TestDiscordBot_SendMessage_NoSession(t *testing.T) {
 	bot := &DiscordBot{Name: "testbot"}
-	err := bot.SendMessage("channel", "test")
+	err := bot.SendMessage(context.Background(), "channel", "test")
 	if err == nil {
 		t.Error("expected error when sending without session")
 	}
@@ -324,6 +330,48 @@ func TestDiscordBot_SendMessage_NoSession(t *testing.T) {
 	}
 }
 
+// roundTripFunc answers the Discord REST API in tests.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestDiscordBot_SendMessage_OnlyPingsUsers(t *testing.T) {
+	session, err := discordgo.New("Bot test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sent []discordgo.MessageSend
+	session.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var m discordgo.MessageSend
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		sent = append(sent, m)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"id":"1","channel_id":"123"}`)),
+			Request:    r,
+		}, nil
+	})}
+	bot := &DiscordBot{Name: "testbot", session: session}
+
+	if err := bot.SendMessage(context.Background(), "123", "@everyone look <@&55> <@42>"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bot.SendMessageWithReactions(context.Background(), "123", "@here vote", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(sent))
+	}
+	for _, m := range sent {
+		if m.AllowedMentions == nil || len(m.AllowedMentions.Parse) != 1 || m.AllowedMentions.Parse[0] != discordgo.AllowedMentionTypeUsers {
+			t.Errorf("expected only user mentions to be allowed for %q, got %+v", m.Content, m.AllowedMentions)
+		}
+	}
+}
+
 func TestDiscordBot_Shutdown_WithExitFunc(t *testing.T) {
 	var exitCode int
 	called := false
@@ -334,7 +382,7 @@ func TestDiscordBot_Shutdown_WithExitFunc(t *testing.T) {
 		},
 	}
 
-	bot.Shutdown(1)
+	bot.Shutdown(context.Background(), 1)
 
 	if !called {
 		t.Error("expected exitFunc to be called")
@@ -352,7 +400,7 @@ func TestDiscordBot_Shutdown_ZeroExitCode(t *testing.T) {
 		},
 	}
 
-	bot.Shutdown(0)
+	bot.Shutdown(context.Background(), 0)
 
 	if exitCode != 0 {
 		t.Errorf("expected exit code 0, got %d", exitCode)
@@ -365,7 +413,7 @@ func TestDiscordBot_Shutdown_WithCustomExitFunc(t *testing.T) {
 	bot := &DiscordBot{
 		exitFunc: func(code int) { called = true },
 	}
-	bot.Shutdown(0)
+	bot.Shutdown(context.Background(), 0)
 	if !called {
 		t.Error("expected exitFunc to be called")
 	}
@@ -411,10 +459,10 @@ func TestChatPlatform_Contract_MockPlatform(t *testing.T) {
 	if len(p.BotChannels()) != 1 {
 		t.Errorf("BotChannels: expected 1, got %d", len(p.BotChannels()))
 	}
-	if p.IsAdmin("ch", "user") {
+	if p.IsAdmin(context.Background(), "ch", "user") {
 		t.Error("IsAdmin: expected false for default mock")
 	}
-	if err := p.SendMessage("ch", "hi"); err != nil {
+	if err := p.SendMessage(context.Background(), "ch", "hi"); err != nil {
 		t.Errorf("SendMessage: unexpected error %v", err)
 	}
 }
@@ -433,11 +481,11 @@ func TestChatPlatform_Contract_DiscordBot(t *testing.T) {
 		t.Errorf("BotChannels: expected 1, got %d", len(p.BotChannels()))
 	}
 	// No session, so IsAdmin should be false
-	if p.IsAdmin("123", "user") {
+	if p.IsAdmin(context.Background(), "123", "user") {
 		t.Error("IsAdmin: expected false without session")
 	}
 	// No session, so SendMessage should error
-	if err := p.SendMessage("123", "hi"); err == nil {
+	if err := p.SendMessage(context.Background(), "123", "hi"); err == nil {
 		t.Error("SendMessage: expected error without session")
 	}
 }
@@ -450,7 +498,7 @@ func TestDiscordBot_Shutdown_NilSession(t *testing.T) {
 	bot := &DiscordBot{
 		exitFunc: func(code int) { exitCode = code },
 	}
-	bot.Shutdown(42)
+	bot.Shutdown(context.Background(), 42)
 	if exitCode != 42 {
 		t.Errorf("expected exit code 42, got %d", exitCode)
 	}
@@ -461,7 +509,7 @@ func TestDiscordBot_Shutdown_NonZeroExitCode(t *testing.T) {
 	bot := &DiscordBot{
 		exitFunc: func(code int) { exitCode = code },
 	}
-	bot.Shutdown(1)
+	bot.Shutdown(context.Background(), 1)
 	if exitCode != 1 {
 		t.Errorf("expected exit code 1, got %d", exitCode)
 	}
@@ -472,7 +520,7 @@ func TestDiscordBot_IsAdmin_NilSession(t *testing.T) {
 		AdminRole: "admin",
 	}
 	// session is nil, should return false
-	if bot.IsAdmin("channel", "user") {
+	if bot.IsAdmin(context.Background(), "channel", "user") {
 		t.Error("expected IsAdmin false with nil session")
 	}
 }
@@ -481,14 +529,14 @@ func TestDiscordBot_IsAdmin_EmptyAdminRole(t *testing.T) {
 	bot := &DiscordBot{
 		AdminRole: "",
 	}
-	if bot.IsAdmin("ch", "user") {
+	if bot.IsAdmin(context.Background(), "ch", "user") {
 		t.Error("expected IsAdmin false with empty admin role")
 	}
 }
 
 func TestDiscordBot_SendMessage_NilSession_ErrorMessage(t *testing.T) {
 	bot := &DiscordBot{Name: "testbot"}
-	err := bot.SendMessage("ch", "hello")
+	err := bot.SendMessage(context.Background(), "ch", "hello")
 	if err == nil {
 		t.Fatal("expected error")
 	}
@@ -532,7 +580,7 @@ func TestMockPlatform_AdminUnknownCommand(t *testing.T) {
 	})
 
 	// Admin sends unknown admin command — should fall through to regular commands
-	_ = parseCommand(mock, "ch1", "admin", "nonexistent", []string{})
+	_ = parseCommand(context.Background(), mock, "ch1", "admin", "nonexistent", []string{})
 
 	// No shutdown, no messages (unknown command)
 	if len(mock.shutdownLog) != 0 {
@@ -545,7 +593,7 @@ func TestMockPlatform_AdminUnknownCommand(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_PingCaseInsensitive(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "user", "ping", []string{"Heyo"})
+	_ = parseCommand(context.Background(), mock, "ch1", "user", "ping", []string{"Heyo"})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -557,7 +605,7 @@ func TestMockPlatform_ParseCommand_PingCaseInsensitive(t *testing.T) {
 
 func TestMockPlatform_ParseCommand_PingHeyooooo(t *testing.T) {
 	mock := newMockPlatform("testbot", []string{"ch1"})
-	_ = parseCommand(mock, "ch1", "user", "ping", []string{"heyooooo"})
+	_ = parseCommand(context.Background(), mock, "ch1", "user", "ping", []string{"heyooooo"})
 
 	if len(mock.messages) != 1 {
 		t.Fatalf("expected 1 message, got %d", len(mock.messages))
@@ -566,3 +614,32 @@ func TestMockPlatform_ParseCommand_PingHeyooooo(t *testing.T) {
 		t.Errorf("expected Heyo response for extended heyo, got %q", mock.messages[0].msg)
 	}
 }
+
+func TestDiscordBot_SetChannelIDs(t *testing.T) {
+	bot := &DiscordBot{ChannelIDs: []string{"1", "2"}}
+	added, removed := bot.SetChannelIDs([]string{"2", "3"})
+	if len(added) != 1 || added[0] != "3" || len(removed) != 1 || removed[0] != "1" {
+		t.Errorf("unexpected diff: added=%v removed=%v", added, removed)
+	}
+	if got := bot.BotChannels(); len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("expected [2 3], got %v", got)
+	}
+}
+
+func TestDiscordBot_SetAdminRole_InvalidatesCache(t *testing.T) {
+	bot := &DiscordBot{AdminRole: "mods", adminRoleCache: map[string]string{"guild": "role-id"}}
+
+	if bot.SetAdminRole("mods") {
+		t.Error("expected unchanged role to report no change")
+	}
+	if len(bot.adminRoleCache) != 1 {
+		t.Error("expected cache kept when role is unchanged")
+	}
+
+	if !bot.SetAdminRole("admins") {
+		t.Error("expected changed role to report a change")
+	}
+	if bot.AdminRole != "admins" || len(bot.adminRoleCache) != 0 {
+		t.Errorf("expected role updated and cache cleared, got %q %v", bot.AdminRole, bot.adminRoleCache)
+	}
+}
@@ -0,0 +1,312 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Plugin defaults and hard limits.
+const (
+	defaultPluginTimeout = 5 * time.Second
+	maxPluginTimeout     = time.Minute
+	maxPluginOutput      = 64 * 1024
+	maxPluginResponses   = 5
+)
+
+// commandNameRegex limits configured command names to what cmdRegex can match.
+var commandNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// pluginBaseEnv are the only parent variables a plugin inherits without
+// being listed, so interpreters found via "#!/usr/bin/env" still work.
+var pluginBaseEnv = []string{"PATH", "LANG", "TZ"}
+
+// PluginConfig binds a command to an external executable.
+type PluginConfig struct {
+	// Command is the chat command name, e.g. "weather".
+	Command string `mapstructure:"command"`
+
+	// Path is the executable to run.
+	Path string `mapstructure:"path"`
+
+	// Args are passed to the executable before any chat input; chat
+	// arguments only ever arrive on stdin.
+	Args []string `mapstructure:"args"`
+
+	// AdminOnly restricts the command to admins.
+	AdminOnly bool `mapstructure:"admin_only"`
+
+	// Timeout kills the plugin after this long. Defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// MaxConcurrent caps simultaneous runs of this plugin. Extra
+	// invocations are refused rather than queued. Defaults to 1.
+	MaxConcurrent int `mapstructure:"max_concurrent"`
+
+	// Env lists extra environment for the plugin: "NAME" passes the
+	// parent's value through, "NAME=value" sets it. DWARFBOT_* variables
+	// are never passed.
+	Env []string `mapstructure:"env"`
+}
+
+// ValidatePluginConfigs checks plugin definitions before they are registered.
+func ValidatePluginConfigs(configs []PluginConfig) error {
+	seen := map[string]bool{}
+	for i, c := range configs {
+		name := strings.ToLower(c.Command)
+		if !commandNameRegex.MatchString(name) {
+			return fmt.Errorf("plugin %d: command must use a-z, 0-9 and _ (max 32), got %q", i, c.Command)
+		}
+		if seen[name] {
+			return fmt.Errorf("plugin %q: duplicate command", name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(c.Path) == "" {
+			return fmt.Errorf("plugin %q: path must be set", name)
+		}
+		if c.Timeout < 0 || c.Timeout > maxPluginTimeout {
+			return fmt.Errorf("plugin %q: timeout must be between 0 and %v, got %v", name, maxPluginTimeout, c.Timeout)
+		}
+		if c.MaxConcurrent < 0 {
+			return fmt.Errorf("plugin %q: max_concurrent must be >= 0, got %d", name, c.MaxConcurrent)
+		}
+		for _, e := range c.Env {
+			key, _, _ := strings.Cut(e, "=")
+			if key == "" {
+				return fmt.Errorf("plugin %q: invalid env entry %q", name, e)
+			}
+			if strings.HasPrefix(strings.ToUpper(key), "DWARFBOT_") {
+				return fmt.Errorf("plugin %q: env %s would expose bot configuration", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// PluginRequest is written to a plugin's stdin as JSON.
+type PluginRequest struct {
+	Command  string   `json:"command"`
+	Platform string   `json:"platform"`
+	Channel  string   `json:"channel"`
+	User     string   `json:"user"`
+	UserName string   `json:"user_name"`
+	Identity string   `json:"identity"`
+	Role     string   `json:"role"`
+	Args     []string `json:"args"`
+}
+
+// PluginResponse is read from a plugin's stdout as JSON. Each response is
+// sent to the invoking channel as its own message.
+type PluginResponse struct {
+	Responses []string `json:"responses"`
+}
+
+type plugin struct {
+	config PluginConfig
+	env    []string
+	slots  chan struct{}
+}
+
+// Plugins runs external executables as chat commands. Plugins run off
+// the platform loop, so a slow plugin never delays chat.
+type Plugins struct {
+	plugins []*plugin
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewPlugins validates the configs and resolves each plugin's environment.
+func NewPlugins(configs []PluginConfig) (*Plugins, error) {
+	if err := ValidatePluginConfigs(configs); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Plugins{ctx: ctx, cancel: cancel}
+	for _, c := range configs {
+		c.Command = strings.ToLower(c.Command)
+		if c.Timeout == 0 {
+			c.Timeout = defaultPluginTimeout
+		}
+		if c.MaxConcurrent == 0 {
+			c.MaxConcurrent = 1
+		}
+		p.plugins = append(p.plugins, &plugin{
+			config: c,
+			env:    pluginEnv(c.Env, os.LookupEnv),
+			slots:  make(chan struct{}, c.MaxConcurrent),
+		})
+	}
+	return p, nil
+}
+
+// pluginEnv builds a plugin's environment from pluginBaseEnv and the
+// configured entries. Nothing else is inherited from the bot.
+func pluginEnv(entries []string, lookup func(string) (string, bool)) []string {
+	var env []string
+	for _, key := range pluginBaseEnv {
+		if v, ok := lookup(key); ok {
+			env = append(env, key+"="+v)
+		}
+	}
+	for _, e := range entries {
+		if strings.Contains(e, "=") {
+			env = append(env, e)
+			continue
+		}
+		if v, ok := lookup(e); ok {
+			env = append(env, e+"="+v)
+		}
+	}
+	return env
+}
+
+// RegisterCommands registers a command per plugin. Call it before
+// Counters.RegisterCommands so stored counters can't shadow a plugin.
+func (p *Plugins) RegisterCommands() error {
+	for _, pl := range p.plugins {
+		if isKnownCommand(pl.config.Command) {
+			return fmt.Errorf("plugin %q clashes with an existing command", pl.config.Command)
+		}
+	}
+	for _, pl := range p.plugins {
+		if pl.config.AdminOnly {
+			RegisterAdminCommand(pl.config.Command, p.handler(pl))
+		} else {
+			RegisterCommand(pl.config.Command, p.handler(pl))
+		}
+	}
+	if len(p.plugins) > 0 {
+		log.Printf("Plugins: registered %d command(s)", len(p.plugins))
+	}
+	return nil
+}
+
+// Stop kills running plugins and waits for them to exit.
+func (p *Plugins) Stop() {
+	p.cancel()
+	p.wg.Wait()
+}
+
+func (p *Plugins) handler(pl *plugin) CommandHandlerFunc {
+	return func(req CommandRequest) error {
+		select {
+		case pl.slots <- struct{}{}:
+		default:
+			return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "plugin.busy", nil))
+		}
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() { <-pl.slots }()
+			p.invoke(pl, req)
+		}()
+		return nil
+	}
+}
+
+func (p *Plugins) invoke(pl *plugin, req CommandRequest) {
+	role := RoleUser
+	if req.Admin {
+		role = RoleAdmin
+	}
+	args := req.Arguments
+	if args == nil {
+		args = []string{}
+	}
+	resp, err := pl.run(p.ctx, PluginRequest{
+		Command:  req.Command,
+		Platform: req.PlatformName,
+		Channel:  req.Channel,
+		User:     req.User,
+		UserName: req.UserName,
+		Identity: req.Identity,
+		Role:     role,
+		Args:     args,
+	})
+	// The dispatcher's deadline has passed by now; replies are bound
+	// to the plugins' own lifetime instead.
+	ctx, cancel := sendContext(p.ctx)
+	defer cancel()
+	if err != nil {
+		log.Printf("Plugin %s: %v", pl.config.Command, err)
+		if p.ctx.Err() == nil {
+			_ = req.Platform.SendMessage(ctx, req.Channel, Say(req.Channel, "plugin.failed", nil))
+		}
+		return
+	}
+	for i, text := range resp.Responses {
+		if i == maxPluginResponses {
+			log.Printf("Plugin %s: dropped %d response(s) over the limit of %d", pl.config.Command, len(resp.Responses)-i, maxPluginResponses)
+			break
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if err := req.Platform.SendMessage(ctx, req.Channel, text); err != nil {
+			log.Printf("Plugin %s: failed to send response: %v", pl.config.Command, err)
+		}
+	}
+}
+
+func (pl *plugin) run(parent context.Context, req PluginRequest) (PluginResponse, error) {
+	var resp PluginResponse
+	input, err := json.Marshal(req)
+	if err != nil {
+		return resp, fmt.Errorf("encoding request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(parent, pl.config.Timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxPluginOutput}
+	stderr := &limitedBuffer{limit: maxPluginOutput}
+	cmd := exec.CommandContext(ctx, pl.config.Path, pl.config.Args...)
+	cmd.Env = pl.env
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Don't wait forever on pipes held open by a plugin's children.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return resp, fmt.Errorf("timed out after %v", pl.config.Timeout)
+		}
+		return resp, fmt.Errorf("%w (stderr: %s)", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return resp, fmt.Errorf("output exceeded %d bytes", maxPluginOutput)
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return resp, fmt.Errorf("decoding response: %w", err)
+	}
+	return resp, nil
+}
+
+// limitedBuffer keeps at most limit bytes and discards the rest, so a
+// chatty plugin can't exhaust memory.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,161 @@
+package dwarfbot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncPlatform guards a mockPlatform for handlers that reply from
+// another goroutine.
+type syncPlatform struct {
+	*mockPlatform
+	mu sync.Mutex
+}
+
+func (s *syncPlatform) SendMessage(ctx context.Context, channel, msg string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mockPlatform.SendMessage(ctx, channel, msg)
+}
+
+func writePluginScript(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0o700); err != nil {
+		t.Fatalf("writing plugin: %v", err)
+	}
+	return path
+}
+
+func TestValidatePluginConfigs(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []PluginConfig
+	}{
+		{"bad command", []PluginConfig{{Command: "no spaces", Path: "/bin/true"}}},
+		{"no path", []PluginConfig{{Command: "weather"}}},
+		{"duplicate", []PluginConfig{{Command: "w", Path: "/bin/true"}, {Command: "W", Path: "/bin/true"}}},
+		{"timeout too long", []PluginConfig{{Command: "w", Path: "/bin/true", Timeout: time.Hour}}},
+		{"negative concurrency", []PluginConfig{{Command: "w", Path: "/bin/true", MaxConcurrent: -1}}},
+		{"bot env", []PluginConfig{{Command: "w", Path: "/bin/true", Env: []string{"DWARFBOT_TWITCH_TOKEN"}}}},
+		{"bot env literal", []PluginConfig{{Command: "w", Path: "/bin/true", Env: []string{"dwarfbot_x=1"}}}},
+	}
+	for _, tt := range tests {
+		if err := ValidatePluginConfigs(tt.configs); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestPluginEnv(t *testing.T) {
+	parent := map[string]string{
+		"PATH":                  "/usr/bin",
+		"DWARFBOT_TWITCH_TOKEN": "oauth:secret",
+		"WEATHER_KEY":           "abc",
+	}
+	lookup := func(k string) (string, bool) {
+		v, ok := parent[k]
+		return v, ok
+	}
+	env := pluginEnv([]string{"WEATHER_KEY", "UNITS=metric", "MISSING"}, lookup)
+	got := strings.Join(env, " ")
+	if got != "PATH=/usr/bin WEATHER_KEY=abc UNITS=metric" {
+		t.Errorf("unexpected env %q", got)
+	}
+}
+
+func TestPlugin_Run(t *testing.T) {
+	t.Setenv("DWARFBOT_TWITCH_TOKEN", "oauth:secret")
+	path := writePluginScript(t, `input=$(cat)
+case "$input" in
+  *'"role":"admin"'*'"args":["a","b"]'*) ;;
+  *) echo "bad input: $input" >&2; exit 1 ;;
+esac
+printf '{"responses":["token=%s units=%s"]}' "$DWARFBOT_TWITCH_TOKEN" "$UNITS"
+`)
+	p, err := NewPlugins([]PluginConfig{{Command: "echo", Path: path, Env: []string{"UNITS=metric"}}})
+	if err != nil {
+		t.Fatalf("NewPlugins: %v", err)
+	}
+	resp, err := p.plugins[0].run(context.Background(), PluginRequest{Command: "echo", Role: RoleAdmin, Args: []string{"a", "b"}})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(resp.Responses) != 1 || resp.Responses[0] != "token= units=metric" {
+		t.Errorf("unexpected responses %v", resp.Responses)
+	}
+}
+
+func TestPlugin_RunErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		script  string
+		timeout time.Duration
+		want    string
+	}{
+		{"timeout", "exec sleep 5\n", 100 * time.Millisecond, "timed out"},
+		{"exit status", "echo boom >&2; exit 3\n", 0, "boom"},
+		{"bad json", "echo not json\n", 0, "decoding response"},
+	}
+	for _, tt := range tests {
+		p, err := NewPlugins([]PluginConfig{{Command: "x", Path: writePluginScript(t, tt.script), Timeout: tt.timeout}})
+		if err != nil {
+			t.Fatalf("%s: NewPlugins: %v", tt.name, err)
+		}
+		_, err = p.plugins[0].run(context.Background(), PluginRequest{})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestPlugins_CommandRepliesAndLimitsConcurrency(t *testing.T) {
+	defer ResetRegistrations()
+	path := writePluginScript(t, `cat >/dev/null
+sleep 0.2
+printf '{"responses":["one","","two"]}'
+`)
+	p, err := NewPlugins([]PluginConfig{{Command: "slow", Path: path}})
+	if err != nil {
+		t.Fatalf("NewPlugins: %v", err)
+	}
+	if err := p.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	platform := &syncPlatform{mockPlatform: mock}
+	_ = parseCommand(context.Background(), platform, "chan", "viewer", "slow", nil)
+	_ = parseCommand(context.Background(), platform, "chan", "viewer", "slow", nil)
+	platform.mu.Lock()
+	sent := append([]mockMessage(nil), mock.messages...)
+	platform.mu.Unlock()
+	if len(sent) != 1 || sent[0].msg != Say("chan", "plugin.busy", nil) {
+		t.Fatalf("expected second call to be refused as busy, got %v", sent)
+	}
+
+	p.wg.Wait()
+	if len(mock.messages) != 3 || mock.messages[1].msg != "one" || mock.messages[2].msg != "two" {
+		t.Errorf("expected plugin responses, got %v", mock.messages)
+	}
+}
+
+func TestPlugins_RegisterRejectsClash(t *testing.T) {
+	defer ResetRegistrations()
+	RegisterCommand("taken", func(CommandRequest) error { return nil })
+	p, err := NewPlugins([]PluginConfig{{Command: "taken", Path: "/bin/true"}})
+	if err != nil {
+		t.Fatalf("NewPlugins: %v", err)
+	}
+	if err := p.RegisterCommands(); err == nil {
+		t.Error("expected clash with an existing command to fail")
+	}
+	if err := (&Plugins{}).RegisterCommands(); err != nil {
+		t.Errorf("expected no plugins to register cleanly, got %v", err)
+	}
+}
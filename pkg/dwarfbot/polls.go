@@ -0,0 +1,622 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pollsBucket is the store bucket the open poll is kept in, under
+// pollKey.
+const (
+	pollsBucket = "polls"
+	pollKey     = "current"
+)
+
+// Poll limits keep announcements short enough for one chat message.
+const (
+	pollDefaultDuration = 2 * time.Minute
+	pollMinDuration     = 15 * time.Second
+	pollMaxDuration     = 24 * time.Hour
+	pollMinOptions      = 2
+	pollMaxOptions      = 10
+	pollMaxQuestion     = 200
+	pollMaxOption       = 50
+)
+
+// pollEmoji are the Discord reactions for options 1 to 10.
+var pollEmoji = []string{"1️⃣", "2️⃣", "3️⃣", "4️⃣", "5️⃣", "6️⃣", "7️⃣", "8️⃣", "9️⃣", "🔟"}
+
+var errPollOpen = errors.New("a poll is already open")
+
+// ReactionMessenger is implemented by platforms that can post a message
+// with reactions already on it, for people to click instead of typing.
+type ReactionMessenger interface {
+	SendMessageWithReactions(ctx context.Context, channel, msg string, emoji []string) (string, error)
+}
+
+// PollChannel is a channel a poll was announced in. MessageID is set
+// where votes can be cast with reactions.
+type PollChannel struct {
+	Platform  string `json:"platform"`
+	Channel   string `json:"channel"`
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// Poll is an open poll. Votes maps each identity to the index of the
+// option it voted for, so linked accounts get one vote between them.
+// Reactions lists the options each identity has reacted with, oldest
+// first.
+type Poll struct {
+	Question  string           `json:"question"`
+	Options   []string         `json:"options"`
+	Opened    time.Time        `json:"opened"`
+	Closes    time.Time        `json:"closes"`
+	OpenedBy  string           `json:"opened_by"`
+	Channels  []PollChannel    `json:"channels"`
+	Votes     map[string]int   `json:"votes"`
+	Reactions map[string][]int `json:"reactions,omitempty"`
+}
+
+// Polls runs one poll at a time across every channel of every running
+// platform. Votes come from the "vote" command and, on Discord, from
+// reactions on the announcement. The open poll is persisted in the
+// store, so a restart picks it up where it left off.
+type Polls struct {
+	mu         sync.Mutex
+	store      *store.Store
+	identities *Identities
+	current    *Poll
+	platforms  map[string]ChatPlatform
+	wake       chan struct{}
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	nowFunc    func() time.Time
+}
+
+// NewPolls loads the open poll, if any, from s. Call SetPlatform for
+// each running platform, then Start.
+func NewPolls(s *store.Store, identities *Identities) (*Polls, error) {
+	p := &Polls{
+		store:      s,
+		identities: identities,
+		platforms:  map[string]ChatPlatform{},
+		wake:       make(chan struct{}, 1),
+		nowFunc:    time.Now,
+	}
+	var poll Poll
+	err := s.Get(pollsBucket, pollKey, &poll)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("loading poll: %w", err)
+	default:
+		if poll.Votes == nil {
+			poll.Votes = map[string]int{}
+		}
+		p.current = &poll
+	}
+	return p, nil
+}
+
+// SetPlatform registers a platform polls are announced on.
+func (p *Polls) SetPlatform(name string, platform ChatPlatform) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.platforms[name] = platform
+}
+
+// RegisterCommands registers the "poll" and "vote" commands and the
+// reaction listener that counts Discord votes.
+func (p *Polls) RegisterCommands() {
+	RegisterCommand("poll", p.HandlePollCommand)
+	RegisterCommand("vote", p.HandleVoteCommand)
+	RegisterReactionListener("polls", p.HandleReaction)
+}
+
+// Start begins watching for the open poll to close. A poll that closed
+// while the bot was down is closed straight away.
+func (p *Polls) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.mu.Lock()
+	p.cancel = cancel
+	open := p.current != nil
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.run(ctx)
+	if open {
+		log.Printf("Polls: resuming open poll")
+	}
+}
+
+// Stop waits for the scheduler to exit. The open poll stays in the
+// store.
+func (p *Polls) Stop() {
+	p.mu.Lock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Polls) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Polls) run(ctx context.Context) {
+	defer p.wg.Done()
+
+	for {
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if closes, ok := p.closes(); ok {
+			timer = time.NewTimer(closes.Sub(p.nowFunc()))
+			wait = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-p.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-wait:
+			if closes, ok := p.closes(); ok && !closes.After(p.nowFunc()) {
+				p.Close(ctx)
+			}
+		}
+	}
+}
+
+func (p *Polls) closes() (time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		return time.Time{}, false
+	}
+	return p.current.Closes, true
+}
+
+// Current returns a copy of the open poll.
+func (p *Polls) Current() (Poll, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		return Poll{}, false
+	}
+	poll := *p.current
+	poll.Channels = append([]PollChannel(nil), poll.Channels...)
+	poll.Votes = make(map[string]int, len(p.current.Votes))
+	for k, v := range p.current.Votes {
+		poll.Votes[k] = v
+	}
+	poll.Reactions = make(map[string][]int, len(p.current.Reactions))
+	for k, v := range p.current.Reactions {
+		poll.Reactions[k] = append([]int(nil), v...)
+	}
+	return poll, true
+}
+
+// Open announces a poll on every channel of every platform and starts
+// taking votes. Channels the announcement can't be sent to are left
+// out.
+func (p *Polls) Open(ctx context.Context, question string, options []string, duration time.Duration, openedBy string) error {
+	now := p.nowFunc()
+	poll := &Poll{
+		Question: question,
+		Options:  options,
+		Opened:   now,
+		Closes:   now.Add(duration),
+		OpenedBy: openedBy,
+		Votes:    map[string]int{},
+	}
+
+	p.mu.Lock()
+	if p.current != nil {
+		p.mu.Unlock()
+		return errPollOpen
+	}
+	// Claim the slot before announcing, so votes cast while the rest of
+	// the announcements go out are counted.
+	p.current = poll
+	names := make([]string, 0, len(p.platforms))
+	platforms := make(map[string]ChatPlatform, len(p.platforms))
+	for name, platform := range p.platforms {
+		names = append(names, name)
+		platforms[name] = platform
+	}
+	p.mu.Unlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		platform := platforms[name]
+		for _, channel := range platform.BotChannels() {
+			pc, err := p.announce(ctx, platform, name, channel, poll)
+			if err != nil {
+				log.Printf("Polls: announcing in %s on %s: %v", channel, name, err)
+			}
+			if pc == nil {
+				continue
+			}
+			p.mu.Lock()
+			if p.current == poll {
+				poll.Channels = append(poll.Channels, *pc)
+			}
+			p.mu.Unlock()
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != poll {
+		// Cancelled while being announced.
+		return nil
+	}
+	p.saveLocked()
+	p.notify()
+	log.Printf("Polls: %s opened %q with %d options for %v", openedBy, question, len(options), duration)
+	return nil
+}
+
+// announce posts the poll in one channel. It returns nil if nothing was
+// posted, and the channel along with an error if the announcement went
+// out but its reactions didn't.
+func (p *Polls) announce(ctx context.Context, platform ChatPlatform, name, channel string, poll *Poll) (*PollChannel, error) {
+	question, options := poll.Question, make([]string, len(poll.Options))
+	for i, o := range poll.Options {
+		options[i] = fmt.Sprintf("%d) %s", i+1, o)
+	}
+	if name == "discord" {
+		question = sanitizeDiscordMentions(question)
+		for i := range options {
+			options[i] = sanitizeDiscordMentions(options[i])
+		}
+	}
+	msg := Say(channel, "poll.open", Vars{
+		"Question": question,
+		"Options":  options,
+		"Max":      len(options),
+		"In":       formatUptime(poll.Closes.Sub(poll.Opened)),
+	})
+
+	sendCtx, cancel := sendContext(ctx)
+	defer cancel()
+	if rm, ok := platform.(ReactionMessenger); ok {
+		id, err := rm.SendMessageWithReactions(sendCtx, channel, msg, pollEmoji[:len(options)])
+		if id == "" {
+			return nil, err
+		}
+		return &PollChannel{Platform: name, Channel: channel, MessageID: id}, err
+	}
+	if err := platform.SendMessage(sendCtx, channel, msg); err != nil {
+		return nil, err
+	}
+	return &PollChannel{Platform: name, Channel: channel}, nil
+}
+
+// Vote records identity's vote for option (0-based), replacing any
+// earlier vote. It reports false if no poll is open.
+func (p *Polls) Vote(identity string, option int) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil || option < 0 || option >= len(p.current.Options) {
+		return false
+	}
+	if prev, ok := p.current.Votes[identity]; ok && prev == option {
+		return true
+	}
+	p.current.Votes[identity] = option
+	p.saveLocked()
+	return true
+}
+
+// HandleReaction counts a reaction on a poll announcement as a vote.
+// Removing the reaction for the option a user voted for moves the vote
+// to their latest remaining reaction, or withdraws it if none is left.
+func (p *Polls) HandleReaction(r Reaction) {
+	option := -1
+	for i, e := range pollEmoji {
+		if e == r.Emoji {
+			option = i
+			break
+		}
+	}
+	if option < 0 {
+		return
+	}
+	identity := p.identities.Resolve(r.Platform, r.UserID)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil || option >= len(p.current.Options) {
+		return
+	}
+	announcement := false
+	for _, pc := range p.current.Channels {
+		if pc.Platform == r.Platform && pc.MessageID != "" && pc.MessageID == r.MessageID {
+			announcement = true
+		}
+	}
+	if !announcement {
+		return
+	}
+	if p.current.Reactions == nil {
+		p.current.Reactions = map[string][]int{}
+	}
+	reacted := slices.DeleteFunc(p.current.Reactions[identity], func(o int) bool { return o == option })
+	if r.Added {
+		reacted = append(reacted, option)
+		p.current.Votes[identity] = option
+	} else if prev, voted := p.current.Votes[identity]; voted && prev == option {
+		if len(reacted) > 0 {
+			p.current.Votes[identity] = reacted[len(reacted)-1]
+		} else {
+			delete(p.current.Votes, identity)
+		}
+	}
+	if len(reacted) > 0 {
+		p.current.Reactions[identity] = reacted
+	} else {
+		delete(p.current.Reactions, identity)
+	}
+	p.saveLocked()
+}
+
+// Close ends the open poll and announces the results wherever it was
+// announced. It reports false if no poll is open.
+func (p *Polls) Close(ctx context.Context) bool {
+	poll, ok := p.take()
+	if !ok {
+		return false
+	}
+
+	counts := make([]int, len(poll.Options))
+	for _, option := range poll.Votes {
+		if option >= 0 && option < len(counts) {
+			counts[option]++
+		}
+	}
+	total, best := 0, 0
+	for _, c := range counts {
+		total += c
+		best = max(best, c)
+	}
+	results := make([]string, len(poll.Options))
+	var winners []string
+	for i, o := range poll.Options {
+		results[i] = fmt.Sprintf("%s %d", o, counts[i])
+		if total > 0 {
+			results[i] += fmt.Sprintf(" (%d%%)", counts[i]*100/total)
+		}
+		if total > 0 && counts[i] == best {
+			winners = append(winners, o)
+		}
+	}
+	log.Printf("Polls: %q closed with %d vote(s): %s", poll.Question, total, strings.Join(results, ", "))
+
+	p.mu.Lock()
+	platforms := make(map[string]ChatPlatform, len(p.platforms))
+	for name, platform := range p.platforms {
+		platforms[name] = platform
+	}
+	p.mu.Unlock()
+
+	key := "poll.results"
+	if total == 0 {
+		key = "poll.no_votes"
+	}
+	for _, pc := range poll.Channels {
+		platform := platforms[pc.Platform]
+		if platform == nil {
+			log.Printf("Polls: platform %s is not running, results not posted in %s", pc.Platform, pc.Channel)
+			continue
+		}
+		question, winner := poll.Question, strings.Join(winners, ", ")
+		shown := results
+		if pc.Platform == "discord" {
+			question, winner = sanitizeDiscordMentions(question), sanitizeDiscordMentions(winner)
+			shown = make([]string, len(results))
+			for i, r := range results {
+				shown[i] = sanitizeDiscordMentions(r)
+			}
+		}
+		vars := Vars{
+			"Question": question,
+			"Results":  shown,
+			"Winner":   winner,
+			"Tie":      len(winners) > 1,
+			"Total":    total,
+		}
+		sendCtx, cancel := sendContext(ctx)
+		if err := platform.SendMessage(sendCtx, pc.Channel, Say(pc.Channel, key, vars)); err != nil {
+			log.Printf("Polls: posting results in %s on %s: %v", pc.Channel, pc.Platform, err)
+		}
+		cancel()
+	}
+	return true
+}
+
+// Cancel ends the open poll without results. It reports false if no
+// poll is open.
+func (p *Polls) Cancel() bool {
+	_, ok := p.take()
+	return ok
+}
+
+// take removes the open poll from memory and the store.
+func (p *Polls) take() (Poll, bool) {
+	p.mu.Lock()
+	poll := p.current
+	p.current = nil
+	p.mu.Unlock()
+	if poll == nil {
+		return Poll{}, false
+	}
+	p.notify()
+	if err := p.store.Delete(pollsBucket, pollKey); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Polls: deleting poll: %v", err)
+	}
+	return *poll, true
+}
+
+// saveLocked persists the open poll. Callers hold p.mu.
+func (p *Polls) saveLocked() {
+	if err := p.store.Put(pollsBucket, pollKey, p.current); err != nil {
+		log.Printf("Polls: saving poll: %v", err)
+	}
+}
+
+// HandlePollCommand implements:
+//
+//	poll                                  show the open poll
+//	poll "<question>" <a> | <b> [| ...] [duration]   admins only
+//	poll end                              admins only; close and announce
+//	poll cancel                           admins only; close silently
+func (p *Polls) HandlePollCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+
+	if len(req.Arguments) == 0 {
+		poll, ok := p.Current()
+		if !ok {
+			return reply(Say(req.Channel, "poll.none", nil))
+		}
+		options := make([]string, len(poll.Options))
+		for i, o := range poll.Options {
+			options[i] = fmt.Sprintf("%d) %s", i+1, o)
+		}
+		return reply(Say(req.Channel, "poll.status", Vars{
+			"Question": poll.Question,
+			"Options":  options,
+			"Votes":    len(poll.Votes),
+			"Left":     formatUptime(poll.Closes.Sub(p.nowFunc())),
+		}))
+	}
+
+	if !req.Admin {
+		return reply(Say(req.Channel, "poll.denied", nil))
+	}
+	if len(req.Arguments) == 1 {
+		switch strings.ToLower(req.Arguments[0]) {
+		case "end", "close":
+			if !p.Close(req.Context) {
+				return reply(Say(req.Channel, "poll.none", nil))
+			}
+			return nil
+		case "cancel":
+			if !p.Cancel() {
+				return reply(Say(req.Channel, "poll.none", nil))
+			}
+			return reply(Say(req.Channel, "poll.cancelled", nil))
+		}
+	}
+
+	question, options, duration, err := parsePoll(req.Arguments)
+	if err != nil {
+		return reply(Say(req.Channel, "poll.invalid", Vars{"Error": err}))
+	}
+	err = p.Open(req.Context, question, options, duration, req.UserName)
+	if errors.Is(err, errPollOpen) {
+		poll, _ := p.Current()
+		return reply(Say(req.Channel, "poll.already_open", Vars{"Question": poll.Question}))
+	}
+	return err
+}
+
+// HandleVoteCommand implements "vote <n>". Votes are counted quietly so
+// a busy poll doesn't flood chat.
+func (p *Polls) HandleVoteCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	poll, ok := p.Current()
+	if !ok {
+		return reply(Say(req.Channel, "poll.none", nil))
+	}
+	n := 0
+	if len(req.Arguments) == 1 {
+		n, _ = strconv.Atoi(strings.TrimPrefix(req.Arguments[0], "#"))
+	}
+	if n < 1 || n > len(poll.Options) {
+		return reply(Say(req.Channel, "vote.invalid", Vars{"Max": len(poll.Options)}))
+	}
+	identity := req.Identity
+	if identity == "" {
+		identity = p.identities.Resolve(req.PlatformName, req.User)
+	}
+	if !p.Vote(identity, n-1) {
+		return reply(Say(req.Channel, "poll.none", nil))
+	}
+	return nil
+}
+
+// parsePoll turns `"<question>" <a> | <b> [| ...] [duration]` into a
+// poll. Without quotes the question runs up to its first "?".
+func parsePoll(args []string) (string, []string, time.Duration, error) {
+	text := strings.NewReplacer("“", `"`, "”", `"`).Replace(strings.Join(args, " "))
+
+	var question, rest string
+	if strings.HasPrefix(text, `"`) {
+		end := strings.Index(text[1:], `"`)
+		if end < 0 {
+			return "", nil, 0, errors.New("the question is missing its closing quote")
+		}
+		question, rest = text[1:end+1], text[end+2:]
+	} else if end := strings.Index(text, "?"); end >= 0 {
+		question, rest = text[:end+1], text[end+1:]
+	} else {
+		return "", nil, 0, errors.New(`put the question in quotes: poll "Which boss next?" Ornstein | Gwyn | Nito 5m`)
+	}
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return "", nil, 0, errors.New("the question is empty")
+	}
+	if len(question) > pollMaxQuestion {
+		return "", nil, 0, fmt.Errorf("the question is longer than %d characters", pollMaxQuestion)
+	}
+
+	options := strings.Split(rest, "|")
+	duration := pollDefaultDuration
+	last := strings.Fields(options[len(options)-1])
+	if len(last) > 1 {
+		if d, err := time.ParseDuration(last[len(last)-1]); err == nil {
+			duration = d
+			options[len(options)-1] = strings.Join(last[:len(last)-1], " ")
+		}
+	}
+	if len(options) < pollMinOptions || len(options) > pollMaxOptions {
+		return "", nil, 0, fmt.Errorf("give %d to %d options separated by |", pollMinOptions, pollMaxOptions)
+	}
+	for i, o := range options {
+		o = strings.Join(strings.Fields(o), " ")
+		if o == "" {
+			return "", nil, 0, fmt.Errorf("option %d is empty", i+1)
+		}
+		if len(o) > pollMaxOption {
+			return "", nil, 0, fmt.Errorf("option %d is longer than %d characters", i+1, pollMaxOption)
+		}
+		options[i] = o
+	}
+	if duration < pollMinDuration || duration > pollMaxDuration {
+		return "", nil, 0, fmt.Errorf("polls run for %v to %v", pollMinDuration, pollMaxDuration)
+	}
+	return question, options, duration, nil
+}
@@ -0,0 +1,281 @@
+package dwarfbot
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reactionPlatform posts every message with reactions, like Discord.
+type reactionPlatform struct {
+	*mockPlatform
+	reactions []string
+}
+
+func (r *reactionPlatform) SendMessageWithReactions(ctx context.Context, channel, msg string, emoji []string) (string, error) {
+	if err := r.SendMessage(ctx, channel, msg); err != nil {
+		return "", err
+	}
+	r.reactions = emoji
+	return "msg-" + channel, nil
+}
+
+func newTestPolls(t *testing.T, path string) (*Polls, *Identities, *time.Time) {
+	t.Helper()
+	s := openTestStore(t, path)
+	ids, err := NewIdentities(s)
+	if err != nil {
+		t.Fatalf("NewIdentities: %v", err)
+	}
+	p, err := NewPolls(s, ids)
+	if err != nil {
+		t.Fatalf("NewPolls: %v", err)
+	}
+	return p, ids, pinClock(&p.nowFunc, time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC))
+}
+
+func pollCommand(t *testing.T, p *Polls, mock ChatPlatform, platform, user string, admin bool, cmd, args string) {
+	t.Helper()
+	handler := p.HandlePollCommand
+	if cmd == "vote" {
+		handler = p.HandleVoteCommand
+	}
+	if err := handler(commandRequest(mock, platform, user, admin, cmd, args)); err != nil {
+		t.Fatalf("%s %s: %v", cmd, args, err)
+	}
+}
+
+func TestParsePoll(t *testing.T) {
+	tests := []struct {
+		args     string
+		question string
+		options  []string
+		duration time.Duration
+	}{
+		{`"Which boss next?" Ornstein | Gwyn | Nito 5m`, "Which boss next?", []string{"Ornstein", "Gwyn", "Nito"}, 5 * time.Minute},
+		{`Which boss next? Ornstein|Gwyn`, "Which boss next?", []string{"Ornstein", "Gwyn"}, pollDefaultDuration},
+		{`“Best   axe” Great Axe | Pick 1h`, "Best axe", []string{"Great Axe", "Pick"}, time.Hour},
+		{`"Break length" 5m | 10m`, "Break length", []string{"5m", "10m"}, pollDefaultDuration},
+	}
+	for _, tt := range tests {
+		q, opts, d, err := parsePoll(strings.Fields(tt.args))
+		if err != nil {
+			t.Errorf("parsePoll(%s): %v", tt.args, err)
+			continue
+		}
+		if q != tt.question || strings.Join(opts, ",") != strings.Join(tt.options, ",") || d != tt.duration {
+			t.Errorf("parsePoll(%s) = %q %q %v, want %q %q %v", tt.args, q, opts, d, tt.question, tt.options, tt.duration)
+		}
+	}
+
+	for _, args := range []string{
+		`no question here | a | b`,
+		`"unterminated question | a | b`,
+		`"Only one?" a`,
+		`"Empty?" a | | b`,
+		`"Too short?" a | b 5s`,
+		`"Too long?" a | b 48h`,
+		`"Too many?" 1|2|3|4|5|6|7|8|9|10|11`,
+		`"" a | b`,
+	} {
+		if _, _, _, err := parsePoll(strings.Fields(args)); err == nil {
+			t.Errorf("parsePoll(%s): expected an error", args)
+		}
+	}
+}
+
+func TestPolls_VoteAcrossPlatforms(t *testing.T) {
+	p, ids, _ := newTestPolls(t, "")
+	twitch := newMockPlatform("testbot", []string{"hammerdwarf", "anvil"})
+	discord := &reactionPlatform{mockPlatform: newMockPlatform("testbot", []string{"123"})}
+	p.SetPlatform("twitch", twitch)
+	p.SetPlatform("discord", discord)
+
+	// grim on Twitch and 42 on Discord are one person.
+	code := ids.IssueCode("discord", "42", "Grim")
+	if _, err := ids.Redeem(code, "twitch", "grim", "grim"); err != nil {
+		t.Fatal(err)
+	}
+
+	pollCommand(t, p, twitch, "twitch", "viewer", false, "poll", `"Which boss next?" Ornstein | Gwyn`)
+	if got := lastMessage(twitch); !strings.Contains(got, "boss") {
+		t.Fatalf("expected non-admins to be refused, got %q", got)
+	}
+	pollCommand(t, p, twitch, "twitch", "hammerdwarf", true, "poll", `"Which boss next?" Ornstein | Gwyn | Nito 5m`)
+	if len(twitch.messages) != 3 || twitch.messages[1].channel != "hammerdwarf" || twitch.messages[2].channel != "anvil" {
+		t.Fatalf("expected an announcement in each Twitch channel, got %v", twitch.messages)
+	}
+	if !strings.Contains(twitch.messages[1].msg, "1) Ornstein 2) Gwyn 3) Nito") {
+		t.Errorf("unexpected announcement %q", twitch.messages[1].msg)
+	}
+	if len(discord.messages) != 1 || len(discord.reactions) != 3 || discord.reactions[2] != "3️⃣" {
+		t.Fatalf("expected a Discord announcement with 3 reactions, got %v %v", discord.messages, discord.reactions)
+	}
+
+	pollCommand(t, p, twitch, "twitch", "hammerdwarf", true, "poll", `"Another?" a | b`)
+	if got := lastMessage(twitch); !strings.Contains(got, "Which boss next?") {
+		t.Errorf("expected a second poll to be refused, got %q", got)
+	}
+
+	before := len(twitch.messages)
+	pollCommand(t, p, twitch, "twitch", "grim", false, "vote", "1")
+	pollCommand(t, p, twitch, "twitch", "viewer", false, "vote", "2")
+	pollCommand(t, p, twitch, "twitch", "lurker", false, "vote", "2")
+	if len(twitch.messages) != before {
+		t.Errorf("expected votes to be counted quietly, got %v", twitch.messages[before:])
+	}
+	pollCommand(t, p, twitch, "twitch", "viewer", false, "vote", "4")
+	if got := lastMessage(twitch); !strings.Contains(got, "1 tae 3") {
+		t.Errorf("expected out-of-range vote to be refused, got %q", got)
+	}
+
+	// Grim changes their vote on Discord; that replaces the Twitch vote.
+	p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "msg-123", UserID: "42", Emoji: "3️⃣", Added: true})
+	// Reactions elsewhere and unknown emoji don't count.
+	p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "other", UserID: "7", Emoji: "1️⃣", Added: true})
+	p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "msg-123", UserID: "7", Emoji: "⛏️", Added: true})
+	// Lurker's linked nothing; their Discord account votes separately
+	// and then thinks better of it.
+	p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "msg-123", UserID: "8", Emoji: "1️⃣", Added: true})
+	p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "msg-123", UserID: "8", Emoji: "1️⃣", Added: false})
+
+	poll, ok := p.Current()
+	if !ok {
+		t.Fatal("expected an open poll")
+	}
+	want := map[string]int{"discord:42": 2, "twitch:viewer": 1, "twitch:lurker": 1}
+	if len(poll.Votes) != len(want) {
+		t.Errorf("votes = %v, want %v", poll.Votes, want)
+	}
+	for k, v := range want {
+		if poll.Votes[k] != v {
+			t.Errorf("votes = %v, want %v", poll.Votes, want)
+			break
+		}
+	}
+
+	pollCommand(t, p, twitch, "twitch", "hammerdwarf", true, "poll", "end")
+	for _, mock := range []*mockPlatform{twitch, discord.mockPlatform} {
+		got := lastMessage(mock)
+		if !strings.Contains(got, "Ornstein 0 (0%), Gwyn 2 (66%), Nito 1 (33%)") || !strings.Contains(got, "Gwyn wins") {
+			t.Errorf("unexpected results %q", got)
+		}
+	}
+	if got := twitch.messages[len(twitch.messages)-2]; got.channel != "hammerdwarf" || !strings.Contains(got.msg, "Gwyn wins") {
+		t.Errorf("expected results in every Twitch channel, got %v", got)
+	}
+	if _, ok := p.Current(); ok {
+		t.Error("expected the poll to be closed")
+	}
+	pollCommand(t, p, twitch, "twitch", "viewer", false, "vote", "1")
+	if got := lastMessage(twitch); !strings.Contains(got, "nae poll") {
+		t.Errorf("expected votes after closing to be refused, got %q", got)
+	}
+}
+
+func TestPolls_RemovingOneOfSeveralReactions(t *testing.T) {
+	p, _, _ := newTestPolls(t, "")
+	discord := &reactionPlatform{mockPlatform: newMockPlatform("testbot", []string{"123"})}
+	p.SetPlatform("discord", discord)
+	pollCommand(t, p, discord, "discord", "42", true, "poll", `"Pick?" a | b | c`)
+	react := func(emoji string, added bool) {
+		p.HandleReaction(Reaction{Platform: "discord", Channel: "123", MessageID: "msg-123", UserID: "7", Emoji: emoji, Added: added})
+	}
+	vote := func() (int, bool) {
+		poll, _ := p.Current()
+		v, ok := poll.Votes["discord:7"]
+		return v, ok
+	}
+
+	react("1️⃣", true)
+	react("2️⃣", true)
+	react("3️⃣", true)
+	react("3️⃣", false)
+	if v, ok := vote(); !ok || v != 1 {
+		t.Errorf("expected the vote to fall back to the latest remaining reaction, got %d %v", v, ok)
+	}
+	react("1️⃣", false)
+	if v, ok := vote(); !ok || v != 1 {
+		t.Errorf("expected removing another reaction to keep the vote, got %d %v", v, ok)
+	}
+	react("2️⃣", false)
+	if _, ok := vote(); ok {
+		t.Error("expected removing the last reaction to withdraw the vote")
+	}
+	if poll, _ := p.Current(); len(poll.Reactions) != 0 {
+		t.Errorf("expected no reactions left, got %v", poll.Reactions)
+	}
+}
+
+func TestPolls_TieAndNoVotes(t *testing.T) {
+	p, _, _ := newTestPolls(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+	p.SetPlatform("twitch", mock)
+
+	pollCommand(t, p, mock, "twitch", "chan", true, "poll", `"Pick?" a | b`)
+	pollCommand(t, p, mock, "twitch", "x", false, "vote", "1")
+	pollCommand(t, p, mock, "twitch", "y", false, "vote", "2")
+	p.Close(context.Background())
+	if got := lastMessage(mock); !strings.Contains(got, "tie between a, b") {
+		t.Errorf("expected a tie, got %q", got)
+	}
+
+	pollCommand(t, p, mock, "twitch", "chan", true, "poll", `"Anyone?" a | b`)
+	p.Close(context.Background())
+	if got := lastMessage(mock); !strings.Contains(got, "Nae one voted") {
+		t.Errorf("expected no votes, got %q", got)
+	}
+
+	pollCommand(t, p, mock, "twitch", "chan", true, "poll", `"Scrap?" a | b`)
+	pollCommand(t, p, mock, "twitch", "chan", true, "poll", "cancel")
+	if got := lastMessage(mock); !strings.Contains(got, "scrapped") {
+		t.Errorf("expected cancellation, got %q", got)
+	}
+	pollCommand(t, p, mock, "twitch", "chan", false, "poll", "")
+	if got := lastMessage(mock); !strings.Contains(got, "nae poll") {
+		t.Errorf("expected no open poll, got %q", got)
+	}
+}
+
+func TestPolls_SurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	p, _, now := newTestPolls(t, path)
+	mock := newMockPlatform("testbot", []string{"chan"})
+	p.SetPlatform("twitch", mock)
+	pollCommand(t, p, mock, "twitch", "chan", true, "poll", `"Which boss next?" Ornstein | Gwyn 5m`)
+	pollCommand(t, p, mock, "twitch", "chan", false, "vote", "2")
+
+	reopened, _, reopenedNow := newTestPolls(t, path)
+	poll, ok := reopened.Current()
+	if !ok || poll.Question != "Which boss next?" || poll.Votes["twitch:chan"] != 1 || len(poll.Channels) != 1 {
+		t.Fatalf("expected the open poll to be reloaded, got %+v", poll)
+	}
+	pollCommand(t, reopened, mock, "twitch", "chan", false, "poll", "")
+	if got := lastMessage(mock); !strings.Contains(got, "1 vote(s) so far, 5m0s tae go") {
+		t.Errorf("unexpected status %q", got)
+	}
+
+	// The poll closed while the bot was down.
+	*reopenedNow = now.Add(10 * time.Minute)
+	after := newMockPlatform("testbot", []string{"chan"})
+	reopened.SetPlatform("twitch", after)
+	reopened.Start()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, open := reopened.Current(); !open || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	reopened.Stop()
+	if got := lastMessage(after); !strings.Contains(got, "Gwyn wins") {
+		t.Errorf("expected overdue poll to close on start, got %q", got)
+	}
+
+	final, _, _ := newTestPolls(t, path)
+	if _, ok := final.Current(); ok {
+		t.Error("expected the closed poll to be removed from the store")
+	}
+}
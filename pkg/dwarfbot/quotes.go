@@ -0,0 +1,248 @@
+package dwarfbot
+
+import (
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// quotesBucket is the store bucket quotes are kept in.
+const quotesBucket = "quotes"
+
+// maxQuoteLength keeps quotes within a single chat message on either platform.
+const maxQuoteLength = 400
+
+// maxSearchResults caps how many quote IDs a search lists.
+const maxSearchResults = 10
+
+// Quote is a remembered chat line. Quotes are shared across platforms.
+type Quote struct {
+	ID         int    `json:"id"`
+	Text       string `json:"text"`
+	QuotedBy   string `json:"quoted_by"`
+	QuotedByID string `json:"quoted_by_id"`
+
+	// QuotedByIdentity is the quoter's linked identity; see Identities.
+	QuotedByIdentity string    `json:"quoted_by_identity,omitempty"`
+	Channel          string    `json:"channel"`
+	Platform         string    `json:"platform"`
+	Time             time.Time `json:"time"`
+}
+
+// ErrNoQuotes is returned by Random when the quote book is empty.
+var ErrNoQuotes = errors.New("no quotes")
+
+// QuoteBook stores quotes in the persistent store.
+type QuoteBook struct {
+	store    *store.Store
+	nowFunc  func() time.Time
+	randIntN func(n int) int
+}
+
+// NewQuoteBook returns a QuoteBook backed by s.
+func NewQuoteBook(s *store.Store) *QuoteBook {
+	return &QuoteBook{
+		store:    s,
+		nowFunc:  time.Now,
+		randIntN: rand.IntN,
+	}
+}
+
+// Add saves a new quote and returns it with its assigned ID.
+func (qb *QuoteBook) Add(q Quote) (Quote, error) {
+	q.Time = qb.nowFunc()
+	err := qb.store.Update(func(tx *store.Tx) error {
+		id, err := tx.NextSequence(quotesBucket)
+		if err != nil {
+			return err
+		}
+		q.ID = id
+		return tx.Put(quotesBucket, store.IntKey(id), q)
+	})
+	return q, err
+}
+
+// Get returns the quote with the given ID.
+func (qb *QuoteBook) Get(id int) (Quote, error) {
+	var q Quote
+	err := qb.store.Get(quotesBucket, store.IntKey(id), &q)
+	return q, err
+}
+
+// Delete removes a quote. It returns store.ErrNotFound if it doesn't exist.
+func (qb *QuoteBook) Delete(id int) error {
+	return qb.store.Update(func(tx *store.Tx) error {
+		var q Quote
+		if err := tx.Get(quotesBucket, store.IntKey(id), &q); err != nil {
+			return err
+		}
+		return tx.Delete(quotesBucket, store.IntKey(id))
+	})
+}
+
+// All returns every quote ordered by ID.
+func (qb *QuoteBook) All() ([]Quote, error) {
+	var quotes []Quote
+	err := qb.store.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(quotesBucket) {
+			var q Quote
+			if err := tx.Get(quotesBucket, key, &q); err != nil {
+				return err
+			}
+			quotes = append(quotes, q)
+		}
+		return nil
+	})
+	sort.Slice(quotes, func(i, j int) bool { return quotes[i].ID < quotes[j].ID })
+	return quotes, err
+}
+
+// Random returns a random quote.
+func (qb *QuoteBook) Random() (Quote, error) {
+	quotes, err := qb.All()
+	if err != nil {
+		return Quote{}, err
+	}
+	if len(quotes) == 0 {
+		return Quote{}, ErrNoQuotes
+	}
+	return quotes[qb.randIntN(len(quotes))], nil
+}
+
+// Search returns quotes containing word, ignoring case.
+func (qb *QuoteBook) Search(word string) ([]Quote, error) {
+	quotes, err := qb.All()
+	if err != nil {
+		return nil, err
+	}
+	word = strings.ToLower(word)
+	var out []Quote
+	for _, q := range quotes {
+		if strings.Contains(strings.ToLower(q.Text), word) {
+			out = append(out, q)
+		}
+	}
+	return out, nil
+}
+
+func formatQuote(q Quote) string {
+	return fmt.Sprintf("Quote #%d: \"%s\" (quoted by %s on %s, %s)",
+		q.ID, q.Text, q.QuotedBy, q.Platform, q.Time.Format("2006-01-02"))
+}
+
+// HandleCommand implements the "quote" command:
+// quote add <text> | quote <id> | quote random | quote search <word> | quote del <id>.
+// Deleting requires admin.
+func (qb *QuoteBook) HandleCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	usage := "Usage: quote add <text>|<id>|random|search <word>|del <id>"
+
+	if len(req.Arguments) == 0 {
+		return qb.replyRandom(req.Channel, reply)
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "add":
+		text := strings.TrimSpace(strings.Join(req.Arguments[1:], " "))
+		if text == "" {
+			return reply(usage)
+		}
+		if len(text) > maxQuoteLength {
+			return reply(Say(req.Channel, "quote.too_long", Vars{"Max": maxQuoteLength}))
+		}
+		q, err := qb.Add(Quote{
+			Text:             text,
+			QuotedBy:         req.UserName,
+			QuotedByID:       req.User,
+			QuotedByIdentity: req.Identity,
+			Channel:          req.Channel,
+			Platform:         req.PlatformName,
+		})
+		if err != nil {
+			return fmt.Errorf("adding quote: %w", err)
+		}
+		return reply(Say(req.Channel, "quote.saved", Vars{"ID": q.ID}))
+
+	case "random":
+		return qb.replyRandom(req.Channel, reply)
+
+	case "search":
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		word := strings.Join(req.Arguments[1:], " ")
+		found, err := qb.Search(word)
+		if err != nil {
+			return fmt.Errorf("searching quotes: %w", err)
+		}
+		switch len(found) {
+		case 0:
+			return reply(Say(req.Channel, "quote.no_match", Vars{"Word": word}))
+		case 1:
+			return reply(formatQuote(found[0]))
+		}
+		ids := make([]string, 0, maxSearchResults)
+		for i, q := range found {
+			if i == maxSearchResults {
+				break
+			}
+			ids = append(ids, "#"+strconv.Itoa(q.ID))
+		}
+		msg := fmt.Sprintf("Found %d quotes: %s", len(found), strings.Join(ids, ", "))
+		if len(found) > maxSearchResults {
+			msg += ", ..."
+		}
+		return reply(msg)
+
+	case "del", "delete":
+		if !req.Admin {
+			return reply(Say(req.Channel, "quote.delete_denied", nil))
+		}
+		if len(req.Arguments) < 2 {
+			return reply(usage)
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(req.Arguments[1], "#"))
+		if err != nil {
+			return reply(usage)
+		}
+		if err := qb.Delete(id); err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return reply(Say(req.Channel, "quote.not_found", Vars{"ID": id}))
+			}
+			return fmt.Errorf("deleting quote: %w", err)
+		}
+		return reply(Say(req.Channel, "quote.deleted", Vars{"ID": id}))
+
+	default:
+		id, err := strconv.Atoi(strings.TrimPrefix(req.Arguments[0], "#"))
+		if err != nil {
+			return reply(usage)
+		}
+		q, err := qb.Get(id)
+		if err != nil {
+			if errors.Is(err, store.ErrNotFound) {
+				return reply(Say(req.Channel, "quote.not_found", Vars{"ID": id}))
+			}
+			return fmt.Errorf("reading quote: %w", err)
+		}
+		return reply(formatQuote(q))
+	}
+}
+
+func (qb *QuoteBook) replyRandom(channel string, reply func(string) error) error {
+	q, err := qb.Random()
+	if errors.Is(err, ErrNoQuotes) {
+		return reply(Say(channel, "quote.none", nil))
+	}
+	if err != nil {
+		return fmt.Errorf("reading quotes: %w", err)
+	}
+	return reply(formatQuote(q))
+}
@@ -0,0 +1,161 @@
+package dwarfbot
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestQuoteBook(t *testing.T) *QuoteBook {
+	t.Helper()
+	qb := NewQuoteBook(openTestStore(t, ""))
+	pinClock(&qb.nowFunc, time.Date(2026, 10, 18, 20, 0, 0, 0, time.UTC))
+	qb.randIntN = func(n int) int { return n - 1 }
+	return qb
+}
+
+func quoteCmd(platform ChatPlatform, platformName, user string, admin bool, args ...string) CommandRequest {
+	return CommandRequest{
+		Platform:     platform,
+		PlatformName: platformName,
+		Channel:      "ch1",
+		User:         user,
+		UserName:     user,
+		Admin:        admin,
+		Command:      "quote",
+		Arguments:    args,
+	}
+}
+
+func TestQuoteBook_AddAndGet(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	mock := newMockPlatform("testbot", nil)
+
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "hammerdwarf", false, "add", "Rock", "and", "stone!"))
+	_ = qb.HandleCommand(quoteCmd(mock, "discord", "someone", false, "1"))
+
+	if len(mock.messages) != 2 {
+		t.Fatalf("expected 2 replies, got %d", len(mock.messages))
+	}
+	if !strings.Contains(mock.messages[0].msg, "#1 saved") {
+		t.Errorf("expected save confirmation, got %q", mock.messages[0].msg)
+	}
+	want := `Quote #1: "Rock and stone!" (quoted by hammerdwarf on twitch, 2026-10-18)`
+	if mock.messages[1].msg != want {
+		t.Errorf("got %q, want %q", mock.messages[1].msg, want)
+	}
+
+	q, err := qb.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Channel != "ch1" || q.QuotedByID != "hammerdwarf" || q.Platform != "twitch" {
+		t.Errorf("unexpected stored quote %+v", q)
+	}
+}
+
+func TestQuoteBook_SharedAcrossPlatforms(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	twitchBook := NewQuoteBook(openTestStore(t, path))
+	if _, err := twitchBook.Add(Quote{Text: "from twitch", Platform: "twitch"}); err != nil {
+		t.Fatal(err)
+	}
+
+	discordBook := NewQuoteBook(openTestStore(t, path))
+	q, err := discordBook.Get(1)
+	if err != nil {
+		t.Fatalf("expected quote to persist: %v", err)
+	}
+	if q.Text != "from twitch" {
+		t.Errorf("unexpected quote %+v", q)
+	}
+}
+
+func TestQuoteBook_RandomAndEmpty(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	mock := newMockPlatform("testbot", nil)
+
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "random"))
+	if !strings.Contains(mock.messages[0].msg, "nae quotes") {
+		t.Errorf("expected empty quote book reply, got %q", mock.messages[0].msg)
+	}
+
+	_, _ = qb.Add(Quote{Text: "first"})
+	_, _ = qb.Add(Quote{Text: "second"})
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false))
+	if !strings.Contains(mock.messages[1].msg, "second") {
+		t.Errorf("expected injected random choice, got %q", mock.messages[1].msg)
+	}
+}
+
+func TestQuoteBook_Search(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	mock := newMockPlatform("testbot", nil)
+	_, _ = qb.Add(Quote{Text: "Beer is life"})
+	_, _ = qb.Add(Quote{Text: "More BEER"})
+	_, _ = qb.Add(Quote{Text: "Stone"})
+
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "search", "beer"))
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "search", "stone"))
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "search", "elf"))
+
+	if mock.messages[0].msg != "Found 2 quotes: #1, #2" {
+		t.Errorf("unexpected search output %q", mock.messages[0].msg)
+	}
+	if !strings.HasPrefix(mock.messages[1].msg, "Quote #3") {
+		t.Errorf("expected single match to print the quote, got %q", mock.messages[1].msg)
+	}
+	if !strings.Contains(mock.messages[2].msg, "Nae quotes") {
+		t.Errorf("expected no-match reply, got %q", mock.messages[2].msg)
+	}
+}
+
+func TestQuoteBook_DeleteRequiresAdmin(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	mock := newMockPlatform("testbot", nil)
+	_, _ = qb.Add(Quote{Text: "keep me"})
+
+	_ = qb.HandleCommand(quoteCmd(mock, "discord", "pleb", false, "del", "1"))
+	if _, err := qb.Get(1); err != nil {
+		t.Fatalf("expected non-admin delete to be refused: %v", err)
+	}
+
+	_ = qb.HandleCommand(quoteCmd(mock, "discord", "boss", true, "del", "#1"))
+	if _, err := qb.Get(1); err == nil {
+		t.Error("expected admin delete to remove the quote")
+	}
+
+	_ = qb.HandleCommand(quoteCmd(mock, "discord", "boss", true, "del", "1"))
+	if !strings.Contains(mock.messages[2].msg, "nae quote #1") {
+		t.Errorf("expected missing quote reply, got %q", mock.messages[2].msg)
+	}
+}
+
+func TestQuoteBook_IDsNotReusedAfterDelete(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	_, _ = qb.Add(Quote{Text: "one"})
+	_ = qb.Delete(1)
+	q, _ := qb.Add(Quote{Text: "two"})
+	if q.ID != 2 {
+		t.Errorf("expected IDs to keep increasing, got %d", q.ID)
+	}
+}
+
+func TestQuoteBook_Usage(t *testing.T) {
+	qb := newTestQuoteBook(t)
+	mock := newMockPlatform("testbot", nil)
+
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "add"))
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "banana"))
+	_ = qb.HandleCommand(quoteCmd(mock, "twitch", "u", false, "add", strings.Repeat("x", maxQuoteLength+1)))
+
+	for i := 0; i < 2; i++ {
+		if !strings.HasPrefix(mock.messages[i].msg, "Usage:") {
+			t.Errorf("expected usage for message %d, got %q", i, mock.messages[i].msg)
+		}
+	}
+	if !strings.Contains(mock.messages[2].msg, "saga") {
+		t.Errorf("expected length refusal, got %q", mock.messages[2].msg)
+	}
+}
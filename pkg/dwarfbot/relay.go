@@ -0,0 +1,320 @@
+package dwarfbot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultRelayPerMinute caps a route when max_per_minute is not set.
+const defaultRelayPerMinute = 20
+
+// relayQueueSize bounds messages waiting to be relayed so a slow
+// platform never blocks the other platform's chat loop.
+const relayQueueSize = 100
+
+// Per-platform message length limits, leaving headroom for the prefix.
+var relayMaxLen = map[string]int{
+	"twitch":  450,
+	"discord": 1900,
+}
+
+// RelayRoute mirrors chat from channels on one platform into a channel on
+// the other. Routes are one-way; configure a second route for the
+// reverse direction.
+type RelayRoute struct {
+	// Name identifies the route in logs. Defaults to "<from>-><to>".
+	Name string `mapstructure:"name"`
+
+	// From is the source platform ("twitch" or "discord").
+	From string `mapstructure:"from"`
+
+	// FromChannels are the source channels. Empty means every channel.
+	FromChannels []string `mapstructure:"from_channels"`
+
+	// To is the destination platform.
+	To string `mapstructure:"to"`
+
+	// ToChannel is the destination channel.
+	ToChannel string `mapstructure:"to_channel"`
+
+	// IncludeCommands relays messages starting with "!" (off by default).
+	IncludeCommands bool `mapstructure:"include_commands"`
+
+	// IncludeBots relays messages from bot accounts (off by default).
+	IncludeBots bool `mapstructure:"include_bots"`
+
+	// IgnoreUsers are source logins/IDs never relayed, e.g. Twitch bots
+	// that Twitch doesn't flag as bots.
+	IgnoreUsers []string `mapstructure:"ignore_users"`
+
+	// MaxPerMinute caps relayed messages on this route. Excess messages
+	// are dropped.
+	MaxPerMinute int `mapstructure:"max_per_minute"`
+}
+
+// ValidateRelayRoutes checks relay routes before they are used.
+func ValidateRelayRoutes(routes []RelayRoute) error {
+	for i, r := range routes {
+		for _, p := range []string{r.From, r.To} {
+			if p != "twitch" && p != "discord" {
+				return fmt.Errorf("relay route %d: platforms must be twitch or discord, got %q", i, p)
+			}
+		}
+		if r.From == r.To {
+			return fmt.Errorf("relay route %d: from and to must be different platforms", i)
+		}
+		if strings.TrimSpace(r.ToChannel) == "" {
+			return fmt.Errorf("relay route %d: to_channel must be set", i)
+		}
+		if r.MaxPerMinute < 0 {
+			return fmt.Errorf("relay route %d: max_per_minute must be >= 0, got %d", i, r.MaxPerMinute)
+		}
+	}
+	return nil
+}
+
+type relayRoute struct {
+	config RelayRoute
+	name   string
+
+	// sent holds send times within the last minute for rate capping.
+	sent []time.Time
+}
+
+type relayItem struct {
+	route   string
+	to      string
+	channel string
+	text    string
+}
+
+// Relay mirrors messages between platforms. It is registered as a
+// message listener and posts through ChatPlatform.SendMessage.
+type Relay struct {
+	mu        sync.Mutex
+	routes    []*relayRoute
+	platforms map[string]ChatPlatform
+	queue     chan relayItem
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	nowFunc   func() time.Time
+}
+
+// NewRelay validates the routes and returns a Relay. Call SetPlatform
+// for each running platform, then Start.
+func NewRelay(routes []RelayRoute) (*Relay, error) {
+	if err := ValidateRelayRoutes(routes); err != nil {
+		return nil, err
+	}
+	r := &Relay{
+		platforms: map[string]ChatPlatform{},
+		queue:     make(chan relayItem, relayQueueSize),
+		nowFunc:   time.Now,
+	}
+	for _, c := range routes {
+		if c.To == "twitch" {
+			c.ToChannel = strings.ToLower(strings.TrimPrefix(c.ToChannel, "#"))
+		}
+		if c.MaxPerMinute == 0 {
+			c.MaxPerMinute = defaultRelayPerMinute
+		}
+		name := c.Name
+		if name == "" {
+			name = c.From + "->" + c.To
+		}
+		r.routes = append(r.routes, &relayRoute{config: c, name: name})
+	}
+	return r, nil
+}
+
+// SetPlatform registers the platform messages for name are sent through.
+func (r *Relay) SetPlatform(name string, platform ChatPlatform) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.platforms[name] = platform
+}
+
+// Start begins delivering queued messages.
+func (r *Relay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go r.deliverLoop(ctx)
+	if len(r.routes) > 0 {
+		log.Printf("Relay: %d route(s) active", len(r.routes))
+	}
+}
+
+// Stop halts delivery. Messages still queued are dropped.
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *Relay) deliverLoop(ctx context.Context) {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item := <-r.queue:
+			r.deliver(ctx, item)
+		}
+	}
+}
+
+func (r *Relay) deliver(ctx context.Context, item relayItem) {
+	r.mu.Lock()
+	platform := r.platforms[item.to]
+	r.mu.Unlock()
+	if platform == nil {
+		return
+	}
+	ctx, cancel := sendContext(ctx)
+	defer cancel()
+	if err := platform.SendMessage(ctx, item.channel, item.text); err != nil {
+		log.Printf("Relay %s: failed to send to %s %s: %v", item.route, item.to, item.channel, err)
+	}
+}
+
+// HandleMessage queues msg on every matching route. It never blocks.
+func (r *Relay) HandleMessage(msg Message) {
+	for _, item := range r.route(msg) {
+		select {
+		case r.queue <- item:
+		default:
+			log.Printf("Relay %s: queue full, dropping message", item.route)
+		}
+	}
+}
+
+// route returns the deliveries msg should produce, applying filters and
+// rate caps.
+func (r *Relay) route(msg Message) []relayItem {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.isLoop(msg) {
+		return nil
+	}
+
+	var out []relayItem
+	now := r.nowFunc()
+	for _, rt := range r.routes {
+		c := rt.config
+		if c.From != msg.Platform {
+			continue
+		}
+		if !matchesAny(c.FromChannels, msg.Channel) {
+			continue
+		}
+		if !c.IncludeCommands && strings.HasPrefix(strings.TrimSpace(msg.Text), "!") {
+			continue
+		}
+		if !c.IncludeBots && msg.IsBot {
+			continue
+		}
+		if len(c.IgnoreUsers) > 0 && matchesAny(c.IgnoreUsers, msg.UserID) {
+			continue
+		}
+		if !rt.allow(now) {
+			log.Printf("Relay %s: rate cap of %d/min reached, dropping message", rt.name, c.MaxPerMinute)
+			continue
+		}
+		out = append(out, relayItem{
+			route:   rt.name,
+			to:      c.To,
+			channel: c.ToChannel,
+			text:    formatRelay(msg, c.To),
+		})
+	}
+	return out
+}
+
+// isLoop reports whether msg is the bot's own output or something it
+// already relayed. Must be called with r.mu held.
+func (r *Relay) isLoop(msg Message) bool {
+	if p := r.platforms[msg.Platform]; p != nil && strings.EqualFold(p.BotName(), msg.UserName) {
+		return true
+	}
+	return relayPrefixRegex.MatchString(msg.Text)
+}
+
+// allow applies the per-minute cap, recording the send if allowed.
+func (rt *relayRoute) allow(now time.Time) bool {
+	cutoff := now.Add(-time.Minute)
+	kept := rt.sent[:0]
+	for _, t := range rt.sent {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	rt.sent = kept
+	if len(rt.sent) >= rt.config.MaxPerMinute {
+		return false
+	}
+	rt.sent = append(rt.sent, now)
+	return true
+}
+
+// relayPrefixRegex matches text that already carries a relay prefix.
+var relayPrefixRegex = regexp.MustCompile(`^\[(twitch|discord)\] `)
+
+// formatRelay renders msg as "[platform] user: text" for the destination.
+func formatRelay(msg Message, to string) string {
+	text := fmt.Sprintf("[%s] %s: %s", msg.Platform, msg.UserName, msg.Text)
+	text = strings.Join(strings.Fields(text), " ")
+	if to == "discord" {
+		text = sanitizeDiscordMentions(text)
+	}
+	if limit := relayMaxLen[to]; limit > 0 && len(text) > limit {
+		text = truncateUTF8(text, limit) + "…"
+	}
+	return text
+}
+
+// discordMentionRegex matches mass mentions and user, role and channel
+// mention syntax.
+var discordMentionRegex = regexp.MustCompile(`@(everyone|here)|<(@[!&]?|#)(\d+)>`)
+
+// zeroWidthSpace breaks mention syntax without visibly changing the text.
+const zeroWidthSpace = "\u200b"
+
+// sanitizeDiscordMentions defuses mentions by inserting a zero-width space
+// so relayed text can't ping @everyone, @here, users or roles.
+func sanitizeDiscordMentions(text string) string {
+	return discordMentionRegex.ReplaceAllStringFunc(text, func(m string) string {
+		if strings.HasPrefix(m, "@") {
+			return "@" + zeroWidthSpace + m[1:]
+		}
+		return "<" + zeroWidthSpace + m[1:]
+	})
+}
+
+// truncateUTF8 shortens s to at most n bytes without splitting a rune.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,153 @@
+package dwarfbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateRelayRoutes(t *testing.T) {
+	tests := []struct {
+		name    string
+		routes  []RelayRoute
+		wantErr bool
+	}{
+		{"valid", []RelayRoute{{From: "twitch", To: "discord", ToChannel: "123"}}, false},
+		{"bad platform", []RelayRoute{{From: "irc", To: "discord", ToChannel: "123"}}, true},
+		{"same platform", []RelayRoute{{From: "discord", To: "discord", ToChannel: "123"}}, true},
+		{"missing to_channel", []RelayRoute{{From: "twitch", To: "discord"}}, true},
+		{"negative cap", []RelayRoute{{From: "twitch", To: "discord", ToChannel: "1", MaxPerMinute: -1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRelayRoutes(tt.routes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRelayRoutes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestRelay(t *testing.T, routes ...RelayRoute) *Relay {
+	t.Helper()
+	r, err := NewRelay(routes)
+	if err != nil {
+		t.Fatalf("NewRelay: %v", err)
+	}
+	r.SetPlatform("twitch", newMockPlatform("dwarfbot", []string{"hammerdwarf"}))
+	r.SetPlatform("discord", newMockPlatform("dwarfbot", []string{"123"}))
+	return r
+}
+
+func TestRelay_FormatsAndRoutes(t *testing.T) {
+	r := newTestRelay(t,
+		RelayRoute{From: "twitch", FromChannels: []string{"#hammerdwarf"}, To: "discord", ToChannel: "123"},
+		RelayRoute{From: "discord", FromChannels: []string{"123"}, To: "twitch", ToChannel: "#HammerDwarf"},
+	)
+
+	items := r.route(Message{Platform: "twitch", Channel: "hammerdwarf", UserName: "viewer", Text: "hello there"})
+	if len(items) != 1 || items[0].to != "discord" || items[0].channel != "123" || items[0].text != "[twitch] viewer: hello there" {
+		t.Fatalf("unexpected twitch->discord items: %+v", items)
+	}
+
+	items = r.route(Message{Platform: "discord", Channel: "123", UserName: "gamer", Text: "hi\nall"})
+	if len(items) != 1 || items[0].channel != "hammerdwarf" || items[0].text != "[discord] gamer: hi all" {
+		t.Fatalf("unexpected discord->twitch items: %+v", items)
+	}
+
+	if items := r.route(Message{Platform: "twitch", Channel: "otherchannel", UserName: "viewer", Text: "hi"}); len(items) != 0 {
+		t.Errorf("expected unlisted channel to be ignored, got %+v", items)
+	}
+}
+
+func TestRelay_Filters(t *testing.T) {
+	r := newTestRelay(t, RelayRoute{From: "discord", To: "twitch", ToChannel: "hammerdwarf", IgnoreUsers: []string{"999"}})
+
+	tests := []struct {
+		name string
+		msg  Message
+	}{
+		{"command", Message{Platform: "discord", Channel: "1", UserName: "u", Text: "!dwarfbot ping"}},
+		{"bot author", Message{Platform: "discord", Channel: "1", UserName: "u", Text: "beep", IsBot: true}},
+		{"ignored user", Message{Platform: "discord", Channel: "1", UserID: "999", UserName: "u", Text: "hi"}},
+		{"own message", Message{Platform: "discord", Channel: "1", UserName: "DwarfBot", Text: "hi"}},
+		{"already relayed", Message{Platform: "discord", Channel: "1", UserName: "u", Text: "[twitch] someone: hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if items := r.route(tt.msg); len(items) != 0 {
+				t.Errorf("expected message to be filtered, got %+v", items)
+			}
+		})
+	}
+
+	r = newTestRelay(t, RelayRoute{From: "discord", To: "twitch", ToChannel: "hammerdwarf", IncludeCommands: true, IncludeBots: true})
+	if items := r.route(Message{Platform: "discord", Channel: "1", UserName: "u", Text: "!roll", IsBot: true}); len(items) != 1 {
+		t.Errorf("expected commands and bots to be relayed when included, got %+v", items)
+	}
+}
+
+func TestRelay_RateCap(t *testing.T) {
+	r := newTestRelay(t, RelayRoute{From: "twitch", To: "discord", ToChannel: "123", MaxPerMinute: 2})
+	now := pinClock(&r.nowFunc, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	msg := Message{Platform: "twitch", Channel: "hammerdwarf", UserName: "viewer", Text: "spam"}
+	for i := 0; i < 2; i++ {
+		if items := r.route(msg); len(items) != 1 {
+			t.Fatalf("message %d: expected relay, got %+v", i, items)
+		}
+	}
+	if items := r.route(msg); len(items) != 0 {
+		t.Fatalf("expected third message to be capped, got %+v", items)
+	}
+
+	*now = now.Add(61 * time.Second)
+	if items := r.route(msg); len(items) != 1 {
+		t.Errorf("expected cap to reset after a minute, got %+v", items)
+	}
+}
+
+func TestRelay_Deliver(t *testing.T) {
+	r := newTestRelay(t, RelayRoute{From: "twitch", To: "discord", ToChannel: "123"})
+	discord := newMockPlatform("dwarfbot", []string{"123"})
+	r.SetPlatform("discord", discord)
+
+	for _, item := range r.route(Message{Platform: "twitch", Channel: "hammerdwarf", UserName: "viewer", Text: "hi"}) {
+		r.deliver(context.Background(), item)
+	}
+	if len(discord.messages) != 1 || discord.messages[0].channel != "123" || discord.messages[0].msg != "[twitch] viewer: hi" {
+		t.Errorf("unexpected delivered messages: %v", discord.messages)
+	}
+}
+
+func TestSanitizeDiscordMentions(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"hello @everyone", "hello @\u200beveryone"},
+		{"@here look", "@\u200bhere look"},
+		{"hi <@123>", "hi <\u200b@123>"},
+		{"hi <@!123>", "hi <\u200b@!123>"},
+		{"role <@&456>", "role <\u200b@&456>"},
+		{"see <#789>", "see <\u200b#789>"},
+		{"email me@example.com", "email me@example.com"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeDiscordMentions(tt.in); got != tt.want {
+			t.Errorf("sanitizeDiscordMentions(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatRelay_Truncates(t *testing.T) {
+	long := strings.Repeat("é", 400)
+	got := formatRelay(Message{Platform: "discord", UserName: "u", Text: long}, "twitch")
+	if len(got) > relayMaxLen["twitch"]+len("…") {
+		t.Errorf("expected message truncated to %d bytes, got %d", relayMaxLen["twitch"], len(got))
+	}
+	if !strings.HasSuffix(got, "…") {
+		t.Errorf("expected truncation marker, got %q", got[len(got)-10:])
+	}
+}
@@ -0,0 +1,469 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// remindersBucket is the store bucket pending reminders are kept in.
+const remindersBucket = "reminders"
+
+// Reminder limits keep one user from flooding the store or a channel.
+const (
+	reminderMaxDelay    = 30 * 24 * time.Hour
+	reminderMaxPerUser  = 10
+	reminderMaxText     = 200
+	reminderMaxAttempts = 5
+	reminderRetryDelay  = time.Minute
+)
+
+// Reminder is a pending message the bot will post when it's due.
+type Reminder struct {
+	ID       int       `json:"id"`
+	Platform string    `json:"platform"`
+	Channel  string    `json:"channel"`
+	UserID   string    `json:"user_id"`
+	UserName string    `json:"user_name"`
+	Text     string    `json:"text"`
+	Due      time.Time `json:"due"`
+	Created  time.Time `json:"created"`
+
+	// ForChannel is true for "remind #channel", which addresses the
+	// channel rather than reminding the author.
+	ForChannel bool `json:"for_channel,omitempty"`
+
+	// Attempts counts failed deliveries.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// Reminders schedules reminders, persists them in the store so they
+// survive restarts, and posts them through the originating platform.
+type Reminders struct {
+	mu        sync.Mutex
+	store     *store.Store
+	pending   map[int]*Reminder
+	platforms map[string]ChatPlatform
+	wake      chan struct{}
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	nowFunc   func() time.Time
+}
+
+// NewReminders loads pending reminders from s. Call SetPlatform for each
+// running platform, then Start.
+func NewReminders(s *store.Store) (*Reminders, error) {
+	r := &Reminders{
+		store:     s,
+		pending:   map[int]*Reminder{},
+		platforms: map[string]ChatPlatform{},
+		wake:      make(chan struct{}, 1),
+		nowFunc:   time.Now,
+	}
+	err := s.View(func(tx *store.Tx) error {
+		for _, key := range tx.Keys(remindersBucket) {
+			var rem Reminder
+			if err := tx.Get(remindersBucket, key, &rem); err != nil {
+				return err
+			}
+			r.pending[rem.ID] = &rem
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading reminders: %w", err)
+	}
+	return r, nil
+}
+
+// SetPlatform registers the platform reminders created on name are
+// delivered through.
+func (r *Reminders) SetPlatform(name string, platform ChatPlatform) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.platforms[name] = platform
+}
+
+// Start begins delivering reminders. Reminders that fell due while the
+// bot was down are delivered straight away.
+func (r *Reminders) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.mu.Lock()
+	r.cancel = cancel
+	n := len(r.pending)
+	r.mu.Unlock()
+
+	r.wg.Add(1)
+	go r.run(ctx)
+	if n > 0 {
+		log.Printf("Reminders: %d pending", n)
+	}
+}
+
+// Stop halts delivery and waits for the scheduler to exit. Pending
+// reminders stay in the store.
+func (r *Reminders) Stop() {
+	r.mu.Lock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+func (r *Reminders) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (r *Reminders) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	for {
+		var wait <-chan time.Time
+		var timer *time.Timer
+		if next, ok := r.nextDue(); ok {
+			timer = time.NewTimer(next.Sub(r.nowFunc()))
+			wait = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-r.wake:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-wait:
+			r.deliverDue(ctx)
+		}
+	}
+}
+
+func (r *Reminders) nextDue() (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var next time.Time
+	for _, rem := range r.pending {
+		if next.IsZero() || rem.Due.Before(next) {
+			next = rem.Due
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// deliverDue posts every reminder that is due. Failed deliveries are
+// retried after reminderRetryDelay, up to reminderMaxAttempts.
+func (r *Reminders) deliverDue(ctx context.Context) {
+	r.mu.Lock()
+	now := r.nowFunc()
+	var due []Reminder
+	for _, rem := range r.pending {
+		if !rem.Due.After(now) {
+			due = append(due, *rem)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].Due.Equal(due[j].Due) {
+			return due[i].Due.Before(due[j].Due)
+		}
+		return due[i].ID < due[j].ID
+	})
+
+	for _, rem := range due {
+		err := r.deliver(ctx, rem)
+		if err == nil {
+			r.remove(rem.ID)
+			continue
+		}
+		rem.Attempts++
+		if rem.Attempts >= reminderMaxAttempts {
+			log.Printf("Reminders: giving up on #%d for %s after %d attempts: %v", rem.ID, rem.UserName, rem.Attempts, err)
+			r.remove(rem.ID)
+			continue
+		}
+		log.Printf("Reminders: delivering #%d failed, retrying in %v: %v", rem.ID, reminderRetryDelay, err)
+		rem.Due = r.nowFunc().Add(reminderRetryDelay)
+		r.save(rem)
+	}
+}
+
+func (r *Reminders) deliver(ctx context.Context, rem Reminder) error {
+	r.mu.Lock()
+	platform := r.platforms[rem.Platform]
+	r.mu.Unlock()
+	if platform == nil {
+		return fmt.Errorf("platform %s is not running", rem.Platform)
+	}
+
+	key := "remind.due"
+	if rem.ForChannel {
+		key = "remind.due_channel"
+	}
+	text := rem.Text
+	if rem.Platform == "discord" {
+		text = sanitizeDiscordMentions(text)
+	}
+	msg := Say(rem.Channel, key, Vars{
+		"Mention": mentionUser(rem.Platform, rem.UserID, rem.UserName),
+		"Text":    text,
+	})
+	sendCtx, cancel := sendContext(ctx)
+	defer cancel()
+	return platform.SendMessage(sendCtx, rem.Channel, msg)
+}
+
+// mentionUser returns a chat mention of the user on platform.
+func mentionUser(platform, userID, userName string) string {
+	if platform == "discord" {
+		return "<@" + userID + ">"
+	}
+	return "@" + userName
+}
+
+// Add schedules rem, assigning its ID.
+func (r *Reminders) Add(rem Reminder) (Reminder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	count := 0
+	for _, p := range r.pending {
+		if p.Platform == rem.Platform && p.UserID == rem.UserID {
+			count++
+		}
+	}
+	if count >= reminderMaxPerUser {
+		return Reminder{}, errTooManyReminders
+	}
+
+	rem.Created = r.nowFunc()
+	err := r.store.Update(func(tx *store.Tx) error {
+		id, err := tx.NextSequence(remindersBucket)
+		if err != nil {
+			return err
+		}
+		rem.ID = id
+		return tx.Put(remindersBucket, store.IntKey(id), rem)
+	})
+	if err != nil {
+		return Reminder{}, err
+	}
+	r.pending[rem.ID] = &rem
+	r.notify()
+	return rem, nil
+}
+
+var errTooManyReminders = errors.New("too many pending reminders")
+
+// Cancel removes a pending reminder. It returns store.ErrNotFound if
+// there is no such reminder.
+func (r *Reminders) Cancel(id int) error {
+	r.mu.Lock()
+	_, ok := r.pending[id]
+	r.mu.Unlock()
+	if !ok {
+		return store.ErrNotFound
+	}
+	r.remove(id)
+	r.notify()
+	return nil
+}
+
+// Get returns a pending reminder.
+func (r *Reminders) Get(id int) (Reminder, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rem, ok := r.pending[id]
+	if !ok {
+		return Reminder{}, false
+	}
+	return *rem, true
+}
+
+// List returns the pending reminders a user created on platform,
+// soonest first.
+func (r *Reminders) List(platform, userID string) []Reminder {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Reminder
+	for _, rem := range r.pending {
+		if rem.Platform == platform && rem.UserID == userID {
+			out = append(out, *rem)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	return out
+}
+
+func (r *Reminders) remove(id int) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+	if err := r.store.Delete(remindersBucket, store.IntKey(id)); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Printf("Reminders: deleting #%d: %v", id, err)
+	}
+}
+
+func (r *Reminders) save(rem Reminder) {
+	r.mu.Lock()
+	if _, ok := r.pending[rem.ID]; !ok {
+		// Cancelled while being delivered.
+		r.mu.Unlock()
+		return
+	}
+	r.pending[rem.ID] = &rem
+	r.mu.Unlock()
+	if err := r.store.Put(remindersBucket, store.IntKey(rem.ID), rem); err != nil {
+		log.Printf("Reminders: saving #%d: %v", rem.ID, err)
+	}
+}
+
+// HandleCommand implements:
+//
+//	remind me in <duration> [to] <text>
+//	remind me at <HH:MM> [to] <text>
+//	remind #channel in|at ... <text>
+//	remind list
+//	remind cancel <id>
+func (r *Reminders) HandleCommand(req CommandRequest) error {
+	reply := func(msg string) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, msg)
+	}
+	if len(req.Arguments) == 0 {
+		return reply(Say(req.Channel, "remind.usage", nil))
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "list":
+		pending := r.List(req.PlatformName, req.User)
+		if len(pending) == 0 {
+			return reply(Say(req.Channel, "remind.none", nil))
+		}
+		now := r.nowFunc()
+		items := make([]string, len(pending))
+		for i, rem := range pending {
+			items[i] = fmt.Sprintf("#%d in %s: %s", rem.ID, formatUptime(rem.Due.Sub(now)), truncateUTF8(rem.Text, 40))
+		}
+		return reply(Say(req.Channel, "remind.list", Vars{"Reminders": items}))
+	case "cancel", "del", "delete":
+		if len(req.Arguments) < 2 {
+			return reply(Say(req.Channel, "remind.usage", nil))
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(req.Arguments[1], "#"))
+		rem, ok := r.Get(id)
+		if err != nil || !ok || rem.Platform != req.PlatformName {
+			return reply(Say(req.Channel, "remind.not_found", Vars{"ID": req.Arguments[1]}))
+		}
+		if rem.UserID != req.User && !req.Admin {
+			return reply(Say(req.Channel, "remind.cancel_denied", nil))
+		}
+		if err := r.Cancel(id); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("cancelling reminder: %w", err)
+		}
+		return reply(Say(req.Channel, "remind.cancelled", Vars{"ID": id}))
+	}
+
+	rem, err := r.parse(req)
+	if err != nil {
+		return reply(Say(req.Channel, "remind.invalid", Vars{"Error": err}))
+	}
+	rem, err = r.Add(rem)
+	if errors.Is(err, errTooManyReminders) {
+		return reply(Say(req.Channel, "remind.too_many", Vars{"Max": reminderMaxPerUser}))
+	}
+	if err != nil {
+		return fmt.Errorf("saving reminder: %w", err)
+	}
+	return reply(Say(req.Channel, "remind.saved", Vars{
+		"ID":   rem.ID,
+		"In":   formatUptime(rem.Due.Sub(rem.Created)),
+		"Self": !rem.ForChannel,
+	}))
+}
+
+// parse turns "me|#channel in|at <when> [to] <text>" into a Reminder.
+func (r *Reminders) parse(req CommandRequest) (Reminder, error) {
+	args := req.Arguments
+	if len(args) < 4 {
+		return Reminder{}, errors.New("try: remind me in 45m to stretch")
+	}
+	rem := Reminder{
+		Platform: req.PlatformName,
+		Channel:  req.Channel,
+		UserID:   req.User,
+		UserName: req.UserName,
+	}
+
+	target := args[0]
+	if !strings.EqualFold(target, "me") {
+		ch, err := reminderChannel(req.Platform, req.PlatformName, target)
+		if err != nil {
+			return Reminder{}, err
+		}
+		if ch != normalizeTimerChannel(req.PlatformName, req.Channel) && !req.Admin {
+			return Reminder{}, errors.New("only admins can set reminders for other channels")
+		}
+		rem.Channel, rem.ForChannel = ch, true
+	}
+
+	now := r.nowFunc()
+	switch strings.ToLower(args[1]) {
+	case "in":
+		d, err := time.ParseDuration(args[2])
+		if err != nil || d <= 0 {
+			return Reminder{}, fmt.Errorf("%q is not a duration like 45m or 1h30m", args[2])
+		}
+		rem.Due = now.Add(d)
+	case "at":
+		clock, err := time.ParseInLocation("15:04", args[2], now.Location())
+		if err != nil {
+			return Reminder{}, fmt.Errorf("%q is not a time like 20:00", args[2])
+		}
+		due := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+		if !due.After(now) {
+			due = due.AddDate(0, 0, 1)
+		}
+		rem.Due = due
+	default:
+		return Reminder{}, errors.New(`say "in" or "at", e.g. remind me in 45m to stretch`)
+	}
+	if rem.Due.Sub(now) > reminderMaxDelay {
+		return Reminder{}, fmt.Errorf("that's too far off (max %s)", formatUptime(reminderMaxDelay))
+	}
+
+	words := args[3:]
+	if strings.EqualFold(words[0], "to") && len(words) > 1 {
+		words = words[1:]
+	}
+	rem.Text = strings.Join(words, " ")
+	if len(rem.Text) > reminderMaxText {
+		return Reminder{}, fmt.Errorf("keep it under %d characters", reminderMaxText)
+	}
+	return rem, nil
+}
+
+// reminderChannel resolves "#name" (Twitch) or "<#id>" (Discord) to one
+// of the platform's channels.
+func reminderChannel(platform ChatPlatform, platformName, target string) (string, error) {
+	ch := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(target, "<"), "#"), ">")
+	ch = normalizeTimerChannel(platformName, ch)
+	for _, c := range platform.BotChannels() {
+		if normalizeTimerChannel(platformName, c) == ch {
+			return ch, nil
+		}
+	}
+	return "", fmt.Errorf("I'm not in %s", target)
+}
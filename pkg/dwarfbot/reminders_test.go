@@ -0,0 +1,275 @@
+package dwarfbot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingPlatform rejects every message.
+type failingPlatform struct {
+	*mockPlatform
+}
+
+func (f *failingPlatform) SendMessage(context.Context, string, string) error {
+	return errors.New("not connected")
+}
+
+func newTestReminders(t *testing.T, path string) (*Reminders, *time.Time) {
+	t.Helper()
+	r, err := NewReminders(openTestStore(t, path))
+	if err != nil {
+		t.Fatalf("NewReminders: %v", err)
+	}
+	return r, pinClock(&r.nowFunc, time.Date(2026, 1, 1, 18, 30, 0, 0, time.UTC))
+}
+
+func remind(t *testing.T, r *Reminders, mock ChatPlatform, user string, admin bool, args string) {
+	t.Helper()
+	if err := r.HandleCommand(commandRequest(mock, "twitch", user, admin, "remind", args)); err != nil {
+		t.Fatalf("remind %s: %v", args, err)
+	}
+}
+
+func TestReminders_InAndDeliver(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+	r.SetPlatform("twitch", mock)
+
+	remind(t, r, mock, "viewer", false, "me in 45m to stretch your legs")
+	if got := lastMessage(mock); !strings.Contains(got, "45m0s") || !strings.Contains(got, "#1") {
+		t.Fatalf("unexpected confirmation %q", got)
+	}
+	if rem, ok := r.Get(1); !ok || rem.Text != "stretch your legs" || !rem.Due.Equal(now.Add(45*time.Minute)) {
+		t.Fatalf("unexpected reminder %+v", rem)
+	}
+
+	mock.messages = nil
+	*now = now.Add(44 * time.Minute)
+	r.deliverDue(context.Background())
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected nothing before it's due, got %v", mock.messages)
+	}
+	*now = now.Add(time.Minute)
+	r.deliverDue(context.Background())
+	if len(mock.messages) != 1 || mock.messages[0].msg != "@viewer, ye asked me tae remind ye: stretch your legs" {
+		t.Fatalf("unexpected delivery %v", mock.messages)
+	}
+	if _, ok := r.Get(1); ok {
+		t.Error("expected delivered reminder to be removed")
+	}
+}
+
+func TestReminders_SameInstantInCreationOrder(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+	r.SetPlatform("twitch", mock)
+
+	// Twelve, so reminder #10 would sort before #9 as text and a random
+	// map order is unlikely to pass by chance.
+	const count = 12
+	for i := 1; i <= count; i++ {
+		remind(t, r, mock, fmt.Sprintf("viewer%d", i), false, fmt.Sprintf("me in 5m to do task %d", i))
+	}
+	mock.messages = nil
+	*now = now.Add(5 * time.Minute)
+	r.deliverDue(context.Background())
+	if len(mock.messages) != count {
+		t.Fatalf("expected %d deliveries, got %v", count, mock.messages)
+	}
+	for i, m := range mock.messages {
+		if want := fmt.Sprintf("task %d", i+1); !strings.HasSuffix(m.msg, want) {
+			t.Errorf("delivery %d: expected %q, got %q", i+1, want, m.msg)
+		}
+	}
+}
+
+func TestReminders_At(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	remind(t, r, mock, "viewer", false, "me at 20:00 raid time")
+	rem, _ := r.Get(1)
+	if want := time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC); !rem.Due.Equal(want) {
+		t.Errorf("expected %v, got %v", want, rem.Due)
+	}
+	remind(t, r, mock, "viewer", false, "me at 18:00 breakfast")
+	rem, _ = r.Get(2)
+	if want := time.Date(2026, 1, 2, 18, 0, 0, 0, time.UTC); !rem.Due.Equal(want) {
+		t.Errorf("expected a past time to mean tomorrow, got %v (now %v)", rem.Due, *now)
+	}
+}
+
+func TestReminders_Channel(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan", "other"})
+	r.SetPlatform("twitch", mock)
+
+	remind(t, r, mock, "viewer", false, "#other in 1m raid")
+	if !strings.Contains(lastMessage(mock), "admins") {
+		t.Errorf("expected non-admins to be refused other channels, got %q", lastMessage(mock))
+	}
+	remind(t, r, mock, "boss", true, "#nowhere in 1m raid")
+	if !strings.Contains(lastMessage(mock), "not in #nowhere") {
+		t.Errorf("expected unknown channel to be refused, got %q", lastMessage(mock))
+	}
+	remind(t, r, mock, "boss", true, "#Other in 1m raid time")
+	remind(t, r, mock, "viewer", false, "#chan in 1m water")
+
+	mock.messages = nil
+	*now = now.Add(time.Minute)
+	r.deliverDue(context.Background())
+	if len(mock.messages) != 2 {
+		t.Fatalf("expected two deliveries, got %v", mock.messages)
+	}
+	if m := mock.messages[0]; m.channel != "other" || m.msg != "Oi! @boss wanted ye all tae know: raid time" {
+		t.Errorf("unexpected channel reminder %+v", m)
+	}
+}
+
+func TestReminders_ListAndCancel(t *testing.T) {
+	r, _ := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	remind(t, r, mock, "viewer", false, "list")
+	if !strings.Contains(lastMessage(mock), "nae reminders") {
+		t.Errorf("expected empty list, got %q", lastMessage(mock))
+	}
+	remind(t, r, mock, "viewer", false, "me in 2h to hydrate")
+	remind(t, r, mock, "viewer", false, "me in 1h to stretch")
+	remind(t, r, mock, "viewer", false, "list")
+	if got := lastMessage(mock); got != "Yer reminders: #2 in 1h0m: stretch; #1 in 2h0m: hydrate" {
+		t.Errorf("unexpected list %q", got)
+	}
+
+	remind(t, r, mock, "other", false, "cancel 1")
+	if !strings.Contains(lastMessage(mock), "no' yer reminder") {
+		t.Errorf("expected other users to be refused, got %q", lastMessage(mock))
+	}
+	remind(t, r, mock, "viewer", false, "cancel #1")
+	if _, ok := r.Get(1); ok {
+		t.Error("expected reminder 1 to be cancelled")
+	}
+	remind(t, r, mock, "boss", true, "cancel 2")
+	if _, ok := r.Get(2); ok {
+		t.Error("expected admins to cancel anyone's reminder")
+	}
+	remind(t, r, mock, "viewer", false, "cancel 2")
+	if !strings.Contains(lastMessage(mock), "nae reminder 2") {
+		t.Errorf("expected not found, got %q", lastMessage(mock))
+	}
+}
+
+func TestReminders_Invalid(t *testing.T) {
+	r, _ := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"chan"})
+
+	for _, args := range []string{
+		"me in soon to stretch",
+		"me in -5m to stretch",
+		"me at 25:00 to stretch",
+		"me on friday to stretch",
+		"me in 1000h to stretch",
+		"me in 5m",
+		"me in 5m to " + strings.Repeat("x", reminderMaxText+1),
+	} {
+		remind(t, r, mock, "viewer", false, args)
+		if !strings.Contains(lastMessage(mock), "cannae make sense") {
+			t.Errorf("%q: expected an error reply, got %q", args, lastMessage(mock))
+		}
+	}
+	for i := 0; i < reminderMaxPerUser; i++ {
+		remind(t, r, mock, "viewer", false, "me in 5m to stretch")
+	}
+	remind(t, r, mock, "viewer", false, "me in 5m to stretch")
+	if !strings.Contains(lastMessage(mock), "already got") {
+		t.Errorf("expected per-user limit, got %q", lastMessage(mock))
+	}
+}
+
+func TestReminders_PersistAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	r, _ := newTestReminders(t, path)
+	mock := newMockPlatform("testbot", []string{"chan"})
+	remind(t, r, mock, "viewer", false, "me in 10m to stretch")
+
+	reloaded, now := newTestReminders(t, path)
+	reloaded.SetPlatform("twitch", mock)
+	mock.messages = nil
+	*now = now.Add(time.Hour)
+	reloaded.deliverDue(context.Background())
+	if len(mock.messages) != 1 || !strings.Contains(mock.messages[0].msg, "stretch") {
+		t.Fatalf("expected reloaded reminder to be delivered late, got %v", mock.messages)
+	}
+
+	again, _ := newTestReminders(t, path)
+	if len(again.pending) != 0 {
+		t.Errorf("expected delivered reminder to be removed from the store, got %v", again.pending)
+	}
+}
+
+func TestReminders_RetryThenGiveUp(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	r.SetPlatform("twitch", &failingPlatform{newMockPlatform("testbot", []string{"chan"})})
+	rem, err := r.Add(Reminder{Platform: "twitch", Channel: "chan", UserID: "viewer", UserName: "viewer", Text: "hi", Due: *now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < reminderMaxAttempts; i++ {
+		r.deliverDue(context.Background())
+		got, ok := r.Get(rem.ID)
+		if !ok || got.Attempts != i || !got.Due.Equal(now.Add(reminderRetryDelay)) {
+			t.Fatalf("attempt %d: unexpected state %+v (ok=%v)", i, got, ok)
+		}
+		*now = now.Add(reminderRetryDelay)
+	}
+	r.deliverDue(context.Background())
+	if _, ok := r.Get(rem.ID); ok {
+		t.Error("expected reminder to be dropped after the last attempt")
+	}
+}
+
+func TestReminders_DiscordMentions(t *testing.T) {
+	r, now := newTestReminders(t, "")
+	mock := newMockPlatform("testbot", []string{"123"})
+	r.SetPlatform("discord", mock)
+	_, err := r.Add(Reminder{Platform: "discord", Channel: "123", UserID: "42", UserName: "gimli", Text: "ping @everyone", Due: *now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.deliverDue(context.Background())
+	want := "<@42>, ye asked me tae remind ye: ping " + sanitizeDiscordMentions("@everyone")
+	if len(mock.messages) != 1 || mock.messages[0].msg != want {
+		t.Errorf("expected %q, got %v", want, mock.messages)
+	}
+}
+
+func TestReminders_StartDelivers(t *testing.T) {
+	r, _ := newTestReminders(t, "")
+	r.nowFunc = time.Now
+	mock := &syncPlatform{mockPlatform: newMockPlatform("testbot", []string{"chan"})}
+	r.SetPlatform("twitch", mock)
+	r.Start()
+	defer r.Stop()
+
+	if _, err := r.Add(Reminder{Platform: "twitch", Channel: "chan", UserID: "viewer", UserName: "viewer", Text: "now", Due: time.Now().Add(20 * time.Millisecond)}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mock.mu.Lock()
+		n := len(mock.messages)
+		mock.mu.Unlock()
+		if n == 1 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the scheduler to deliver the reminder")
+}
@@ -0,0 +1,397 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Script limits. Steps count Starlark bytecode instructions; the timeout
+// also covers time spent in the bot API.
+const (
+	scriptMaxSteps    = 1_000_000
+	scriptTimeout     = 2 * time.Second
+	scriptMaxSends    = 5
+	scriptMaxKeyLen   = 128
+	scriptMaxValueLen = 4096
+	scriptsBucket     = "scripts"
+)
+
+// scriptFileOptions allows while loops and recursion; the step limit
+// keeps them from running away.
+var scriptFileOptions = &syntax.FileOptions{While: true, Recursion: true, Set: true}
+
+// ScriptCounters lets scripts read named counters.
+type ScriptCounters interface {
+	Get(name string) (Counter, error)
+}
+
+// ScriptPublisher lets scripts publish to MQTT.
+type ScriptPublisher interface {
+	Publish(topic, payload string) error
+}
+
+// scriptValue is how script key/value entries are stored. Exactly one
+// field is set.
+type scriptValue struct {
+	String *string `json:"s,omitempty"`
+	Int    *int64  `json:"i,omitempty"`
+	Bool   *bool   `json:"b,omitempty"`
+}
+
+type script struct {
+	name      string
+	main      *starlark.Function
+	adminOnly bool
+	slot      chan struct{}
+}
+
+// Scripts runs Starlark scripts as chat commands. Each "<name>.star" file
+// in the scripts directory becomes the command <name>; it must define
+// main(ctx) and may set admin_only = True.
+type Scripts struct {
+	store    *store.Store
+	counters ScriptCounters
+	scripts  []*script
+	wg       sync.WaitGroup
+
+	mu        sync.Mutex
+	publisher ScriptPublisher
+
+	maxSteps uint64
+	timeout  time.Duration
+}
+
+// LoadScripts compiles every script in dir. An empty dir loads nothing.
+// Counters may be nil.
+func LoadScripts(dir string, s *store.Store, counters ScriptCounters) (*Scripts, error) {
+	sc := &Scripts{
+		store:    s,
+		counters: counters,
+		maxSteps: scriptMaxSteps,
+		timeout:  scriptTimeout,
+	}
+	if dir == "" {
+		return sc, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.star"))
+	if err != nil {
+		return nil, fmt.Errorf("listing scripts: %w", err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading script: %w", err)
+		}
+		scr, err := sc.compile(strings.TrimSuffix(filepath.Base(path), ".star"), path, src)
+		if err != nil {
+			return nil, err
+		}
+		sc.scripts = append(sc.scripts, scr)
+	}
+	return sc, nil
+}
+
+// compile runs the script's top level under the step limit and picks out
+// main and admin_only. Globals are frozen afterwards, so invocations
+// can't leak state to each other except through the key/value store.
+func (sc *Scripts) compile(name, filename string, src []byte) (*script, error) {
+	name = strings.ToLower(name)
+	if !commandNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("script %s: file name must use a-z, 0-9 and _ (max 32)", filename)
+	}
+	thread := &starlark.Thread{Name: name, Print: scriptPrint}
+	thread.SetMaxExecutionSteps(sc.maxSteps)
+	globals, err := starlark.ExecFileOptions(scriptFileOptions, thread, filename, src, nil)
+	if err != nil {
+		return nil, fmt.Errorf("script %s: %w", name, err)
+	}
+	main, ok := globals["main"].(*starlark.Function)
+	if !ok || main.NumParams() != 1 {
+		return nil, fmt.Errorf("script %s: must define main(ctx)", name)
+	}
+	scr := &script{name: name, main: main, slot: make(chan struct{}, 1)}
+	if v, ok := globals["admin_only"]; ok {
+		b, ok := v.(starlark.Bool)
+		if !ok {
+			return nil, fmt.Errorf("script %s: admin_only must be True or False", name)
+		}
+		scr.adminOnly = bool(b)
+	}
+	return scr, nil
+}
+
+func scriptPrint(thread *starlark.Thread, msg string) {
+	log.Printf("Script %s: %s", thread.Name, msg)
+}
+
+// SetPublisher enables publish() for scripts. Without one, publish fails.
+func (sc *Scripts) SetPublisher(p ScriptPublisher) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.publisher = p
+}
+
+// RegisterCommands registers a command per script. Call it before
+// Counters.RegisterCommands so stored counters can't shadow a script.
+func (sc *Scripts) RegisterCommands() error {
+	for _, scr := range sc.scripts {
+		if isKnownCommand(scr.name) {
+			return fmt.Errorf("script %q clashes with an existing command", scr.name)
+		}
+	}
+	for _, scr := range sc.scripts {
+		if scr.adminOnly {
+			RegisterAdminCommand(scr.name, sc.handler(scr))
+		} else {
+			RegisterCommand(scr.name, sc.handler(scr))
+		}
+	}
+	if len(sc.scripts) > 0 {
+		log.Printf("Scripts: registered %d command(s)", len(sc.scripts))
+	}
+	return nil
+}
+
+// Wait blocks until running scripts finish. A run, its messages
+// included, ends within the time limit plus one send timeout.
+func (sc *Scripts) Wait() {
+	sc.wg.Wait()
+}
+
+func (sc *Scripts) handler(scr *script) CommandHandlerFunc {
+	return func(req CommandRequest) error {
+		select {
+		case scr.slot <- struct{}{}:
+		default:
+			return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "script.busy", nil))
+		}
+		sc.wg.Add(1)
+		go func() {
+			defer sc.wg.Done()
+			defer func() { <-scr.slot }()
+			// Scripts run after the dispatcher returns, so the run gets
+			// its own deadline, with room left to report a failure.
+			ctx, cancel := context.WithTimeout(context.Background(), sc.timeout+sendTimeout)
+			defer cancel()
+			if err := sc.invoke(ctx, scr, req); err != nil {
+				log.Printf("Script %s: %v", scr.name, err)
+				_ = req.Platform.SendMessage(ctx, req.Channel, Say(req.Channel, "script.failed", nil))
+			}
+		}()
+		return nil
+	}
+}
+
+// invoke calls main(ctx) under the step and time limits and sends what it
+// returns: a string, a list of strings, or None.
+func (sc *Scripts) invoke(ctx context.Context, scr *script, req CommandRequest) error {
+	ctx, cancel := context.WithTimeout(ctx, sc.timeout)
+	defer cancel()
+	thread := &starlark.Thread{Name: scr.name, Print: scriptPrint}
+	thread.SetMaxExecutionSteps(sc.maxSteps)
+	stop := context.AfterFunc(ctx, func() { thread.Cancel("time limit exceeded") })
+	defer stop()
+
+	sends := 0
+	send := func(text string) error {
+		if sends == scriptMaxSends {
+			return fmt.Errorf("at most %d messages per run", scriptMaxSends)
+		}
+		sends++
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		// Sends count against the time limit.
+		return req.Platform.SendMessage(ctx, req.Channel, text)
+	}
+
+	result, err := starlark.Call(thread, scr.main, starlark.Tuple{sc.context(scr, req, send)}, nil)
+	if err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			return errors.New(evalErr.Backtrace())
+		}
+		return err
+	}
+
+	switch v := result.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.String:
+		return send(string(v))
+	case *starlark.List:
+		for i := 0; i < v.Len(); i++ {
+			s, ok := starlark.AsString(v.Index(i))
+			if !ok {
+				return fmt.Errorf("main returned a list containing %s, want strings", v.Index(i).Type())
+			}
+			if err := send(s); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("main returned %s, want string, list or None", result.Type())
+	}
+}
+
+// context builds the ctx argument: the invocation's details plus the safe
+// bot API.
+func (sc *Scripts) context(scr *script, req CommandRequest, send func(string) error) starlark.Value {
+	role := RoleUser
+	if req.Admin {
+		role = RoleAdmin
+	}
+	args := make([]starlark.Value, len(req.Arguments))
+	for i, a := range req.Arguments {
+		args[i] = starlark.String(a)
+	}
+
+	builtin := func(name string, fn func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error)) *starlark.Builtin {
+		return starlark.NewBuiltin(name, fn)
+	}
+	return starlarkstruct.FromStringDict(starlark.String("ctx"), starlark.StringDict{
+		"command":   starlark.String(req.Command),
+		"platform":  starlark.String(req.PlatformName),
+		"channel":   starlark.String(req.Channel),
+		"user":      starlark.String(req.User),
+		"user_name": starlark.String(req.UserName),
+		"identity":  starlark.String(req.Identity),
+		"role":      starlark.String(role),
+		"args":      starlark.Tuple(args),
+
+		"send": builtin("send", func(_ *starlark.Thread, b *starlark.Builtin, a starlark.Tuple, kw []starlark.Tuple) (starlark.Value, error) {
+			var text string
+			if err := starlark.UnpackPositionalArgs(b.Name(), a, kw, 1, &text); err != nil {
+				return nil, err
+			}
+			return starlark.None, send(text)
+		}),
+		"kv_get": builtin("kv_get", func(_ *starlark.Thread, b *starlark.Builtin, a starlark.Tuple, kw []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			var def starlark.Value = starlark.None
+			if err := starlark.UnpackArgs(b.Name(), a, kw, "key", &key, "default?", &def); err != nil {
+				return nil, err
+			}
+			return sc.kvGet(scr, key, def)
+		}),
+		"kv_set": builtin("kv_set", func(_ *starlark.Thread, b *starlark.Builtin, a starlark.Tuple, kw []starlark.Tuple) (starlark.Value, error) {
+			var key string
+			var value starlark.Value
+			if err := starlark.UnpackArgs(b.Name(), a, kw, "key", &key, "value", &value); err != nil {
+				return nil, err
+			}
+			return starlark.None, sc.kvSet(scr, key, value)
+		}),
+		"counter": builtin("counter", func(_ *starlark.Thread, b *starlark.Builtin, a starlark.Tuple, kw []starlark.Tuple) (starlark.Value, error) {
+			var name string
+			if err := starlark.UnpackPositionalArgs(b.Name(), a, kw, 1, &name); err != nil {
+				return nil, err
+			}
+			if sc.counters == nil {
+				return starlark.None, nil
+			}
+			ctr, err := sc.counters.Get(name)
+			if errors.Is(err, store.ErrNotFound) {
+				return starlark.None, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			return starlark.MakeInt(ctr.Value), nil
+		}),
+		"publish": builtin("publish", func(_ *starlark.Thread, b *starlark.Builtin, a starlark.Tuple, kw []starlark.Tuple) (starlark.Value, error) {
+			var topic, payload string
+			if err := starlark.UnpackPositionalArgs(b.Name(), a, kw, 2, &topic, &payload); err != nil {
+				return nil, err
+			}
+			sc.mu.Lock()
+			p := sc.publisher
+			sc.mu.Unlock()
+			if p == nil {
+				return nil, errors.New("MQTT is not configured")
+			}
+			return starlark.None, p.Publish(topic, payload)
+		}),
+	})
+}
+
+// scriptKey namespaces keys per script so scripts can't read each other's
+// data.
+func scriptKey(scr *script, key string) (string, error) {
+	if key == "" || len(key) > scriptMaxKeyLen {
+		return "", fmt.Errorf("keys must be 1-%d bytes", scriptMaxKeyLen)
+	}
+	return scr.name + "/" + key, nil
+}
+
+func (sc *Scripts) kvGet(scr *script, key string, def starlark.Value) (starlark.Value, error) {
+	k, err := scriptKey(scr, key)
+	if err != nil {
+		return nil, err
+	}
+	var v scriptValue
+	if err := sc.store.Get(scriptsBucket, k, &v); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return def, nil
+		}
+		return nil, err
+	}
+	switch {
+	case v.String != nil:
+		return starlark.String(*v.String), nil
+	case v.Int != nil:
+		return starlark.MakeInt64(*v.Int), nil
+	case v.Bool != nil:
+		return starlark.Bool(*v.Bool), nil
+	}
+	return def, nil
+}
+
+// kvSet stores a string, int or bool; None deletes the key.
+func (sc *Scripts) kvSet(scr *script, key string, value starlark.Value) error {
+	k, err := scriptKey(scr, key)
+	if err != nil {
+		return err
+	}
+	var v scriptValue
+	switch x := value.(type) {
+	case starlark.NoneType:
+		if err := sc.store.Delete(scriptsBucket, k); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+		return nil
+	case starlark.String:
+		if len(x) > scriptMaxValueLen {
+			return fmt.Errorf("values must be at most %d bytes", scriptMaxValueLen)
+		}
+		s := string(x)
+		v.String = &s
+	case starlark.Int:
+		i, ok := x.Int64()
+		if !ok {
+			return errors.New("int value out of range")
+		}
+		v.Int = &i
+	case starlark.Bool:
+		b := bool(x)
+		v.Bool = &b
+	default:
+		return fmt.Errorf("cannot store %s; use a string, int, bool or None", value.Type())
+	}
+	return sc.store.Put(scriptsBucket, k, v)
+}
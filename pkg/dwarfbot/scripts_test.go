@@ -0,0 +1,250 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/store"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeScriptCounters map[string]int
+
+func (f fakeScriptCounters) Get(name string) (Counter, error) {
+	v, ok := f[name]
+	if !ok {
+		return Counter{}, store.ErrNotFound
+	}
+	return Counter{Name: name, Value: v}, nil
+}
+
+type fakePublisher struct {
+	published []string
+	err       error
+}
+
+func (f *fakePublisher) Publish(topic, payload string) error {
+	f.published = append(f.published, topic+" "+payload)
+	return f.err
+}
+
+func loadTestScripts(t *testing.T, files map[string]string) (*Scripts, error) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o600); err != nil {
+			t.Fatalf("writing script: %v", err)
+		}
+	}
+	return LoadScripts(dir, openTestStore(t, ""), fakeScriptCounters{"deaths": 7})
+}
+
+func runScript(t *testing.T, sc *Scripts, name string, args ...string) *mockPlatform {
+	t.Helper()
+	mock := newMockPlatform("testbot", []string{"chan"})
+	c, ok := getCommand(name)
+	if !ok {
+		t.Fatalf("script command %q not registered", name)
+	}
+	if err := c.handler(CommandRequest{Platform: mock, PlatformName: "twitch", Channel: "chan", User: "viewer", UserName: "Viewer", Command: name, Arguments: args}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	sc.Wait()
+	return mock
+}
+
+func TestScripts_RunsWithContextAndStore(t *testing.T) {
+	defer ResetRegistrations()
+	sc, err := loadTestScripts(t, map[string]string{
+		"hug.star": `
+def main(ctx):
+    n = ctx.kv_get("hugs", 0) + 1
+    ctx.kv_set("hugs", n)
+    ctx.send("*hugs " + " ".join(ctx.args) + "*")
+    return ["%s has given %d hug(s)" % (ctx.user_name, n), "deaths: %d" % ctx.counter("deaths")]
+`,
+	})
+	if err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	if err := sc.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+
+	runScript(t, sc, "hug", "the", "dwarf")
+	mock := runScript(t, sc, "hug", "everyone")
+	want := []string{"*hugs everyone*", "Viewer has given 2 hug(s)", "deaths: 7"}
+	if len(mock.messages) != len(want) {
+		t.Fatalf("expected %v, got %v", want, mock.messages)
+	}
+	for i, w := range want {
+		if mock.messages[i].msg != w {
+			t.Errorf("message %d: expected %q, got %q", i, w, mock.messages[i].msg)
+		}
+	}
+}
+
+func TestScripts_Limits(t *testing.T) {
+	defer ResetRegistrations()
+	sc, err := loadTestScripts(t, map[string]string{
+		"spin.star": "def main(ctx):\n    while True:\n        pass\n",
+		"chatty.star": `
+def main(ctx):
+    for i in range(10):
+        ctx.send("spam")
+`,
+	})
+	if err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	sc.maxSteps = 10000
+	if err := sc.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+	failed := Say("chan", "script.failed", nil)
+
+	mock := runScript(t, sc, "spin")
+	if len(mock.messages) != 1 || mock.messages[0].msg != failed {
+		t.Errorf("expected step limit to fail the script, got %v", mock.messages)
+	}
+
+	sc.maxSteps = 0
+	sc.timeout = 50 * time.Millisecond
+	start := time.Now()
+	mock = runScript(t, sc, "spin")
+	if time.Since(start) > time.Second {
+		t.Errorf("expected time limit to stop the script promptly")
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != failed {
+		t.Errorf("expected time limit to fail the script, got %v", mock.messages)
+	}
+
+	mock = runScript(t, sc, "chatty")
+	if len(mock.messages) != scriptMaxSends+1 || mock.messages[scriptMaxSends].msg != failed {
+		t.Errorf("expected sends to be capped at %d, got %v", scriptMaxSends, mock.messages)
+	}
+}
+
+// stuckPlatform never delivers "slow" and gives up only when the context
+// does.
+type stuckPlatform struct {
+	*mockPlatform
+}
+
+func (p *stuckPlatform) SendMessage(ctx context.Context, channel, msg string) error {
+	if msg == "slow" {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return p.mockPlatform.SendMessage(ctx, channel, msg)
+}
+
+func TestScripts_SendsCountAgainstTimeLimit(t *testing.T) {
+	defer ResetRegistrations()
+	sc, err := loadTestScripts(t, map[string]string{
+		"slow.star": "def main(ctx):\n    ctx.send(\"slow\")\n",
+	})
+	if err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	sc.timeout = 50 * time.Millisecond
+	if err := sc.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+	mock := &stuckPlatform{mockPlatform: newMockPlatform("testbot", []string{"chan"})}
+	c, _ := getCommand("slow")
+
+	start := time.Now()
+	if err := c.handler(CommandRequest{Platform: mock, PlatformName: "twitch", Channel: "chan", User: "viewer", Command: "slow"}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	sc.Wait()
+	if time.Since(start) > time.Second {
+		t.Errorf("expected the stuck send to end with the time limit")
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "script.failed", nil) {
+		t.Errorf("expected the script to fail, got %v", mock.messages)
+	}
+}
+
+func TestScripts_Publish(t *testing.T) {
+	defer ResetRegistrations()
+	sc, err := loadTestScripts(t, map[string]string{
+		"lights.star": `
+def main(ctx):
+    ctx.publish("home/lights", ctx.args[0])
+    return "Lights " + ctx.args[0]
+`,
+	})
+	if err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	if err := sc.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+
+	mock := runScript(t, sc, "lights", "on")
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "script.failed", nil) {
+		t.Errorf("expected publish without MQTT to fail, got %v", mock.messages)
+	}
+
+	pub := &fakePublisher{}
+	sc.SetPublisher(pub)
+	mock = runScript(t, sc, "lights", "on")
+	if len(pub.published) != 1 || pub.published[0] != "home/lights on" {
+		t.Errorf("unexpected published messages %v", pub.published)
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != "Lights on" {
+		t.Errorf("unexpected reply %v", mock.messages)
+	}
+
+	pub.err = errors.New("bridge disabled")
+	mock = runScript(t, sc, "lights", "off")
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "script.failed", nil) {
+		t.Errorf("expected publish error to fail the script, got %v", mock.messages)
+	}
+}
+
+func TestScripts_AdminOnly(t *testing.T) {
+	defer ResetRegistrations()
+	sc, err := loadTestScripts(t, map[string]string{
+		"secret.star": "admin_only = True\n\ndef main(ctx):\n    return 'for the boss'\n",
+	})
+	if err != nil {
+		t.Fatalf("LoadScripts: %v", err)
+	}
+	if err := sc.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+	if c, ok := getCommand("secret"); !ok || !c.adminOnly {
+		t.Error("expected secret to be registered as an admin command")
+	}
+}
+
+func TestLoadScripts_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"no main", map[string]string{"a.star": "x = 1\n"}, "main(ctx)"},
+		{"syntax", map[string]string{"a.star": "def main(ctx)\n"}, "got newline"},
+		{"bad name", map[string]string{"my-cmd.star": "def main(ctx):\n    pass\n"}, "file name"},
+		{"admin_only type", map[string]string{"a.star": "admin_only = 'yes'\ndef main(ctx):\n    pass\n"}, "admin_only"},
+		{"load", map[string]string{"a.star": "load('other.star', 'x')\ndef main(ctx):\n    pass\n"}, "load"},
+	}
+	for _, tt := range tests {
+		_, err := loadTestScripts(t, tt.files)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	sc, err := LoadScripts("", nil, nil)
+	if err != nil || len(sc.scripts) != 0 {
+		t.Errorf("expected empty dir to load nothing, got %v, %v", sc, err)
+	}
+}
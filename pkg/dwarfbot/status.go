@@ -0,0 +1,69 @@
+package dwarfbot
+
+import (
+	"dwarfbot/pkg/status"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// StatusSource provides the snapshot the status command reports.
+type StatusSource interface {
+	Snapshot() status.Report
+}
+
+// NewStatusCommand returns the handler for "status", which reports
+// version, uptime and the state of every tracked component.
+func NewStatusCommand(source StatusSource) CommandHandlerFunc {
+	return func(req CommandRequest) error {
+		return req.Platform.SendMessage(req.Context, req.Channel, formatStatus(source.Snapshot()))
+	}
+}
+
+func formatStatus(r status.Report) string {
+	parts := []string{fmt.Sprintf("DwarfBot %s, up %s", r.Version, formatUptime(r.Uptime()))}
+	for _, c := range r.Components {
+		var state string
+		switch {
+		case c.Since.IsZero():
+			state = "not connected yet"
+		case c.Connected:
+			state = "connected for " + formatUptime(r.Now.Sub(c.Since))
+		default:
+			state = "down for " + formatUptime(r.Now.Sub(c.Since))
+			if c.Reason != "" {
+				state += " (" + c.Reason + ")"
+			}
+		}
+		if c.Disabled {
+			state = "disabled, " + state
+		}
+		parts = append(parts, c.Name+": "+state)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// formatUptime renders a duration compactly, e.g. "2d3h", "3h12m", "45s".
+func formatUptime(d time.Duration) string {
+	d = d.Round(time.Second)
+	if d < 0 {
+		d = 0
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	seconds := (d - minutes*time.Minute) / time.Second
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm%ds", minutes, seconds)
+	default:
+		return fmt.Sprintf("%ds", seconds)
+	}
+}
@@ -0,0 +1,66 @@
+package dwarfbot
+
+import (
+	"context"
+	"dwarfbot/pkg/status"
+	"testing"
+	"time"
+)
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{45 * time.Second, "45s"},
+		{3*time.Minute + 5*time.Second, "3m5s"},
+		{3*time.Hour + 12*time.Minute, "3h12m"},
+		{51 * time.Hour, "2d3h"},
+		{-time.Second, "0s"},
+	}
+	for _, tt := range tests {
+		if got := formatUptime(tt.d); got != tt.want {
+			t.Errorf("formatUptime(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestFormatStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := status.Report{
+		Version: "v1.2.3",
+		Started: now.Add(-3 * time.Hour),
+		Now:     now,
+		Components: []status.Component{
+			{Name: "twitch", Connected: true, Since: now.Add(-2 * time.Hour)},
+			{Name: "discord", Since: now.Add(-5 * time.Minute), Reason: "gateway"},
+			{Name: "mqtt", Connected: true, Disabled: true, Since: now.Add(-time.Minute)},
+			{Name: "other"},
+		},
+	}
+	want := "DwarfBot v1.2.3, up 3h0m | twitch: connected for 2h0m | discord: down for 5m0s (gateway) | mqtt: disabled, connected for 1m0s | other: not connected yet"
+	if got := formatStatus(r); got != want {
+		t.Errorf("formatStatus() =\n%q\nwant\n%q", got, want)
+	}
+}
+
+type fakeStatusSource struct {
+	report status.Report
+}
+
+func (f fakeStatusSource) Snapshot() status.Report {
+	return f.report
+}
+
+func TestStatusCommand_Dispatch(t *testing.T) {
+	RegisterCommand("status", NewStatusCommand(fakeStatusSource{status.Report{Version: "dev"}}))
+	defer RegisterCommand("status", nil)
+
+	mock := newMockPlatform("testbot", []string{"chan"})
+	_ = parseCommand(context.Background(), mock, "chan", "viewer", "status", nil)
+
+	if len(mock.messages) != 1 || mock.messages[0].msg != "DwarfBot dev, up 0s" {
+		t.Errorf("unexpected status reply: %v", mock.messages)
+	}
+}
@@ -0,0 +1,388 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"context"
+	"dwarfbot/pkg/cron"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// minTimerInterval keeps a misconfigured timer from flooding chat.
+const minTimerInterval = time.Minute
+
+// TimerConfig describes a recurring announcement. Exactly one of Interval
+// or Cron must be set.
+type TimerConfig struct {
+	// Name identifies the timer in admin commands.
+	Name string `mapstructure:"name"`
+
+	// Interval between announcements, e.g. "20m".
+	Interval time.Duration `mapstructure:"interval"`
+
+	// Cron is a five-field cron expression, e.g. "*/20 * * * *".
+	Cron string `mapstructure:"cron"`
+
+	// Platform to announce on ("twitch" or "discord").
+	Platform string `mapstructure:"platform"`
+
+	// Channels to announce in. Empty means every channel the platform
+	// is participating in.
+	Channels []string `mapstructure:"channels"`
+
+	// Message is a text/template with .Channel, .Platform, .BotName
+	// and .Timer available.
+	Message string `mapstructure:"message"`
+
+	// MinLines is the number of chat lines that must have been seen in a
+	// channel since the previous announcement. Twitch timers always
+	// require at least one so the bot doesn't talk to an empty room.
+	MinLines int `mapstructure:"min_lines"`
+}
+
+// ValidateTimerConfigs checks timer definitions before they are scheduled.
+func ValidateTimerConfigs(configs []TimerConfig) error {
+	seen := map[string]bool{}
+	for i, c := range configs {
+		if c.Name == "" {
+			return fmt.Errorf("timer %d: name must be set", i)
+		}
+		name := strings.ToLower(c.Name)
+		if seen[name] {
+			return fmt.Errorf("timer %q: duplicate name", c.Name)
+		}
+		seen[name] = true
+
+		switch {
+		case c.Interval != 0 && c.Cron != "":
+			return fmt.Errorf("timer %q: set either interval or cron, not both", c.Name)
+		case c.Interval == 0 && c.Cron == "":
+			return fmt.Errorf("timer %q: interval or cron must be set", c.Name)
+		case c.Cron != "":
+			if _, err := cron.Parse(c.Cron); err != nil {
+				return fmt.Errorf("timer %q: %w", c.Name, err)
+			}
+		case c.Interval < minTimerInterval:
+			return fmt.Errorf("timer %q: interval must be at least %v, got %v", c.Name, minTimerInterval, c.Interval)
+		}
+
+		switch c.Platform {
+		case "twitch", "discord":
+		default:
+			return fmt.Errorf("timer %q: platform must be twitch or discord, got %q", c.Name, c.Platform)
+		}
+
+		if strings.TrimSpace(c.Message) == "" {
+			return fmt.Errorf("timer %q: message must be set", c.Name)
+		}
+		if _, err := template.New(c.Name).Parse(c.Message); err != nil {
+			return fmt.Errorf("timer %q: invalid message template: %w", c.Name, err)
+		}
+		if c.MinLines < 0 {
+			return fmt.Errorf("timer %q: min_lines must be >= 0, got %d", c.Name, c.MinLines)
+		}
+	}
+	return nil
+}
+
+// TimerStatus is a snapshot of a timer for the admin command.
+type TimerStatus struct {
+	Name     string
+	Platform string
+	Paused   bool
+	Next     time.Time
+}
+
+// timerData is the template context for timer messages.
+type timerData struct {
+	Channel  string
+	Platform string
+	BotName  string
+	Timer    string
+}
+
+type timer struct {
+	config   TimerConfig
+	schedule *cron.Schedule
+	tmpl     *template.Template
+	paused   bool
+	next     time.Time
+
+	// lines counts chat lines per channel since the last announcement.
+	lines map[string]int
+}
+
+func (t *timer) minLines() int {
+	if t.config.Platform == "twitch" && t.config.MinLines < 1 {
+		return 1
+	}
+	return t.config.MinLines
+}
+
+func (t *timer) nextAfter(now time.Time) time.Time {
+	if t.schedule != nil {
+		return t.schedule.Next(now)
+	}
+	return now.Add(t.config.Interval)
+}
+
+// TimerManager posts recurring announcements through ChatPlatform.SendMessage.
+type TimerManager struct {
+	mu        sync.Mutex
+	timers    []*timer
+	platforms map[string]ChatPlatform
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	nowFunc   func() time.Time
+}
+
+// NewTimerManager validates the configs and prepares the timers.
+// Call SetPlatform for each running platform, then Start.
+func NewTimerManager(configs []TimerConfig) (*TimerManager, error) {
+	if err := ValidateTimerConfigs(configs); err != nil {
+		return nil, err
+	}
+
+	tm := &TimerManager{
+		platforms: map[string]ChatPlatform{},
+		nowFunc:   time.Now,
+	}
+	for _, c := range configs {
+		t := &timer{
+			config: c,
+			tmpl:   template.Must(template.New(c.Name).Parse(c.Message)),
+			lines:  map[string]int{},
+		}
+		if c.Cron != "" {
+			t.schedule, _ = cron.Parse(c.Cron)
+		}
+		tm.timers = append(tm.timers, t)
+	}
+	return tm, nil
+}
+
+// SetPlatform registers the platform that timers for name post through.
+func (tm *TimerManager) SetPlatform(name string, platform ChatPlatform) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.platforms[name] = platform
+}
+
+// Start begins scheduling every timer.
+func (tm *TimerManager) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	tm.mu.Lock()
+	tm.cancel = cancel
+	timers := tm.timers
+	tm.mu.Unlock()
+
+	for _, t := range timers {
+		tm.wg.Add(1)
+		go tm.run(ctx, t)
+	}
+	if len(timers) > 0 {
+		log.Printf("Timers: scheduled %d timer(s)", len(timers))
+	}
+}
+
+// Stop halts all timers, cancelling announcements in flight, and waits
+// for them to exit.
+func (tm *TimerManager) Stop() {
+	tm.mu.Lock()
+	if tm.cancel != nil {
+		tm.cancel()
+		tm.cancel = nil
+	}
+	tm.mu.Unlock()
+	tm.wg.Wait()
+}
+
+func (tm *TimerManager) run(ctx context.Context, t *timer) {
+	defer tm.wg.Done()
+
+	for {
+		tm.mu.Lock()
+		now := tm.nowFunc()
+		t.next = t.nextAfter(now)
+		next := t.next
+		tm.mu.Unlock()
+
+		if next.IsZero() {
+			log.Printf("Timers: %q will never fire; stopping it", t.config.Name)
+			return
+		}
+
+		wait := time.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return
+		case <-wait.C:
+			tm.fire(ctx, t)
+		}
+	}
+}
+
+// fire posts the timer's message to each target channel that has seen
+// enough chat activity, unless the timer is paused.
+func (tm *TimerManager) fire(ctx context.Context, t *timer) {
+	tm.mu.Lock()
+	if t.paused {
+		tm.mu.Unlock()
+		return
+	}
+	platform := tm.platforms[t.config.Platform]
+	if platform == nil {
+		tm.mu.Unlock()
+		return
+	}
+
+	targets := t.config.Channels
+	if len(targets) == 0 {
+		targets = platform.BotChannels()
+	}
+
+	// Render before resetting a channel's count, so a template error
+	// leaves it due for the next tick instead of silently skipping it.
+	type post struct{ channel, text string }
+	var due []post
+	for _, ch := range targets {
+		ch = normalizeTimerChannel(t.config.Platform, ch)
+		if t.lines[ch] < t.minLines() {
+			continue
+		}
+		var buf bytes.Buffer
+		data := timerData{Channel: ch, Platform: t.config.Platform, BotName: platform.BotName(), Timer: t.config.Name}
+		if err := t.tmpl.Execute(&buf, data); err != nil {
+			log.Printf("Timers: failed to render %q for %s: %v", t.config.Name, ch, err)
+			continue
+		}
+		t.lines[ch] = 0
+		due = append(due, post{channel: ch, text: buf.String()})
+	}
+	tm.mu.Unlock()
+
+	for _, p := range due {
+		sendCtx, cancel := sendContext(ctx)
+		err := platform.SendMessage(sendCtx, p.channel, p.text)
+		cancel()
+		if err != nil {
+			log.Printf("Timers: failed to post %q to %s: %v", t.config.Name, p.channel, err)
+		}
+	}
+}
+
+// HandleMessage counts chat activity for timers targeting msg's channel.
+// It is registered as a message listener. Bot chatter isn't activity.
+func (tm *TimerManager) HandleMessage(msg Message) {
+	if msg.IsBot {
+		return
+	}
+	ch := normalizeTimerChannel(msg.Platform, msg.Channel)
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, t := range tm.timers {
+		if t.config.Platform == msg.Platform {
+			t.lines[ch]++
+		}
+	}
+}
+
+// normalizeTimerChannel makes Twitch channel names comparable regardless
+// of case or a leading "#".
+func normalizeTimerChannel(platform, channel string) string {
+	if platform == "twitch" {
+		return strings.ToLower(strings.TrimPrefix(channel, "#"))
+	}
+	return channel
+}
+
+// List returns the state of every timer, sorted by name.
+func (tm *TimerManager) List() []TimerStatus {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	out := make([]TimerStatus, 0, len(tm.timers))
+	for _, t := range tm.timers {
+		out = append(out, TimerStatus{
+			Name:     t.config.Name,
+			Platform: t.config.Platform,
+			Paused:   t.paused,
+			Next:     t.next,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Pause stops a timer from posting until it is resumed.
+func (tm *TimerManager) Pause(name string) error {
+	return tm.setPaused(name, true)
+}
+
+// Resume re-enables a paused timer.
+func (tm *TimerManager) Resume(name string) error {
+	return tm.setPaused(name, false)
+}
+
+func (tm *TimerManager) setPaused(name string, paused bool) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, t := range tm.timers {
+		if strings.EqualFold(t.config.Name, name) {
+			t.paused = paused
+			return nil
+		}
+	}
+	return fmt.Errorf("no timer named %q", name)
+}
+
+// HandleCommand implements the "timers" admin command:
+// timers list|pause <name>|resume <name>.
+func (tm *TimerManager) HandleCommand(req CommandRequest) error {
+	usage := "Usage: timers list|pause <name>|resume <name>"
+	if len(req.Arguments) == 0 {
+		return req.Platform.SendMessage(req.Context, req.Channel, usage)
+	}
+
+	switch strings.ToLower(req.Arguments[0]) {
+	case "list":
+		timers := tm.List()
+		if len(timers) == 0 {
+			return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "timer.none", nil))
+		}
+		parts := make([]string, 0, len(timers))
+		for _, t := range timers {
+			state := "running"
+			if t.Paused {
+				state = "paused"
+			}
+			parts = append(parts, fmt.Sprintf("%s (%s, %s)", t.Name, t.Platform, state))
+		}
+		return req.Platform.SendMessage(req.Context, req.Channel, "Timers: "+strings.Join(parts, ", "))
+	case "pause", "resume":
+		if len(req.Arguments) < 2 {
+			return req.Platform.SendMessage(req.Context, req.Channel, usage)
+		}
+		var err error
+		verb := "paused"
+		if strings.EqualFold(req.Arguments[0], "pause") {
+			err = tm.Pause(req.Arguments[1])
+		} else {
+			err = tm.Resume(req.Arguments[1])
+			verb = "resumed"
+		}
+		if err != nil {
+			return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "timer.not_found", Vars{"Name": req.Arguments[1]}))
+		}
+		return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "timer.toggled", Vars{"Name": req.Arguments[1], "State": verb}))
+	default:
+		return req.Platform.SendMessage(req.Context, req.Channel, usage)
+	}
+}
@@ -0,0 +1,284 @@
+package dwarfbot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateTimerConfigs(t *testing.T) {
+	valid := TimerConfig{Name: "discord", Interval: 20 * time.Minute, Platform: "twitch", Message: "Join us!"}
+	tests := []struct {
+		name    string
+		configs []TimerConfig
+		wantErr bool
+	}{
+		{"valid interval", []TimerConfig{valid}, false},
+		{"valid cron", []TimerConfig{{Name: "c", Cron: "*/20 * * * *", Platform: "discord", Message: "hi"}}, false},
+		{"missing name", []TimerConfig{{Interval: time.Hour, Platform: "twitch", Message: "hi"}}, true},
+		{"duplicate name", []TimerConfig{valid, valid}, true},
+		{"both schedules", []TimerConfig{{Name: "x", Interval: time.Hour, Cron: "* * * * *", Platform: "twitch", Message: "hi"}}, true},
+		{"no schedule", []TimerConfig{{Name: "x", Platform: "twitch", Message: "hi"}}, true},
+		{"bad cron", []TimerConfig{{Name: "x", Cron: "nope", Platform: "twitch", Message: "hi"}}, true},
+		{"interval too short", []TimerConfig{{Name: "x", Interval: time.Second, Platform: "twitch", Message: "hi"}}, true},
+		{"bad platform", []TimerConfig{{Name: "x", Interval: time.Hour, Platform: "irc", Message: "hi"}}, true},
+		{"empty message", []TimerConfig{{Name: "x", Interval: time.Hour, Platform: "twitch"}}, true},
+		{"bad template", []TimerConfig{{Name: "x", Interval: time.Hour, Platform: "twitch", Message: "{{.Nope"}}, true},
+		{"negative min lines", []TimerConfig{{Name: "x", Interval: time.Hour, Platform: "twitch", Message: "hi", MinLines: -1}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTimerConfigs(tt.configs)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTimerConfigs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func newTestTimerManager(t *testing.T, configs ...TimerConfig) *TimerManager {
+	t.Helper()
+	tm, err := NewTimerManager(configs)
+	if err != nil {
+		t.Fatalf("NewTimerManager: %v", err)
+	}
+	return tm
+}
+
+func TestTimer_TwitchRequiresActivity(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "follow", Interval: time.Hour, Platform: "twitch", Channels: []string{"#Hammerdwarf"}, Message: "Follow us!"})
+	mock := newMockPlatform("testbot", []string{"hammerdwarf"})
+	tm.SetPlatform("twitch", mock)
+
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected no announcement without chat activity, got %v", mock.messages)
+	}
+
+	tm.HandleMessage(Message{Platform: "twitch", Channel: "hammerdwarf", Text: "hello"})
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 || mock.messages[0].msg != "Follow us!" {
+		t.Fatalf("expected one announcement after activity, got %v", mock.messages)
+	}
+	if mock.messages[0].channel != "hammerdwarf" {
+		t.Errorf("expected normalized channel, got %q", mock.messages[0].channel)
+	}
+
+	// Counter resets after posting
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 {
+		t.Errorf("expected no second announcement without new activity, got %d", len(mock.messages))
+	}
+}
+
+func TestTimer_MinLines(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "twitch", Channels: []string{"ch"}, Message: "hi", MinLines: 3})
+	mock := newMockPlatform("testbot", nil)
+	tm.SetPlatform("twitch", mock)
+
+	for range 2 {
+		tm.HandleMessage(Message{Platform: "twitch", Channel: "ch"})
+	}
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected no announcement below min_lines, got %v", mock.messages)
+	}
+	tm.HandleMessage(Message{Platform: "twitch", Channel: "ch"})
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected announcement at min_lines, got %v", mock.messages)
+	}
+}
+
+func TestTimer_BotMessagesAreNotActivity(t *testing.T) {
+	defer ResetRegistrations()
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "discord", Channels: []string{"ch"}, Message: "hi", MinLines: 1})
+	mock := newMockPlatform("testbot", []string{"ch"})
+	tm.SetPlatform("discord", mock)
+	RegisterMessageListener("timers", tm.HandleMessage)
+
+	// Even a bot allowed to run commands doesn't count.
+	msg := Message{Platform: "discord", Channel: "ch", UserID: "99", Text: "beep", IsBot: true}
+	if err := dispatchInbound(context.Background(), mock, msg, parseCommandOpts{platformName: "discord", allowBots: []string{"99"}}); err != nil {
+		t.Fatal(err)
+	}
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected bot chatter not to count as activity, got %v", mock.messages)
+	}
+	msg.IsBot = false
+	if err := dispatchInbound(context.Background(), mock, msg, parseCommandOpts{platformName: "discord"}); err != nil {
+		t.Fatal(err)
+	}
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 {
+		t.Errorf("expected a human message to count, got %v", mock.messages)
+	}
+}
+
+func TestTimer_ActivityIsPerPlatform(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "twitch", Channels: []string{"ch"}, Message: "hi"})
+	mock := newMockPlatform("testbot", nil)
+	tm.SetPlatform("twitch", mock)
+
+	tm.HandleMessage(Message{Platform: "discord", Channel: "ch"})
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 0 {
+		t.Errorf("expected discord activity not to count for a twitch timer, got %v", mock.messages)
+	}
+}
+
+func TestTimer_DiscordWithoutMinLinesAlwaysPosts(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "discord", Message: "{{.BotName}} in {{.Channel}} via {{.Platform}}"})
+	mock := newMockPlatform("testbot", []string{"111", "222"})
+	tm.SetPlatform("discord", mock)
+
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 2 {
+		t.Fatalf("expected one announcement per bot channel, got %v", mock.messages)
+	}
+	if mock.messages[0].msg != "testbot in 111 via discord" {
+		t.Errorf("unexpected rendered message %q", mock.messages[0].msg)
+	}
+}
+
+func TestTimer_RenderErrorSkipsOnlyThatChannel(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "twitch", Channels: []string{"bad", "good"},
+		Message: `{{if eq .Channel "bad"}}{{index .Timer 99}}{{end}}hi`})
+	mock := newMockPlatform("testbot", nil)
+	tm.SetPlatform("twitch", mock)
+
+	tm.HandleMessage(Message{Platform: "twitch", Channel: "bad"})
+	tm.HandleMessage(Message{Platform: "twitch", Channel: "good"})
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 || mock.messages[0].channel != "good" {
+		t.Fatalf("expected the good channel to still be posted to, got %v", mock.messages)
+	}
+	if tm.timers[0].lines["bad"] == 0 {
+		t.Error("expected the failed channel to keep its activity")
+	}
+	if tm.timers[0].lines["good"] != 0 {
+		t.Error("expected the posted channel's activity to reset")
+	}
+}
+
+func TestTimer_PausedDoesNotPost(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "Follow", Interval: time.Hour, Platform: "discord", Channels: []string{"1"}, Message: "hi"})
+	mock := newMockPlatform("testbot", nil)
+	tm.SetPlatform("discord", mock)
+
+	if err := tm.Pause("follow"); err != nil {
+		t.Fatal(err)
+	}
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected paused timer to stay quiet, got %v", mock.messages)
+	}
+	if err := tm.Resume("FOLLOW"); err != nil {
+		t.Fatal(err)
+	}
+	tm.fire(context.Background(), tm.timers[0])
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected resumed timer to post, got %v", mock.messages)
+	}
+	if err := tm.Pause("missing"); err == nil {
+		t.Error("expected error pausing unknown timer")
+	}
+}
+
+func TestTimer_NoPlatformIsNoop(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "discord", Message: "hi"})
+	tm.fire(context.Background(), tm.timers[0]) // must not panic
+}
+
+func TestTimer_NextAfter(t *testing.T) {
+	tm := newTestTimerManager(t,
+		TimerConfig{Name: "i", Interval: 20 * time.Minute, Platform: "discord", Message: "hi"},
+		TimerConfig{Name: "c", Cron: "0 * * * *", Platform: "discord", Message: "hi"},
+	)
+	now := time.Date(2026, 1, 1, 10, 5, 0, 0, time.UTC)
+	if got := tm.timers[0].nextAfter(now); !got.Equal(now.Add(20 * time.Minute)) {
+		t.Errorf("interval timer: got %v", got)
+	}
+	if got := tm.timers[1].nextAfter(now); !got.Equal(time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("cron timer: got %v", got)
+	}
+}
+
+func TestTimerManager_StartStop(t *testing.T) {
+	tm := newTestTimerManager(t, TimerConfig{Name: "t", Interval: time.Hour, Platform: "discord", Message: "hi"})
+	tm.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if !tm.List()[0].Next.IsZero() || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if tm.List()[0].Next.IsZero() {
+		t.Error("expected next fire time to be scheduled")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		tm.Stop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not return")
+	}
+}
+
+func TestTimerManager_HandleCommand(t *testing.T) {
+	tm := newTestTimerManager(t,
+		TimerConfig{Name: "follow", Interval: time.Hour, Platform: "twitch", Message: "hi"},
+		TimerConfig{Name: "discord", Interval: time.Hour, Platform: "discord", Message: "hi"},
+	)
+	mock := newMockPlatform("testbot", nil)
+	req := CommandRequest{Platform: mock, Channel: "ch"}
+
+	req.Arguments = []string{"pause", "follow"}
+	_ = tm.HandleCommand(req)
+	req.Arguments = []string{"list"}
+	_ = tm.HandleCommand(req)
+	req.Arguments = []string{"resume", "nope"}
+	_ = tm.HandleCommand(req)
+	req.Arguments = nil
+	_ = tm.HandleCommand(req)
+
+	if len(mock.messages) != 4 {
+		t.Fatalf("expected 4 replies, got %d", len(mock.messages))
+	}
+	if !strings.Contains(mock.messages[0].msg, "paused") {
+		t.Errorf("expected pause confirmation, got %q", mock.messages[0].msg)
+	}
+	if mock.messages[1].msg != "Timers: discord (discord, running), follow (twitch, paused)" {
+		t.Errorf("unexpected list output %q", mock.messages[1].msg)
+	}
+	if !strings.Contains(mock.messages[2].msg, "nae timer") {
+		t.Errorf("expected unknown timer reply, got %q", mock.messages[2].msg)
+	}
+	if !strings.HasPrefix(mock.messages[3].msg, "Usage:") {
+		t.Errorf("expected usage, got %q", mock.messages[3].msg)
+	}
+}
+
+func TestParseCommand_TimersAdminOnly(t *testing.T) {
+	tm := newTestTimerManager(t)
+	RegisterAdminCommand("timers", tm.HandleCommand)
+	defer RegisterAdminCommand("timers", nil)
+
+	mock := newMockPlatformWithAdmin("testbot", []string{"ch1"}, func(ch, user string) bool { return user == "boss" })
+	_ = parseCommand(context.Background(), mock, "ch1", "pleb", "timers", []string{"list"})
+	if len(mock.messages) != 0 {
+		t.Fatalf("expected non-admin to be ignored, got %v", mock.messages)
+	}
+	_ = parseCommand(context.Background(), mock, "ch1", "boss", "timers", []string{"list"})
+	if len(mock.messages) != 1 || !strings.Contains(mock.messages[0].msg, "No timers") {
+		t.Fatalf("expected admin to get timer list, got %v", mock.messages)
+	}
+}
@@ -0,0 +1,326 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultTriggerCooldown applies when a trigger sets no cooldown, so a
+// busy chat can't make the bot repeat itself every line.
+const defaultTriggerCooldown = 30 * time.Second
+
+// triggerQueueSize bounds responses waiting to be sent. Listeners run on
+// the platform's receive loop, so a slow send must not hold it up.
+const triggerQueueSize = 100
+
+// TriggerConfig is an auto-responder that reacts to plain chat messages
+// (anything that isn't a !command) matching a regular expression.
+type TriggerConfig struct {
+	// Name identifies the trigger in logs. Defaults to the pattern.
+	Name string `mapstructure:"name"`
+
+	// Pattern is a Go regular expression, e.g. "(?i)^first!?$". Use
+	// (?i) for case-insensitive matching.
+	Pattern string `mapstructure:"pattern"`
+
+	// Responses are text/templates; one is picked at random. .User,
+	// .Channel, .Platform, .Match, .Groups (by index) and .Named (by
+	// capture group name) are available.
+	Responses []string `mapstructure:"responses"`
+
+	// Cooldown is the minimum time between responses from this trigger
+	// in a single channel, e.g. "5m". Defaults to 30s.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+
+	// Platforms limits the trigger to "twitch" and/or "discord". Empty
+	// means both.
+	Platforms []string `mapstructure:"platforms"`
+
+	// Channels limits the trigger to Twitch channel names or Discord
+	// channel IDs. Empty means every channel.
+	Channels []string `mapstructure:"channels"`
+}
+
+// ValidateTriggerConfigs checks triggers before they are used.
+func ValidateTriggerConfigs(configs []TriggerConfig) error {
+	for i, c := range configs {
+		label := c.Name
+		if label == "" {
+			label = fmt.Sprintf("%d", i)
+		}
+		if strings.TrimSpace(c.Pattern) == "" {
+			return fmt.Errorf("trigger %s: pattern must be set", label)
+		}
+		if _, err := regexp.Compile(c.Pattern); err != nil {
+			return fmt.Errorf("trigger %s: invalid pattern: %w", label, err)
+		}
+		if len(c.Responses) == 0 {
+			return fmt.Errorf("trigger %s: at least one response must be set", label)
+		}
+		for j, r := range c.Responses {
+			if _, err := template.New(label).Parse(r); err != nil {
+				return fmt.Errorf("trigger %s: response %d: invalid template: %w", label, j+1, err)
+			}
+		}
+		if c.Cooldown < 0 {
+			return fmt.Errorf("trigger %s: cooldown must be >= 0, got %v", label, c.Cooldown)
+		}
+		for _, p := range c.Platforms {
+			if p != "twitch" && p != "discord" {
+				return fmt.Errorf("trigger %s: platforms must be twitch or discord, got %q", label, p)
+			}
+		}
+	}
+	return nil
+}
+
+// triggerData is the template context for trigger responses.
+type triggerData struct {
+	User     string
+	Channel  string
+	Platform string
+	Match    string
+	Groups   []string
+	Named    map[string]string
+}
+
+type trigger struct {
+	config    TriggerConfig
+	name      string
+	re        *regexp.Regexp
+	responses []*template.Template
+	cooldown  time.Duration
+}
+
+func (t *trigger) applies(msg Message) bool {
+	if len(t.config.Platforms) > 0 && !contains(t.config.Platforms, msg.Platform) {
+		return false
+	}
+	if len(t.config.Channels) > 0 && !containsFold(t.config.Channels, msg.Channel) {
+		return false
+	}
+	return true
+}
+
+type triggerReply struct {
+	trigger  string
+	platform string
+	channel  string
+	text     string
+}
+
+// Triggers answers plain chat messages that match a configured pattern.
+// Triggers are checked in order and only the first match responds.
+type Triggers struct {
+	mu        sync.Mutex
+	triggers  []*trigger
+	platforms map[string]ChatPlatform
+	queue     chan triggerReply
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+
+	// lastFired is keyed by trigger name and channel.
+	lastFired map[string]time.Time
+
+	nowFunc  func() time.Time
+	randIntN func(n int) int
+}
+
+// NewTriggers validates and compiles the configs. Call SetPlatform for
+// each running platform, register HandleMessage as a listener, then
+// Start.
+func NewTriggers(configs []TriggerConfig) (*Triggers, error) {
+	t := &Triggers{
+		platforms: map[string]ChatPlatform{},
+		queue:     make(chan triggerReply, triggerQueueSize),
+		lastFired: map[string]time.Time{},
+		nowFunc:   time.Now,
+		randIntN:  rand.IntN,
+	}
+	if err := t.SetTriggers(configs); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// SetTriggers validates and atomically replaces the trigger set.
+// Cooldowns of triggers that keep their name carry over.
+func (t *Triggers) SetTriggers(configs []TriggerConfig) error {
+	if err := ValidateTriggerConfigs(configs); err != nil {
+		return err
+	}
+	compiled := make([]*trigger, 0, len(configs))
+	for _, c := range configs {
+		tr := &trigger{
+			config:   c,
+			name:     c.Name,
+			re:       regexp.MustCompile(c.Pattern),
+			cooldown: c.Cooldown,
+		}
+		if tr.name == "" {
+			tr.name = c.Pattern
+		}
+		if tr.cooldown == 0 {
+			tr.cooldown = defaultTriggerCooldown
+		}
+		for _, r := range c.Responses {
+			tr.responses = append(tr.responses, template.Must(template.New(tr.name).Parse(r)))
+		}
+		compiled = append(compiled, tr)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.triggers = compiled
+	return nil
+}
+
+// Len returns the number of configured triggers.
+func (t *Triggers) Len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.triggers)
+}
+
+// SetPlatform registers the platform that responses for name are sent on.
+func (t *Triggers) SetPlatform(name string, platform ChatPlatform) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.platforms[name] = platform
+}
+
+// Start begins sending queued responses.
+func (t *Triggers) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.mu.Lock()
+	t.cancel = cancel
+	t.mu.Unlock()
+
+	t.wg.Add(1)
+	go t.deliverLoop(ctx)
+}
+
+// Stop halts sending. Responses still queued are dropped.
+func (t *Triggers) Stop() {
+	t.mu.Lock()
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+}
+
+func (t *Triggers) deliverLoop(ctx context.Context) {
+	defer t.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case reply := <-t.queue:
+			t.deliver(ctx, reply)
+		}
+	}
+}
+
+func (t *Triggers) deliver(ctx context.Context, reply triggerReply) {
+	t.mu.Lock()
+	platform := t.platforms[reply.platform]
+	t.mu.Unlock()
+	if platform == nil {
+		return
+	}
+	ctx, cancel := sendContext(ctx)
+	defer cancel()
+	if err := platform.SendMessage(ctx, reply.channel, reply.text); err != nil {
+		log.Printf("Trigger %s: failed to respond in %s on %s: %v", reply.trigger, reply.channel, reply.platform, err)
+	}
+}
+
+// HandleMessage is a MessageListenerFunc. Commands, bot accounts and the
+// bot's own messages are ignored. The response is queued for Start's
+// worker; it never blocks.
+func (t *Triggers) HandleMessage(msg Message) {
+	reply, ok := t.respond(msg)
+	if !ok {
+		return
+	}
+	select {
+	case t.queue <- reply:
+	default:
+		log.Printf("Trigger %s: queue full, dropping response", reply.trigger)
+	}
+}
+
+// respond picks and renders the response to msg, if any trigger fires.
+func (t *Triggers) respond(msg Message) (triggerReply, bool) {
+	if msg.IsBot || cmdRegex.MatchString(msg.Text) {
+		return triggerReply{}, false
+	}
+	settings := ChannelSettingsFor(msg.Channel)
+	if !settings.AutoRespondersEnabled() {
+		return triggerReply{}, false
+	}
+
+	t.mu.Lock()
+	platform := t.platforms[msg.Platform]
+	if platform == nil || strings.EqualFold(msg.UserName, platform.BotName()) {
+		t.mu.Unlock()
+		return triggerReply{}, false
+	}
+	var (
+		tr      *trigger
+		matches []string
+	)
+	for _, candidate := range t.triggers {
+		if !candidate.applies(msg) {
+			continue
+		}
+		if m := candidate.re.FindStringSubmatch(msg.Text); m != nil {
+			tr, matches = candidate, m
+			break
+		}
+	}
+	if tr == nil {
+		t.mu.Unlock()
+		return triggerReply{}, false
+	}
+	now := t.nowFunc()
+	key := tr.name + "\x00" + msg.Channel
+	if last, ok := t.lastFired[key]; ok && now.Sub(last) < settings.Cooldown(tr.cooldown) {
+		t.mu.Unlock()
+		return triggerReply{}, false
+	}
+	t.lastFired[key] = now
+	tmpl := tr.responses[t.randIntN(len(tr.responses))]
+	t.mu.Unlock()
+
+	data := triggerData{
+		User:     msg.UserName,
+		Channel:  msg.Channel,
+		Platform: msg.Platform,
+		Match:    matches[0],
+		Groups:   matches,
+		Named:    map[string]string{},
+	}
+	for i, name := range tr.re.SubexpNames() {
+		if name != "" {
+			data.Named[name] = matches[i]
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		log.Printf("Trigger %s: rendering response: %v", tr.name, err)
+		return triggerReply{}, false
+	}
+	return triggerReply{trigger: tr.name, platform: msg.Platform, channel: msg.Channel, text: buf.String()}, true
+}
@@ -0,0 +1,184 @@
+package dwarfbot
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestTriggers(t *testing.T, configs []TriggerConfig) (*Triggers, *mockPlatform, *time.Time) {
+	t.Helper()
+	tr, err := NewTriggers(configs)
+	if err != nil {
+		t.Fatalf("NewTriggers: %v", err)
+	}
+	now := pinClock(&tr.nowFunc, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	mock := newMockPlatform("dwarfbot", []string{"chan"})
+	tr.SetPlatform("twitch", mock)
+	return tr, mock, now
+}
+
+// handleTrigger passes msg to tr and sends whatever it queued, as Start's
+// worker would.
+func handleTrigger(tr *Triggers, msg Message) {
+	tr.HandleMessage(msg)
+	for {
+		select {
+		case reply := <-tr.queue:
+			tr.deliver(context.Background(), reply)
+		default:
+			return
+		}
+	}
+}
+
+func chat(text string) Message {
+	return Message{Platform: "twitch", Channel: "chan", UserID: "viewer", UserName: "viewer", Text: text}
+}
+
+func TestValidateTriggerConfigs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  TriggerConfig
+	}{
+		{"no pattern", TriggerConfig{Responses: []string{"hi"}}},
+		{"bad pattern", TriggerConfig{Pattern: "(", Responses: []string{"hi"}}},
+		{"no responses", TriggerConfig{Pattern: "hi"}},
+		{"bad template", TriggerConfig{Pattern: "hi", Responses: []string{"{{.User"}}},
+		{"negative cooldown", TriggerConfig{Pattern: "hi", Responses: []string{"hi"}, Cooldown: -time.Second}},
+		{"bad platform", TriggerConfig{Pattern: "hi", Responses: []string{"hi"}, Platforms: []string{"irc"}}},
+	}
+	for _, tt := range tests {
+		if err := ValidateTriggerConfigs([]TriggerConfig{tt.cfg}); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+	if err := ValidateTriggerConfigs([]TriggerConfig{{Pattern: "hi", Responses: []string{"hi"}}}); err != nil {
+		t.Errorf("expected valid trigger, got %v", err)
+	}
+}
+
+func TestTriggers_CaptureGroups(t *testing.T) {
+	tr, mock, _ := newTestTriggers(t, []TriggerConfig{{
+		Name:      "game",
+		Pattern:   `(?i)what game is (?P<what>this|that)`,
+		Responses: []string{"{{.User}} asked about {{.Named.what}} ({{index .Groups 1}})"},
+	}})
+
+	handleTrigger(tr, chat("hey, What game is this?"))
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected one response, got %v", mock.messages)
+	}
+	if got := mock.messages[0].msg; got != "viewer asked about this (this)" {
+		t.Errorf("unexpected response %q", got)
+	}
+}
+
+func TestTriggers_Cooldown(t *testing.T) {
+	tr, mock, now := newTestTriggers(t, []TriggerConfig{{
+		Pattern:   `(?i)^first!?$`,
+		Responses: []string{"Ye're not first, I was here all along."},
+		Cooldown:  time.Minute,
+	}})
+
+	handleTrigger(tr, chat("first!"))
+	handleTrigger(tr, chat("FIRST"))
+	if len(mock.messages) != 1 {
+		t.Fatalf("expected cooldown to suppress second response, got %v", mock.messages)
+	}
+
+	other := chat("first")
+	other.Channel = "other"
+	handleTrigger(tr, other)
+	if len(mock.messages) != 2 {
+		t.Fatalf("expected cooldown to be per channel, got %v", mock.messages)
+	}
+
+	*now = now.Add(time.Minute)
+	handleTrigger(tr, chat("first"))
+	if len(mock.messages) != 3 {
+		t.Errorf("expected response after cooldown, got %v", mock.messages)
+	}
+}
+
+func TestTriggers_Scope(t *testing.T) {
+	tr, mock, _ := newTestTriggers(t, []TriggerConfig{{
+		Pattern:   "hello",
+		Responses: []string{"hi"},
+		Platforms: []string{"twitch"},
+		Channels:  []string{"Chan"},
+	}})
+	discord := newMockPlatform("dwarfbot", []string{"1"})
+	tr.SetPlatform("discord", discord)
+
+	off := chat("hello")
+	off.Channel = "elsewhere"
+	handleTrigger(tr, off)
+	handleTrigger(tr, Message{Platform: "discord", Channel: "chan", UserName: "viewer", Text: "hello"})
+	if len(mock.messages)+len(discord.messages) != 0 {
+		t.Fatalf("expected out-of-scope messages to be ignored")
+	}
+
+	handleTrigger(tr, chat("hello"))
+	if len(mock.messages) != 1 {
+		t.Errorf("expected in-scope message to respond, got %v", mock.messages)
+	}
+}
+
+func TestTriggers_Ignores(t *testing.T) {
+	tr, mock, _ := newTestTriggers(t, []TriggerConfig{{Pattern: "hello", Responses: []string{"hi"}}})
+
+	handleTrigger(tr, chat("!dwarfbot hello there"))
+
+	bot := chat("hello")
+	bot.IsBot = true
+	handleTrigger(tr, bot)
+
+	self := chat("hello")
+	self.UserName = "DwarfBot"
+	handleTrigger(tr, self)
+
+	if len(mock.messages) != 0 {
+		t.Errorf("expected commands, bots and self to be ignored, got %v", mock.messages)
+	}
+}
+
+func TestTriggers_FirstMatchWins(t *testing.T) {
+	tr, mock, _ := newTestTriggers(t, []TriggerConfig{
+		{Name: "a", Pattern: "hello", Responses: []string{"first"}},
+		{Name: "b", Pattern: "hello", Responses: []string{"second"}},
+	})
+	handleTrigger(tr, chat("hello"))
+	if len(mock.messages) != 1 || mock.messages[0].msg != "first" {
+		t.Errorf("expected only the first trigger to respond, got %v", mock.messages)
+	}
+}
+
+func TestTriggers_SlowSendDoesNotBlock(t *testing.T) {
+	tr, mock, _ := newTestTriggers(t, []TriggerConfig{{Pattern: "hello", Responses: []string{"slow"}}})
+	stuck := &stuckPlatform{mockPlatform: mock}
+	tr.SetPlatform("twitch", stuck)
+	tr.Start()
+
+	done := make(chan struct{})
+	go func() {
+		tr.HandleMessage(chat("hello"))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleMessage blocked on a stuck send")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		tr.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop did not cancel the stuck send")
+	}
+}
@@ -0,0 +1,364 @@
+package dwarfbot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Webhook defaults and hard limits.
+const (
+	defaultWebhookTimeout = 5 * time.Second
+	maxWebhookTimeout     = 30 * time.Second
+	maxWebhookRetries     = 5
+	maxWebhookResponse    = 1 << 20
+	webhookRetryDelay     = 500 * time.Millisecond
+)
+
+// webhookFuncs are available in URL, body and response templates.
+var webhookFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// WebhookConfig binds a command to an outbound HTTP request.
+type WebhookConfig struct {
+	// Command is the chat command name, e.g. "clip".
+	Command string `mapstructure:"command"`
+
+	// Method defaults to POST.
+	Method string `mapstructure:"method"`
+
+	// URL is a text/template, e.g. "http://obs:4455/scene/{{urlquery .Text}}".
+	URL string `mapstructure:"url"`
+
+	// Headers are sent as-is with every request.
+	Headers map[string]string `mapstructure:"headers"`
+
+	// SecretHeader is sent with the value of the SecretEnv environment
+	// variable, so secrets stay out of the config file.
+	SecretHeader string `mapstructure:"secret_header"`
+	SecretEnv    string `mapstructure:"secret_env"`
+
+	// Body is a text/template that must render to JSON; use the json
+	// function to quote values, e.g. {"scene": {{json .Text}}}. Empty
+	// sends no body.
+	Body string `mapstructure:"body"`
+
+	// Response is a text/template rendered into chat on success, with
+	// the decoded JSON response as .JSON and the status code as
+	// .Status. Empty sends a short acknowledgement.
+	Response string `mapstructure:"response"`
+
+	// Timeout per attempt. Defaults to 5s.
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// Retries after a network error, 429 or 5xx response.
+	Retries int `mapstructure:"retries"`
+
+	// AdminOnly restricts the command to admins.
+	AdminOnly bool `mapstructure:"admin_only"`
+}
+
+// ValidateWebhookConfigs checks webhook definitions before they are
+// registered.
+func ValidateWebhookConfigs(configs []WebhookConfig) error {
+	seen := map[string]bool{}
+	for i, c := range configs {
+		name := strings.ToLower(c.Command)
+		if !commandNameRegex.MatchString(name) {
+			return fmt.Errorf("webhook %d: command must use a-z, 0-9 and _ (max 32), got %q", i, c.Command)
+		}
+		if seen[name] {
+			return fmt.Errorf("webhook %q: duplicate command", name)
+		}
+		seen[name] = true
+		if strings.TrimSpace(c.URL) == "" {
+			return fmt.Errorf("webhook %q: url must be set", name)
+		}
+		for field, text := range map[string]string{"url": c.URL, "body": c.Body, "response": c.Response} {
+			if _, err := template.New(field).Funcs(webhookFuncs).Parse(text); err != nil {
+				return fmt.Errorf("webhook %q: invalid %s template: %w", name, field, err)
+			}
+		}
+		if (c.SecretHeader == "") != (c.SecretEnv == "") {
+			return fmt.Errorf("webhook %q: secret_header and secret_env must be set together", name)
+		}
+		if c.Timeout < 0 || c.Timeout > maxWebhookTimeout {
+			return fmt.Errorf("webhook %q: timeout must be between 0 and %v, got %v", name, maxWebhookTimeout, c.Timeout)
+		}
+		if c.Retries < 0 || c.Retries > maxWebhookRetries {
+			return fmt.Errorf("webhook %q: retries must be between 0 and %d, got %d", name, maxWebhookRetries, c.Retries)
+		}
+	}
+	return nil
+}
+
+// webhookData is the template context for webhook URL and body templates.
+type webhookData struct {
+	Command  string
+	Platform string
+	Channel  string
+	User     string
+	UserName string
+	Identity string
+	Role     string
+	Args     []string
+
+	// Text is Args joined with spaces.
+	Text string
+}
+
+// webhookResult is the template context for the response template.
+type webhookResult struct {
+	webhookData
+	Status int
+	JSON   any
+}
+
+type webhook struct {
+	config   WebhookConfig
+	secret   string
+	url      *template.Template
+	body     *template.Template
+	response *template.Template
+	slot     chan struct{}
+}
+
+// Webhooks sends HTTP requests for chat commands. Requests run off the
+// platform loop, one at a time per command.
+type Webhooks struct {
+	webhooks   []*webhook
+	client     *http.Client
+	retryDelay time.Duration
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+// NewWebhooks validates the configs and resolves secrets from the
+// environment.
+func NewWebhooks(configs []WebhookConfig) (*Webhooks, error) {
+	if err := ValidateWebhookConfigs(configs); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Webhooks{
+		client:     &http.Client{},
+		retryDelay: webhookRetryDelay,
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	for _, c := range configs {
+		c.Command = strings.ToLower(c.Command)
+		c.Method = strings.ToUpper(c.Method)
+		if c.Method == "" {
+			c.Method = http.MethodPost
+		}
+		if c.Timeout == 0 {
+			c.Timeout = defaultWebhookTimeout
+		}
+		wh := &webhook{
+			config:   c,
+			url:      template.Must(template.New("url").Funcs(webhookFuncs).Parse(c.URL)),
+			body:     template.Must(template.New("body").Funcs(webhookFuncs).Parse(c.Body)),
+			response: template.Must(template.New("response").Funcs(webhookFuncs).Parse(c.Response)),
+			slot:     make(chan struct{}, 1),
+		}
+		if c.SecretEnv != "" {
+			wh.secret = os.Getenv(c.SecretEnv)
+			if wh.secret == "" {
+				cancel()
+				return nil, fmt.Errorf("webhook %q: environment variable %s is not set", c.Command, c.SecretEnv)
+			}
+		}
+		w.webhooks = append(w.webhooks, wh)
+	}
+	return w, nil
+}
+
+// RegisterCommands registers a command per webhook. Call it before
+// Counters.RegisterCommands so stored counters can't shadow a webhook.
+func (w *Webhooks) RegisterCommands() error {
+	for _, wh := range w.webhooks {
+		if isKnownCommand(wh.config.Command) {
+			return fmt.Errorf("webhook %q clashes with an existing command", wh.config.Command)
+		}
+	}
+	for _, wh := range w.webhooks {
+		if wh.config.AdminOnly {
+			RegisterAdminCommand(wh.config.Command, w.handler(wh))
+		} else {
+			RegisterCommand(wh.config.Command, w.handler(wh))
+		}
+	}
+	if len(w.webhooks) > 0 {
+		log.Printf("Webhooks: registered %d command(s)", len(w.webhooks))
+	}
+	return nil
+}
+
+// Stop cancels in-flight requests and waits for them to finish.
+func (w *Webhooks) Stop() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+func (w *Webhooks) handler(wh *webhook) CommandHandlerFunc {
+	return func(req CommandRequest) error {
+		select {
+		case wh.slot <- struct{}{}:
+		default:
+			return req.Platform.SendMessage(req.Context, req.Channel, Say(req.Channel, "webhook.busy", nil))
+		}
+		w.wg.Add(1)
+		go func() {
+			defer w.wg.Done()
+			defer func() { <-wh.slot }()
+			reply, err := w.call(wh, req)
+			if err != nil {
+				log.Printf("Webhook %s: %v", wh.config.Command, err)
+				if w.ctx.Err() != nil {
+					return
+				}
+				reply = Say(req.Channel, "webhook.failed", nil)
+			}
+			if strings.TrimSpace(reply) == "" {
+				return
+			}
+			ctx, cancel := sendContext(w.ctx)
+			defer cancel()
+			if err := req.Platform.SendMessage(ctx, req.Channel, reply); err != nil {
+				log.Printf("Webhook %s: failed to send reply: %v", wh.config.Command, err)
+			}
+		}()
+		return nil
+	}
+}
+
+// call renders and sends the request, retrying as configured, and
+// renders the chat reply.
+func (w *Webhooks) call(wh *webhook, req CommandRequest) (string, error) {
+	role := RoleUser
+	if req.Admin {
+		role = RoleAdmin
+	}
+	data := webhookData{
+		Command:  req.Command,
+		Platform: req.PlatformName,
+		Channel:  req.Channel,
+		User:     req.User,
+		UserName: req.UserName,
+		Identity: req.Identity,
+		Role:     role,
+		Args:     req.Arguments,
+		Text:     strings.Join(req.Arguments, " "),
+	}
+	url, err := renderTemplate(wh.url, data)
+	if err != nil {
+		return "", fmt.Errorf("rendering url: %w", err)
+	}
+	body, err := renderTemplate(wh.body, data)
+	if err != nil {
+		return "", fmt.Errorf("rendering body: %w", err)
+	}
+	if body != "" && !json.Valid([]byte(body)) {
+		return "", fmt.Errorf("body is not valid JSON: %s", body)
+	}
+
+	var (
+		status  int
+		payload []byte
+	)
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		status, payload, retry, err = w.send(wh, url, body)
+		if err == nil || !retry || attempt == wh.config.Retries {
+			break
+		}
+		log.Printf("Webhook %s: attempt %d failed, retrying: %v", wh.config.Command, attempt+1, err)
+		select {
+		case <-w.ctx.Done():
+			return "", w.ctx.Err()
+		case <-time.After(w.retryDelay * time.Duration(attempt+1)):
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if wh.config.Response == "" {
+		return Say(req.Channel, "webhook.done", nil), nil
+	}
+	result := webhookResult{webhookData: data, Status: status}
+	if len(bytes.TrimSpace(payload)) > 0 {
+		if err := json.Unmarshal(payload, &result.JSON); err != nil {
+			return "", fmt.Errorf("decoding response: %w", err)
+		}
+	}
+	reply, err := renderTemplate(wh.response, result)
+	if err != nil {
+		return "", fmt.Errorf("rendering response: %w", err)
+	}
+	return reply, nil
+}
+
+// send makes one attempt. retry reports whether a failure is worth
+// retrying: network errors, 429 and 5xx.
+func (w *Webhooks) send(wh *webhook, url, body string) (status int, payload []byte, retry bool, err error) {
+	ctx, cancel := context.WithTimeout(w.ctx, wh.config.Timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, wh.config.Method, url, reader)
+	if err != nil {
+		return 0, nil, false, fmt.Errorf("building request: %w", err)
+	}
+	if body != "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	for k, v := range wh.config.Headers {
+		httpReq.Header.Set(k, v)
+	}
+	if wh.config.SecretHeader != "" {
+		httpReq.Header.Set(wh.config.SecretHeader, wh.secret)
+	}
+
+	resp, err := w.client.Do(httpReq)
+	if err != nil {
+		return 0, nil, w.ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	payload, err = io.ReadAll(io.LimitReader(resp.Body, maxWebhookResponse))
+	if err != nil {
+		return resp.StatusCode, nil, true, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return resp.StatusCode, payload, retry, fmt.Errorf("%s %s: %s", wh.config.Method, url, resp.Status)
+	}
+	return resp.StatusCode, payload, false, nil
+}
+
+func renderTemplate(tmpl *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
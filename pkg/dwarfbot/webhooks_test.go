@@ -0,0 +1,203 @@
+package dwarfbot
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestWebhooks(t *testing.T, configs []WebhookConfig) *Webhooks {
+	t.Helper()
+	w, err := NewWebhooks(configs)
+	if err != nil {
+		t.Fatalf("NewWebhooks: %v", err)
+	}
+	w.retryDelay = time.Millisecond
+	if err := w.RegisterCommands(); err != nil {
+		t.Fatalf("RegisterCommands: %v", err)
+	}
+	t.Cleanup(func() {
+		w.Stop()
+		ResetRegistrations()
+	})
+	return w
+}
+
+func runWebhook(t *testing.T, w *Webhooks, name string, admin bool, args ...string) *mockPlatform {
+	t.Helper()
+	mock := newMockPlatform("testbot", []string{"chan"})
+	c, ok := getCommand(name)
+	if !ok {
+		t.Fatalf("webhook command %q not registered", name)
+	}
+	if err := c.handler(CommandRequest{Platform: mock, PlatformName: "twitch", Channel: "chan", User: "viewer", UserName: "Viewer", Admin: admin, Command: name, Arguments: args}); err != nil {
+		t.Fatalf("handler: %v", err)
+	}
+	w.wg.Wait()
+	return mock
+}
+
+func TestValidateWebhookConfigs(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  WebhookConfig
+	}{
+		{"bad command", WebhookConfig{Command: "a b", URL: "http://x"}},
+		{"no url", WebhookConfig{Command: "clip"}},
+		{"bad body template", WebhookConfig{Command: "clip", URL: "http://x", Body: "{{.Text"}},
+		{"secret header without env", WebhookConfig{Command: "clip", URL: "http://x", SecretHeader: "X-Token"}},
+		{"timeout too long", WebhookConfig{Command: "clip", URL: "http://x", Timeout: time.Hour}},
+		{"too many retries", WebhookConfig{Command: "clip", URL: "http://x", Retries: 50}},
+	}
+	for _, tt := range tests {
+		if err := ValidateWebhookConfigs([]WebhookConfig{tt.cfg}); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestNewWebhooks_MissingSecret(t *testing.T) {
+	t.Setenv("DWARFBOT_TEST_WEBHOOK_SECRET", "")
+	_, err := NewWebhooks([]WebhookConfig{{Command: "clip", URL: "http://x", SecretHeader: "X-Token", SecretEnv: "DWARFBOT_TEST_WEBHOOK_SECRET"}})
+	if err == nil {
+		t.Error("expected unset secret env to fail")
+	}
+}
+
+func TestWebhooks_RequestAndResponse(t *testing.T) {
+	t.Setenv("TEST_WEBHOOK_SECRET", "s3cret")
+	var got struct {
+		method, path, secret, custom, contentType string
+		body                                      map[string]any
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.method, got.path = r.Method, r.URL.Path
+		got.secret, got.custom = r.Header.Get("X-Token"), r.Header.Get("X-Source")
+		got.contentType = r.Header.Get("Content-Type")
+		b, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(b, &got.body)
+		_, _ = w.Write([]byte(`{"scene": {"name": "BRB"}, "ok": true}`))
+	}))
+	defer srv.Close()
+
+	w := newTestWebhooks(t, []WebhookConfig{{
+		Command:      "scene",
+		Method:       "put",
+		URL:          srv.URL + "/scenes/{{urlquery (index .Args 0)}}",
+		Headers:      map[string]string{"X-Source": "dwarfbot"},
+		SecretHeader: "X-Token",
+		SecretEnv:    "TEST_WEBHOOK_SECRET",
+		Body:         `{"scene": {{json .Text}}, "by": {{json .UserName}}, "role": {{json .Role}}}`,
+		Response:     `Switched to {{.JSON.scene.name}} fer {{.UserName}} ({{.Status}})`,
+	}})
+
+	mock := runWebhook(t, w, "scene", true, "brb", `"now"`)
+	if got.method != http.MethodPut || got.path != "/scenes/brb" {
+		t.Errorf("unexpected request %s %s", got.method, got.path)
+	}
+	if got.secret != "s3cret" || got.custom != "dwarfbot" || got.contentType != "application/json" {
+		t.Errorf("unexpected headers secret=%q custom=%q content-type=%q", got.secret, got.custom, got.contentType)
+	}
+	if got.body["scene"] != `brb "now"` || got.body["by"] != "Viewer" || got.body["role"] != RoleAdmin {
+		t.Errorf("unexpected body %v", got.body)
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != "Switched to BRB fer Viewer (200)" {
+		t.Errorf("unexpected reply %v", mock.messages)
+	}
+}
+
+func TestWebhooks_DefaultAcknowledgement(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w := newTestWebhooks(t, []WebhookConfig{{Command: "clip", URL: srv.URL}})
+	mock := runWebhook(t, w, "clip", false)
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "webhook.done", nil) {
+		t.Errorf("expected acknowledgement, got %v", mock.messages)
+	}
+}
+
+func TestWebhooks_Retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"url": "https://clips.example/abc"}`))
+	}))
+	defer srv.Close()
+
+	w := newTestWebhooks(t, []WebhookConfig{{Command: "clip", URL: srv.URL, Retries: 2, Response: "{{.JSON.url}}"}})
+	mock := runWebhook(t, w, "clip", false)
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls.Load())
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != "https://clips.example/abc" {
+		t.Errorf("unexpected reply %v", mock.messages)
+	}
+}
+
+func TestWebhooks_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	w := newTestWebhooks(t, []WebhookConfig{{Command: "clip", URL: srv.URL, Retries: 3}})
+	mock := runWebhook(t, w, "clip", false)
+	if calls.Load() != 1 {
+		t.Errorf("expected 4xx not to be retried, got %d attempts", calls.Load())
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "webhook.failed", nil) {
+		t.Errorf("expected failure reply, got %v", mock.messages)
+	}
+}
+
+func TestWebhooks_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w := newTestWebhooks(t, []WebhookConfig{{Command: "clip", URL: srv.URL, Timeout: 50 * time.Millisecond}})
+	start := time.Now()
+	mock := runWebhook(t, w, "clip", false)
+	if time.Since(start) > 2*time.Second {
+		t.Error("expected the timeout to cut the request short")
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "webhook.failed", nil) {
+		t.Errorf("expected failure reply, got %v", mock.messages)
+	}
+}
+
+func TestWebhooks_InvalidBody(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	// .Text unquoted renders invalid JSON.
+	w := newTestWebhooks(t, []WebhookConfig{{Command: "clip", URL: srv.URL, Body: `{"title": {{.Text}}}`}})
+	mock := runWebhook(t, w, "clip", false, "hello")
+	if calls.Load() != 0 {
+		t.Error("expected invalid JSON body not to be sent")
+	}
+	if len(mock.messages) != 1 || mock.messages[0].msg != Say("chan", "webhook.failed", nil) {
+		t.Errorf("expected failure reply, got %v", mock.messages)
+	}
+}
@@ -0,0 +1,149 @@
+// Package events is an in-process publish/subscribe bus for bot events.
+// Platforms and the MQTT bridge publish typed events (connection changes,
+// joins, messages, commands) and features subscribe to the types they
+// care about, without being wired to each publisher.
+package events
+
+import (
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Default is the bus the platforms and the MQTT bridge publish to.
+var Default = NewBus()
+
+// Connected is published when a component (twitch, discord, mqtt)
+// connects.
+type Connected struct {
+	Component string
+}
+
+// Disconnected is published when a component loses or closes its
+// connection.
+type Disconnected struct {
+	Component string
+	Reason    string
+}
+
+// Enabled is published when a component that can be switched off at
+// runtime (the MQTT bridge) is switched on or off.
+type Enabled struct {
+	Component string
+	Enabled   bool
+}
+
+// ChannelJoined is published when the bot joins or starts listening in
+// a channel.
+type ChannelJoined struct {
+	Platform string
+	Channel  string
+}
+
+// ChannelParted is published when the bot leaves or stops listening in
+// a channel.
+type ChannelParted struct {
+	Platform string
+	Channel  string
+}
+
+// MessageReceived is published for every chat message that makes it
+// through the inbound middleware.
+type MessageReceived struct {
+	Platform string
+	Channel  string
+	UserID   string
+	UserName string
+	Text     string
+	IsBot    bool
+	Time     time.Time
+}
+
+// CommandExecuted is published after a known command has been
+// dispatched. Err is the handler's error, if any.
+type CommandExecuted struct {
+	Platform string
+	Channel  string
+	UserID   string
+	UserName string
+	Command  string
+	Args     []string
+	Admin    bool
+	Err      error
+}
+
+type subscriber struct {
+	id uint64
+	fn func(any)
+}
+
+// Bus delivers events to subscribers by type. The zero value is not
+// usable; use NewBus. It is safe for concurrent use.
+type Bus struct {
+	mu   sync.RWMutex
+	next uint64
+	subs map[reflect.Type][]subscriber
+}
+
+// NewBus returns an empty bus.
+func NewBus() *Bus {
+	return &Bus{subs: map[reflect.Type][]subscriber{}}
+}
+
+// Subscribe calls fn for every event of type T published on b, in
+// subscription order. Handlers run synchronously on the publisher's
+// goroutine, so they must be quick and must not call back into the
+// publisher. The returned function removes the subscription.
+func Subscribe[T any](b *Bus, fn func(T)) (unsubscribe func()) {
+	t := reflect.TypeFor[T]()
+	b.mu.Lock()
+	b.next++
+	id := b.next
+	b.subs[t] = append(b.subs[t], subscriber{id: id, fn: func(e any) { fn(e.(T)) }})
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// Copy so publishers holding the old slice are unaffected.
+		kept := make([]subscriber, 0, len(b.subs[t]))
+		for _, s := range b.subs[t] {
+			if s.id != id {
+				kept = append(kept, s)
+			}
+		}
+		b.subs[t] = kept
+	}
+}
+
+// Publish delivers event to every subscriber of its type. A nil bus
+// drops the event. A panicking subscriber is logged and skipped.
+func Publish[T any](b *Bus, event T) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	subs := b.subs[reflect.TypeFor[T]()]
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		deliver(s, event)
+	}
+}
+
+func deliver(s subscriber, event any) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Events: subscriber for %T panicked: %v", event, r)
+		}
+	}()
+	s.fn(event)
+}
+
+// Reset removes every subscription.
+func (b *Bus) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = map[reflect.Type][]subscriber{}
+}
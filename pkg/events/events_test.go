@@ -0,0 +1,62 @@
+package events
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPublish_OrderAndTypes(t *testing.T) {
+	bus := NewBus()
+	var got []string
+	Subscribe(bus, func(e Connected) { got = append(got, "a:"+e.Component) })
+	Subscribe(bus, func(e Connected) { got = append(got, "b:"+e.Component) })
+	Subscribe(bus, func(e Disconnected) { got = append(got, "d:"+e.Component) })
+
+	Publish(bus, Connected{Component: "twitch"})
+	if strings.Join(got, ",") != "a:twitch,b:twitch" {
+		t.Errorf("expected subscribers of Connected only, in order, got %v", got)
+	}
+}
+
+func TestSubscribe_Unsubscribe(t *testing.T) {
+	bus := NewBus()
+	count := 0
+	unsubscribe := Subscribe(bus, func(Connected) { count++ })
+	Subscribe(bus, func(Connected) { count += 10 })
+
+	Publish(bus, Connected{})
+	unsubscribe()
+	unsubscribe()
+	Publish(bus, Connected{})
+	if count != 21 {
+		t.Errorf("expected 21, got %d", count)
+	}
+}
+
+func TestPublish_RecoversPanics(t *testing.T) {
+	bus := NewBus()
+	delivered := false
+	Subscribe(bus, func(Connected) { panic("boom") })
+	Subscribe(bus, func(Connected) { delivered = true })
+
+	Publish(bus, Connected{})
+	if !delivered {
+		t.Error("expected later subscribers to run after a panic")
+	}
+}
+
+func TestPublish_NilBus(t *testing.T) {
+	Publish[Connected](nil, Connected{})
+}
+
+func TestBus_Reset(t *testing.T) {
+	bus := NewBus()
+	called := false
+	Subscribe(bus, func(Connected) { called = true })
+	bus.Reset()
+
+	Publish(bus, Connected{})
+	if called {
+		t.Error("expected Reset to remove subscriptions")
+	}
+}
@@ -26,9 +26,18 @@ type Metrics struct {
 	MessagesReceivedTotal  *prometheus.CounterVec
 	MessagesSentTotal      *prometheus.CounterVec
 	CommandsProcessedTotal *prometheus.CounterVec
+	CommandsDeniedTotal    *prometheus.CounterVec
+
+	// Feature metrics
+	CounterValue         *prometheus.GaugeVec
+	GoldMintedTotal      *prometheus.CounterVec
+	GoldTransferredTotal prometheus.Counter
+	GoldSupply           prometheus.Gauge
+	GoldHolders          prometheus.Gauge
 
 	// App metrics
-	Info *prometheus.GaugeVec
+	Info               *prometheus.GaugeVec
+	ConfigReloadsTotal *prometheus.CounterVec
 }
 
 // New creates and registers all metrics on a new registry.
@@ -109,6 +118,51 @@ func New() *Metrics {
 		[]string{"platform", "command", "admin"},
 	)
 
+	m.CommandsDeniedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dwarfbot_commands_denied_total",
+			Help: "Total commands refused by the access control list, by platform and command name.",
+		},
+		[]string{"platform", "command"},
+	)
+
+	m.CounterValue = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "dwarfbot_counter_value",
+			Help: "Current value of each named chat counter.",
+		},
+		[]string{"name"},
+	)
+
+	m.GoldMintedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dwarfbot_gold_minted_total",
+			Help: "Total loyalty gold created, by reason (chat, presence or grant).",
+		},
+		[]string{"reason"},
+	)
+
+	m.GoldTransferredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "dwarfbot_gold_transferred_total",
+			Help: "Total loyalty gold moved between users.",
+		},
+	)
+
+	m.GoldSupply = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dwarfbot_gold_supply",
+			Help: "Loyalty gold held across every balance.",
+		},
+	)
+
+	m.GoldHolders = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "dwarfbot_gold_holders",
+			Help: "Number of users holding any loyalty gold.",
+		},
+	)
+
 	m.Info = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "dwarfbot_info",
@@ -117,6 +171,14 @@ func New() *Metrics {
 		[]string{"version", "go_version"},
 	)
 
+	m.ConfigReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "dwarfbot_config_reloads_total",
+			Help: "Total hot configuration reloads by result (success or failure).",
+		},
+		[]string{"result"},
+	)
+
 	reg.MustRegister(
 		m.PlatformConnected,
 		m.PlatformConnectionAttemptsTotal,
@@ -127,7 +189,14 @@ func New() *Metrics {
 		m.MessagesReceivedTotal,
 		m.MessagesSentTotal,
 		m.CommandsProcessedTotal,
+		m.CommandsDeniedTotal,
+		m.CounterValue,
+		m.GoldMintedTotal,
+		m.GoldTransferredTotal,
+		m.GoldSupply,
+		m.GoldHolders,
 		m.Info,
+		m.ConfigReloadsTotal,
 		collectors.NewGoCollector(),
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 	)
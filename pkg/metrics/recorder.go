@@ -2,7 +2,8 @@ package metrics
 
 import "time"
 
-// Recorder implements PlatformMetrics using Prometheus metrics.
+// Recorder implements PlatformMetrics, CounterMetrics and GoldMetrics using
+// Prometheus metrics.
 type Recorder struct {
 	metrics *Metrics
 }
@@ -40,3 +41,32 @@ func (r *Recorder) RecordMessageSent(platform, result string) {
 func (r *Recorder) RecordCommandProcessed(platform, command, admin string) {
 	r.metrics.CommandsProcessedTotal.WithLabelValues(platform, command, admin).Inc()
 }
+
+func (r *Recorder) RecordCommandDenied(platform, command string) {
+	r.metrics.CommandsDeniedTotal.WithLabelValues(platform, command).Inc()
+}
+
+func (r *Recorder) SetCounterValue(name string, value int) {
+	r.metrics.CounterValue.WithLabelValues(name).Set(float64(value))
+}
+
+func (r *Recorder) DeleteCounterValue(name string) {
+	r.metrics.CounterValue.DeleteLabelValues(name)
+}
+
+func (r *Recorder) RecordGoldMinted(reason string, amount int) {
+	r.metrics.GoldMintedTotal.WithLabelValues(reason).Add(float64(amount))
+}
+
+func (r *Recorder) RecordGoldTransferred(amount int) {
+	r.metrics.GoldTransferredTotal.Add(float64(amount))
+}
+
+func (r *Recorder) SetGoldSupply(total, holders int) {
+	r.metrics.GoldSupply.Set(float64(total))
+	r.metrics.GoldHolders.Set(float64(holders))
+}
+
+func (r *Recorder) RecordConfigReload(result string) {
+	r.metrics.ConfigReloadsTotal.WithLabelValues(result).Inc()
+}
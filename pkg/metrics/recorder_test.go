@@ -115,3 +115,69 @@ func TestRecorder_RecordCommandProcessed(t *testing.T) {
 		t.Errorf("expected 1 shutdown, got %f", v)
 	}
 }
+
+func TestRecorder_RecordCommandDenied(t *testing.T) {
+	m := New()
+	r := NewRecorder(m)
+
+	r.RecordCommandDenied("twitch", "shutdown")
+	r.RecordCommandDenied("twitch", "shutdown")
+
+	if v := testutil.ToFloat64(m.CommandsDeniedTotal.WithLabelValues("twitch", "shutdown")); v != 2 {
+		t.Errorf("expected 2 denied commands, got %f", v)
+	}
+}
+
+func TestRecorder_CounterValue(t *testing.T) {
+	m := New()
+	r := NewRecorder(m)
+
+	r.SetCounterValue("deaths", 12)
+	if v := testutil.ToFloat64(m.CounterValue.WithLabelValues("deaths")); v != 12 {
+		t.Errorf("expected deaths=12, got %f", v)
+	}
+
+	r.DeleteCounterValue("deaths")
+	if n := testutil.CollectAndCount(m.CounterValue); n != 0 {
+		t.Errorf("expected deleted counter to disappear, got %d series", n)
+	}
+}
+
+func TestRecorder_RecordConfigReload(t *testing.T) {
+	m := New()
+	r := NewRecorder(m)
+
+	r.RecordConfigReload("success")
+	r.RecordConfigReload("failure")
+	r.RecordConfigReload("success")
+
+	if v := testutil.ToFloat64(m.ConfigReloadsTotal.WithLabelValues("success")); v != 2 {
+		t.Errorf("expected 2 successful reloads, got %f", v)
+	}
+	if v := testutil.ToFloat64(m.ConfigReloadsTotal.WithLabelValues("failure")); v != 1 {
+		t.Errorf("expected 1 failed reload, got %f", v)
+	}
+}
+
+func TestRecorder_Gold(t *testing.T) {
+	m := New()
+	r := NewRecorder(m)
+
+	r.RecordGoldMinted("chat", 3)
+	r.RecordGoldMinted("chat", 2)
+	r.RecordGoldTransferred(4)
+	r.SetGoldSupply(105, 7)
+
+	if v := testutil.ToFloat64(m.GoldMintedTotal.WithLabelValues("chat")); v != 5 {
+		t.Errorf("expected 5 gold minted for chat, got %f", v)
+	}
+	if v := testutil.ToFloat64(m.GoldTransferredTotal); v != 4 {
+		t.Errorf("expected 4 gold transferred, got %f", v)
+	}
+	if v := testutil.ToFloat64(m.GoldSupply); v != 105 {
+		t.Errorf("expected supply 105, got %f", v)
+	}
+	if v := testutil.ToFloat64(m.GoldHolders); v != 7 {
+		t.Errorf("expected 7 holders, got %f", v)
+	}
+}
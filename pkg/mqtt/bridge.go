@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"dwarfbot/pkg/events"
 	"errors"
 	"fmt"
 	"log"
@@ -12,6 +13,12 @@ import (
 
 type PostFunc func(channelID, msg string) error
 
+// publishTimeout bounds how long Publish waits for the broker.
+const publishTimeout = 5 * time.Second
+
+// eventComponent is the component name in the bridge's events.
+const eventComponent = "mqtt"
+
 type BridgeStatus struct {
 	Enabled     bool
 	Connected   bool
@@ -60,6 +67,20 @@ func NewBridgeWithFactory(cfg Config, postFunc PostFunc, metrics *BridgeMetrics,
 	}
 }
 
+// setConnectedLocked records a connection state change and publishes it
+// on the event bus. Must be called with b.mu held.
+func (b *Bridge) setConnectedLocked(connected bool, reason string) {
+	b.connected = connected
+	if b.metrics != nil {
+		b.metrics.SetConnected(connected)
+	}
+	if connected {
+		events.Publish(events.Default, events.Connected{Component: eventComponent})
+	} else {
+		events.Publish(events.Default, events.Disconnected{Component: eventComponent, Reason: reason})
+	}
+}
+
 func (b *Bridge) Start() error {
 	b.mu.Lock()
 	b.stopCh = make(chan struct{})
@@ -67,6 +88,7 @@ func (b *Bridge) Start() error {
 	if b.enabled && b.metrics != nil {
 		b.metrics.SetEnabled(true)
 	}
+	events.Publish(events.Default, events.Enabled{Component: eventComponent, Enabled: b.enabled})
 	b.mu.Unlock()
 
 	if err := b.connect(); err != nil {
@@ -95,10 +117,7 @@ func (b *Bridge) Stop() {
 	}
 
 	b.mu.Lock()
-	b.connected = false
-	if b.metrics != nil {
-		b.metrics.SetConnected(false)
-	}
+	b.setConnectedLocked(false, "shutdown")
 	b.mu.Unlock()
 
 	log.Println("MQTT bridge stopped")
@@ -119,10 +138,7 @@ func (b *Bridge) connect() error {
 	}
 
 	b.mu.Lock()
-	b.connected = true
-	if b.metrics != nil {
-		b.metrics.SetConnected(true)
-	}
+	b.setConnectedLocked(true, "")
 	b.mu.Unlock()
 
 	if err := b.subscribe(); err != nil {
@@ -140,8 +156,12 @@ func (b *Bridge) subscribe() error {
 		return fmt.Errorf("no MQTT client")
 	}
 
+	b.mu.Lock()
+	topics := append([]string(nil), b.config.Topics...)
+	b.mu.Unlock()
+
 	var errs []error
-	for _, topic := range b.config.Topics {
+	for _, topic := range topics {
 		token := client.Subscribe(topic, 0, b.messageHandler)
 		token.Wait()
 		if err := token.Error(); err != nil {
@@ -175,10 +195,7 @@ func (b *Bridge) onConnectionLost(_ pahomqtt.Client, err error) {
 	log.Printf("MQTT bridge: connection lost: %v", err)
 
 	b.mu.Lock()
-	b.connected = false
-	if b.metrics != nil {
-		b.metrics.SetConnected(false)
-	}
+	b.setConnectedLocked(false, "connection lost")
 	stopped := b.stopped
 	b.mu.Unlock()
 
@@ -190,10 +207,7 @@ func (b *Bridge) onConnectionLost(_ pahomqtt.Client, err error) {
 
 func (b *Bridge) onConnect(_ pahomqtt.Client) {
 	b.mu.Lock()
-	b.connected = true
-	if b.metrics != nil {
-		b.metrics.SetConnected(true)
-	}
+	b.setConnectedLocked(true, "")
 	b.mu.Unlock()
 
 	if err := b.subscribe(); err != nil {
@@ -332,6 +346,7 @@ func (b *Bridge) Enable() {
 	if b.metrics != nil {
 		b.metrics.SetEnabled(true)
 	}
+	events.Publish(events.Default, events.Enabled{Component: eventComponent, Enabled: true})
 }
 
 func (b *Bridge) Disable() {
@@ -341,6 +356,85 @@ func (b *Bridge) Disable() {
 	if b.metrics != nil {
 		b.metrics.SetEnabled(false)
 	}
+	events.Publish(events.Default, events.Enabled{Component: eventComponent, Enabled: false})
+}
+
+// SetTopics replaces the subscribed topic filters. When connected, removed
+// topics are unsubscribed and added ones subscribed immediately;
+// otherwise the new list is used on the next connect. It returns what
+// changed.
+func (b *Bridge) SetTopics(topics []string) (added, removed []string, err error) {
+	b.mu.Lock()
+	old := b.config.Topics
+	b.config.Topics = append([]string(nil), topics...)
+	client := b.client
+	connected := b.connected
+	b.mu.Unlock()
+
+	for _, t := range topics {
+		if !containsTopic(old, t) {
+			added = append(added, t)
+		}
+	}
+	for _, t := range old {
+		if !containsTopic(topics, t) {
+			removed = append(removed, t)
+		}
+	}
+	if client == nil || !connected {
+		return added, removed, nil
+	}
+
+	var errs []error
+	if len(removed) > 0 {
+		token := client.Unsubscribe(removed...)
+		token.Wait()
+		if err := token.Error(); err != nil {
+			errs = append(errs, fmt.Errorf("unsubscribe: %w", err))
+		}
+	}
+	for _, topic := range added {
+		token := client.Subscribe(topic, 0, b.messageHandler)
+		token.Wait()
+		if err := token.Error(); err != nil {
+			errs = append(errs, fmt.Errorf("topic %s: %w", topic, err))
+		} else {
+			log.Printf("MQTT bridge: subscribed to %s", topic)
+		}
+	}
+	return added, removed, errors.Join(errs...)
+}
+
+func containsTopic(topics []string, topic string) bool {
+	for _, t := range topics {
+		if t == topic {
+			return true
+		}
+	}
+	return false
+}
+
+// Publish sends payload to topic at QoS 0. It fails when the bridge is
+// disabled or not connected rather than queueing.
+func (b *Bridge) Publish(topic, payload string) error {
+	b.mu.Lock()
+	enabled, connected, client := b.enabled, b.connected, b.client
+	b.mu.Unlock()
+	if !enabled {
+		return errors.New("MQTT bridge is disabled")
+	}
+	if client == nil || !connected {
+		return errors.New("MQTT bridge is not connected")
+	}
+
+	token := client.Publish(topic, 0, false, payload)
+	if !token.WaitTimeout(publishTimeout) {
+		return fmt.Errorf("publish to %s timed out", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("publish to %s: %w", topic, err)
+	}
+	return nil
 }
 
 func (b *Bridge) Status() BridgeStatus {
@@ -1,6 +1,7 @@
 package mqtt
 
 import (
+	"dwarfbot/pkg/events"
 	"fmt"
 	"strings"
 	"sync"
@@ -1104,3 +1105,137 @@ func TestNewBridgeWithFactory_UsesCustomFactory(t *testing.T) {
 		t.Error("expected custom factory to be set")
 	}
 }
+
+type statusEvent struct {
+	component string
+	state     string
+}
+
+// recordBridgeEvents collects the bridge's state events from the default
+// bus until the test ends.
+func recordBridgeEvents(t *testing.T) func() []statusEvent {
+	t.Helper()
+	var (
+		mu  sync.Mutex
+		got []statusEvent
+	)
+	record := func(component, state string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, statusEvent{component, state})
+	}
+	unsubs := []func(){
+		events.Subscribe(events.Default, func(e events.Connected) { record(e.Component, "connected") }),
+		events.Subscribe(events.Default, func(e events.Disconnected) { record(e.Component, "disconnected: "+e.Reason) }),
+		events.Subscribe(events.Default, func(e events.Enabled) { record(e.Component, fmt.Sprintf("enabled=%v", e.Enabled)) }),
+	}
+	t.Cleanup(func() {
+		for _, u := range unsubs {
+			u()
+		}
+	})
+	return func() []statusEvent {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]statusEvent(nil), got...)
+	}
+}
+
+func TestBridge_PublishesEvents(t *testing.T) {
+	client := newMockClient(nil)
+	collector := &messageCollector{}
+	b, _ := newTestBridge(t, client, collector)
+	getEvents := recordBridgeEvents(t)
+
+	_ = b.Start()
+	b.Disable()
+	b.onConnectionLost(nil, fmt.Errorf("network error"))
+	b.Stop()
+
+	want := []statusEvent{
+		{"mqtt", "enabled=true"},
+		{"mqtt", "connected"},
+		{"mqtt", "enabled=false"},
+		{"mqtt", "disconnected: connection lost"},
+		{"mqtt", "disconnected: shutdown"},
+	}
+	got := getEvents()
+	if len(got) < len(want) {
+		t.Fatalf("expected at least %d events, got %v", len(want), got)
+	}
+	for i, w := range want {
+		if got[i] != w {
+			t.Errorf("event %d: expected %v, got %v", i, w, got[i])
+		}
+	}
+}
+
+func TestBridge_SetTopics_Resubscribes(t *testing.T) {
+	client := newMockClient(nil)
+	collector := &messageCollector{}
+	b, _ := newTestBridge(t, client, collector)
+
+	_ = b.Start()
+	defer b.Stop()
+
+	added, removed, err := b.SetTopics([]string{"ai/#", "garage/#"})
+	if err != nil {
+		t.Fatalf("SetTopics: %v", err)
+	}
+	if len(added) != 1 || added[0] != "garage/#" || len(removed) != 1 || removed[0] != "home/#" {
+		t.Errorf("unexpected diff: added=%v removed=%v", added, removed)
+	}
+	if unsub := client.getUnsubscribed(); len(unsub) != 1 || unsub[0] != "home/#" {
+		t.Errorf("expected home/# unsubscribed, got %v", unsub)
+	}
+	subs := client.getSubscriptions()
+	if subs[len(subs)-1] != "garage/#" {
+		t.Errorf("expected garage/# subscribed, got %v", subs)
+	}
+	if topics := b.Status().Topics; len(topics) != 2 || topics[1] != "garage/#" {
+		t.Errorf("expected status to show new topics, got %v", topics)
+	}
+}
+
+func TestBridge_SetTopics_NotConnected(t *testing.T) {
+	client := newMockClient(nil)
+	collector := &messageCollector{}
+	b, _ := newTestBridge(t, client, collector)
+
+	if _, _, err := b.SetTopics([]string{"garage/#"}); err != nil {
+		t.Fatalf("SetTopics: %v", err)
+	}
+	if len(client.getSubscriptions()) != 0 || len(client.getUnsubscribed()) != 0 {
+		t.Error("expected no client calls before connecting")
+	}
+
+	_ = b.Start()
+	defer b.Stop()
+	if subs := client.getSubscriptions(); len(subs) != 1 || subs[0] != "garage/#" {
+		t.Errorf("expected new topics used on connect, got %v", subs)
+	}
+}
+
+func TestBridge_Publish(t *testing.T) {
+	client := newMockClient(nil)
+	collector := &messageCollector{}
+	b, _ := newTestBridge(t, client, collector)
+
+	if err := b.Publish("home/light", "on"); err == nil {
+		t.Error("expected publish before connecting to fail")
+	}
+
+	_ = b.Start()
+	defer b.Stop()
+	if err := b.Publish("home/light", "on"); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if pub := client.getPublished(); len(pub) != 1 || pub[0] != "home/light on" {
+		t.Errorf("unexpected published messages %v", pub)
+	}
+
+	b.Disable()
+	if err := b.Publish("home/light", "off"); err == nil {
+		t.Error("expected publish while disabled to fail")
+	}
+}
@@ -8,6 +8,8 @@ type MQTTClient interface {
 	Connect() pahomqtt.Token
 	Disconnect(quiesce uint)
 	Subscribe(topic string, qos byte, callback pahomqtt.MessageHandler) pahomqtt.Token
+	Unsubscribe(topics ...string) pahomqtt.Token
+	Publish(topic string, qos byte, retained bool, payload interface{}) pahomqtt.Token
 	IsConnected() bool
 }
 
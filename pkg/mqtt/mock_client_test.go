@@ -23,6 +23,8 @@ type mockClient struct {
 	connectErr      error
 	subscribeErr    error
 	subscriptions   []string
+	unsubscribed    []string
+	published       []string
 	disconnectCalls int
 }
 
@@ -53,6 +55,36 @@ func (c *mockClient) Subscribe(topic string, qos byte, callback pahomqtt.Message
 	return &mockToken{err: c.subscribeErr}
 }
 
+func (c *mockClient) Unsubscribe(topics ...string) pahomqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.unsubscribed = append(c.unsubscribed, topics...)
+	return &mockToken{}
+}
+
+func (c *mockClient) Publish(topic string, qos byte, retained bool, payload interface{}) pahomqtt.Token {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, fmt.Sprintf("%s %v", topic, payload))
+	return &mockToken{}
+}
+
+func (c *mockClient) getPublished() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, len(c.published))
+	copy(out, c.published)
+	return out
+}
+
+func (c *mockClient) getUnsubscribed() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]string, len(c.unsubscribed))
+	copy(out, c.unsubscribed)
+	return out
+}
+
 func (c *mockClient) IsConnected() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
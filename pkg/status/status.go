@@ -0,0 +1,160 @@
+// Package status tracks process uptime and the connection state of each
+// bot component (chat platforms, the MQTT bridge) so it can be reported
+// in chat. A Tracker follows state changes on the event bus; readers take
+// a Snapshot.
+package status
+
+import (
+	"dwarfbot/pkg/events"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Version returns the module version from the build info, or "dev" when
+// it is unavailable.
+func Version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// Component is the state of one tracked component.
+type Component struct {
+	// Name of the component, e.g. "twitch".
+	Name string
+
+	// Connected reports whether the component is currently connected.
+	Connected bool
+
+	// Disabled is set for components that can be switched off at runtime
+	// (the MQTT bridge) and currently are.
+	Disabled bool
+
+	// Since is when Connected last changed. Zero means the component has
+	// not reported yet.
+	Since time.Time
+
+	// Reason is why the component last disconnected.
+	Reason string
+}
+
+// Report is a point-in-time view of the whole bot.
+type Report struct {
+	Version    string
+	Started    time.Time
+	Now        time.Time
+	Components []Component
+}
+
+// Uptime is how long the process has been running.
+func (r Report) Uptime() time.Duration {
+	return r.Now.Sub(r.Started)
+}
+
+// Tracker collects component state. It is safe for concurrent use.
+type Tracker struct {
+	mu         sync.Mutex
+	version    string
+	started    time.Time
+	components map[string]*Component
+
+	// order keeps components in registration order for stable output.
+	order   []string
+	nowFunc func() time.Time
+}
+
+// New returns a Tracker for a process started at started.
+func New(version string, started time.Time) *Tracker {
+	return &Tracker{
+		version:    version,
+		started:    started,
+		components: map[string]*Component{},
+		nowFunc:    time.Now,
+	}
+}
+
+// component returns the named component, creating it if needed. Must be
+// called with t.mu held.
+func (t *Tracker) component(name string) *Component {
+	c, ok := t.components[name]
+	if !ok {
+		c = &Component{Name: name}
+		t.components[name] = c
+		t.order = append(t.order, name)
+	}
+	return c
+}
+
+// Register adds a component so it is listed before it first reports.
+func (t *Tracker) Register(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.component(name)
+}
+
+// Subscribe keeps the tracker up to date from connection events on bus.
+// The returned function stops tracking.
+func (t *Tracker) Subscribe(bus *events.Bus) (unsubscribe func()) {
+	unsubs := []func(){
+		events.Subscribe(bus, func(e events.Connected) { t.ReportConnected(e.Component) }),
+		events.Subscribe(bus, func(e events.Disconnected) { t.ReportDisconnected(e.Component, e.Reason) }),
+		events.Subscribe(bus, func(e events.Enabled) { t.ReportEnabled(e.Component, e.Enabled) }),
+	}
+	return func() {
+		for _, u := range unsubs {
+			u()
+		}
+	}
+}
+
+// ReportConnected marks a component as connected. Repeated reports keep
+// the original connection time.
+func (t *Tracker) ReportConnected(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.component(name)
+	if c.Connected && !c.Since.IsZero() {
+		return
+	}
+	c.Connected = true
+	c.Since = t.nowFunc()
+	c.Reason = ""
+}
+
+// ReportDisconnected marks a component as disconnected.
+func (t *Tracker) ReportDisconnected(name, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.component(name)
+	if c.Connected || c.Since.IsZero() {
+		c.Since = t.nowFunc()
+	}
+	c.Connected = false
+	c.Reason = reason
+}
+
+// ReportEnabled records whether a component is switched on.
+func (t *Tracker) ReportEnabled(name string, enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.component(name).Disabled = !enabled
+}
+
+// Snapshot returns the current state of every component.
+func (t *Tracker) Snapshot() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := Report{
+		Version:    t.version,
+		Started:    t.started,
+		Now:        t.nowFunc(),
+		Components: make([]Component, 0, len(t.order)),
+	}
+	for _, name := range t.order {
+		r.Components = append(r.Components, *t.components[name])
+	}
+	return r
+}
@@ -0,0 +1,128 @@
+package status
+
+import (
+	"dwarfbot/pkg/events"
+	"testing"
+	"time"
+)
+
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tr := New("v1.2.3", now)
+	tr.nowFunc = func() time.Time { return now }
+	return tr, &now
+}
+
+func TestTracker_Uptime(t *testing.T) {
+	tr, now := newTestTracker()
+	*now = now.Add(90 * time.Minute)
+
+	r := tr.Snapshot()
+	if r.Version != "v1.2.3" {
+		t.Errorf("expected version v1.2.3, got %q", r.Version)
+	}
+	if r.Uptime() != 90*time.Minute {
+		t.Errorf("expected uptime 90m, got %v", r.Uptime())
+	}
+}
+
+func TestTracker_RegisterKeepsOrder(t *testing.T) {
+	tr, _ := newTestTracker()
+	tr.Register("twitch")
+	tr.Register("discord")
+	tr.ReportConnected("mqtt")
+	tr.Register("twitch")
+
+	r := tr.Snapshot()
+	if len(r.Components) != 3 {
+		t.Fatalf("expected 3 components, got %d", len(r.Components))
+	}
+	for i, want := range []string{"twitch", "discord", "mqtt"} {
+		if r.Components[i].Name != want {
+			t.Errorf("component %d: expected %q, got %q", i, want, r.Components[i].Name)
+		}
+	}
+	if !r.Components[0].Since.IsZero() {
+		t.Error("expected registered-only component to have zero Since")
+	}
+}
+
+func TestTracker_ConnectDisconnect(t *testing.T) {
+	tr, now := newTestTracker()
+	start := *now
+
+	tr.ReportConnected("twitch")
+	*now = now.Add(time.Minute)
+	tr.ReportConnected("twitch")
+
+	c := tr.Snapshot().Components[0]
+	if !c.Connected || !c.Since.Equal(start) {
+		t.Errorf("expected repeated connect to keep original time, got %+v", c)
+	}
+
+	*now = now.Add(time.Minute)
+	tr.ReportDisconnected("twitch", "read error")
+	c = tr.Snapshot().Components[0]
+	if c.Connected || c.Reason != "read error" || !c.Since.Equal(*now) {
+		t.Errorf("unexpected state after disconnect: %+v", c)
+	}
+
+	disconnectedAt := *now
+	*now = now.Add(time.Minute)
+	tr.ReportDisconnected("twitch", "retry failed")
+	c = tr.Snapshot().Components[0]
+	if !c.Since.Equal(disconnectedAt) || c.Reason != "retry failed" {
+		t.Errorf("expected repeated disconnect to keep time and update reason, got %+v", c)
+	}
+
+	tr.ReportConnected("twitch")
+	c = tr.Snapshot().Components[0]
+	if !c.Connected || c.Reason != "" || !c.Since.Equal(*now) {
+		t.Errorf("unexpected state after reconnect: %+v", c)
+	}
+}
+
+func TestTracker_ReportEnabled(t *testing.T) {
+	tr, _ := newTestTracker()
+	tr.ReportEnabled("mqtt", false)
+	if c := tr.Snapshot().Components[0]; !c.Disabled {
+		t.Errorf("expected mqtt disabled, got %+v", c)
+	}
+	tr.ReportEnabled("mqtt", true)
+	if c := tr.Snapshot().Components[0]; c.Disabled {
+		t.Errorf("expected mqtt enabled, got %+v", c)
+	}
+}
+
+func TestTracker_Subscribe(t *testing.T) {
+	tr, _ := newTestTracker()
+	bus := events.NewBus()
+	unsubscribe := tr.Subscribe(bus)
+
+	events.Publish(bus, events.Connected{Component: "twitch"})
+	events.Publish(bus, events.Enabled{Component: "mqtt", Enabled: false})
+	events.Publish(bus, events.Disconnected{Component: "twitch", Reason: "read error"})
+
+	r := tr.Snapshot()
+	if len(r.Components) != 2 {
+		t.Fatalf("expected 2 components, got %+v", r.Components)
+	}
+	if c := r.Components[0]; c.Name != "twitch" || c.Connected || c.Reason != "read error" {
+		t.Errorf("unexpected twitch state %+v", c)
+	}
+	if c := r.Components[1]; c.Name != "mqtt" || !c.Disabled {
+		t.Errorf("unexpected mqtt state %+v", c)
+	}
+
+	unsubscribe()
+	events.Publish(bus, events.Connected{Component: "twitch"})
+	if c := tr.Snapshot().Components[0]; c.Connected {
+		t.Error("expected no updates after unsubscribe")
+	}
+}
+
+func TestVersion_NotEmpty(t *testing.T) {
+	if Version() == "" {
+		t.Error("expected a version string")
+	}
+}
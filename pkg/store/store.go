@@ -0,0 +1,279 @@
+// Package store is a small persistent key/value store for bot state such
+// as quotes and counters. Values are JSON-encoded and grouped into named
+// buckets. The whole store lives in memory and is written atomically to a
+// single JSON file after every committed update, which is plenty for the
+// few thousand records a chat bot accumulates.
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// sequenceBucket holds the per-bucket counters used by NextSequence.
+const sequenceBucket = "_sequences"
+
+// ErrNotFound is returned by Get when the key does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Store is a bucketed key/value store. The zero value is not usable;
+// create one with Open.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	data map[string]map[string]json.RawMessage
+}
+
+// Open loads the store from path, creating it on first write if it does
+// not exist. An empty path gives an in-memory store that is never
+// persisted.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: map[string]map[string]json.RawMessage{},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading store %s: %w", path, err)
+	}
+	if len(raw) == 0 {
+		return s, nil
+	}
+	if err := json.Unmarshal(raw, &s.data); err != nil {
+		return nil, fmt.Errorf("parsing store %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Path returns the backing file, or "" for an in-memory store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Tx is a view of the store inside View or Update. Writes made through a
+// Tx are only applied if the Update function returns nil.
+type Tx struct {
+	s        *Store
+	writable bool
+
+	// pending holds writes; a nil value marks a deletion.
+	pending map[string]map[string]json.RawMessage
+}
+
+// View runs fn with a read-only transaction.
+func (s *Store) View(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(&Tx{s: s})
+}
+
+// Update runs fn with a read-write transaction. If fn returns an error
+// nothing is written; otherwise all writes are applied and persisted
+// together.
+func (s *Store) Update(fn func(tx *Tx) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Tx{s: s, writable: true, pending: map[string]map[string]json.RawMessage{}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.pending) == 0 {
+		return nil
+	}
+
+	// Keep the previous state so a failed write leaves memory and disk
+	// consistent.
+	backup := make(map[string]map[string]json.RawMessage, len(tx.pending))
+	for bucket := range tx.pending {
+		backup[bucket] = s.data[bucket]
+	}
+	for bucket, writes := range tx.pending {
+		b := make(map[string]json.RawMessage, len(s.data[bucket])+len(writes))
+		for k, v := range s.data[bucket] {
+			b[k] = v
+		}
+		for k, v := range writes {
+			if v == nil {
+				delete(b, k)
+			} else {
+				b[k] = v
+			}
+		}
+		s.data[bucket] = b
+	}
+
+	if err := s.persist(); err != nil {
+		for bucket, b := range backup {
+			if b == nil {
+				delete(s.data, bucket)
+			} else {
+				s.data[bucket] = b
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	raw, err := json.Marshal(s.data)
+	if err != nil {
+		return fmt.Errorf("encoding store: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing store: %w", err)
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(raw); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("writing store: %w", err)
+	}
+	if err := os.Rename(tmpName, s.path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("writing store: %w", err)
+	}
+	return nil
+}
+
+func (tx *Tx) raw(bucket, key string) (json.RawMessage, bool) {
+	if writes, ok := tx.pending[bucket]; ok {
+		if v, ok := writes[key]; ok {
+			return v, v != nil
+		}
+	}
+	v, ok := tx.s.data[bucket][key]
+	return v, ok
+}
+
+// Get decodes the value at bucket/key into v. It returns ErrNotFound if
+// the key does not exist.
+func (tx *Tx) Get(bucket, key string, v any) error {
+	raw, ok := tx.raw(bucket, key)
+	if !ok {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("decoding %s/%s: %w", bucket, key, err)
+	}
+	return nil
+}
+
+// Put stores v at bucket/key.
+func (tx *Tx) Put(bucket, key string, v any) error {
+	if !tx.writable {
+		return errors.New("store: Put in read-only transaction")
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s/%s: %w", bucket, key, err)
+	}
+	tx.write(bucket, key, raw)
+	return nil
+}
+
+// Delete removes bucket/key. Deleting a missing key is not an error.
+func (tx *Tx) Delete(bucket, key string) error {
+	if !tx.writable {
+		return errors.New("store: Delete in read-only transaction")
+	}
+	tx.write(bucket, key, nil)
+	return nil
+}
+
+func (tx *Tx) write(bucket, key string, raw json.RawMessage) {
+	if tx.pending[bucket] == nil {
+		tx.pending[bucket] = map[string]json.RawMessage{}
+	}
+	tx.pending[bucket][key] = raw
+}
+
+// Keys returns the keys in bucket in sorted order.
+func (tx *Tx) Keys(bucket string) []string {
+	seen := map[string]bool{}
+	for k := range tx.s.data[bucket] {
+		seen[k] = true
+	}
+	for k, v := range tx.pending[bucket] {
+		seen[k] = v != nil
+	}
+	keys := make([]string, 0, len(seen))
+	for k, present := range seen {
+		if present {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NextSequence returns the next integer ID for bucket, starting at 1.
+func (tx *Tx) NextSequence(bucket string) (int, error) {
+	var n int
+	if err := tx.Get(sequenceBucket, bucket, &n); err != nil && !errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	n++
+	if err := tx.Put(sequenceBucket, bucket, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Get is a convenience wrapper for a single read.
+func (s *Store) Get(bucket, key string, v any) error {
+	return s.View(func(tx *Tx) error { return tx.Get(bucket, key, v) })
+}
+
+// Put is a convenience wrapper for a single write.
+func (s *Store) Put(bucket, key string, v any) error {
+	return s.Update(func(tx *Tx) error { return tx.Put(bucket, key, v) })
+}
+
+// Delete is a convenience wrapper for a single delete.
+func (s *Store) Delete(bucket, key string) error {
+	return s.Update(func(tx *Tx) error { return tx.Delete(bucket, key) })
+}
+
+// Keys is a convenience wrapper returning the sorted keys of bucket.
+func (s *Store) Keys(bucket string) []string {
+	var keys []string
+	_ = s.View(func(tx *Tx) error {
+		keys = tx.Keys(bucket)
+		return nil
+	})
+	return keys
+}
+
+// IntKey formats an integer ID as a store key.
+func IntKey(id int) string {
+	return strconv.Itoa(id)
+}
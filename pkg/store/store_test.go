@@ -0,0 +1,182 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type record struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestOpen_MissingFileIsEmpty(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if keys := s.Keys("anything"); len(keys) != 0 {
+		t.Errorf("expected empty store, got keys %v", keys)
+	}
+}
+
+func TestOpen_CorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil {
+		t.Error("expected error for corrupt store file")
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	s, _ := Open("")
+
+	if err := s.Put("things", "a", record{Name: "axe", Count: 2}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	var got record
+	if err := s.Get("things", "a", &got); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Name != "axe" || got.Count != 2 {
+		t.Errorf("unexpected record %+v", got)
+	}
+
+	if err := s.Delete("things", "a"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Get("things", "a", &got); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound after delete, got %v", err)
+	}
+}
+
+func TestPersistsAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("things", "a", record{Name: "pick"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	var got record
+	if err := reopened.Get("things", "a", &got); err != nil {
+		t.Fatalf("Get after reopen: %v", err)
+	}
+	if got.Name != "pick" {
+		t.Errorf("expected persisted record, got %+v", got)
+	}
+
+	matches, _ := filepath.Glob(path + ".tmp-*")
+	if len(matches) != 0 {
+		t.Errorf("expected temp files to be cleaned up, found %v", matches)
+	}
+}
+
+func TestUpdate_ErrorDiscardsWrites(t *testing.T) {
+	s, _ := Open("")
+	_ = s.Put("b", "keep", 1)
+
+	boom := errors.New("boom")
+	err := s.Update(func(tx *Tx) error {
+		_ = tx.Put("b", "new", 2)
+		_ = tx.Delete("b", "keep")
+		return boom
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected update error, got %v", err)
+	}
+
+	keys := s.Keys("b")
+	if len(keys) != 1 || keys[0] != "keep" {
+		t.Errorf("expected failed update to leave store unchanged, got %v", keys)
+	}
+}
+
+func TestUpdate_ReadsOwnWrites(t *testing.T) {
+	s, _ := Open("")
+	_ = s.Put("b", "x", 1)
+
+	err := s.Update(func(tx *Tx) error {
+		var n int
+		if err := tx.Get("b", "x", &n); err != nil {
+			return err
+		}
+		if err := tx.Put("b", "x", n+1); err != nil {
+			return err
+		}
+		if err := tx.Get("b", "x", &n); err != nil || n != 2 {
+			t.Errorf("expected to read own write 2, got %d (%v)", n, err)
+		}
+		_ = tx.Delete("b", "x")
+		if err := tx.Get("b", "x", &n); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected own delete to hide key, got %v", err)
+		}
+		if keys := tx.Keys("b"); len(keys) != 0 {
+			t.Errorf("expected deleted key to be hidden from Keys, got %v", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestView_IsReadOnly(t *testing.T) {
+	s, _ := Open("")
+	err := s.View(func(tx *Tx) error { return tx.Put("b", "x", 1) })
+	if err == nil {
+		t.Error("expected Put in View to fail")
+	}
+}
+
+func TestNextSequence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	s, _ := Open(path)
+
+	for want := 1; want <= 3; want++ {
+		var got int
+		err := s.Update(func(tx *Tx) error {
+			var err error
+			got, err = tx.NextSequence("quotes")
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected sequence %d, got %d", want, got)
+		}
+	}
+
+	reopened, _ := Open(path)
+	var next int
+	_ = reopened.Update(func(tx *Tx) error {
+		next, _ = tx.NextSequence("quotes")
+		return nil
+	})
+	if next != 4 {
+		t.Errorf("expected sequence to persist, got %d", next)
+	}
+}
+
+func TestUpdate_PersistFailureRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	s, _ := Open(filepath.Join(dir, "missing-dir", "state.json"))
+
+	if err := s.Put("b", "x", 1); err == nil {
+		t.Fatal("expected write into missing directory to fail")
+	}
+	if keys := s.Keys("b"); len(keys) != 0 {
+		t.Errorf("expected in-memory state to roll back, got %v", keys)
+	}
+}